@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"formlander/internal"
+	"formlander/internal/accounts"
 	"formlander/internal/database"
 	"formlander/internal/license"
 
@@ -61,6 +63,11 @@ func main() {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "reset-2fa":
+		if err := runResetTwoFactor(m, os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 	case "upgrade-to-pro":
 		runUpgradeToPro(m)
 	case "update-license-key":
@@ -187,6 +194,57 @@ func runAdminPasswordChange(m *matcha.Matcha) error {
 	return nil
 }
 
+// runResetTwoFactor turns off two-factor authentication for a user who lost
+// their authenticator and recovery codes. On the host it asks for the email
+// and re-runs itself inside the container with --direct, which changes the
+// database there.
+func runResetTwoFactor(m *matcha.Matcha, args []string) error {
+	if len(args) == 2 && args[0] == "--direct" {
+		return resetTwoFactorDirect(args[1])
+	}
+
+	email := ""
+	if len(args) == 1 {
+		email = args[0]
+	} else {
+		fmt.Print("Enter the email of the user to reset: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read email: %w", err)
+		}
+		email = line
+	}
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return fmt.Errorf("email cannot be empty")
+	}
+
+	fmt.Println("Resetting two-factor authentication...")
+	if err := m.Exec("/usr/local/bin/formlander", "reset-2fa", "--direct", email); err != nil {
+		return fmt.Errorf("failed to reset two-factor authentication: %w", err)
+	}
+
+	fmt.Println("Two-factor authentication reset. The user can sign in with their password and set it up again.")
+	return nil
+}
+
+func resetTwoFactorDirect(email string) error {
+	app, err := internal.NewApp()
+	if err != nil {
+		return err
+	}
+	if err := internal.RunMigrations(app); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	if err := accounts.ResetTOTP(app.Logger, app.GetDB(), email); err != nil {
+		if errors.Is(err, accounts.ErrUserNotFound) {
+			return fmt.Errorf("no user with email %s", email)
+		}
+		return err
+	}
+	return nil
+}
+
 const proImage = "karloscodes/formlander-pro:latest"
 
 func runUpgradeToPro(m *matcha.Matcha) {
@@ -310,6 +368,7 @@ func printUsage() {
 	fmt.Println("  reload                      Reload containers")
 	fmt.Println("  restore-db                  Restore database from backup")
 	fmt.Println("  change-admin-password       Change admin password")
+	fmt.Println("  reset-2fa [email]           Turn off two-factor authentication for a user")
 	fmt.Println("  check                       Check server security")
 	fmt.Println("")
 	fmt.Println("Pro Commands:")
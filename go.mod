@@ -4,6 +4,7 @@ go 1.25.10
 
 require (
 	github.com/gofiber/fiber/v2 v2.52.13
+	github.com/gofiber/template/html/v2 v2.1.3
 	github.com/karloscodes/cartridge v0.15.0
 	github.com/karloscodes/matcha v0.12.18
 	github.com/spf13/viper v1.21.0
@@ -24,7 +25,6 @@ require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.4.0 // indirect
 	github.com/gofiber/template v1.8.3 // indirect
-	github.com/gofiber/template/html/v2 v2.1.3 // indirect
 	github.com/gofiber/utils v1.2.0 // indirect
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
@@ -10,6 +10,7 @@ import (
 	"gorm.io/gorm"
 	"log/slog"
 
+	"formlander/internal/audit"
 	"formlander/internal/pkg/dbtxn"
 )
 
@@ -21,8 +22,35 @@ var (
 	ErrMissingFields      = errors.New("required fields are missing")
 	ErrDuplicateEmail     = errors.New("email is already in use")
 	ErrInvalidEmail       = errors.New("email is not valid")
+	ErrUserDeactivated    = errors.New("account is deactivated")
+	ErrSelfManagement     = errors.New("you can't change your own account here")
+	ErrLastActiveUser     = errors.New("at least one active user must remain")
+	ErrLastOwner          = errors.New("at least one active owner must remain")
+	ErrOwnerRequired      = errors.New("only an owner can manage owners")
+	ErrInvalidRole        = errors.New("role is not valid")
 )
 
+// Roles, from most to least privileged. Owners and admins see every form and
+// manage users, mailers and captcha; only owners manage other owners. Editors
+// and viewers see the forms they are granted, and only editors change them or
+// work their submissions.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Roles lists every role for select inputs, most privileged first.
+var Roles = []string{RoleOwner, RoleAdmin, RoleEditor, RoleViewer}
+
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3, RoleOwner: 4}
+
+// ValidRole reports whether role is one of Roles.
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
 // Default admin credentials created on first boot of an empty install. They
 // work in every environment and remain valid until the operator changes them.
 const (
@@ -41,20 +69,48 @@ const timingEqualizerHash = "$2a$10$Q1pg.L2uyfJ2QportzoH9.UPdkdy2skSFqtGaRfOXpO0
 // it's accurate, so it can never go stale.
 func IsDefaultAdminActive(db *gorm.DB) bool {
 	user, err := FindByEmail(db, DefaultAdminEmail)
-	if err != nil {
+	if err != nil || !user.Active() {
 		return false
 	}
 	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(DefaultAdminPassword)) == nil
 }
 
-// User represents the single admin user for the MVP.
+// User is an admin account. The first one is seeded on an empty install;
+// everyone else joins through an Invitation.
 type User struct {
-	ID           uint       `gorm:"primaryKey"`
-	Email        string     `gorm:"size:255;uniqueIndex;not null"`
-	PasswordHash string     `gorm:"size:255;not null"`
-	LastLoginAt  *time.Time `gorm:"index"` // nil = first login required, force password change
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID            uint       `gorm:"primaryKey"`
+	Email         string     `gorm:"size:255;uniqueIndex;not null"`
+	PasswordHash  string     `gorm:"size:255;not null"`
+	LastLoginAt   *time.Time `gorm:"index"` // nil = first login required, force password change
+	DeactivatedAt *time.Time `gorm:"index"` // set = can't sign in, existing sessions are rejected
+	Role          string     `gorm:"size:16;not null;default:owner"`
+	TOTPSecret    string     `gorm:"column:totp_secret;size:64"`               // base32; set during enrollment, before it's enabled
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at"`                   // set = a second factor is required at sign-in
+	TOTPLastStep  int64      `gorm:"column:totp_last_step;not null;default:0"` // last time step used, so codes can't be replayed
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Active reports whether the user may sign in.
+func (u *User) Active() bool {
+	return u.DeactivatedAt == nil
+}
+
+// HasRole reports whether the user's role is role or a more privileged one.
+func (u *User) HasRole(role string) bool {
+	return roleRanks[u.Role] >= roleRanks[role]
+}
+
+// SeesAllForms reports whether the user has access to every form without
+// grants.
+func (u *User) SeesAllForms() bool {
+	return u.HasRole(RoleAdmin)
+}
+
+// CanEdit reports whether the user's role allows changing forms and working
+// submissions. Editors still need a grant for the form.
+func (u *User) CanEdit() bool {
+	return u.HasRole(RoleEditor)
 }
 
 // Settings stores global application configuration as key-value pairs.
@@ -97,6 +153,13 @@ func FindByID(db *gorm.DB, id uint) (*User, error) {
 	return &user, nil
 }
 
+// ListUsers returns all admin users ordered by email.
+func ListUsers(db *gorm.DB) ([]User, error) {
+	var users []User
+	err := db.Order("email ASC").Find(&users).Error
+	return users, err
+}
+
 // Authenticate verifies credentials and updates last login timestamp
 func Authenticate(logger *slog.Logger, db *gorm.DB, email, password string) (*AuthenticationResult, error) {
 	email = strings.ToLower(strings.TrimSpace(email))
@@ -121,6 +184,9 @@ func Authenticate(logger *slog.Logger, db *gorm.DB, email, password string) (*Au
 	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || err == gorm.ErrRecordNotFound {
 		return nil, ErrInvalidCredentials
 	}
+	if !user.Active() {
+		return nil, ErrUserDeactivated
+	}
 
 	// Check if this is the first login
 	isFirstLogin := user.LastLoginAt == nil
@@ -185,8 +251,21 @@ func ChangeEmail(logger *slog.Logger, db *gorm.DB, currentEmail, newEmail, curre
 
 	user.Email = newEmail
 
+	// Changing the email signs the user out everywhere; the caller starts
+	// a new session for the browser that made the change.
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Save(&user).Error
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user.ID, 0, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionUserEmailChanged,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Details:    audit.Changes(map[string]any{"email": currentEmail}, map[string]any{"email": newEmail}),
+		})
 	}); err != nil {
 		logger.Error("failed to update email", slog.Any("error", err), slog.String("email", currentEmail))
 		return err
@@ -225,8 +304,15 @@ func ChangePassword(logger *slog.Logger, db *gorm.DB, email, currentPassword, ne
 	// Update user password
 	user.PasswordHash = string(hash)
 
+	// Like an email change, a new password signs the user out everywhere.
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Save(&user).Error
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user.ID, 0, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{Action: audit.ActionUserPasswordChanged, TargetType: "user", TargetID: user.ID})
 	}); err != nil {
 		logger.Error("failed to update password", slog.Any("error", err), slog.String("email", email))
 		return err
@@ -0,0 +1,307 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// InvitationTTL is how long an invite link stays valid.
+const InvitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvitationInvalid  = errors.New("invitation link is not valid")
+	ErrInvitationExpired  = errors.New("invitation link has expired")
+	ErrInvitationAccepted = errors.New("invitation was already accepted")
+	ErrMailerRequired     = errors.New("choose a mailer profile to send the invitation")
+)
+
+// Invitation lets someone create their own account by following an emailed
+// link. The link is signed and names its expiry; the row decides whether it
+// is still usable, so revoking (deleting) or resending an invitation
+// invalidates links sent earlier.
+type Invitation struct {
+	ID              uint                        `gorm:"primaryKey"`
+	Email           string                      `gorm:"size:255;index;not null"`
+	Role            string                      `gorm:"size:16;not null;default:viewer"` // Given to the user on accept
+	InvitedByID     *uint                       `gorm:"index"`
+	InvitedBy       *User                       `gorm:"constraint:OnDelete:SET NULL"`
+	MailerProfileID *uint                       `gorm:"index"`
+	MailerProfile   *integrations.MailerProfile `gorm:"constraint:OnDelete:SET NULL"`
+	ExpiresAt       time.Time                   `gorm:"not null"`
+	SentAt          *time.Time
+	SendError       string     `gorm:"type:text"`
+	AcceptedAt      *time.Time `gorm:"index"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// Status describes where the invitation stands for the users page.
+func (i *Invitation) Status() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return "Accepted"
+	case !time.Now().Before(i.ExpiresAt):
+		return "Expired"
+	case i.SendError != "":
+		return "Not sent"
+	case i.SentAt == nil:
+		return "Sending"
+	}
+	return "Sent"
+}
+
+// InviteUser records an invitation for email, to be sent through a mailer
+// profile by the invitation job. Inviting an address with a pending
+// invitation renews that invitation instead of adding another. Only owners
+// invite owners.
+func InviteUser(logger *slog.Logger, db *gorm.DB, invitedByID uint, email, role string, mailerProfileID uint, now time.Time) (*Invitation, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if role == RoleOwner {
+		if err := requireOwner(db, invitedByID); err != nil {
+			return nil, err
+		}
+	}
+	if mailerProfileID == 0 {
+		return nil, ErrMailerRequired
+	}
+	if _, err := integrations.GetMailerProfileByID(db, mailerProfileID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMailerRequired
+		}
+		return nil, err
+	}
+	taken, err := emailTaken(db, email, 0)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrDuplicateEmail
+	}
+
+	invitation := &Invitation{Email: email}
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		err := tx.Where("email = ? AND accepted_at IS NULL", email).First(invitation).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		invitation.InvitedByID = &invitedByID
+		invitation.Role = role
+		invitation.MailerProfileID = &mailerProfileID
+		invitation.ExpiresAt = now.UTC().Add(InvitationTTL).Truncate(time.Second)
+		invitation.SentAt = nil
+		invitation.SendError = ""
+		if err := tx.Save(invitation).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionInvitationCreated,
+			TargetType: "invitation",
+			TargetID:   invitation.ID,
+			Details:    map[string]any{"email": email, "role": role},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// ResendInvitation renews a pending invitation's expiry and queues its email
+// again. Links from earlier emails stop working.
+func ResendInvitation(logger *slog.Logger, db *gorm.DB, id uint, now time.Time) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var invitation Invitation
+		if err := tx.First(&invitation, id).Error; err != nil {
+			return err
+		}
+		if invitation.AcceptedAt != nil {
+			return ErrInvitationAccepted
+		}
+		if err := tx.Model(&invitation).Updates(map[string]any{
+			"expires_at": now.UTC().Add(InvitationTTL).Truncate(time.Second),
+			"sent_at":    nil,
+			"send_error": "",
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionInvitationResent,
+			TargetType: "invitation",
+			TargetID:   id,
+			Details:    map[string]any{"email": invitation.Email},
+		})
+	})
+}
+
+// RevokeInvitation deletes a pending invitation, invalidating its link.
+func RevokeInvitation(logger *slog.Logger, db *gorm.DB, id uint) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var invitation Invitation
+		if err := tx.Where("id = ? AND accepted_at IS NULL", id).First(&invitation).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&invitation).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionInvitationRevoked,
+			TargetType: "invitation",
+			TargetID:   id,
+			Details:    map[string]any{"email": invitation.Email},
+		})
+	})
+}
+
+// PendingInvitations lists invitations that haven't been accepted, newest
+// first, including expired ones so they can be resent.
+func PendingInvitations(db *gorm.DB) ([]Invitation, error) {
+	var invitations []Invitation
+	err := db.Preload("InvitedBy").Preload("MailerProfile").
+		Where("accepted_at IS NULL").
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// UnsentInvitations returns live invitations whose email hasn't gone out.
+func UnsentInvitations(db *gorm.DB, now time.Time, limit int) ([]Invitation, error) {
+	var invitations []Invitation
+	err := db.Preload("MailerProfile").
+		Where("accepted_at IS NULL AND sent_at IS NULL AND send_error = '' AND expires_at > ?", now.UTC()).
+		Order("id ASC").
+		Limit(limit).
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// RecordInvitationSent stores the outcome of sending an invitation. A send
+// error stops further attempts until the invitation is resent.
+func RecordInvitationSent(logger *slog.Logger, db *gorm.DB, id uint, sentAt time.Time, sendErr string) error {
+	values := map[string]any{"send_error": sendErr}
+	if sendErr == "" {
+		values["sent_at"] = sentAt.UTC()
+	}
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		return tx.Model(&Invitation{}).Where("id = ?", id).Updates(values).Error
+	})
+}
+
+// InvitationToken signs the link token of an invitation.
+func InvitationToken(secret string, invitation *Invitation) string {
+	payload := fmt.Sprintf("%d.%d", invitation.ID, invitation.ExpiresAt.Unix())
+	return payload + "." + invitationSignature(secret, payload)
+}
+
+// FindInvitation verifies an invite link token and returns its pending
+// invitation.
+func FindInvitation(db *gorm.DB, secret, token string, now time.Time) (*Invitation, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvitationInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(invitationSignature(secret, payload))) {
+		return nil, ErrInvitationInvalid
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvitationInvalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvitationInvalid
+	}
+
+	var invitation Invitation
+	if err := db.First(&invitation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, err
+	}
+	if invitation.ExpiresAt.Unix() != expires {
+		return nil, ErrInvitationInvalid
+	}
+	if invitation.AcceptedAt != nil {
+		return nil, ErrInvitationAccepted
+	}
+	if !now.Before(invitation.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+	return &invitation, nil
+}
+
+// AcceptInvitation creates the invited user with the chosen password and
+// marks the invitation used. The new user is the actor of its audit entry.
+func AcceptInvitation(logger *slog.Logger, db *gorm.DB, secret, token, password string, now time.Time) (*User, error) {
+	if len(password) < 8 {
+		return nil, ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("failed to generate password hash", slog.Any("error", err))
+		return nil, err
+	}
+
+	var user *User
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		invitation, err := FindInvitation(tx, secret, token, now)
+		if err != nil {
+			return err
+		}
+		taken, err := emailTaken(tx, invitation.Email, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateEmail
+		}
+
+		signedIn := now.UTC()
+		user = &User{Email: invitation.Email, PasswordHash: string(hash), Role: invitation.Role, LastLoginAt: &signedIn}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(invitation).Update("accepted_at", signedIn).Error; err != nil {
+			return err
+		}
+		actor, _ := audit.ActorFrom(tx.Statement.Context)
+		actor.UserID, actor.Email = user.ID, user.Email
+		return audit.Record(tx.WithContext(audit.WithActor(tx.Statement.Context, actor)), audit.Event{
+			Action:     audit.ActionInvitationAccepted,
+			TargetType: "invitation",
+			TargetID:   invitation.ID,
+			Details:    map[string]any{"email": user.Email, "role": user.Role},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func invitationSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("invite:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
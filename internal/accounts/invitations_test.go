@@ -0,0 +1,303 @@
+package accounts_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/audit"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestInvitations(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	const secret = "secret"
+
+	admin := createTestUser(t, db, "admin@example.com", "password123", true)
+	profile := &integrations.MailerProfile{Name: "Team", Provider: "smtp", DefaultFromEmail: "team@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+
+	t.Run("validates the address and mailer", func(t *testing.T) {
+		_, err := accounts.InviteUser(logger, db, admin.ID, "not-an-email", accounts.RoleEditor, profile.ID, now)
+		assert.ErrorIs(t, err, accounts.ErrInvalidEmail)
+		_, err = accounts.InviteUser(logger, db, admin.ID, "new@example.com", accounts.RoleEditor, 0, now)
+		assert.ErrorIs(t, err, accounts.ErrMailerRequired)
+		_, err = accounts.InviteUser(logger, db, admin.ID, "Admin@Example.com", accounts.RoleEditor, profile.ID, now)
+		assert.ErrorIs(t, err, accounts.ErrDuplicateEmail)
+	})
+
+	invitation, err := accounts.InviteUser(logger, db, admin.ID, " New@Example.com ", accounts.RoleEditor, profile.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", invitation.Email)
+	assert.Equal(t, now.Add(accounts.InvitationTTL), invitation.ExpiresAt)
+
+	unsent, err := accounts.UnsentInvitations(db, now, 10)
+	require.NoError(t, err)
+	require.Len(t, unsent, 1)
+	require.NotNil(t, unsent[0].MailerProfile)
+	require.NoError(t, accounts.RecordInvitationSent(logger, db, invitation.ID, now, ""))
+	unsent, err = accounts.UnsentInvitations(db, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, unsent)
+
+	t.Run("inviting again renews the pending invitation", func(t *testing.T) {
+		again, err := accounts.InviteUser(logger, db, admin.ID, "new@example.com", accounts.RoleEditor, profile.ID, now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, invitation.ID, again.ID)
+		assert.Nil(t, again.SentAt)
+
+		old := accounts.InvitationToken(secret, invitation)
+		_, err = accounts.FindInvitation(db, secret, old, now)
+		assert.ErrorIs(t, err, accounts.ErrInvitationInvalid, "links from earlier emails stop working")
+		invitation = again
+	})
+
+	token := accounts.InvitationToken(secret, invitation)
+
+	t.Run("rejects tampered, foreign and expired tokens", func(t *testing.T) {
+		_, err := accounts.FindInvitation(db, "other-secret", token, now)
+		assert.ErrorIs(t, err, accounts.ErrInvitationInvalid)
+		_, err = accounts.FindInvitation(db, secret, token+"x", now)
+		assert.ErrorIs(t, err, accounts.ErrInvitationInvalid)
+		_, err = accounts.FindInvitation(db, secret, token, invitation.ExpiresAt)
+		assert.ErrorIs(t, err, accounts.ErrInvitationExpired)
+	})
+
+	t.Run("accepting creates the user once", func(t *testing.T) {
+		_, err := accounts.AcceptInvitation(logger, db, secret, token, "short", now)
+		assert.ErrorIs(t, err, accounts.ErrWeakPassword)
+
+		user, err := accounts.AcceptInvitation(logger, db, secret, token, "password123", now)
+		require.NoError(t, err)
+		assert.Equal(t, "new@example.com", user.Email)
+
+		result, err := accounts.Authenticate(logger, db, "new@example.com", "password123")
+		require.NoError(t, err)
+		assert.False(t, result.IsFirstLogin)
+
+		_, err = accounts.AcceptInvitation(logger, db, secret, token, "password123", now)
+		assert.ErrorIs(t, err, accounts.ErrInvitationAccepted)
+
+		pending, err := accounts.PendingInvitations(db)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("revoking deletes a pending invitation", func(t *testing.T) {
+		other, err := accounts.InviteUser(logger, db, admin.ID, "other@example.com", accounts.RoleEditor, profile.ID, now)
+		require.NoError(t, err)
+		require.NoError(t, accounts.RevokeInvitation(logger, db, other.ID))
+		_, err = accounts.FindInvitation(db, secret, accounts.InvitationToken(secret, other), now)
+		assert.ErrorIs(t, err, accounts.ErrInvitationInvalid)
+		assert.ErrorIs(t, accounts.RevokeInvitation(logger, db, invitation.ID), gorm.ErrRecordNotFound, "accepted invitations stay")
+	})
+}
+
+func TestManageUsers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	admin := createTestUser(t, db, "admin@example.com", "password123", true)
+	teammate := createTestUser(t, db, "teammate@example.com", "password123", true)
+
+	t.Run("updates email and password on someone's behalf", func(t *testing.T) {
+		assert.ErrorIs(t, accounts.UpdateUserEmail(logger, db, admin.ID, teammate.ID, "admin@example.com"), accounts.ErrDuplicateEmail)
+		require.NoError(t, accounts.UpdateUserEmail(logger, db, admin.ID, teammate.ID, "Mate@Example.com"))
+		assert.ErrorIs(t, accounts.SetPassword(logger, db, admin.ID, teammate.ID, "short"), accounts.ErrWeakPassword)
+		require.NoError(t, accounts.SetPassword(logger, db, admin.ID, teammate.ID, "new-password"))
+
+		_, err := accounts.Authenticate(logger, db, "mate@example.com", "new-password")
+		require.NoError(t, err)
+	})
+
+	t.Run("deactivated users can't sign in", func(t *testing.T) {
+		assert.ErrorIs(t, accounts.SetUserActive(logger, db, admin.ID, admin.ID, false), accounts.ErrSelfManagement)
+		require.NoError(t, accounts.SetUserActive(logger, db, admin.ID, teammate.ID, false))
+
+		_, err := accounts.Authenticate(logger, db, "mate@example.com", "new-password")
+		assert.ErrorIs(t, err, accounts.ErrUserDeactivated)
+		_, err = accounts.Authenticate(logger, db, "mate@example.com", "wrong-password")
+		assert.ErrorIs(t, err, accounts.ErrInvalidCredentials, "deactivation isn't revealed without the password")
+
+		require.NoError(t, accounts.SetUserActive(logger, db, admin.ID, teammate.ID, true))
+		_, err = accounts.Authenticate(logger, db, "mate@example.com", "new-password")
+		require.NoError(t, err)
+	})
+
+	t.Run("the last active user stays", func(t *testing.T) {
+		require.NoError(t, accounts.SetUserActive(logger, db, admin.ID, teammate.ID, false))
+		assert.ErrorIs(t, accounts.SetUserActive(logger, db, teammate.ID, admin.ID, false), accounts.ErrLastActiveUser)
+		assert.ErrorIs(t, accounts.DeleteUser(logger, db, teammate.ID, admin.ID, nil), accounts.ErrLastActiveUser)
+	})
+
+	t.Run("removes a user and detaches their records", func(t *testing.T) {
+		var detached uint
+		detach := func(tx *gorm.DB, userID uint) error {
+			detached = userID
+			return nil
+		}
+		assert.ErrorIs(t, accounts.DeleteUser(logger, db, admin.ID, admin.ID, detach), accounts.ErrSelfManagement)
+		require.NoError(t, accounts.DeleteUser(logger, db, admin.ID, teammate.ID, detach))
+		assert.Equal(t, teammate.ID, detached)
+		_, err := accounts.FindByID(db, teammate.ID)
+		assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+	})
+}
+
+func TestUserChangesAreAudited(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	admin := createTestUser(t, db, "admin@example.com", "password123", true)
+	teammate := createTestUser(t, db, "teammate@example.com", "password123", true)
+	profile := &integrations.MailerProfile{Name: "Team", Provider: "smtp", DefaultFromEmail: "team@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+	asAdmin := db.WithContext(audit.WithActor(context.Background(), audit.Actor{UserID: admin.ID, Email: admin.Email}))
+
+	lastEntry := func() audit.Entry {
+		t.Helper()
+		var entry audit.Entry
+		require.NoError(t, db.Last(&entry).Error)
+		return entry
+	}
+
+	t.Run("invitations", func(t *testing.T) {
+		invitation, err := accounts.InviteUser(logger, asAdmin, admin.ID, "new@example.com", accounts.RoleEditor, profile.ID, now)
+		require.NoError(t, err)
+		entry := lastEntry()
+		assert.Equal(t, audit.ActionInvitationCreated, entry.Action)
+		assert.Equal(t, invitation.ID, entry.TargetID)
+		assert.Equal(t, "admin@example.com", entry.ActorEmail)
+		assert.Equal(t, []string{"email: new@example.com", "role: editor"}, entry.DetailLines())
+
+		require.NoError(t, accounts.ResendInvitation(logger, asAdmin, invitation.ID, now))
+		assert.Equal(t, audit.ActionInvitationResent, lastEntry().Action)
+
+		user, err := accounts.AcceptInvitation(logger, db, "secret", accounts.InvitationToken("secret", invitation), "password123", now)
+		require.NoError(t, err)
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionInvitationAccepted, entry.Action)
+		require.NotNil(t, entry.ActorID)
+		assert.Equal(t, user.ID, *entry.ActorID, "the new user accepted it")
+
+		other, err := accounts.InviteUser(logger, asAdmin, admin.ID, "other@example.com", accounts.RoleViewer, profile.ID, now)
+		require.NoError(t, err)
+		require.NoError(t, accounts.RevokeInvitation(logger, asAdmin, other.ID))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionInvitationRevoked, entry.Action)
+		assert.Equal(t, other.ID, entry.TargetID)
+		assert.Contains(t, entry.DetailLines(), "email: other@example.com")
+	})
+
+	t.Run("changes to someone else's account", func(t *testing.T) {
+		require.NoError(t, accounts.UpdateUserEmail(logger, asAdmin, admin.ID, teammate.ID, "mate@example.com"))
+		entry := lastEntry()
+		assert.Equal(t, audit.ActionUserEmailChanged, entry.Action)
+		assert.Equal(t, teammate.ID, entry.TargetID)
+		assert.Equal(t, []string{"email: teammate@example.com → mate@example.com"}, entry.DetailLines())
+
+		require.NoError(t, accounts.SetPassword(logger, asAdmin, admin.ID, teammate.ID, "new-password"))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionUserPasswordSet, entry.Action)
+		assert.NotContains(t, entry.Details, "new-password")
+
+		require.NoError(t, accounts.SetUserRole(logger, asAdmin, admin.ID, teammate.ID, accounts.RoleViewer))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionUserRoleChanged, entry.Action)
+		assert.Contains(t, entry.DetailLines(), "role: owner → viewer")
+
+		require.NoError(t, accounts.SetUserActive(logger, asAdmin, admin.ID, teammate.ID, false))
+		assert.Equal(t, audit.ActionUserDeactivated, lastEntry().Action)
+		require.NoError(t, accounts.SetUserActive(logger, asAdmin, admin.ID, teammate.ID, true))
+		assert.Equal(t, audit.ActionUserReactivated, lastEntry().Action)
+
+		require.NoError(t, accounts.DeleteUser(logger, asAdmin, admin.ID, teammate.ID, nil))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionUserDeleted, entry.Action)
+		assert.Equal(t, teammate.ID, entry.TargetID)
+		assert.Contains(t, entry.DetailLines(), "email: mate@example.com", "the entry still says who it was")
+		assert.Equal(t, "admin@example.com", entry.ActorEmail)
+	})
+
+	t.Run("changes to one's own account", func(t *testing.T) {
+		require.NoError(t, accounts.ChangePassword(logger, asAdmin, "admin@example.com", "password123", "changed-password"))
+		entry := lastEntry()
+		assert.Equal(t, audit.ActionUserPasswordChanged, entry.Action)
+		assert.Equal(t, admin.ID, entry.TargetID)
+
+		require.NoError(t, accounts.ChangeEmail(logger, asAdmin, "admin@example.com", "boss@example.com", "changed-password"))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionUserEmailChanged, entry.Action)
+		assert.Equal(t, []string{"email: admin@example.com → boss@example.com"}, entry.DetailLines())
+	})
+
+	t.Run("rejected changes leave no entry", func(t *testing.T) {
+		before := lastEntry().ID
+		assert.ErrorIs(t, accounts.SetUserRole(logger, asAdmin, admin.ID, admin.ID, accounts.RoleViewer), accounts.ErrSelfManagement)
+		assert.ErrorIs(t, accounts.ChangePassword(logger, asAdmin, "boss@example.com", "wrong-password", "another-password"), accounts.ErrPasswordMismatch)
+		assert.Equal(t, before, lastEntry().ID)
+	})
+}
+
+func TestRoles(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	owner := createTestUser(t, db, "owner@example.com", "password123", true)
+	admin := createTestUser(t, db, "admin@example.com", "password123", true)
+	assert.Equal(t, accounts.RoleOwner, owner.Role, "existing and seeded users keep full access")
+
+	t.Run("ranks roles", func(t *testing.T) {
+		viewer := &accounts.User{Role: accounts.RoleViewer}
+		editor := &accounts.User{Role: accounts.RoleEditor}
+		assert.False(t, viewer.CanEdit())
+		assert.True(t, editor.CanEdit())
+		assert.False(t, editor.SeesAllForms())
+		assert.True(t, (&accounts.User{Role: accounts.RoleAdmin}).SeesAllForms())
+		assert.False(t, (&accounts.User{Role: "root"}).HasRole(accounts.RoleViewer))
+	})
+
+	require.ErrorIs(t, accounts.SetUserRole(logger, db, owner.ID, admin.ID, "root"), accounts.ErrInvalidRole)
+	require.NoError(t, accounts.SetUserRole(logger, db, owner.ID, admin.ID, accounts.RoleAdmin))
+
+	t.Run("only owners manage owners", func(t *testing.T) {
+		assert.ErrorIs(t, accounts.SetUserRole(logger, db, admin.ID, owner.ID, accounts.RoleViewer), accounts.ErrOwnerRequired)
+		assert.ErrorIs(t, accounts.SetUserActive(logger, db, admin.ID, owner.ID, false), accounts.ErrOwnerRequired)
+		assert.ErrorIs(t, accounts.SetPassword(logger, db, admin.ID, owner.ID, "taken-over"), accounts.ErrOwnerRequired)
+		assert.ErrorIs(t, accounts.DeleteUser(logger, db, admin.ID, owner.ID, nil), accounts.ErrOwnerRequired)
+
+		profile := &integrations.MailerProfile{Name: "Team", Provider: "smtp", DefaultFromEmail: "team@example.com"}
+		require.NoError(t, db.Create(profile).Error)
+		_, err := accounts.InviteUser(logger, db, admin.ID, "boss@example.com", accounts.RoleOwner, profile.ID, time.Now())
+		assert.ErrorIs(t, err, accounts.ErrOwnerRequired)
+		invitation, err := accounts.InviteUser(logger, db, admin.ID, "mate@example.com", accounts.RoleEditor, profile.ID, time.Now())
+		require.NoError(t, err)
+		user, err := accounts.AcceptInvitation(logger, db, "secret", accounts.InvitationToken("secret", invitation), "password123", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, accounts.RoleEditor, user.Role)
+	})
+
+	t.Run("the last active owner stays", func(t *testing.T) {
+		require.NoError(t, accounts.SetUserRole(logger, db, owner.ID, admin.ID, accounts.RoleOwner))
+		require.NoError(t, accounts.SetUserRole(logger, db, admin.ID, owner.ID, accounts.RoleAdmin))
+		assert.ErrorIs(t, accounts.SetUserRole(logger, db, owner.ID, admin.ID, accounts.RoleAdmin), accounts.ErrOwnerRequired)
+
+		editor, err := accounts.FindByEmail(db, "mate@example.com")
+		require.NoError(t, err)
+		require.NoError(t, accounts.SetUserRole(logger, db, admin.ID, editor.ID, accounts.RoleOwner))
+		require.NoError(t, accounts.SetUserActive(logger, db, admin.ID, editor.ID, false))
+		assert.ErrorIs(t, accounts.SetUserRole(logger, db, editor.ID, admin.ID, accounts.RoleAdmin), accounts.ErrLastOwner)
+		assert.ErrorIs(t, accounts.DeleteUser(logger, db, editor.ID, admin.ID, nil), accounts.ErrLastOwner)
+	})
+}
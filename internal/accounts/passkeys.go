@@ -0,0 +1,197 @@
+package accounts
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// PasskeyNameMaxLength bounds the label users give their passkeys.
+const PasskeyNameMaxLength = 64
+
+var (
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	ErrPasskeyExists   = errors.New("passkey is already registered")
+	ErrPasskeyCloned   = errors.New("passkey signature counter went backwards")
+)
+
+// Passkey is a WebAuthn credential a user signs in with instead of their
+// password and second factor. The ceremonies live in internal/auth; this is
+// what is kept between them.
+type Passkey struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"index;not null"`
+	Name         string `gorm:"size:64;not null"`
+	CredentialID string `gorm:"size:1400;uniqueIndex;not null"` // base64url
+	PublicKey    []byte `gorm:"not null"`                       // COSE_Key
+	SignCount    uint32 `gorm:"not null;default:0"`
+	LastUsedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// AddPasskey stores a credential registered by userID.
+func AddPasskey(logger *slog.Logger, db *gorm.DB, userID uint, name string, credentialID, publicKey []byte, signCount uint32) (*Passkey, error) {
+	passkey := &Passkey{
+		UserID:       userID,
+		Name:         passkeyName(name),
+		CredentialID: encodeCredentialID(credentialID),
+		PublicKey:    publicKey,
+		SignCount:    signCount,
+	}
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Passkey{}).Where("credential_id = ?", passkey.CredentialID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPasskeyExists
+		}
+		if err := tx.Create(passkey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionPasskeyAdded,
+			TargetType: "passkey",
+			TargetID:   passkey.ID,
+			Details:    map[string]any{"name": passkey.Name},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return passkey, nil
+}
+
+// ListPasskeys returns a user's passkeys, oldest first.
+func ListPasskeys(db *gorm.DB, userID uint) ([]Passkey, error) {
+	var passkeys []Passkey
+	err := db.Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&passkeys).Error
+	return passkeys, err
+}
+
+// PasskeyCredentialIDs lists the raw credential IDs of a user's passkeys, so
+// registration can keep an authenticator from enrolling twice.
+func PasskeyCredentialIDs(db *gorm.DB, userID uint) ([][]byte, error) {
+	var encoded []string
+	if err := db.Model(&Passkey{}).Where("user_id = ?", userID).Pluck("credential_id", &encoded).Error; err != nil {
+		return nil, err
+	}
+	ids := make([][]byte, 0, len(encoded))
+	for _, id := range encoded {
+		if raw, err := base64.RawURLEncoding.DecodeString(id); err == nil {
+			ids = append(ids, raw)
+		}
+	}
+	return ids, nil
+}
+
+// FindPasskey looks up a passkey by the credential ID an authenticator
+// presented.
+func FindPasskey(db *gorm.DB, credentialID []byte) (*Passkey, error) {
+	var passkey Passkey
+	if err := db.Where("credential_id = ?", encodeCredentialID(credentialID)).First(&passkey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// RenamePasskey changes the label of one of userID's passkeys.
+func RenamePasskey(logger *slog.Logger, db *gorm.DB, userID, id uint, name string) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		res := tx.Model(&Passkey{}).Where("id = ? AND user_id = ?", id, userID).Update("name", passkeyName(name))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrPasskeyNotFound
+		}
+		return nil
+	})
+}
+
+// RevokePasskey deletes one of userID's passkeys; it can't sign in again.
+func RevokePasskey(logger *slog.Logger, db *gorm.DB, userID, id uint) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var passkey Passkey
+		err := tx.Where("id = ? AND user_id = ?", id, userID).First(&passkey).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPasskeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&passkey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionPasskeyRevoked,
+			TargetType: "passkey",
+			TargetID:   id,
+			Details:    map[string]any{"name": passkey.Name},
+		})
+	})
+}
+
+// RecordPasskeyLogin stores the counter from a verified assertion and
+// returns the passkey's user if they may sign in. Authenticators that keep
+// a counter must increase it; one going backwards means the credential was
+// copied, and the sign-in is refused.
+func RecordPasskeyLogin(logger *slog.Logger, db *gorm.DB, passkeyID uint, signCount uint32, now time.Time) (*User, error) {
+	var user *User
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var passkey Passkey
+		if err := tx.First(&passkey, passkeyID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPasskeyNotFound
+			}
+			return err
+		}
+		if (signCount != 0 || passkey.SignCount != 0) && signCount <= passkey.SignCount {
+			return ErrPasskeyCloned
+		}
+
+		var err error
+		if user, err = FindByID(tx, passkey.UserID); err != nil {
+			return err
+		}
+		if !user.Active() {
+			return ErrUserDeactivated
+		}
+
+		used := now.UTC()
+		if err := tx.Model(&passkey).Updates(map[string]any{"sign_count": signCount, "last_used_at": used}).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).Update("last_login_at", used).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func passkeyName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "Passkey"
+	}
+	if runes := []rune(name); len(runes) > PasskeyNameMaxLength {
+		name = string(runes[:PasskeyNameMaxLength])
+	}
+	return name
+}
+
+func encodeCredentialID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
@@ -0,0 +1,90 @@
+package accounts_test
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasskeys(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	user := createTestUser(t, db, "admin@example.com", "password123", true)
+	other := createTestUser(t, db, "other@example.com", "password123", true)
+
+	passkey, err := accounts.AddPasskey(logger, db, user.ID, "  ", []byte("credential-1"), []byte{0xa0}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Passkey", passkey.Name, "blank names get a default")
+
+	t.Run("credential IDs are unique", func(t *testing.T) {
+		_, err := accounts.AddPasskey(logger, db, other.ID, "Laptop", []byte("credential-1"), []byte{0xa0}, 0)
+		assert.ErrorIs(t, err, accounts.ErrPasskeyExists)
+
+		ids, err := accounts.PasskeyCredentialIDs(db, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("credential-1")}, ids)
+
+		found, err := accounts.FindPasskey(db, []byte("credential-1"))
+		require.NoError(t, err)
+		assert.Equal(t, passkey.ID, found.ID)
+		_, err = accounts.FindPasskey(db, []byte("credential-2"))
+		assert.ErrorIs(t, err, accounts.ErrPasskeyNotFound)
+	})
+
+	t.Run("only the owner renames and revokes", func(t *testing.T) {
+		assert.ErrorIs(t, accounts.RenamePasskey(logger, db, other.ID, passkey.ID, "Mine now"), accounts.ErrPasskeyNotFound)
+		assert.ErrorIs(t, accounts.RevokePasskey(logger, db, other.ID, passkey.ID), accounts.ErrPasskeyNotFound)
+
+		require.NoError(t, accounts.RenamePasskey(logger, db, user.ID, passkey.ID, strings.Repeat("k", 100)))
+		list, err := accounts.ListPasskeys(db, user.ID)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Len(t, list[0].Name, accounts.PasskeyNameMaxLength)
+	})
+
+	t.Run("sign-in records the counter and refuses it going back", func(t *testing.T) {
+		signedIn, err := accounts.RecordPasskeyLogin(logger, db, passkey.ID, 5, now)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, signedIn.ID)
+
+		_, err = accounts.RecordPasskeyLogin(logger, db, passkey.ID, 5, now)
+		assert.ErrorIs(t, err, accounts.ErrPasskeyCloned)
+		_, err = accounts.RecordPasskeyLogin(logger, db, passkey.ID, 3, now)
+		assert.ErrorIs(t, err, accounts.ErrPasskeyCloned)
+
+		found, err := accounts.FindPasskey(db, []byte("credential-1"))
+		require.NoError(t, err)
+		assert.EqualValues(t, 5, found.SignCount)
+		require.NotNil(t, found.LastUsedAt)
+	})
+
+	t.Run("authenticators without a counter always send zero", func(t *testing.T) {
+		synced, err := accounts.AddPasskey(logger, db, user.ID, "Phone", []byte("credential-2"), []byte{0xa0}, 0)
+		require.NoError(t, err)
+		for range 2 {
+			_, err := accounts.RecordPasskeyLogin(logger, db, synced.ID, 0, now)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("deactivated users can't sign in", func(t *testing.T) {
+		require.NoError(t, accounts.SetUserActive(logger, db, other.ID, user.ID, false))
+		_, err := accounts.RecordPasskeyLogin(logger, db, passkey.ID, 9, now)
+		assert.ErrorIs(t, err, accounts.ErrUserDeactivated)
+	})
+
+	t.Run("revoked passkeys are gone", func(t *testing.T) {
+		require.NoError(t, accounts.RevokePasskey(logger, db, user.ID, passkey.ID))
+		_, err := accounts.FindPasskey(db, []byte("credential-1"))
+		assert.ErrorIs(t, err, accounts.ErrPasskeyNotFound)
+	})
+}
@@ -0,0 +1,260 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// PasswordResetTTL is how long an emailed reset link works.
+const PasswordResetTTL = time.Hour
+
+// systemMailerSetting names the Settings row holding the ID of the mailer
+// profile that sends account emails such as password resets.
+const systemMailerSetting = "system_mailer_profile_id"
+
+var (
+	ErrResetTokenInvalid = errors.New("password reset link is not valid or has expired")
+	ErrNoSystemMailer    = errors.New("no mailer profile is set for system emails")
+)
+
+// PasswordReset is a request to set a new password through an emailed link.
+// The link's token is signed from a random nonce with the session secret, so
+// the job runner can email it later while the table alone can't reset
+// anyone's password. A reset works once, and asking again replaces it.
+type PasswordReset struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"index;not null"`
+	User        *User     `gorm:"constraint:OnDelete:CASCADE"`
+	Nonce       string    `gorm:"size:64"`
+	TokenHash   string    `gorm:"size:64;uniqueIndex;not null"` // hex SHA-256 of the token
+	ExpiresAt   time.Time `gorm:"not null"`
+	RequestedIP string    `gorm:"size:64"`
+	SentAt      *time.Time
+	SendError   string `gorm:"type:text"`
+	UsedAt      *time.Time
+	CreatedAt   time.Time
+}
+
+// SystemMailerProfile returns the mailer profile for system emails.
+func SystemMailerProfile(db *gorm.DB) (*integrations.MailerProfile, error) {
+	value, err := GetSetting(db, systemMailerSetting)
+	if errors.Is(err, gorm.ErrRecordNotFound) || value == "" {
+		return nil, ErrNoSystemMailer
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, ErrNoSystemMailer
+	}
+	profile, err := integrations.GetMailerProfileByID(db, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The profile was deleted since it was chosen.
+		return nil, ErrNoSystemMailer
+	}
+	return profile, err
+}
+
+// SetSystemMailerProfile chooses the mailer profile for system emails; 0
+// turns them off.
+func SetSystemMailerProfile(logger *slog.Logger, db *gorm.DB, id uint) error {
+	value := ""
+	if id != 0 {
+		if _, err := integrations.GetMailerProfileByID(db, id); err != nil {
+			return err
+		}
+		value = strconv.FormatUint(uint64(id), 10)
+	}
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := saveSetting(tx, systemMailerSetting, value); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionSystemMailerChanged,
+			TargetType: "mailer_profile",
+			TargetID:   id,
+		})
+	})
+}
+
+// RequestPasswordReset starts a reset for the active user with email and
+// returns it with the token for the link, which the job runner emails.
+// Earlier unused resets of the user stop working. Every request is recorded
+// in the audit log, including those for addresses without an active
+// account, so both cases do the same work.
+func RequestPasswordReset(logger *slog.Logger, db *gorm.DB, secret, email, ip string, now time.Time) (*PasswordReset, string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	pending := &PasswordReset{Nonce: base64.RawURLEncoding.EncodeToString(nonce)}
+	token := PasswordResetToken(secret, pending)
+
+	var reset *PasswordReset
+	var refused error
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		reset, refused = nil, nil
+		event := audit.Event{Action: audit.ActionPasswordResetRequested, Details: map[string]any{"email": email}}
+
+		user, err := FindByEmail(tx, email)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			refused = err
+		case err != nil:
+			return err
+		case !user.Active():
+			refused = ErrUserDeactivated
+		}
+		if refused != nil {
+			event.Details["outcome"] = "no_active_account"
+			return audit.Record(tx, event)
+		}
+
+		reset = &PasswordReset{
+			UserID:      user.ID,
+			User:        user,
+			Nonce:       pending.Nonce,
+			TokenHash:   hashToken(token),
+			ExpiresAt:   now.UTC().Add(PasswordResetTTL),
+			RequestedIP: ip,
+		}
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&PasswordReset{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("User").Create(reset).Error; err != nil {
+			return err
+		}
+		event.TargetType = "user"
+		event.TargetID = user.ID
+		return audit.Record(tx, event)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if refused != nil {
+		return nil, "", refused
+	}
+	return reset, token, nil
+}
+
+// PasswordResetToken signs the link token of a reset.
+func PasswordResetToken(secret string, reset *PasswordReset) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("reset:" + reset.Nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// UnsentPasswordResets returns live resets whose email hasn't been sent or
+// attempted yet, oldest first.
+func UnsentPasswordResets(db *gorm.DB, now time.Time, limit int) ([]PasswordReset, error) {
+	var resets []PasswordReset
+	err := db.Preload("User").
+		Where("used_at IS NULL AND sent_at IS NULL AND send_error = '' AND expires_at > ?", now.UTC()).
+		Order("id ASC").
+		Limit(limit).
+		Find(&resets).Error
+	return resets, err
+}
+
+// RecordPasswordResetSent stores the outcome of emailing a reset link.
+func RecordPasswordResetSent(logger *slog.Logger, db *gorm.DB, id uint, sentAt time.Time, sendErr string) error {
+	values := map[string]any{"send_error": sendErr}
+	if sendErr == "" {
+		values["sent_at"] = sentAt.UTC()
+	}
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		return tx.Model(&PasswordReset{}).Where("id = ?", id).Updates(values).Error
+	})
+}
+
+// FindPasswordReset returns the usable reset for token, with its user.
+func FindPasswordReset(db *gorm.DB, token string, now time.Time) (*PasswordReset, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrResetTokenInvalid
+	}
+	var reset PasswordReset
+	err := db.Preload("User").Where("token_hash = ?", hashToken(token)).First(&reset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reset.UsedAt != nil || !now.Before(reset.ExpiresAt) || reset.User == nil || !reset.User.Active() {
+		return nil, ErrResetTokenInvalid
+	}
+	return &reset, nil
+}
+
+// ResetPassword sets a new password through a reset link and uses the link
+// up, along with any other reset of the user. Whoever knew the old password
+// is signed out.
+func ResetPassword(logger *slog.Logger, db *gorm.DB, token, password string, now time.Time) (*User, error) {
+	if len(password) < 8 {
+		return nil, ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("failed to generate password hash", slog.Any("error", err))
+		return nil, err
+	}
+
+	var user *User
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		reset, err := FindPasswordReset(tx, token, now)
+		if err != nil {
+			return err
+		}
+		// Claim the reset with a conditional update, so two submissions of
+		// one link can't both succeed.
+		res := tx.Model(&PasswordReset{}).Where("id = ? AND used_at IS NULL", reset.ID).Update("used_at", now.UTC())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrResetTokenInvalid
+		}
+		if err := tx.Where("user_id = ? AND used_at IS NULL", reset.UserID).Delete(&PasswordReset{}).Error; err != nil {
+			return err
+		}
+		user = reset.User
+		if err := tx.Model(user).Update("password_hash", string(hash)).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user.ID, 0, now); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionPasswordResetCompleted,
+			TargetType: "user",
+			TargetID:   user.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
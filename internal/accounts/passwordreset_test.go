@@ -0,0 +1,143 @@
+package accounts_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/audit"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordReset(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	user := createTestUser(t, db, "ada@example.com", "password123", true)
+
+	t.Run("only a hash of the token is stored", func(t *testing.T) {
+		reset, token, err := accounts.RequestPasswordReset(logger, db, "secret", "Ada@Example.com", "203.0.113.7", now)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, reset.UserID)
+		assert.Equal(t, now.Add(accounts.PasswordResetTTL), reset.ExpiresAt)
+		assert.NotEmpty(t, token)
+		assert.NotContains(t, reset.TokenHash, token)
+
+		found, err := accounts.FindPasswordReset(db, token, now)
+		require.NoError(t, err)
+		assert.Equal(t, reset.ID, found.ID)
+		assert.Equal(t, "ada@example.com", found.User.Email)
+	})
+
+	t.Run("unknown emails are reported to the caller only", func(t *testing.T) {
+		_, _, err := accounts.RequestPasswordReset(logger, db, "secret", "nobody@example.com", "", now)
+		assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+	})
+
+	t.Run("links expire", func(t *testing.T) {
+		_, token, err := accounts.RequestPasswordReset(logger, db, "secret", "ada@example.com", "", now)
+		require.NoError(t, err)
+		_, err = accounts.FindPasswordReset(db, token, now.Add(accounts.PasswordResetTTL))
+		assert.ErrorIs(t, err, accounts.ErrResetTokenInvalid)
+		_, err = accounts.ResetPassword(logger, db, token, "new-password", now.Add(accounts.PasswordResetTTL))
+		assert.ErrorIs(t, err, accounts.ErrResetTokenInvalid)
+	})
+
+	t.Run("a newer request replaces the older link", func(t *testing.T) {
+		_, older, err := accounts.RequestPasswordReset(logger, db, "secret", "ada@example.com", "", now)
+		require.NoError(t, err)
+		_, newer, err := accounts.RequestPasswordReset(logger, db, "secret", "ada@example.com", "", now)
+		require.NoError(t, err)
+		_, err = accounts.FindPasswordReset(db, older, now)
+		assert.ErrorIs(t, err, accounts.ErrResetTokenInvalid)
+		_, err = accounts.FindPasswordReset(db, newer, now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("a link sets the password once", func(t *testing.T) {
+		_, token, err := accounts.RequestPasswordReset(logger, db, "secret", "ada@example.com", "", now)
+		require.NoError(t, err)
+		_, sessionToken, err := accounts.StartSession(logger, db, user.ID, "password", "", "", now, time.Hour)
+		require.NoError(t, err)
+
+		_, err = accounts.ResetPassword(logger, db, token, "short", now)
+		assert.ErrorIs(t, err, accounts.ErrWeakPassword)
+
+		reset, err := accounts.ResetPassword(logger, db, token, "new-password", now)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, reset.ID)
+
+		_, err = accounts.Authenticate(logger, db, "ada@example.com", "new-password")
+		assert.NoError(t, err)
+		_, err = accounts.Authenticate(logger, db, "ada@example.com", "password123")
+		assert.ErrorIs(t, err, accounts.ErrInvalidCredentials)
+
+		_, err = accounts.ResetPassword(logger, db, token, "another-password", now)
+		assert.ErrorIs(t, err, accounts.ErrResetTokenInvalid)
+
+		_, err = accounts.ValidateSession(logger, db, user.ID, sessionToken, now, time.Hour)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid, "a reset signs the user out everywhere")
+	})
+
+	t.Run("requests and resets are audited", func(t *testing.T) {
+		_, token, err := accounts.RequestPasswordReset(logger, db, "secret", "ada@example.com", "", now)
+		require.NoError(t, err)
+		_, _, err = accounts.RequestPasswordReset(logger, db, "secret", "nobody@example.com", "", now)
+		require.ErrorIs(t, err, accounts.ErrUserNotFound)
+		_, err = accounts.ResetPassword(logger, db, token, "audited-password", now)
+		require.NoError(t, err)
+
+		var entries []audit.Entry
+		require.NoError(t, db.Where("action LIKE ?", "password_reset.%").Order("id DESC").Limit(3).Find(&entries).Error)
+		require.Len(t, entries, 3)
+		assert.Equal(t, audit.ActionPasswordResetCompleted, entries[0].Action)
+		assert.Equal(t, user.ID, entries[0].TargetID)
+		assert.Equal(t, audit.ActionPasswordResetRequested, entries[1].Action)
+		assert.Equal(t, []string{"email: nobody@example.com", "outcome: no_active_account"}, entries[1].DetailLines())
+		assert.Equal(t, audit.ActionPasswordResetRequested, entries[2].Action)
+		assert.Equal(t, user.ID, entries[2].TargetID)
+	})
+
+	t.Run("deactivated users can't reset", func(t *testing.T) {
+		other := createTestUser(t, db, "grace@example.com", "password123", true)
+		_, token, err := accounts.RequestPasswordReset(logger, db, "secret", "grace@example.com", "", now)
+		require.NoError(t, err)
+		require.NoError(t, accounts.SetUserActive(logger, db, user.ID, other.ID, false))
+
+		_, err = accounts.FindPasswordReset(db, token, now)
+		assert.ErrorIs(t, err, accounts.ErrResetTokenInvalid)
+		_, _, err = accounts.RequestPasswordReset(logger, db, "secret", "grace@example.com", "", now)
+		assert.ErrorIs(t, err, accounts.ErrUserDeactivated)
+	})
+}
+
+func TestSystemMailerProfile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	_, err := accounts.SystemMailerProfile(db)
+	assert.ErrorIs(t, err, accounts.ErrNoSystemMailer)
+
+	profile := &integrations.MailerProfile{Name: "Team", Provider: "smtp", DefaultFromEmail: "team@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+	require.NoError(t, accounts.SetSystemMailerProfile(logger, db, profile.ID))
+	system, err := accounts.SystemMailerProfile(db)
+	require.NoError(t, err)
+	assert.Equal(t, profile.ID, system.ID)
+
+	assert.Error(t, accounts.SetSystemMailerProfile(logger, db, profile.ID+1), "unknown profiles can't be chosen")
+
+	require.NoError(t, db.Delete(profile).Error)
+	_, err = accounts.SystemMailerProfile(db)
+	assert.ErrorIs(t, err, accounts.ErrNoSystemMailer, "a deleted profile turns system emails off")
+
+	require.NoError(t, accounts.SetSystemMailerProfile(logger, db, 0))
+	_, err = accounts.SystemMailerProfile(db)
+	assert.ErrorIs(t, err, accounts.ErrNoSystemMailer)
+}
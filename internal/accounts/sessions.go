@@ -0,0 +1,213 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// sessionTouchInterval throttles LastSeenAt updates, so browsing doesn't
+// write on every request.
+const sessionTouchInterval = time.Minute
+
+var (
+	ErrSessionInvalid  = errors.New("session is not valid")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// Session is one sign-in of a user on a device. The browser holds a random
+// token for it next to the signed session cookie, and only a hash of the
+// token is stored. A session ends when it is revoked, goes idle or reaches
+// ExpiresAt, whichever comes first.
+type Session struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"index;not null"`
+	TokenHash  string    `gorm:"size:64;uniqueIndex;not null"` // hex SHA-256
+	IP         string    `gorm:"size:64"`
+	UserAgent  string    `gorm:"size:512"`
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// Device describes the browser and system of the session's user agent, such
+// as "Firefox on macOS".
+func (s *Session) Device() string {
+	ua := s.UserAgent
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	system := ""
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			system = o.name
+			break
+		}
+	}
+	if system == "" {
+		return browser
+	}
+	return browser + " on " + system
+}
+
+// StartSession records a sign-in of userID by method, such as "password" or
+// "passkey", and returns it with the token for the browser. Ended sessions of
+// the user are cleared out on the way.
+func StartSession(logger *slog.Logger, db *gorm.DB, userID uint, method, ip, userAgent string, now time.Time, maxAge time.Duration) (*Session, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	now = now.UTC()
+	session := &Session{
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		IP:         ip,
+		UserAgent:  userAgent,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(maxAge),
+	}
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND (revoked_at IS NOT NULL OR expires_at <= ?)", userID, now).Delete(&Session{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionLogin,
+			TargetType: "user",
+			TargetID:   userID,
+			Details:    map[string]any{"method": method, "device": session.Device()},
+		})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// ValidateSession returns the live session of userID for token and marks it
+// as seen.
+func ValidateSession(logger *slog.Logger, db *gorm.DB, userID uint, token string, now time.Time, idle time.Duration) (*Session, error) {
+	session, err := FindSession(db, userID, token, now, idle)
+	if err != nil {
+		return nil, err
+	}
+
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		session.LastSeenAt = now.UTC()
+		err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+			return tx.Model(&Session{}).Where("id = ?", session.ID).Update("last_seen_at", session.LastSeenAt).Error
+		})
+		if err != nil {
+			// Seeing the session is bookkeeping; the request goes on.
+			logger.Warn("failed to touch session", slog.Any("error", err), slog.Uint64("session_id", uint64(session.ID)))
+		}
+	}
+	return session, nil
+}
+
+// FindSession returns the live session of userID for token without marking
+// it as seen, for requests that aren't the user's own activity.
+func FindSession(db *gorm.DB, userID uint, token string, now time.Time, idle time.Duration) (*Session, error) {
+	if token == "" {
+		return nil, ErrSessionInvalid
+	}
+	var session Session
+	err := db.Where("token_hash = ?", hashToken(token)).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID || !session.live(now, idle) {
+		return nil, ErrSessionInvalid
+	}
+	return &session, nil
+}
+
+func (s *Session) live(now time.Time, idle time.Duration) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt) && now.Sub(s.LastSeenAt) < idle
+}
+
+// ListSessions returns the live sessions of userID, most recently seen
+// first.
+func ListSessions(db *gorm.DB, userID uint, now time.Time, idle time.Duration) ([]Session, error) {
+	var sessions []Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ? AND last_seen_at > ?", userID, now.UTC(), now.UTC().Add(-idle)).
+		Order("last_seen_at DESC, id DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession signs one of userID's sessions out.
+func RevokeSession(logger *slog.Logger, db *gorm.DB, userID, id uint, now time.Time) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var session Session
+		err := tx.Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&session).Update("revoked_at", now.UTC()).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionSessionRevoked,
+			TargetType: "session",
+			TargetID:   id,
+			Details:    map[string]any{"device": session.Device()},
+		})
+	})
+}
+
+// RevokeOtherSessions signs userID out everywhere but the session keepID.
+func RevokeOtherSessions(logger *slog.Logger, db *gorm.DB, userID, keepID uint, now time.Time) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := revokeSessions(tx, userID, keepID, now); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{Action: audit.ActionOtherSessionsRevoked, TargetType: "user", TargetID: userID})
+	})
+}
+
+// revokeSessions signs userID out everywhere but exceptID (0 for
+// everywhere), within the caller's transaction.
+func revokeSessions(tx *gorm.DB, userID, exceptID uint, now time.Time) error {
+	return tx.Model(&Session{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", now.UTC()).Error
+}
@@ -0,0 +1,191 @@
+package accounts_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/audit"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	idle := time.Hour
+	maxAge := 24 * time.Hour
+	ada := createTestUser(t, db, "ada@example.com", "password123", true)
+	grace := createTestUser(t, db, "grace@example.com", "password123", true)
+
+	start := func(userID uint) (*accounts.Session, string) {
+		t.Helper()
+		session, token, err := accounts.StartSession(logger, db, userID, "password", "203.0.113.7", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Gecko/20100101 Firefox/128.0", now, maxAge)
+		require.NoError(t, err)
+		return session, token
+	}
+
+	t.Run("a session is valid for its user only", func(t *testing.T) {
+		session, token := start(ada.ID)
+		assert.Equal(t, "Firefox on macOS", session.Device())
+
+		found, err := accounts.ValidateSession(logger, db, ada.ID, token, now, idle)
+		require.NoError(t, err)
+		assert.Equal(t, session.ID, found.ID)
+
+		_, err = accounts.ValidateSession(logger, db, grace.ID, token, now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+		_, err = accounts.ValidateSession(logger, db, ada.ID, "forged", now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+	})
+
+	t.Run("requests keep a session from going idle until its max age", func(t *testing.T) {
+		_, token := start(ada.ID)
+		at := now
+		for at.Before(now.Add(maxAge - idle)) {
+			at = at.Add(idle - time.Minute)
+			_, err := accounts.ValidateSession(logger, db, ada.ID, token, at, idle)
+			require.NoError(t, err, "active at %s", at)
+		}
+		_, err := accounts.ValidateSession(logger, db, ada.ID, token, now.Add(maxAge), idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+	})
+
+	t.Run("idle sessions end", func(t *testing.T) {
+		_, token := start(ada.ID)
+		_, err := accounts.ValidateSession(logger, db, ada.ID, token, now.Add(idle), idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+	})
+
+	t.Run("sessions are revoked one by one or all but one", func(t *testing.T) {
+		first, firstToken := start(grace.ID)
+		second, secondToken := start(grace.ID)
+		third, thirdToken := start(grace.ID)
+
+		sessions, err := accounts.ListSessions(db, grace.ID, now, idle)
+		require.NoError(t, err)
+		assert.Len(t, sessions, 3)
+
+		require.NoError(t, accounts.RevokeSession(logger, db, grace.ID, first.ID, now))
+		_, err = accounts.ValidateSession(logger, db, grace.ID, firstToken, now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+		assert.ErrorIs(t, accounts.RevokeSession(logger, db, grace.ID, first.ID, now), accounts.ErrSessionNotFound)
+		assert.ErrorIs(t, accounts.RevokeSession(logger, db, ada.ID, second.ID, now), accounts.ErrSessionNotFound, "users revoke only their own sessions")
+
+		require.NoError(t, accounts.RevokeOtherSessions(logger, db, grace.ID, third.ID, now))
+		_, err = accounts.ValidateSession(logger, db, grace.ID, secondToken, now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+		_, err = accounts.ValidateSession(logger, db, grace.ID, thirdToken, now, idle)
+		assert.NoError(t, err)
+
+		sessions, err = accounts.ListSessions(db, grace.ID, now, idle)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, third.ID, sessions[0].ID)
+	})
+
+	t.Run("credential changes sign the user out everywhere", func(t *testing.T) {
+		_, token := start(ada.ID)
+		require.NoError(t, accounts.ChangePassword(logger, db, "ada@example.com", "password123", "new-password"))
+		_, err := accounts.ValidateSession(logger, db, ada.ID, token, now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+
+		_, token = start(ada.ID)
+		require.NoError(t, accounts.ChangeEmail(logger, db, "ada@example.com", "lovelace@example.com", "new-password"))
+		_, err = accounts.ValidateSession(logger, db, ada.ID, token, now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+
+		_, token = start(grace.ID)
+		require.NoError(t, accounts.SetPassword(logger, db, ada.ID, grace.ID, "reset-by-admin"))
+		_, err = accounts.ValidateSession(logger, db, grace.ID, token, now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+
+		_, token = start(grace.ID)
+		require.NoError(t, accounts.SetUserActive(logger, db, ada.ID, grace.ID, false))
+		_, err = accounts.ValidateSession(logger, db, grace.ID, token, now, idle)
+		assert.ErrorIs(t, err, accounts.ErrSessionInvalid)
+	})
+}
+
+func TestSignInSecurityChangesAreAudited(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Now()
+	ada := createTestUser(t, db, "ada@example.com", "password123", true)
+	asAda := db.WithContext(audit.WithActor(context.Background(), audit.Actor{UserID: ada.ID, Email: ada.Email}))
+
+	lastEntry := func() audit.Entry {
+		t.Helper()
+		var entry audit.Entry
+		require.NoError(t, db.Last(&entry).Error)
+		return entry
+	}
+
+	t.Run("two-factor authentication", func(t *testing.T) {
+		secret, err := accounts.BeginTOTPEnrollment(logger, db, ada.ID)
+		require.NoError(t, err)
+		code, err := accounts.TOTPCode(secret, now)
+		require.NoError(t, err)
+		_, err = accounts.EnableTOTP(logger, asAda, ada.ID, code, now)
+		require.NoError(t, err)
+		assert.Equal(t, audit.ActionTwoFactorEnabled, lastEntry().Action)
+
+		require.NoError(t, accounts.DisableTOTP(logger, asAda, ada.ID, "password123"))
+		entry := lastEntry()
+		assert.Equal(t, audit.ActionTwoFactorDisabled, entry.Action)
+		assert.Equal(t, ada.ID, entry.TargetID)
+		assert.Equal(t, "ada@example.com", entry.ActorEmail)
+
+		secret, err = accounts.BeginTOTPEnrollment(logger, db, ada.ID)
+		require.NoError(t, err)
+		code, err = accounts.TOTPCode(secret, now)
+		require.NoError(t, err)
+		_, err = accounts.EnableTOTP(logger, asAda, ada.ID, code, now)
+		require.NoError(t, err)
+		require.NoError(t, accounts.ResetTOTP(logger, db, "ada@example.com"))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionTwoFactorReset, entry.Action)
+		assert.Equal(t, ada.ID, entry.TargetID)
+		assert.Equal(t, "System", entry.Actor(), "operators reset from the command line")
+	})
+
+	t.Run("passkeys", func(t *testing.T) {
+		passkey, err := accounts.AddPasskey(logger, asAda, ada.ID, "Laptop", []byte("credential-1"), []byte{0xa0}, 0)
+		require.NoError(t, err)
+		entry := lastEntry()
+		assert.Equal(t, audit.ActionPasskeyAdded, entry.Action)
+		assert.Equal(t, passkey.ID, entry.TargetID)
+		assert.Equal(t, []string{"name: Laptop"}, entry.DetailLines())
+
+		require.NoError(t, accounts.RevokePasskey(logger, asAda, ada.ID, passkey.ID))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionPasskeyRevoked, entry.Action)
+		assert.Equal(t, []string{"name: Laptop"}, entry.DetailLines())
+		assert.ErrorIs(t, accounts.RevokePasskey(logger, asAda, ada.ID, passkey.ID), accounts.ErrPasskeyNotFound)
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		phone, _, err := accounts.StartSession(logger, db, ada.ID, "password", "", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile/15E148 Safari/604.1", now, time.Hour)
+		require.NoError(t, err)
+		laptop, _, err := accounts.StartSession(logger, db, ada.ID, "password", "", "", now, time.Hour)
+		require.NoError(t, err)
+
+		require.NoError(t, accounts.RevokeSession(logger, asAda, ada.ID, phone.ID, now))
+		entry := lastEntry()
+		assert.Equal(t, audit.ActionSessionRevoked, entry.Action)
+		assert.Equal(t, phone.ID, entry.TargetID)
+		assert.Equal(t, []string{"device: Safari on iOS"}, entry.DetailLines())
+		assert.ErrorIs(t, accounts.RevokeSession(logger, asAda, ada.ID, phone.ID, now), accounts.ErrSessionNotFound)
+
+		require.NoError(t, accounts.RevokeOtherSessions(logger, asAda, ada.ID, laptop.ID, now))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionOtherSessionsRevoked, entry.Action)
+		assert.Equal(t, ada.ID, entry.TargetID)
+	})
+}
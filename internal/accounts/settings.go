@@ -46,3 +46,16 @@ func SetSetting(db *gorm.DB, logger *slog.Logger, key, value string) error {
 		return tx.Save(&setting).Error
 	})
 }
+
+// saveSetting updates or creates a setting within the caller's transaction.
+func saveSetting(tx *gorm.DB, key, value string) error {
+	var setting Settings
+	err := tx.Where("key = ?", key).First(&setting).Error
+	if err == gorm.ErrRecordNotFound {
+		return tx.Create(&Settings{Key: key, Value: value}).Error
+	} else if err != nil {
+		return err
+	}
+	setting.Value = value
+	return tx.Save(&setting).Error
+}
@@ -0,0 +1,144 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"formlander/internal/pkg/dbtxn"
+)
+
+var (
+	ErrSSODomainNotAllowed = errors.New("email domain is not allowed to sign in with SSO")
+	ErrSSOEmailUnverified  = errors.New("identity provider has not verified the email")
+)
+
+// Identity links a user to their account at an OpenID Connect provider. The
+// issuer and subject never change for that account, unlike its email.
+type Identity struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index;not null"`
+	Issuer     string `gorm:"size:255;not null;uniqueIndex:idx_identities_issuer_subject"`
+	Subject    string `gorm:"size:255;not null;uniqueIndex:idx_identities_issuer_subject"`
+	Email      string `gorm:"size:255"` // as last reported by the provider
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// SSOLogin is a sign-in the identity provider vouched for, with the
+// instance's provisioning policy.
+type SSOLogin struct {
+	Issuer         string
+	Subject        string
+	Email          string
+	EmailVerified  bool
+	AllowedDomains []string // lowercased; empty = existing accounts only
+	DefaultRole    string   // role of provisioned users
+}
+
+// SignInWithSSO returns the user an identity provider signed in. A known
+// identity signs in its user. Otherwise a verified email links to the
+// account with that email, or, when its domain is allowed, a new user is
+// provisioned with the default role. With allowed domains set, no other
+// domain signs in this way.
+func SignInWithSSO(logger *slog.Logger, db *gorm.DB, login SSOLogin, now time.Time) (*User, error) {
+	email := strings.ToLower(strings.TrimSpace(login.Email))
+	domainAllowed := emailDomainAllowed(email, login.AllowedDomains)
+	if len(login.AllowedDomains) > 0 && !domainAllowed {
+		return nil, ErrSSODomainNotAllowed
+	}
+	role := login.DefaultRole
+	if !ValidRole(role) || role == RoleOwner {
+		role = RoleViewer
+	}
+
+	var user *User
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		signedIn := now.UTC()
+		var identity Identity
+		err := tx.Where("issuer = ? AND subject = ?", login.Issuer, login.Subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if user, err = FindByID(tx, identity.UserID); err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !login.EmailVerified {
+				return ErrSSOEmailUnverified
+			}
+			if user, err = ssoAccount(tx, email, role, domainAllowed, signedIn); err != nil {
+				return err
+			}
+			identity = Identity{UserID: user.ID, Issuer: login.Issuer, Subject: login.Subject}
+		default:
+			return err
+		}
+
+		if !user.Active() {
+			return ErrUserDeactivated
+		}
+		identity.Email = email
+		identity.LastUsedAt = &signedIn
+		if err := tx.Save(&identity).Error; err != nil {
+			return err
+		}
+		user.LastLoginAt = &signedIn
+		return tx.Model(user).Update("last_login_at", signedIn).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ssoAccount finds the account a new identity belongs to, provisioning one
+// when the domain allows it.
+func ssoAccount(tx *gorm.DB, email, role string, provision bool, now time.Time) (*User, error) {
+	user, err := FindByEmail(tx, email)
+	if !errors.Is(err, ErrUserNotFound) {
+		return user, err
+	}
+	if !provision {
+		return nil, ErrSSODomainNotAllowed
+	}
+	if email, err = normalizeEmail(email); err != nil {
+		return nil, err
+	}
+
+	// Provisioned users sign in through the provider; their password is
+	// random and unknown until they set one.
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user = &User{Email: email, PasswordHash: string(hash), Role: role, LastLoginAt: &now}
+	if err := tx.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func emailDomainAllowed(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range domains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
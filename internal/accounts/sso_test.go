@@ -0,0 +1,98 @@
+package accounts_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignInWithSSO(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	existing := createTestUser(t, db, "ada@example.com", "password123", true)
+
+	login := func(subject, email string) accounts.SSOLogin {
+		return accounts.SSOLogin{
+			Issuer:         "https://idp.example.com",
+			Subject:        subject,
+			Email:          email,
+			EmailVerified:  true,
+			AllowedDomains: []string{"example.com"},
+			DefaultRole:    accounts.RoleEditor,
+		}
+	}
+
+	t.Run("a verified email links to the existing account", func(t *testing.T) {
+		user, err := accounts.SignInWithSSO(logger, db, login("sub-ada", "Ada@Example.com"), now)
+		require.NoError(t, err)
+		assert.Equal(t, existing.ID, user.ID)
+		assert.Equal(t, accounts.RoleOwner, user.Role, "linking doesn't change the role")
+	})
+
+	t.Run("the identity keeps signing in after an email change", func(t *testing.T) {
+		user, err := accounts.SignInWithSSO(logger, db, login("sub-ada", "lovelace@example.com"), now)
+		require.NoError(t, err)
+		assert.Equal(t, existing.ID, user.ID)
+	})
+
+	t.Run("new users in an allowed domain are provisioned", func(t *testing.T) {
+		user, err := accounts.SignInWithSSO(logger, db, login("sub-grace", "grace@example.com"), now)
+		require.NoError(t, err)
+		assert.Equal(t, "grace@example.com", user.Email)
+		assert.Equal(t, accounts.RoleEditor, user.Role)
+		require.NotNil(t, user.LastLoginAt)
+
+		again, err := accounts.SignInWithSSO(logger, db, login("sub-grace", "grace@example.com"), now)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, again.ID)
+	})
+
+	t.Run("other domains are refused", func(t *testing.T) {
+		_, err := accounts.SignInWithSSO(logger, db, login("sub-mallory", "mallory@example.org"), now)
+		assert.ErrorIs(t, err, accounts.ErrSSODomainNotAllowed)
+		_, err = accounts.SignInWithSSO(logger, db, login("sub-mallory", "mallory@evil-example.com"), now)
+		assert.ErrorIs(t, err, accounts.ErrSSODomainNotAllowed)
+	})
+
+	t.Run("unverified emails neither link nor provision", func(t *testing.T) {
+		unverified := login("sub-eve", "ada@example.com")
+		unverified.EmailVerified = false
+		_, err := accounts.SignInWithSSO(logger, db, unverified, now)
+		assert.ErrorIs(t, err, accounts.ErrSSOEmailUnverified)
+	})
+
+	t.Run("without allowed domains only existing accounts sign in", func(t *testing.T) {
+		closed := login("sub-alan", "alan@example.com")
+		closed.AllowedDomains = nil
+		_, err := accounts.SignInWithSSO(logger, db, closed, now)
+		assert.ErrorIs(t, err, accounts.ErrSSODomainNotAllowed)
+
+		createTestUser(t, db, "alan@example.com", "password123", true)
+		_, err = accounts.SignInWithSSO(logger, db, closed, now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("the owner role is never handed out", func(t *testing.T) {
+		owner := login("sub-linus", "linus@example.com")
+		owner.DefaultRole = accounts.RoleOwner
+		user, err := accounts.SignInWithSSO(logger, db, owner, now)
+		require.NoError(t, err)
+		assert.Equal(t, accounts.RoleViewer, user.Role)
+	})
+
+	t.Run("deactivated users can't sign in", func(t *testing.T) {
+		grace, err := accounts.FindByEmail(db, "grace@example.com")
+		require.NoError(t, err)
+		require.NoError(t, accounts.SetUserActive(logger, db, existing.ID, grace.ID, false))
+		_, err = accounts.SignInWithSSO(logger, db, login("sub-grace", "grace@example.com"), now)
+		assert.ErrorIs(t, err, accounts.ErrUserDeactivated)
+	})
+}
@@ -0,0 +1,390 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// TOTP parameters as authenticator apps expect them by default (RFC 6238).
+const (
+	TOTPIssuer   = "Formlander"
+	totpPeriod   = 30
+	totpDigits   = 6
+	totpSkew     = 1 // steps accepted either side of now, for clock drift
+	secretLength = 20
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time.
+const RecoveryCodeCount = 10
+
+// LoginChallengeTTL is how long a user has to enter their second factor after
+// their password.
+const LoginChallengeTTL = 5 * time.Minute
+
+var (
+	ErrInvalidCode           = errors.New("verification code is not valid")
+	ErrTOTPEnabled           = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled        = errors.New("two-factor authentication is not enabled")
+	ErrLoginChallengeInvalid = errors.New("sign-in verification has expired")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryCode is a single-use code that stands in for the authenticator
+// app. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"size:64;index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// TOTPEnabled reports whether the user must enter a code after their
+// password.
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// BeginTOTPEnrollment gives the user a new secret to add to their
+// authenticator app. It takes effect once EnableTOTP confirms a code from
+// it; until then sign-in is unchanged.
+func BeginTOTPEnrollment(logger *slog.Logger, db *gorm.DB, userID uint) (string, error) {
+	raw := make([]byte, secretLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	secret := totpEncoding.EncodeToString(raw)
+
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := FindByID(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TOTPEnabled() {
+			return ErrTOTPEnabled
+		}
+		return tx.Model(user).Updates(map[string]any{"totp_secret": secret, "totp_last_step": 0}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// EnableTOTP turns on two-factor authentication once code proves the
+// user's app holds the enrolled secret. It returns the recovery codes,
+// which are shown once and never again.
+func EnableTOTP(logger *slog.Logger, db *gorm.DB, userID uint, code string, now time.Time) ([]string, error) {
+	var codes []string
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := FindByID(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TOTPEnabled() {
+			return ErrTOTPEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrTOTPNotEnabled
+		}
+		step, ok := matchTOTP(user.TOTPSecret, code, now, 0)
+		if !ok {
+			return ErrInvalidCode
+		}
+		enabled := now.UTC()
+		if err := tx.Model(user).Updates(map[string]any{"totp_enabled_at": enabled, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+		if codes, err = replaceRecoveryCodes(tx, userID); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{Action: audit.ActionTwoFactorEnabled, TargetType: "user", TargetID: userID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns off two-factor authentication after checking the
+// user's password, and discards their secret and recovery codes.
+func DisableTOTP(logger *slog.Logger, db *gorm.DB, userID uint, password string) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := FindByID(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return ErrPasswordMismatch
+		}
+		if !user.TOTPEnabled() {
+			return ErrTOTPNotEnabled
+		}
+		if err := clearTOTP(tx, userID); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{Action: audit.ActionTwoFactorDisabled, TargetType: "user", TargetID: userID})
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// their password. Earlier codes stop working.
+func RegenerateRecoveryCodes(logger *slog.Logger, db *gorm.DB, userID uint, password string) ([]string, error) {
+	var codes []string
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := FindByID(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return ErrPasswordMismatch
+		}
+		if !user.TOTPEnabled() {
+			return ErrTOTPNotEnabled
+		}
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RemainingRecoveryCodes counts the user's unused recovery codes.
+func RemainingRecoveryCodes(db *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	err := db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// VerifySecondFactor checks an authenticator code or an unused recovery
+// code for a user who has passed the password step. Each authenticator code
+// and recovery code works once.
+func VerifySecondFactor(logger *slog.Logger, db *gorm.DB, userID uint, code string, now time.Time) (*User, error) {
+	code = normalizeCode(code)
+	var user *User
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var err error
+		user, err = FindByID(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.Active() {
+			return ErrUserDeactivated
+		}
+		if !user.TOTPEnabled() {
+			return ErrTOTPNotEnabled
+		}
+
+		if len(code) == totpDigits {
+			step, ok := matchTOTP(user.TOTPSecret, code, now, user.TOTPLastStep)
+			if !ok {
+				return ErrInvalidCode
+			}
+			// Guarded on the previous step so two requests can't both
+			// spend the same code.
+			res := tx.Model(&User{}).Where("id = ? AND totp_last_step < ?", userID, step).Update("totp_last_step", step)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrInvalidCode
+			}
+			return nil
+		}
+
+		res := tx.Model(&RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+			Update("used_at", now.UTC())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResetTOTP turns off two-factor authentication for the user with email,
+// for operators helping someone who lost their authenticator and recovery
+// codes.
+func ResetTOTP(logger *slog.Logger, db *gorm.DB, email string) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := FindByEmail(tx, email)
+		if err != nil {
+			return err
+		}
+		if err := clearTOTP(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionTwoFactorReset,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Details:    map[string]any{"email": user.Email},
+		})
+	})
+}
+
+// TOTPURI is the otpauth:// link authenticator apps import, usually from a
+// QR code.
+func TOTPURI(email, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", TOTPIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(totpDigits))
+	query.Set("period", strconv.Itoa(totpPeriod))
+	label := url.PathEscape(TOTPIssuer + ":" + email)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPCode computes the code for secret at a point in time.
+func TOTPCode(secret string, at time.Time) (string, error) {
+	return totpCode(secret, at.Unix()/totpPeriod)
+}
+
+// LoginChallengeToken signs the cookie that carries a user from the password
+// step to the second-factor step.
+func LoginChallengeToken(secret string, userID uint, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d", userID, expires.Unix())
+	return payload + "." + loginChallengeSignature(secret, payload)
+}
+
+// VerifyLoginChallenge checks a login challenge token and returns the user
+// it was issued to.
+func VerifyLoginChallenge(secret, token string, now time.Time) (uint, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrLoginChallengeInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(loginChallengeSignature(secret, payload))) {
+		return 0, ErrLoginChallengeInvalid
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, ErrLoginChallengeInvalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return 0, ErrLoginChallengeInvalid
+	}
+	return uint(id), nil
+}
+
+func loginChallengeSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("2fa:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// matchTOTP looks for code within the allowed drift of now, ignoring steps
+// at or before lastStep, which were already used.
+func matchTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = normalizeCode(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// replaceRecoveryCodes issues a fresh set of recovery codes, returning them
+// formatted for display.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, RecoveryCodeCount)
+	rows := make([]RecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		rows[i] = RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func clearTOTP(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error
+}
+
+// normalizeCode drops the spaces and dashes people type or paste into codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,161 @@
+package accounts_test
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 SHA-1 test vectors, truncated to six digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		got, err := accounts.TOTPCode(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "at %d", unix)
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	user := createTestUser(t, db, "admin@example.com", "password123", true)
+
+	secret, err := accounts.BeginTOTPEnrollment(logger, db, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+	assert.Contains(t, accounts.TOTPURI(user.Email, secret), "otpauth://totp/Formlander:admin@example.com?")
+
+	t.Run("enrollment needs a code from the new secret", func(t *testing.T) {
+		_, err := accounts.EnableTOTP(logger, db, user.ID, "000000", now)
+		assert.ErrorIs(t, err, accounts.ErrInvalidCode)
+
+		reloaded, err := accounts.FindByID(db, user.ID)
+		require.NoError(t, err)
+		assert.False(t, reloaded.TOTPEnabled())
+	})
+
+	code, err := accounts.TOTPCode(secret, now)
+	require.NoError(t, err)
+	recovery, err := accounts.EnableTOTP(logger, db, user.ID, code, now)
+	require.NoError(t, err)
+	require.Len(t, recovery, accounts.RecoveryCodeCount)
+
+	t.Run("recovery codes are stored hashed", func(t *testing.T) {
+		var stored []accounts.RecoveryCode
+		require.NoError(t, db.Where("user_id = ?", user.ID).Find(&stored).Error)
+		require.Len(t, stored, accounts.RecoveryCodeCount)
+		for _, row := range stored {
+			for _, plain := range recovery {
+				assert.NotContains(t, row.CodeHash, strings.ReplaceAll(plain, "-", ""))
+			}
+		}
+	})
+
+	t.Run("authenticator codes work once and within the drift window", func(t *testing.T) {
+		_, err := accounts.VerifySecondFactor(logger, db, user.ID, code, now)
+		assert.ErrorIs(t, err, accounts.ErrInvalidCode, "code used to enable can't sign in")
+
+		next := now.Add(30 * time.Second)
+		nextCode, err := accounts.TOTPCode(secret, next)
+		require.NoError(t, err)
+		verified, err := accounts.VerifySecondFactor(logger, db, user.ID, nextCode[:3]+" "+nextCode[3:], now)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, verified.ID)
+		_, err = accounts.VerifySecondFactor(logger, db, user.ID, nextCode, now)
+		assert.ErrorIs(t, err, accounts.ErrInvalidCode)
+
+		later := now.Add(time.Hour)
+		stale, err := accounts.TOTPCode(secret, later.Add(-2*time.Minute))
+		require.NoError(t, err)
+		_, err = accounts.VerifySecondFactor(logger, db, user.ID, stale, later)
+		assert.ErrorIs(t, err, accounts.ErrInvalidCode)
+	})
+
+	t.Run("recovery codes work once", func(t *testing.T) {
+		_, err := accounts.VerifySecondFactor(logger, db, user.ID, strings.ToUpper(recovery[0]), now)
+		require.NoError(t, err)
+		_, err = accounts.VerifySecondFactor(logger, db, user.ID, recovery[0], now)
+		assert.ErrorIs(t, err, accounts.ErrInvalidCode)
+
+		remaining, err := accounts.RemainingRecoveryCodes(db, user.ID)
+		require.NoError(t, err)
+		assert.EqualValues(t, accounts.RecoveryCodeCount-1, remaining)
+	})
+
+	t.Run("regenerating replaces earlier codes", func(t *testing.T) {
+		_, err := accounts.RegenerateRecoveryCodes(logger, db, user.ID, "wrong-password")
+		assert.ErrorIs(t, err, accounts.ErrPasswordMismatch)
+
+		fresh, err := accounts.RegenerateRecoveryCodes(logger, db, user.ID, "password123")
+		require.NoError(t, err)
+		_, err = accounts.VerifySecondFactor(logger, db, user.ID, recovery[1], now)
+		assert.ErrorIs(t, err, accounts.ErrInvalidCode)
+		_, err = accounts.VerifySecondFactor(logger, db, user.ID, fresh[0], now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("enrolling again is refused while enabled", func(t *testing.T) {
+		_, err := accounts.BeginTOTPEnrollment(logger, db, user.ID)
+		assert.ErrorIs(t, err, accounts.ErrTOTPEnabled)
+	})
+
+	t.Run("disabling takes the password and clears codes", func(t *testing.T) {
+		assert.ErrorIs(t, accounts.DisableTOTP(logger, db, user.ID, "wrong-password"), accounts.ErrPasswordMismatch)
+		require.NoError(t, accounts.DisableTOTP(logger, db, user.ID, "password123"))
+
+		reloaded, err := accounts.FindByID(db, user.ID)
+		require.NoError(t, err)
+		assert.False(t, reloaded.TOTPEnabled())
+		assert.Empty(t, reloaded.TOTPSecret)
+		remaining, err := accounts.RemainingRecoveryCodes(db, user.ID)
+		require.NoError(t, err)
+		assert.Zero(t, remaining)
+	})
+
+	t.Run("operators reset by email", func(t *testing.T) {
+		secret, err := accounts.BeginTOTPEnrollment(logger, db, user.ID)
+		require.NoError(t, err)
+		code, err := accounts.TOTPCode(secret, now)
+		require.NoError(t, err)
+		_, err = accounts.EnableTOTP(logger, db, user.ID, code, now)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, accounts.ResetTOTP(logger, db, "nobody@example.com"), accounts.ErrUserNotFound)
+		require.NoError(t, accounts.ResetTOTP(logger, db, " Admin@Example.com "))
+		reloaded, err := accounts.FindByID(db, user.ID)
+		require.NoError(t, err)
+		assert.False(t, reloaded.TOTPEnabled())
+	})
+}
+
+func TestLoginChallenge(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	token := accounts.LoginChallengeToken("secret", 42, now.Add(accounts.LoginChallengeTTL))
+
+	userID, err := accounts.VerifyLoginChallenge("secret", token, now)
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, userID)
+
+	_, err = accounts.VerifyLoginChallenge("secret", token, now.Add(accounts.LoginChallengeTTL))
+	assert.ErrorIs(t, err, accounts.ErrLoginChallengeInvalid)
+	_, err = accounts.VerifyLoginChallenge("other", token, now)
+	assert.ErrorIs(t, err, accounts.ErrLoginChallengeInvalid)
+	_, err = accounts.VerifyLoginChallenge("secret", "43"+token[2:], now)
+	assert.ErrorIs(t, err, accounts.ErrLoginChallengeInvalid)
+}
@@ -0,0 +1,285 @@
+package accounts
+
+import (
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// normalizeEmail lowercases and trims an address and checks it parses.
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", ErrInvalidEmail
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// emailTaken reports whether another user than exceptID uses email.
+func emailTaken(db *gorm.DB, email string, exceptID uint) (bool, error) {
+	var count int64
+	err := db.Model(&User{}).Where("email = ? AND id <> ?", email, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// requireOwner checks that actorID is an owner.
+func requireOwner(db *gorm.DB, actorID uint) error {
+	actor, err := FindByID(db, actorID)
+	if err != nil {
+		return err
+	}
+	if !actor.HasRole(RoleOwner) {
+		return ErrOwnerRequired
+	}
+	return nil
+}
+
+// findManaged loads the user actorID is about to change.
+func findManaged(db *gorm.DB, actorID, id uint) (*User, error) {
+	if actorID == id {
+		return nil, ErrSelfManagement
+	}
+	user, err := FindByID(db, id)
+	if err != nil {
+		return nil, err
+	}
+	// Only owners manage owners.
+	if user.Role == RoleOwner {
+		if err := requireOwner(db, actorID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// UpdateUserEmail changes another user's email on their behalf. Users change
+// their own email with ChangeEmail, which asks for their password.
+func UpdateUserEmail(logger *slog.Logger, db *gorm.DB, actorID, id uint, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	user, err := findManaged(db, actorID, id)
+	if err != nil {
+		return err
+	}
+	taken, err := emailTaken(db, email, id)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateEmail
+	}
+
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", id).Update("email", email).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, id, 0, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionUserEmailChanged,
+			TargetType: "user",
+			TargetID:   id,
+			Details:    audit.Changes(map[string]any{"email": user.Email}, map[string]any{"email": email}),
+		})
+	})
+}
+
+// SetPassword replaces a user's password without asking for the current one,
+// for admins resetting a teammate's access.
+func SetPassword(logger *slog.Logger, db *gorm.DB, actorID, id uint, password string) error {
+	if len(password) < 8 {
+		return ErrWeakPassword
+	}
+	user, err := findManaged(db, actorID, id)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("failed to generate password hash", slog.Any("error", err))
+		return err
+	}
+
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", id).Update("password_hash", string(hash)).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, id, 0, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionUserPasswordSet,
+			TargetType: "user",
+			TargetID:   id,
+			Details:    map[string]any{"email": user.Email},
+		})
+	})
+}
+
+// SetUserRole changes another user's role. Only owners hand out or take away
+// the owner role, and the last active owner keeps it.
+func SetUserRole(logger *slog.Logger, db *gorm.DB, actorID, id uint, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := findManaged(tx, actorID, id)
+		if err != nil {
+			return err
+		}
+		if user.Role == role {
+			return nil
+		}
+		if role == RoleOwner {
+			if err := requireOwner(tx, actorID); err != nil {
+				return err
+			}
+		}
+		if user.Role == RoleOwner && user.Active() {
+			if err := ensureOtherActiveOwner(tx, id); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&User{}).Where("id = ?", id).Update("role", role).Error; err != nil {
+			return err
+		}
+		details := audit.Changes(map[string]any{"role": user.Role}, map[string]any{"role": role})
+		details["email"] = user.Email
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionUserRoleChanged,
+			TargetType: "user",
+			TargetID:   id,
+			Details:    details,
+		})
+	})
+}
+
+// SetUserActive deactivates or reactivates a user. Nobody can lock
+// themselves out, and the last active user and owner stay active.
+func SetUserActive(logger *slog.Logger, db *gorm.DB, actorID, id uint, active bool) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := findManaged(tx, actorID, id)
+		if err != nil {
+			return err
+		}
+		if user.Active() == active {
+			return nil
+		}
+		if !active {
+			if err := ensureOtherActive(tx, user); err != nil {
+				return err
+			}
+		}
+
+		var deactivatedAt *time.Time
+		if !active {
+			now := time.Now().UTC()
+			deactivatedAt = &now
+			if err := revokeSessions(tx, id, 0, now); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&User{}).Where("id = ?", id).Update("deactivated_at", deactivatedAt).Error; err != nil {
+			return err
+		}
+		action := audit.ActionUserReactivated
+		if !active {
+			action = audit.ActionUserDeactivated
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     action,
+			TargetType: "user",
+			TargetID:   id,
+			Details:    map[string]any{"email": user.Email},
+		})
+	})
+}
+
+// DeleteUser removes a user for good. detach runs in the same transaction to
+// clear what other packages keep about the user (assignments, saved views),
+// so a later account reusing the ID doesn't inherit them.
+func DeleteUser(logger *slog.Logger, db *gorm.DB, actorID, id uint, detach func(tx *gorm.DB, userID uint) error) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		user, err := findManaged(tx, actorID, id)
+		if err != nil {
+			return err
+		}
+		if user.Active() {
+			if err := ensureOtherActive(tx, user); err != nil {
+				return err
+			}
+		}
+		if detach != nil {
+			if err := detach(tx, id); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Invitation{}).Where("invited_by_id = ?", id).Update("invited_by_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&Passkey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&Identity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&PasswordReset{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&Session{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&User{}, id).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionUserDeleted,
+			TargetType: "user",
+			TargetID:   id,
+			Details:    map[string]any{"email": user.Email, "role": user.Role},
+		})
+	})
+}
+
+// ensureOtherActive guards taking user out of action: someone else must be
+// able to sign in, and an owner must remain if user is one.
+func ensureOtherActive(tx *gorm.DB, user *User) error {
+	var others int64
+	if err := tx.Model(&User{}).Where("id <> ? AND deactivated_at IS NULL", user.ID).Count(&others).Error; err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastActiveUser
+	}
+	if user.Role == RoleOwner {
+		return ensureOtherActiveOwner(tx, user.ID)
+	}
+	return nil
+}
+
+func ensureOtherActiveOwner(tx *gorm.DB, id uint) error {
+	var owners int64
+	if err := tx.Model(&User{}).Where("id <> ? AND role = ? AND deactivated_at IS NULL", id, RoleOwner).Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
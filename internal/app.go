@@ -38,6 +38,12 @@ func NewApp() (*App, error) {
 		cartridge.WithJobs(2*time.Minute,
 			jobs.NewWebhookDispatcher(cfg),
 			jobs.NewEmailDispatcher(cfg),
+			jobs.NewDigestDispatcher(cfg),
+			jobs.NewOptInDispatcher(cfg),
+			jobs.NewReplyDispatcher(cfg),
+			jobs.NewInvitationDispatcher(cfg),
+			jobs.NewPasswordResetDispatcher(cfg),
+			jobs.NewAnalyticsRollup(),
 		),
 		cartridge.WithRoutes(func(s *cartridge.Server) {
 			MountRoutes(s, cfg)
@@ -72,6 +78,10 @@ func RunMigrations(app *App) error {
 	return nil
 }
 
+// ensureAdminUser seeds the default admin on an empty install. Once any user
+// exists, further users join by invitation. The admin UI never deactivates
+// the last active user, so an install with none was edited by hand; it is
+// reported rather than reseeded.
 func ensureAdminUser(db *gorm.DB, cfg *config.Config, logger *slog.Logger) error {
 	var count int64
 	if err := db.Model(&accounts.User{}).Count(&count).Error; err != nil {
@@ -79,6 +89,13 @@ func ensureAdminUser(db *gorm.DB, cfg *config.Config, logger *slog.Logger) error
 	}
 
 	if count > 0 {
+		var active int64
+		if err := db.Model(&accounts.User{}).Where("deactivated_at IS NULL").Count(&active).Error; err != nil {
+			return err
+		}
+		if active == 0 {
+			logger.Warn("no active users: every account is deactivated")
+		}
 		return nil
 	}
 
@@ -0,0 +1,312 @@
+// Package audit keeps an append-only log of who did what and when.
+//
+// Entries are written by the domain packages inside the transaction of the
+// change they describe, so a change can't be committed without its entry.
+// The acting user travels with the database handle's context (WithActor),
+// which keeps the domain signatures free of request details.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/pkg/dbtxn"
+)
+
+// Actions recorded in the log.
+const (
+	ActionLogin                    = "auth.login"
+	ActionLoginFailed              = "auth.login_failed"
+	ActionPasswordResetRequested   = "password_reset.requested"
+	ActionPasswordResetCompleted   = "password_reset.completed"
+	ActionUserPasswordChanged      = "user.password_changed"
+	ActionUserEmailChanged         = "user.email_changed"
+	ActionUserPasswordSet          = "user.password_set"
+	ActionUserRoleChanged          = "user.role_changed"
+	ActionUserDeactivated          = "user.deactivated"
+	ActionUserReactivated          = "user.reactivated"
+	ActionUserDeleted              = "user.deleted"
+	ActionUserFormGrantsChanged    = "user.form_grants_changed"
+	ActionInvitationCreated        = "invitation.created"
+	ActionInvitationResent         = "invitation.resent"
+	ActionInvitationRevoked        = "invitation.revoked"
+	ActionInvitationAccepted       = "invitation.accepted"
+	ActionTwoFactorEnabled         = "two_factor.enabled"
+	ActionTwoFactorDisabled        = "two_factor.disabled"
+	ActionTwoFactorReset           = "two_factor.reset"
+	ActionPasskeyAdded             = "passkey.added"
+	ActionPasskeyRevoked           = "passkey.revoked"
+	ActionSessionRevoked           = "session.revoked"
+	ActionOtherSessionsRevoked     = "session.others_revoked"
+	ActionFormCreated              = "form.created"
+	ActionFormUpdated              = "form.updated"
+	ActionFormDeleted              = "form.deleted"
+	ActionAllFormsDigestChanged    = "form.all_forms_digest_changed"
+	ActionRoutingRuleCreated       = "routing_rule.created"
+	ActionRoutingRuleDeleted       = "routing_rule.deleted"
+	ActionMailerCreated            = "mailer_profile.created"
+	ActionMailerUpdated            = "mailer_profile.updated"
+	ActionMailerDeleted            = "mailer_profile.deleted"
+	ActionSystemMailerChanged      = "mailer_profile.system_changed"
+	ActionCaptchaCreated           = "captcha_profile.created"
+	ActionCaptchaUpdated           = "captcha_profile.updated"
+	ActionCaptchaDeleted           = "captcha_profile.deleted"
+	ActionSubmissionViewed         = "submission.viewed"
+	ActionSubmissionFileDownloaded = "submission.file_downloaded"
+	ActionSubmissionsExported      = "submissions.exported"
+)
+
+// Actions lists every action, in the order the log page offers them.
+var Actions = []string{
+	ActionLogin,
+	ActionLoginFailed,
+	ActionPasswordResetRequested,
+	ActionPasswordResetCompleted,
+	ActionUserPasswordChanged,
+	ActionUserEmailChanged,
+	ActionUserPasswordSet,
+	ActionUserRoleChanged,
+	ActionUserDeactivated,
+	ActionUserReactivated,
+	ActionUserDeleted,
+	ActionUserFormGrantsChanged,
+	ActionInvitationCreated,
+	ActionInvitationResent,
+	ActionInvitationRevoked,
+	ActionInvitationAccepted,
+	ActionTwoFactorEnabled,
+	ActionTwoFactorDisabled,
+	ActionTwoFactorReset,
+	ActionPasskeyAdded,
+	ActionPasskeyRevoked,
+	ActionSessionRevoked,
+	ActionOtherSessionsRevoked,
+	ActionFormCreated,
+	ActionFormUpdated,
+	ActionFormDeleted,
+	ActionAllFormsDigestChanged,
+	ActionRoutingRuleCreated,
+	ActionRoutingRuleDeleted,
+	ActionMailerCreated,
+	ActionMailerUpdated,
+	ActionMailerDeleted,
+	ActionSystemMailerChanged,
+	ActionCaptchaCreated,
+	ActionCaptchaUpdated,
+	ActionCaptchaDeleted,
+	ActionSubmissionViewed,
+	ActionSubmissionFileDownloaded,
+	ActionSubmissionsExported,
+}
+
+// ErrAppendOnly is returned when something tries to change or remove an entry.
+var ErrAppendOnly = errors.New("audit log is append-only")
+
+// Entry is one line of the audit log. The actor's email is copied in, so the
+// entry still says who it was after the user is removed.
+type Entry struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"index"`
+	ActorID    *uint     `gorm:"index"` // nil for the system and for failed sign-ins
+	ActorEmail string    `gorm:"size:255;index"`
+	IP         string    `gorm:"size:64"`
+	Action     string    `gorm:"size:64;index;not null"`
+	TargetType string    `gorm:"size:64"`
+	TargetID   uint
+	Details    string `gorm:"type:text"` // JSON object
+}
+
+// TableName keeps the table name readable next to the other tables.
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// BeforeUpdate keeps entries from being rewritten.
+func (*Entry) BeforeUpdate(*gorm.DB) error {
+	return ErrAppendOnly
+}
+
+// BeforeDelete keeps entries from being removed.
+func (*Entry) BeforeDelete(*gorm.DB) error {
+	return ErrAppendOnly
+}
+
+// Actor returns who made the entry, for display.
+func (e *Entry) Actor() string {
+	if e.ActorEmail != "" {
+		return e.ActorEmail
+	}
+	if e.IP != "" {
+		return "Anonymous"
+	}
+	return "System"
+}
+
+// DetailLines returns the entry's details for display, one "key: value" line
+// per detail in key order. Changes read "key: old → new".
+func (e *Entry) DetailLines() []string {
+	details := map[string]any{}
+	if e.Details == "" || json.Unmarshal([]byte(e.Details), &details) != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		if from, to, ok := change(details[key]); ok {
+			lines[i] = fmt.Sprintf("%s: %v → %v", key, displayValue(from), displayValue(to))
+			continue
+		}
+		lines[i] = fmt.Sprintf("%s: %v", key, displayValue(details[key]))
+	}
+	return lines
+}
+
+// change unpacks a {"from": old, "to": new} detail written by Changes.
+func change(v any) (from, to any, ok bool) {
+	m, isMap := v.(map[string]any)
+	if !isMap || len(m) != 2 {
+		return nil, nil, false
+	}
+	from, hasFrom := m["from"]
+	to, hasTo := m["to"]
+	return from, to, hasFrom && hasTo
+}
+
+func displayValue(v any) any {
+	if v == nil || v == "" {
+		return "(empty)"
+	}
+	return v
+}
+
+// Actor is who an entry is recorded for: a signed-in user, or just an
+// address for anonymous requests such as failed sign-ins.
+type Actor struct {
+	UserID uint
+	Email  string
+	IP     string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying actor. Entries recorded through a
+// database handle with this context are attributed to actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by ctx.
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	if ctx == nil {
+		return Actor{}, false
+	}
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// Event describes what happened: the action, what it happened to and any
+// details worth keeping. Secrets in Details must be masked by the caller.
+type Event struct {
+	Action     string
+	TargetType string
+	TargetID   uint
+	Details    map[string]any
+}
+
+// Record appends event to the log within the caller's transaction,
+// attributed to the actor of the transaction's context.
+func Record(tx *gorm.DB, event Event) error {
+	entry := Entry{
+		CreatedAt:  time.Now().UTC(),
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+	}
+	if actor, ok := ActorFrom(tx.Statement.Context); ok {
+		if actor.UserID != 0 {
+			id := actor.UserID
+			entry.ActorID = &id
+		}
+		entry.ActorEmail = actor.Email
+		entry.IP = actor.IP
+	}
+	if len(event.Details) > 0 {
+		data, err := json.Marshal(event.Details)
+		if err != nil {
+			return err
+		}
+		entry.Details = string(data)
+	}
+	return tx.Create(&entry).Error
+}
+
+// Log appends event in a transaction of its own, for things that write
+// nothing else, such as viewing a submission.
+func Log(logger *slog.Logger, db *gorm.DB, event Event) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		return Record(tx, event)
+	})
+}
+
+// Mask hides a secret, keeping only whether it is set.
+func Mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
+
+// Changes compares column values before and after a change and returns the
+// ones that differ as {"from": old, "to": new}, keyed by column. Pass nil
+// for before to describe something new by its non-empty values. Columns
+// listed in secrets are compared as is but recorded masked.
+func Changes(before, after map[string]any, secrets ...string) map[string]any {
+	masked := make(map[string]bool, len(secrets))
+	for _, column := range secrets {
+		masked[column] = true
+	}
+	changes := map[string]any{}
+	for column, value := range after {
+		old, existed := before[column]
+		if existed && sameValue(old, value) {
+			continue
+		}
+		if masked[column] {
+			old, value = maskValue(old), maskValue(value)
+		}
+		if before == nil {
+			if value != nil && !reflect.ValueOf(value).IsZero() {
+				changes[column] = value
+			}
+			continue
+		}
+		changes[column] = map[string]any{"from": old, "to": value}
+	}
+	return changes
+}
+
+func sameValue(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+func maskValue(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	return Mask(s)
+}
@@ -0,0 +1,139 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+
+	"formlander/internal/audit"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	t.Run("entries carry the actor of the database context", func(t *testing.T) {
+		ctx := audit.WithActor(context.Background(), audit.Actor{UserID: 7, Email: "ada@example.com", IP: "203.0.113.7"})
+		require.NoError(t, audit.Log(logger, db.WithContext(ctx), audit.Event{
+			Action:     audit.ActionFormCreated,
+			TargetType: "form",
+			TargetID:   3,
+			Details:    map[string]any{"name": "Contact"},
+		}))
+
+		var entry audit.Entry
+		require.NoError(t, db.Last(&entry).Error)
+		require.NotNil(t, entry.ActorID)
+		assert.Equal(t, uint(7), *entry.ActorID)
+		assert.Equal(t, "ada@example.com", entry.Actor())
+		assert.Equal(t, "203.0.113.7", entry.IP)
+		assert.Equal(t, []string{"name: Contact"}, entry.DetailLines())
+	})
+
+	t.Run("without an actor the system is recorded", func(t *testing.T) {
+		require.NoError(t, audit.Log(logger, db, audit.Event{Action: audit.ActionFormDeleted}))
+		var entry audit.Entry
+		require.NoError(t, db.Last(&entry).Error)
+		assert.Nil(t, entry.ActorID)
+		assert.Equal(t, "System", entry.Actor())
+	})
+
+	t.Run("entries can't be changed or removed", func(t *testing.T) {
+		var entry audit.Entry
+		require.NoError(t, db.First(&entry).Error)
+		entry.Action = audit.ActionLogin
+		assert.ErrorIs(t, db.Save(&entry).Error, audit.ErrAppendOnly)
+		assert.ErrorIs(t, db.Delete(&entry).Error, audit.ErrAppendOnly)
+
+		var count int64
+		db.Model(&audit.Entry{}).Where("action = ?", audit.ActionFormCreated).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func TestChanges(t *testing.T) {
+	before := map[string]any{"name": "Team", "smtp_port": 587, "smtp_password": "hunter22", "api_key": ""}
+	after := map[string]any{"name": "Support", "smtp_port": 587, "smtp_password": "correct-horse", "api_key": ""}
+
+	changes := audit.Changes(before, after, "smtp_password", "api_key")
+	assert.Equal(t, map[string]any{
+		"name":          map[string]any{"from": "Team", "to": "Support"},
+		"smtp_password": map[string]any{"from": "********", "to": "********"},
+	}, changes)
+
+	created := audit.Changes(nil, after, "smtp_password", "api_key")
+	assert.Equal(t, map[string]any{"name": "Support", "smtp_port": 587, "smtp_password": "********"}, created)
+}
+
+func TestFilter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	as := func(email string) context.Context {
+		return audit.WithActor(context.Background(), audit.Actor{UserID: 1, Email: email})
+	}
+	for _, e := range []struct {
+		email  string
+		action string
+	}{
+		{"ada@example.com", audit.ActionLogin},
+		{"ada@example.com", audit.ActionFormCreated},
+		{"grace@example.com", audit.ActionFormUpdated},
+		{"grace@example.com", audit.ActionMailerUpdated},
+	} {
+		require.NoError(t, audit.Log(logger, db.WithContext(as(e.email)), audit.Event{Action: e.action}))
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		values, _ := url.ParseQuery(query)
+		entries, total, err := audit.List(db, audit.ParseFilter(values), 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(entries)), total)
+		var actions []string
+		for _, e := range entries {
+			actions = append(actions, e.Action)
+		}
+		return actions
+	}
+
+	assert.Equal(t, []string{audit.ActionMailerUpdated, audit.ActionFormUpdated, audit.ActionFormCreated, audit.ActionLogin}, list(""), "newest first")
+	assert.Equal(t, []string{audit.ActionFormUpdated, audit.ActionFormCreated}, list("action=form."))
+	assert.Equal(t, []string{audit.ActionLogin}, list("action=auth.login"))
+	assert.Equal(t, []string{audit.ActionMailerUpdated, audit.ActionFormUpdated}, list("actor=GRACE"))
+	assert.Len(t, list("action=bogus&from=yesterday"), 4, "unknown values are dropped")
+	assert.Empty(t, list("to=2000-01-01"))
+
+	filter := audit.ParseFilter(url.Values{"action": {"form."}, "actor": {"ada"}})
+	assert.Equal(t, "action=form.&actor=ada", filter.Encode())
+}
+
+func TestWriteJSON(t *testing.T) {
+	id := uint(7)
+	entries := []audit.Entry{{
+		ID:         1,
+		ActorID:    &id,
+		ActorEmail: "ada@example.com",
+		Action:     audit.ActionMailerUpdated,
+		TargetType: "mailer_profile",
+		TargetID:   2,
+		Details:    `{"api_key":{"from":"********","to":"********"}}`,
+	}}
+
+	var buf strings.Builder
+	require.NoError(t, audit.WriteJSON(&buf, entries))
+
+	var out []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(buf.String()), &out))
+	require.Len(t, out, 1)
+	assert.Equal(t, "ada@example.com", out[0]["actor_email"])
+	assert.Equal(t, "mailer_profile.updated", out[0]["action"])
+	assert.Equal(t, map[string]any{"api_key": map[string]any{"from": "********", "to": "********"}}, out[0]["details"])
+}
@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// MaxExportEntries caps how many entries one JSON export includes.
+const MaxExportEntries = 10000
+
+// exportedEntry is an entry as it appears in a JSON export.
+type exportedEntry struct {
+	ID         uint            `json:"id"`
+	CreatedAt  string          `json:"created_at"`
+	ActorID    *uint           `json:"actor_id"`
+	ActorEmail string          `json:"actor_email"`
+	IP         string          `json:"ip"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   uint            `json:"target_id,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty"`
+}
+
+// WriteJSON writes entries as a JSON array, with each entry's details as an
+// object.
+func WriteJSON(w io.Writer, entries []Entry) error {
+	out := make([]exportedEntry, len(entries))
+	for i, e := range entries {
+		out[i] = exportedEntry{
+			ID:         e.ID,
+			CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339),
+			ActorID:    e.ActorID,
+			ActorEmail: e.ActorEmail,
+			IP:         e.IP,
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+		}
+		if e.Details != "" {
+			out[i].Details = json.RawMessage(e.Details)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// filterDateLayout is the format of the From and To dates.
+const filterDateLayout = "2006-01-02"
+
+// Filter narrows the audit log. It round-trips through a query string.
+type Filter struct {
+	Action string // an action, or a prefix such as "form." for a group
+	Actor  string // part of the actor's email
+	From   string // YYYY-MM-DD, inclusive, UTC
+	To     string // YYYY-MM-DD, inclusive, UTC
+}
+
+// ParseFilter reads a filter from query parameters, dropping values it
+// doesn't recognize.
+func ParseFilter(values url.Values) Filter {
+	f := Filter{
+		Action: strings.TrimSpace(values.Get("action")),
+		Actor:  strings.ToLower(strings.TrimSpace(values.Get("actor"))),
+		From:   values.Get("from"),
+		To:     values.Get("to"),
+	}
+	if !slices.Contains(Actions, f.Action) && !slices.Contains(ActionGroups(), f.Action) {
+		f.Action = ""
+	}
+	if _, err := time.Parse(filterDateLayout, f.From); err != nil {
+		f.From = ""
+	}
+	if _, err := time.Parse(filterDateLayout, f.To); err != nil {
+		f.To = ""
+	}
+	return f
+}
+
+// ActionGroups returns the prefixes that group the actions, such as "form.".
+func ActionGroups() []string {
+	var groups []string
+	for _, action := range Actions {
+		group := action[:strings.Index(action, ".")+1]
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Values returns the filter as query parameters, leaving out empty ones.
+func (f Filter) Values() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{"action": f.Action, "actor": f.Actor, "from": f.From, "to": f.To} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+// Encode returns the filter as a query string.
+func (f Filter) Encode() string {
+	return f.Values().Encode()
+}
+
+// IsZero reports whether the filter matches everything.
+func (f Filter) IsZero() bool {
+	return f == Filter{}
+}
+
+// Apply narrows query to the entries the filter matches.
+func (f Filter) Apply(query *gorm.DB) *gorm.DB {
+	if strings.HasSuffix(f.Action, ".") {
+		query = query.Where("action LIKE ?", f.Action+"%")
+	} else if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.Actor != "" {
+		query = query.Where("actor_email LIKE ?", "%"+f.Actor+"%")
+	}
+	if from, err := time.Parse(filterDateLayout, f.From); err == nil {
+		query = query.Where("created_at >= ?", from)
+	}
+	if to, err := time.Parse(filterDateLayout, f.To); err == nil {
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+	return query
+}
+
+// List returns a page of the entries the filter matches, newest first, and
+// how many match in all.
+func List(db *gorm.DB, f Filter, limit, offset int) ([]Entry, int64, error) {
+	var total int64
+	if err := f.Apply(db.Model(&Entry{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []Entry
+	err := f.Apply(db.Model(&Entry{})).Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, total, err
+}
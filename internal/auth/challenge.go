@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChallengeTTL is how long a ceremony may take, from options to response.
+const ChallengeTTL = 5 * time.Minute
+
+// ErrChallengeExpired is returned for a missing, tampered or expired
+// challenge token.
+var ErrChallengeExpired = errors.New("auth: passkey challenge has expired")
+
+// Ceremony purposes, signed into challenge tokens so a token issued for one
+// can't be used for the other.
+const (
+	PurposeRegister = "register"
+	PurposeLogin    = "login"
+)
+
+// SealChallenge signs a ceremony's challenge, with the user it was issued to
+// (0 for sign-in, where the user isn't known yet), for the client to hold in
+// a cookie until it responds.
+func SealChallenge(secret, purpose string, userID uint, challenge []byte, expires time.Time) string {
+	payload := fmt.Sprintf("%s.%d.%s.%d", purpose, userID, base64.RawURLEncoding.EncodeToString(challenge), expires.Unix())
+	return payload + "." + challengeSignature(secret, payload)
+}
+
+// usedChallenges remembers redeemed challenges until their tokens expire, so
+// a token answered once can't be answered again, even by someone who copied
+// the cookie along with the response.
+var usedChallenges = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: map[string]time.Time{}}
+
+// RedeemChallenge verifies a challenge token for purpose and returns the user
+// and challenge it carries. The token is used up: redeeming it again fails
+// with ErrChallengeExpired.
+func RedeemChallenge(secret, purpose, token string, now time.Time) (uint, []byte, error) {
+	userID, challenge, expires, err := openChallenge(secret, purpose, token, now)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	key := base64.RawURLEncoding.EncodeToString(challenge)
+	usedChallenges.Lock()
+	defer usedChallenges.Unlock()
+	for seen, until := range usedChallenges.expires {
+		if !now.Before(until) {
+			delete(usedChallenges.expires, seen)
+		}
+	}
+	if _, used := usedChallenges.expires[key]; used {
+		return 0, nil, ErrChallengeExpired
+	}
+	usedChallenges.expires[key] = expires
+	return userID, challenge, nil
+}
+
+// openChallenge verifies a challenge token and returns its contents and
+// expiry.
+func openChallenge(secret, purpose, token string, now time.Time) (uint, []byte, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[0] != purpose {
+		return 0, nil, time.Time{}, ErrChallengeExpired
+	}
+	payload := strings.Join(parts[:4], ".")
+	if !hmac.Equal([]byte(parts[4]), []byte(challengeSignature(secret, payload))) {
+		return 0, nil, time.Time{}, ErrChallengeExpired
+	}
+	userID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, nil, time.Time{}, ErrChallengeExpired
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, time.Time{}, ErrChallengeExpired
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return 0, nil, time.Time{}, ErrChallengeExpired
+	}
+	return uint(userID), challenge, time.Unix(expires, 0), nil
+}
+
+func challengeSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("passkey:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
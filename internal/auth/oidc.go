@@ -0,0 +1,514 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthRequestTTL is how long a user may take at the identity provider, from
+// leaving the login page to coming back.
+const AuthRequestTTL = 10 * time.Minute
+
+var (
+	ErrProvider           = errors.New("auth: identity provider request failed")
+	ErrIDToken            = errors.New("auth: ID token is not valid")
+	ErrAuthRequestExpired = errors.New("auth: sign-in request has expired")
+)
+
+const (
+	// clockSkew is the leeway on ID token times for clocks that disagree.
+	clockSkew = time.Minute
+	// jwksTTL is how long signing keys are used before they are fetched
+	// again; an unknown key ID fetches sooner, but at most once per
+	// jwksMinRefresh, so forged tokens can't make us hammer the provider.
+	jwksTTL        = time.Hour
+	jwksMinRefresh = time.Minute
+	// providerResponseLimit bounds the documents read from the provider.
+	providerResponseLimit = 1 << 20
+)
+
+// Provider is an OpenID Connect provider users sign in through with the
+// authorization code flow and PKCE. Its discovery document and signing keys
+// are fetched on first use and cached.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+
+	mu            sync.Mutex
+	metadata      *providerMetadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider returns a provider for issuer. clientSecret may be empty for
+// public clients; PKCE protects the code either way.
+func NewProvider(issuer, clientID, clientSecret string) *Provider {
+	return &Provider{
+		issuer:       strings.TrimRight(strings.TrimSpace(issuer), "/"),
+		clientID:     strings.TrimSpace(clientID),
+		clientSecret: clientSecret,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthRequest is what a sign-in must remember between sending the user to
+// the provider and their return: the state that ties the callback to this
+// browser, the nonce the ID token must carry and the PKCE code verifier.
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// NewAuthRequest returns fresh random values for one sign-in.
+func NewAuthRequest() (AuthRequest, error) {
+	var values [3]string
+	for i := range values {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return AuthRequest{}, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(buf)
+	}
+	return AuthRequest{State: values[0], Nonce: values[1], CodeVerifier: values[2]}, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge for the request's verifier.
+func (r AuthRequest) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(r.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// SealAuthRequest signs a sign-in request for the client to hold in a
+// cookie until the provider sends it back.
+func SealAuthRequest(secret string, req AuthRequest, expires time.Time) string {
+	payload := fmt.Sprintf("%s.%s.%s.%d", req.State, req.Nonce, req.CodeVerifier, expires.Unix())
+	return payload + "." + authRequestSignature(secret, payload)
+}
+
+// OpenAuthRequest verifies a sealed sign-in request and returns it.
+func OpenAuthRequest(secret, token string, now time.Time) (AuthRequest, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return AuthRequest{}, ErrAuthRequestExpired
+	}
+	payload := strings.Join(parts[:4], ".")
+	if !hmac.Equal([]byte(parts[4]), []byte(authRequestSignature(secret, payload))) {
+		return AuthRequest{}, ErrAuthRequestExpired
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return AuthRequest{}, ErrAuthRequestExpired
+	}
+	return AuthRequest{State: parts[0], Nonce: parts[1], CodeVerifier: parts[2]}, nil
+}
+
+func authRequestSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("oidc:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// AuthCodeURL is where to send the user to sign in at the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, req AuthRequest, redirectURI string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	endpoint, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: authorization endpoint: %v", ErrProvider, err)
+	}
+	query := endpoint.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.clientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", "openid email profile")
+	query.Set("state", req.State)
+	query.Set("nonce", req.Nonce)
+	query.Set("code_challenge", req.CodeChallenge())
+	query.Set("code_challenge_method", "S256")
+	endpoint.RawQuery = query.Encode()
+	return endpoint.String(), nil
+}
+
+// IDClaims are the verified claims of an ID token that sign-in uses.
+type IDClaims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Exchange redeems the authorization code the provider sent back for req
+// and returns the claims of the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, req AuthRequest, code, redirectURI string, now time.Time) (*IDClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {req.CodeVerifier},
+	}
+	if p.clientSecret == "" {
+		form.Set("client_id", p.clientID)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		// client_secret_basic form-encodes both parts (RFC 6749 §2.3.1).
+		httpReq.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(httpReq, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || token.IDToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint returned %d %s %s", ErrProvider, status, token.Error, token.ErrorDescription)
+	}
+	return p.verifyIDToken(ctx, metadata.Issuer, token.IDToken, req.Nonce, now)
+}
+
+type idTokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type idTokenClaims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      audience        `json:"aud"`
+	AuthorizedFor string          `json:"azp"`
+	Expiry        int64           `json:"exp"`
+	IssuedAt      int64           `json:"iat"`
+	NotBefore     int64           `json:"nbf"`
+	Nonce         string          `json:"nonce"`
+	Email         string          `json:"email"`
+	EmailVerified json.RawMessage `json:"email_verified"`
+	Name          string          `json:"name"`
+}
+
+// audience is the aud claim, a single string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, issuer, raw, nonce string, now time.Time) (*IDClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrIDToken)
+	}
+	var header idTokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrIDToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", ErrIDToken)
+	}
+	key, err := p.signingKey(ctx, header.Kid, now)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyJWS(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, fmt.Errorf("%w: signature", ErrIDToken)
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrIDToken, err)
+	}
+	switch {
+	case claims.Issuer != issuer:
+		return nil, fmt.Errorf("%w: issuer %q", ErrIDToken, claims.Issuer)
+	case !claims.Audience.contains(p.clientID):
+		return nil, fmt.Errorf("%w: audience", ErrIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedFor != p.clientID:
+		return nil, fmt.Errorf("%w: authorized party", ErrIDToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", ErrIDToken)
+	case nonce == "" || !hmac.Equal([]byte(claims.Nonce), []byte(nonce)):
+		return nil, fmt.Errorf("%w: nonce", ErrIDToken)
+	case !now.Before(time.Unix(claims.Expiry, 0).Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrIDToken)
+	case now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrIDToken)
+	case claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)):
+		return nil, fmt.Errorf("%w: not valid yet", ErrIDToken)
+	}
+
+	return &IDClaims{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: emailVerified(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// emailVerified reads email_verified, which some providers send as the
+// string "true".
+func emailVerified(raw json.RawMessage) bool {
+	var verified bool
+	if json.Unmarshal(raw, &verified) == nil {
+		return verified
+	}
+	var s string
+	return json.Unmarshal(raw, &s) == nil && s == "true"
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifyJWS checks a JWS signature. Only the algorithms providers sign ID
+// tokens with are accepted; "none" and HMAC never are.
+func verifyJWS(alg string, key crypto.PublicKey, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, digest[:], r, s)
+	}
+	return false
+}
+
+// discover fetches and caches the provider's metadata.
+func (p *Provider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	var metadata providerMetadata
+	status, err := p.do(req, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery returned %d", ErrProvider, status)
+	}
+	// The issuer must be the one configured, so a provider can't vouch for
+	// another (OpenID Connect Discovery §4.3).
+	if strings.TrimRight(metadata.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("%w: discovery issuer %q does not match", ErrProvider, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is incomplete", ErrProvider)
+	}
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// signingKey returns the provider key with kid, fetching the key set when
+// the cached one is stale or doesn't have it.
+func (p *Provider) signingKey(ctx context.Context, kid string, now time.Time) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, found := p.lookupKey(kid)
+	age := now.Sub(p.keysFetchedAt)
+	if (found && age < jwksTTL) || (!found && age < jwksMinRefresh) {
+		if found {
+			return key, nil
+		}
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrIDToken, kid)
+	}
+
+	if err := p.fetchKeys(ctx, now); err != nil {
+		if found {
+			// Keep using a known key while the provider is unreachable.
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, found = p.lookupKey(kid); found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", ErrIDToken, kid)
+}
+
+// lookupKey finds kid in the cached key set. Tokens without a key ID are
+// accepted only when the set has a single key.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys replaces the cached key set. Called with p.mu held, after
+// discovery.
+func (p *Provider) fetchKeys(ctx context.Context, now time.Time) error {
+	if p.metadata == nil {
+		return fmt.Errorf("%w: provider not discovered", ErrProvider)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.metadata.JWKSURI, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%w: key set returned %d", ErrProvider, status)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = now
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errUnsupportedKey
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, errUnsupportedKey
+		}
+		return pub, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errUnsupportedKey
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errUnsupportedKey
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+	}
+	return nil, errUnsupportedKey
+}
+
+// do sends req and decodes a JSON response into v, whatever the status.
+func (p *Provider) do(req *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, providerResponseLimit))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: response from %s is not JSON", ErrProvider, req.URL.Host)
+	}
+	return resp.StatusCode, nil
+}
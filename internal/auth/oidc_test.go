@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"formlander/internal/pkg/oidctest"
+)
+
+const testRedirectURI = "https://forms.example.com/admin/login/sso/callback"
+
+// signIn runs one authorization at the stand-in provider and returns the
+// request and the code it sent back.
+func signIn(t *testing.T, idp *oidctest.Provider, provider *Provider) (AuthRequest, string) {
+	t.Helper()
+	req, err := NewAuthRequest()
+	if err != nil {
+		t.Fatalf("auth request: %v", err)
+	}
+	authURL, err := provider.AuthCodeURL(context.Background(), req, testRedirectURI)
+	if err != nil {
+		t.Fatalf("auth URL: %v", err)
+	}
+	callback := idp.Authorize(t, authURL)
+	if callback.Query().Get("state") != req.State {
+		t.Fatalf("state not returned: %s", callback)
+	}
+	return req, callback.Query().Get("code")
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	idp := oidctest.NewProvider(t, "formlander", "s3cret/+")
+	idp.SignIn(oidctest.Identity{Subject: "user-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"})
+	provider := NewProvider(idp.Issuer()+"/", "formlander", "s3cret/+")
+	now := time.Now()
+
+	req, code := signIn(t, idp, provider)
+	authURL, _ := provider.AuthCodeURL(context.Background(), req, testRedirectURI)
+	query, _ := url.Parse(authURL)
+	if query.Query().Get("code_challenge") != req.CodeChallenge() || query.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("expected a PKCE challenge in %s", authURL)
+	}
+
+	claims, err := provider.Exchange(context.Background(), req, code, testRedirectURI, now)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Email != "ada@example.com" || !claims.EmailVerified || claims.Issuer != idp.Issuer() {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	t.Run("codes work once", func(t *testing.T) {
+		if _, err := provider.Exchange(context.Background(), req, code, testRedirectURI, now); !errors.Is(err, ErrProvider) {
+			t.Fatalf("expected ErrProvider, got %v", err)
+		}
+	})
+
+	t.Run("the code needs its verifier", func(t *testing.T) {
+		req, code := signIn(t, idp, provider)
+		other, _ := NewAuthRequest()
+		req.CodeVerifier = other.CodeVerifier
+		if _, err := provider.Exchange(context.Background(), req, code, testRedirectURI, now); !errors.Is(err, ErrProvider) {
+			t.Fatalf("expected ErrProvider, got %v", err)
+		}
+	})
+
+	t.Run("the ID token must carry the nonce", func(t *testing.T) {
+		req, code := signIn(t, idp, provider)
+		req.Nonce = "other"
+		if _, err := provider.Exchange(context.Background(), req, code, testRedirectURI, now); !errors.Is(err, ErrIDToken) {
+			t.Fatalf("expected ErrIDToken, got %v", err)
+		}
+	})
+
+	for name, edit := range map[string]func(map[string]any){
+		"expired":              func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() },
+		"issued in the future": func(c map[string]any) { c["iat"] = now.Add(10 * time.Minute).Unix() },
+		"other audience":       func(c map[string]any) { c["aud"] = "someone-else" },
+		"other issuer":         func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"shared without azp":   func(c map[string]any) { c["aud"] = []string{"formlander", "someone-else"} },
+		"no subject":           func(c map[string]any) { c["sub"] = "" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			idp.EditClaims(edit)
+			defer idp.EditClaims(nil)
+			req, code := signIn(t, idp, provider)
+			if _, err := provider.Exchange(context.Background(), req, code, testRedirectURI, now); !errors.Is(err, ErrIDToken) {
+				t.Fatalf("expected ErrIDToken, got %v", err)
+			}
+		})
+	}
+
+	t.Run("signing keys are cached and refetched after rotation", func(t *testing.T) {
+		if n := idp.KeySetRequests(); n != 1 {
+			t.Fatalf("expected the key set to be fetched once, got %d", n)
+		}
+		idp.RotateKey(t)
+
+		req, code := signIn(t, idp, provider)
+		if _, err := provider.Exchange(context.Background(), req, code, testRedirectURI, now.Add(30*time.Second)); !errors.Is(err, ErrIDToken) {
+			t.Fatalf("expected an unknown key to wait for the refresh interval, got %v", err)
+		}
+		req, code = signIn(t, idp, provider)
+		if _, err := provider.Exchange(context.Background(), req, code, testRedirectURI, now.Add(2*time.Minute)); err != nil {
+			t.Fatalf("expected the rotated key to be fetched, got %v", err)
+		}
+		if n := idp.KeySetRequests(); n != 2 {
+			t.Fatalf("expected two key set fetches, got %d", n)
+		}
+	})
+}
+
+func TestOIDCPublicClient(t *testing.T) {
+	idp := oidctest.NewProvider(t, "formlander", "")
+	idp.SignIn(oidctest.Identity{Subject: "user-1", Email: "ada@example.com", EmailVerified: true})
+	provider := NewProvider(idp.Issuer(), "formlander", "")
+
+	req, code := signIn(t, idp, provider)
+	if _, err := provider.Exchange(context.Background(), req, code, testRedirectURI, time.Now()); err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+}
+
+func TestOIDCDiscoveryFailure(t *testing.T) {
+	idp := oidctest.NewProvider(t, "formlander", "")
+	provider := NewProvider(idp.Issuer()+"/tenant", "formlander", "")
+	req, _ := NewAuthRequest()
+	if _, err := provider.AuthCodeURL(context.Background(), req, testRedirectURI); !errors.Is(err, ErrProvider) {
+		t.Fatalf("expected ErrProvider, got %v", err)
+	}
+}
+
+func TestVerifyJWSRejectsUnsignedTokens(t *testing.T) {
+	for _, alg := range []string{"none", "HS256", ""} {
+		if verifyJWS(alg, nil, []byte("header.claims"), nil) {
+			t.Fatalf("expected alg %q to be rejected", alg)
+		}
+	}
+}
+
+func TestAuthRequestToken(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	req, _ := NewAuthRequest()
+	token := SealAuthRequest("secret", req, now.Add(AuthRequestTTL))
+
+	opened, err := OpenAuthRequest("secret", token, now)
+	if err != nil || opened != req {
+		t.Fatalf("expected request to open, got %+v %v", opened, err)
+	}
+	if _, err := OpenAuthRequest("other", token, now); !errors.Is(err, ErrAuthRequestExpired) {
+		t.Fatalf("expected another secret to be rejected, got %v", err)
+	}
+	if _, err := OpenAuthRequest("secret", token, now.Add(AuthRequestTTL)); !errors.Is(err, ErrAuthRequestExpired) {
+		t.Fatalf("expected an expired request to be rejected, got %v", err)
+	}
+}
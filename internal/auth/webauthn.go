@@ -0,0 +1,330 @@
+// Package auth holds the ways users prove who they are: password hashes,
+// WebAuthn passkeys (https://www.w3.org/TR/webauthn-2/) and OpenID Connect
+// single sign-on. Passkey attestation statements are not verified:
+// registration asks for none, which is what browsers give relying parties
+// that don't pin authenticator models.
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrInvalidResponse  = errors.New("auth: passkey response is not valid")
+	ErrChallenge        = errors.New("auth: passkey challenge does not match")
+	ErrOrigin           = errors.New("auth: passkey response is from another site")
+	ErrUserVerification = errors.New("auth: passkey did not verify the user")
+	ErrSignature        = errors.New("auth: passkey signature is not valid")
+)
+
+// challengeLength is the size of ceremony challenges in bytes.
+const challengeLength = 32
+
+// Authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// ceremonyTimeout is how long browsers are told to wait for the
+// authenticator, in milliseconds. It matches ChallengeTTL.
+const ceremonyTimeout = 300000
+
+// RelyingParty identifies this instance to authenticators. Passkeys are
+// bound to ID, the host name, and responses must come from Origin.
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// Base64URL is binary data that travels as unpadded base64url in JSON, as
+// the browser side encodes ArrayBuffers.
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// UserEntity describes the account a passkey is created for. ID is the user
+// handle authenticators return at sign-in; it must not contain personal
+// data.
+type UserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type credentialDescriptor struct {
+	Type string    `json:"type"`
+	ID   Base64URL `json:"id"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CreationOptions are the publicKey options for navigator.credentials.create.
+type CreationOptions struct {
+	Challenge Base64URL `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		RequireResident  bool   `json:"requireResidentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions are the publicKey options for navigator.credentials.get.
+// No credentials are listed: passkeys are discoverable, so the browser
+// offers the ones it holds for this site.
+type RequestOptions struct {
+	Challenge        Base64URL `json:"challenge"`
+	RPID             string    `json:"rpId"`
+	Timeout          int       `json:"timeout"`
+	UserVerification string    `json:"userVerification"`
+}
+
+// RegistrationResponse is the browser's PublicKeyCredential from create,
+// with its buffers base64url-encoded.
+type RegistrationResponse struct {
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AttestationObject Base64URL `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the browser's PublicKeyCredential from get, with its
+// buffers base64url-encoded.
+type AssertionResponse struct {
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AuthenticatorData Base64URL `json:"authenticatorData"`
+		Signature         Base64URL `json:"signature"`
+		UserHandle        Base64URL `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is a newly registered passkey, to be stored with its user.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key, as sent by the authenticator
+	SignCount uint32
+}
+
+// NewChallenge returns random bytes for one ceremony.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CreationOptions builds registration options for user. Credentials in
+// exclude are already registered, so authenticators holding one decline
+// to make another.
+func (rp RelyingParty) CreationOptions(challenge []byte, user UserEntity, exclude [][]byte) CreationOptions {
+	var opts CreationOptions
+	opts.Challenge = challenge
+	opts.RP.ID = rp.ID
+	opts.RP.Name = rp.Name
+	opts.User = user
+	opts.PubKeyCredParams = []credentialParameter{
+		{Type: "public-key", Alg: algES256},
+		{Type: "public-key", Alg: algEdDSA},
+		{Type: "public-key", Alg: algRS256},
+	}
+	opts.Timeout = ceremonyTimeout
+	opts.ExcludeCredentials = make([]credentialDescriptor, len(exclude))
+	for i, id := range exclude {
+		opts.ExcludeCredentials[i] = credentialDescriptor{Type: "public-key", ID: id}
+	}
+	opts.AuthenticatorSelection.ResidentKey = "required"
+	opts.AuthenticatorSelection.RequireResident = true
+	opts.AuthenticatorSelection.UserVerification = "required"
+	opts.Attestation = "none"
+	return opts
+}
+
+// RequestOptions builds sign-in options.
+func (rp RelyingParty) RequestOptions(challenge []byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          ceremonyTimeout,
+		UserVerification: "required",
+	}
+}
+
+// VerifyRegistration checks a create response against the challenge issued
+// for it and returns the new credential.
+func (rp RelyingParty) VerifyRegistration(challenge []byte, resp RegistrationResponse) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	item, _, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+	attestation, ok := item.(map[any]any)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	authData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+
+	data, err := rp.parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if data.flags&flagAttestedData == 0 {
+		return nil, ErrInvalidResponse
+	}
+	if !bytes.Equal(data.credentialID, resp.RawID) {
+		return nil, ErrInvalidResponse
+	}
+	if _, err := parseCOSEKey(data.publicKey); err != nil {
+		return nil, err
+	}
+	return &Credential{ID: data.credentialID, PublicKey: data.publicKey, SignCount: data.signCount}, nil
+}
+
+// VerifyAssertion checks a get response against the challenge issued for
+// it and the stored public key of the credential it names. It returns the
+// authenticator's new signature counter.
+func (rp RelyingParty) VerifyAssertion(challenge, storedKey []byte, resp AssertionResponse) (uint32, error) {
+	if resp.Type != "public-key" {
+		return 0, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	data, err := rp.parseAuthenticatorData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parseCOSEKey(storedKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, resp.Response.Signature) {
+		return 0, ErrSignature
+	}
+	return data.signCount, nil
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ErrInvalidResponse
+	}
+	if data.Type != ceremony {
+		return ErrInvalidResponse
+	}
+	got, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrChallenge
+	}
+	if data.Origin != rp.Origin || data.CrossOrigin {
+		return ErrOrigin
+	}
+	return nil
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+// parseAuthenticatorData checks the parts every ceremony shares: the
+// response is for this relying party and the user was present and
+// verified.
+func (rp RelyingParty) parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, ErrInvalidResponse
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(raw[:32], rpIDHash[:]) != 1 {
+		return nil, ErrOrigin
+	}
+	data := &authenticatorData{flags: raw[32], signCount: binary.BigEndian.Uint32(raw[33:37])}
+	if data.flags&flagUserPresent == 0 || data.flags&flagUserVerified == 0 {
+		return nil, ErrUserVerification
+	}
+
+	if data.flags&flagAttestedData != 0 {
+		rest := raw[37:]
+		// AAGUID (16 bytes), then the credential ID with its length.
+		if len(rest) < 18 {
+			return nil, ErrInvalidResponse
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, ErrInvalidResponse
+		}
+		data.credentialID = append([]byte(nil), rest[:idLength]...)
+		rest = rest[idLength:]
+
+		// The key is one CBOR item; extensions may follow it.
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrInvalidResponse
+		}
+		data.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+	}
+	return data, nil
+}
@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// encodeCBOR is the small encoder the test authenticator needs: integers,
+// byte and text strings, and maps with int or string keys.
+func encodeCBOR(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case map[any]any:
+		keys := make([]any, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return string(encodeCBOR(keys[i])) < string(encodeCBOR(keys[j])) })
+		out := head(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(out, encodeCBOR(k)...)
+			out = append(out, encodeCBOR(v[k])...)
+		}
+		return out
+	}
+	panic("unsupported CBOR value")
+}
+
+type testAuthenticator struct {
+	rpID         string
+	credentialID []byte
+	ecKey        *ecdsa.PrivateKey
+	edKey        ed25519.PrivateKey
+	signCount    uint32
+	flags        byte
+}
+
+func newTestAuthenticator(t *testing.T, rpID string) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return &testAuthenticator{rpID: rpID, credentialID: []byte("credential-1"), ecKey: key, flags: flagUserPresent | flagUserVerified}
+}
+
+func (a *testAuthenticator) coseKey() []byte {
+	if a.edKey != nil {
+		return encodeCBOR(map[any]any{coseKty: ktyOKP, coseAlg: algEdDSA, coseCrv: crvEd255, coseX: []byte(a.edKey.Public().(ed25519.PublicKey))})
+	}
+	point, _ := a.ecKey.PublicKey.Bytes()
+	return encodeCBOR(map[any]any{coseKty: ktyEC2, coseAlg: algES256, coseCrv: crvP256, coseX: point[1:33], coseY: point[33:]})
+}
+
+func (a *testAuthenticator) authData(attested bool) []byte {
+	hash := sha256.Sum256([]byte(a.rpID))
+	data := append(hash[:], a.flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data[32] |= flagAttestedData
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func clientDataJSON(ceremony string, challenge []byte, origin string) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	return data
+}
+
+func (a *testAuthenticator) create(challenge []byte, origin string) RegistrationResponse {
+	var resp RegistrationResponse
+	resp.RawID = a.credentialID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = clientDataJSON("webauthn.create", challenge, origin)
+	resp.Response.AttestationObject = encodeCBOR(map[any]any{"fmt": "none", "attStmt": map[any]any{}, "authData": a.authData(true)})
+	return resp
+}
+
+func (a *testAuthenticator) get(t *testing.T, challenge []byte, origin string) AssertionResponse {
+	a.signCount++
+	var resp AssertionResponse
+	resp.RawID = a.credentialID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = clientDataJSON("webauthn.get", challenge, origin)
+	resp.Response.AuthenticatorData = a.authData(false)
+
+	clientHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.Response.AuthenticatorData...), clientHash[:]...)
+	if a.edKey != nil {
+		resp.Response.Signature = ed25519.Sign(a.edKey, signed)
+		return resp
+	}
+	digest := sha256.Sum256(signed)
+	sig, err := ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	resp.Response.Signature = sig
+	return resp
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := RelyingParty{ID: "forms.example.com", Name: "Formlander", Origin: "https://forms.example.com"}
+
+	for _, keyType := range []string{"ES256", "EdDSA"} {
+		t.Run(keyType, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t, rp.ID)
+			if keyType == "EdDSA" {
+				_, authenticator.edKey, _ = ed25519.GenerateKey(rand.Reader)
+			}
+			challenge, err := NewChallenge()
+			if err != nil {
+				t.Fatalf("challenge: %v", err)
+			}
+
+			credential, err := rp.VerifyRegistration(challenge, authenticator.create(challenge, rp.Origin))
+			if err != nil {
+				t.Fatalf("expected registration to verify, got %v", err)
+			}
+			if string(credential.ID) != "credential-1" {
+				t.Fatalf("unexpected credential ID %q", credential.ID)
+			}
+
+			challenge, _ = NewChallenge()
+			count, err := rp.VerifyAssertion(challenge, credential.PublicKey, authenticator.get(t, challenge, rp.Origin))
+			if err != nil {
+				t.Fatalf("expected assertion to verify, got %v", err)
+			}
+			if count != 1 {
+				t.Fatalf("expected sign count 1, got %d", count)
+			}
+
+			resp := authenticator.get(t, challenge, rp.Origin)
+			resp.Response.Signature[len(resp.Response.Signature)-1] ^= 0xff
+			if _, err := rp.VerifyAssertion(challenge, credential.PublicKey, resp); !errors.Is(err, ErrSignature) {
+				t.Fatalf("expected ErrSignature for a tampered signature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCeremonyChecks(t *testing.T) {
+	rp := RelyingParty{ID: "forms.example.com", Name: "Formlander", Origin: "https://forms.example.com"}
+	authenticator := newTestAuthenticator(t, rp.ID)
+	challenge, _ := NewChallenge()
+	credential, err := rp.VerifyRegistration(challenge, authenticator.create(challenge, rp.Origin))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		want error
+		run  func() error
+	}{
+		{"other challenge", ErrChallenge, func() error {
+			other, _ := NewChallenge()
+			_, err := rp.VerifyAssertion(challenge, credential.PublicKey, authenticator.get(t, other, rp.Origin))
+			return err
+		}},
+		{"other origin", ErrOrigin, func() error {
+			_, err := rp.VerifyAssertion(challenge, credential.PublicKey, authenticator.get(t, challenge, "https://evil.example.com"))
+			return err
+		}},
+		{"other relying party", ErrOrigin, func() error {
+			phished := newTestAuthenticator(t, "evil.example.com")
+			_, err := rp.VerifyRegistration(challenge, phished.create(challenge, rp.Origin))
+			return err
+		}},
+		{"user not verified", ErrUserVerification, func() error {
+			authenticator.flags = flagUserPresent
+			defer func() { authenticator.flags = flagUserPresent | flagUserVerified }()
+			_, err := rp.VerifyAssertion(challenge, credential.PublicKey, authenticator.get(t, challenge, rp.Origin))
+			return err
+		}},
+		{"create response used to sign in", ErrInvalidResponse, func() error {
+			resp := authenticator.get(t, challenge, rp.Origin)
+			resp.Response.ClientDataJSON = clientDataJSON("webauthn.create", challenge, rp.Origin)
+			_, err := rp.VerifyAssertion(challenge, credential.PublicKey, resp)
+			return err
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.run(); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	value, rest, err := decodeCBOR(append(encodeCBOR(map[any]any{1: 2, -3: []byte{9}, "a": "b"}), 0xff))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	fields := value.(map[any]any)
+	if fields[int64(1)] != int64(2) || string(fields[int64(-3)].([]byte)) != "\x09" || fields["a"] != "b" {
+		t.Fatalf("unexpected map %#v", fields)
+	}
+	if len(rest) != 1 || rest[0] != 0xff {
+		t.Fatalf("expected trailing byte to be returned, got %x", rest)
+	}
+
+	for _, bad := range [][]byte{
+		{},
+		{0x5a, 0xff, 0xff, 0xff, 0xff}, // byte string longer than the input
+		{0x9f},                         // indefinite-length array
+		{0xa1, 0x41, 0x00, 0x00},       // map with a byte string key
+		{0xfb, 0, 0, 0, 0, 0, 0, 0, 0}, // float
+	} {
+		if _, _, err := decodeCBOR(bad); err == nil {
+			t.Fatalf("expected %x to be rejected", bad)
+		}
+	}
+}
+
+func TestChallengeToken(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	challenge, _ := NewChallenge()
+	token := SealChallenge("secret", PurposeRegister, 7, challenge, now.Add(ChallengeTTL))
+
+	for name, open := range map[string]func() error{
+		"other purpose": func() error { _, _, err := RedeemChallenge("secret", PurposeLogin, token, now); return err },
+		"other secret":  func() error { _, _, err := RedeemChallenge("other", PurposeRegister, token, now); return err },
+		"expired": func() error {
+			_, _, err := RedeemChallenge("secret", PurposeRegister, token, now.Add(ChallengeTTL))
+			return err
+		},
+	} {
+		if err := open(); !errors.Is(err, ErrChallengeExpired) {
+			t.Fatalf("%s: expected ErrChallengeExpired, got %v", name, err)
+		}
+	}
+
+	userID, opened, err := RedeemChallenge("secret", PurposeRegister, token, now)
+	if err != nil || userID != 7 || string(opened) != string(challenge) {
+		t.Fatalf("expected challenge to open, got %d %x %v", userID, opened, err)
+	}
+	if _, _, err := RedeemChallenge("secret", PurposeRegister, token, now.Add(time.Second)); !errors.Is(err, ErrChallengeExpired) {
+		t.Fatalf("expected a redeemed challenge to be refused, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/karloscodes/cartridge/config"
 	"github.com/spf13/viper"
@@ -20,8 +21,81 @@ type Config struct {
 	// Form limits.
 	MaxInputFields int `mapstructure:"maxinputfields"`
 
+	// BaseURL is the public URL of this instance (e.g. https://forms.example.com),
+	// used to build absolute links in outbound emails.
+	BaseURL string `mapstructure:"baseurl"`
+
 	// Webhook configuration.
 	Webhook WebhookConfig `mapstructure:"webhook"`
+
+	// Inbound email configuration.
+	Inbound InboundConfig `mapstructure:"inbound"`
+
+	// OpenID Connect single sign-on.
+	OIDC OIDCConfig `mapstructure:"oidc"`
+
+	// Admin session lifetimes.
+	Sessions SessionsConfig `mapstructure:"sessions"`
+}
+
+// SessionsConfig bounds how long an admin stays signed in: a session ends
+// after IdleMinutes without a request, and MaxAgeHours after sign-in at the
+// latest.
+type SessionsConfig struct {
+	IdleMinutes int `mapstructure:"idleminutes"`
+	MaxAgeHours int `mapstructure:"maxagehours"`
+}
+
+// IdleTimeout is how long a session lasts without a request.
+func (s SessionsConfig) IdleTimeout() time.Duration {
+	if s.IdleMinutes <= 0 {
+		return 12 * time.Hour
+	}
+	return time.Duration(s.IdleMinutes) * time.Minute
+}
+
+// MaxAge is how long a session lasts after sign-in, however active.
+func (s SessionsConfig) MaxAge() time.Duration {
+	if s.MaxAgeHours <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(s.MaxAgeHours) * time.Hour
+}
+
+// OIDCConfig configures sign-in through an OpenID Connect provider. It is
+// off until an issuer and client ID are set. Only users whose email domain
+// is in AllowedDomains may sign in this way; without it, SSO is limited to
+// existing accounts and nobody is provisioned.
+type OIDCConfig struct {
+	Issuer         string `mapstructure:"issuer"`
+	ClientID       string `mapstructure:"clientid"`
+	ClientSecret   string `mapstructure:"clientsecret"`
+	AllowedDomains string `mapstructure:"alloweddomains"` // comma-separated
+	DefaultRole    string `mapstructure:"defaultrole"`    // role of provisioned users
+	ButtonLabel    string `mapstructure:"buttonlabel"`
+}
+
+// Enabled reports whether SSO is configured.
+func (o OIDCConfig) Enabled() bool {
+	return strings.TrimSpace(o.Issuer) != "" && strings.TrimSpace(o.ClientID) != ""
+}
+
+// Domains returns the allowed email domains, lowercased.
+func (o OIDCConfig) Domains() []string {
+	var domains []string
+	for _, domain := range strings.Split(o.AllowedDomains, ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// InboundConfig configures the inbound email gateway. The endpoint is
+// disabled until a signing key is set.
+type InboundConfig struct {
+	SigningKey string `mapstructure:"signingkey"`
 }
 
 // WebhookConfig configures outbound webhook delivery.
@@ -63,14 +137,36 @@ func Get() *Config {
 
 		// Set formlander-specific defaults
 		v.SetDefault("maxinputfields", 200)
+		v.SetDefault("baseurl", "")
+		_ = v.BindEnv("baseurl", "FORMLANDER_BASE_URL")
 		v.SetDefault("webhook.signatureheader", "X-Formlander-Signature")
 		v.SetDefault("webhook.retrylimit", 3)
 		v.SetDefault("webhook.backoffschedule", "1,5,15,60")
+		v.SetDefault("inbound.signingkey", "")
+		_ = v.BindEnv("inbound.signingkey", "FORMLANDER_INBOUND_SIGNING_KEY")
+		v.SetDefault("oidc.issuer", "")
+		_ = v.BindEnv("oidc.issuer", "FORMLANDER_OIDC_ISSUER")
+		v.SetDefault("oidc.clientid", "")
+		_ = v.BindEnv("oidc.clientid", "FORMLANDER_OIDC_CLIENT_ID")
+		v.SetDefault("oidc.clientsecret", "")
+		_ = v.BindEnv("oidc.clientsecret", "FORMLANDER_OIDC_CLIENT_SECRET")
+		v.SetDefault("oidc.alloweddomains", "")
+		_ = v.BindEnv("oidc.alloweddomains", "FORMLANDER_OIDC_ALLOWED_DOMAINS")
+		v.SetDefault("oidc.defaultrole", "viewer")
+		_ = v.BindEnv("oidc.defaultrole", "FORMLANDER_OIDC_DEFAULT_ROLE")
+		v.SetDefault("oidc.buttonlabel", "Sign in with SSO")
+		_ = v.BindEnv("oidc.buttonlabel", "FORMLANDER_OIDC_BUTTON_LABEL")
+		v.SetDefault("sessions.idleminutes", 720)
+		_ = v.BindEnv("sessions.idleminutes", "FORMLANDER_SESSION_IDLE_MINUTES")
+		v.SetDefault("sessions.maxagehours", 168)
+		_ = v.BindEnv("sessions.maxagehours", "FORMLANDER_SESSION_MAX_AGE_HOURS")
 
 		cfgInst = &Config{Config: base}
 		if err := v.Unmarshal(cfgInst); err != nil {
 			log.Fatalf("config: failed to unmarshal: %v", err)
 		}
+		// The session cookie lives as long as the longest session.
+		base.SessionTimeout = int(cfgInst.Sessions.MaxAge().Seconds())
 	})
 	return cfgInst
 }
@@ -97,6 +193,16 @@ func (c *Config) WebhookBackoff() []int {
 	return backoff
 }
 
+// AbsoluteURL joins path onto BaseURL. Without a configured base URL the path
+// is returned unchanged.
+func (c *Config) AbsoluteURL(path string) string {
+	base := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/")
+	if base == "" {
+		return path
+	}
+	return base + path
+}
+
 // Reset clears the cached configuration; intended for tests.
 func Reset() {
 	cfgOnce = sync.Once{}
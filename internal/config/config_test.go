@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfig(t *testing.T) {
@@ -141,6 +143,49 @@ func TestEnvironmentVariableOverrides(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name:     "FORMLANDER_OIDC_ALLOWED_DOMAINS",
+			envVar:   "FORMLANDER_OIDC_ALLOWED_DOMAINS",
+			envValue: " Example.com, @corp.example.com ,",
+			setup: func() {
+				os.Setenv("FORMLANDER_ENV", "development")
+				os.Setenv("FORMLANDER_OIDC_ISSUER", "https://idp.example.com")
+				os.Setenv("FORMLANDER_OIDC_CLIENT_ID", "formlander")
+			},
+			check: func(c *Config) error {
+				if !c.OIDC.Enabled() {
+					t.Error("Expected OIDC to be enabled with an issuer and client ID")
+				}
+				if got := strings.Join(c.OIDC.Domains(), ","); got != "example.com,corp.example.com" {
+					t.Errorf("Expected domains example.com,corp.example.com, got %s", got)
+				}
+				if c.OIDC.DefaultRole != "viewer" {
+					t.Errorf("Expected DefaultRole=viewer, got %s", c.OIDC.DefaultRole)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "FORMLANDER_SESSION_IDLE_MINUTES",
+			envVar:   "FORMLANDER_SESSION_IDLE_MINUTES",
+			envValue: "30",
+			setup: func() {
+				os.Setenv("FORMLANDER_ENV", "development")
+				os.Setenv("FORMLANDER_SESSION_MAX_AGE_HOURS", "24")
+			},
+			check: func(c *Config) error {
+				if c.Sessions.IdleTimeout() != 30*time.Minute {
+					t.Errorf("Expected IdleTimeout=30m, got %s", c.Sessions.IdleTimeout())
+				}
+				if c.Sessions.MaxAge() != 24*time.Hour {
+					t.Errorf("Expected MaxAge=24h, got %s", c.Sessions.MaxAge())
+				}
+				if c.SessionTimeout != 24*60*60 {
+					t.Errorf("Expected the session cookie to last 24h, got %ds", c.SessionTimeout)
+				}
+				return nil
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -4,6 +4,7 @@ import (
 	"gorm.io/gorm"
 
 	"formlander/internal/accounts"
+	"formlander/internal/audit"
 	"formlander/internal/forms"
 	"formlander/internal/integrations"
 )
@@ -12,15 +13,30 @@ import (
 func Migrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&accounts.User{},
+		&accounts.Invitation{},
+		&accounts.RecoveryCode{},
+		&accounts.Passkey{},
+		&accounts.Identity{},
+		&accounts.PasswordReset{},
+		&accounts.Session{},
 		&accounts.Settings{},
 		&integrations.MailerProfile{},
 		&integrations.CaptchaProfile{},
 		&forms.Form{},
 		&forms.WebhookDelivery{},
 		&forms.EmailDelivery{},
+		&forms.AllFormsDigest{},
 		&forms.Submission{},
 		&forms.WebhookEvent{},
 		&forms.EmailEvent{},
 		&forms.SubmissionFile{},
+		&forms.RoutingRule{},
+		&forms.SubmissionNote{},
+		&forms.SubmissionReply{},
+		&forms.SavedView{},
+		&forms.FormGrant{},
+		&forms.SubmissionRollup{},
+		&forms.FormView{},
+		&audit.Entry{},
 	)
 }
@@ -0,0 +1,90 @@
+package forms
+
+import (
+	"log/slog"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/audit"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// grantedForms selects the IDs of the forms granted to a user.
+const grantedForms = "SELECT form_id FROM form_grants WHERE user_id = ?"
+
+// ScopeForms narrows a forms query to those user may see.
+func ScopeForms(query *gorm.DB, user *accounts.User) *gorm.DB {
+	if user.SeesAllForms() {
+		return query
+	}
+	return query.Where("forms.id IN ("+grantedForms+")", user.ID)
+}
+
+// ScopeSubmissions narrows a submissions query to the forms user may see.
+func ScopeSubmissions(query *gorm.DB, user *accounts.User) *gorm.DB {
+	if user.SeesAllForms() {
+		return query
+	}
+	return query.Where("submissions.form_id IN ("+grantedForms+")", user.ID)
+}
+
+// CanAccessForm reports whether user may see formID and its submissions.
+// Whether they may also change them is up to their role.
+func CanAccessForm(db *gorm.DB, user *accounts.User, formID uint) (bool, error) {
+	if user.SeesAllForms() {
+		return true, nil
+	}
+	var count int64
+	err := db.Model(&FormGrant{}).Where("user_id = ? AND form_id = ?", user.ID, formID).Count(&count).Error
+	return count > 0, err
+}
+
+// GrantedFormIDs lists the forms granted to a user.
+func GrantedFormIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&FormGrant{}).Where("user_id = ?", userID).Order("form_id ASC").Pluck("form_id", &ids).Error
+	return ids, err
+}
+
+// SetFormGrants replaces the forms granted to a user. Unknown form IDs are
+// ignored. Changes are recorded in the audit log.
+func SetFormGrants(logger *slog.Logger, db *gorm.DB, userID uint, formIDs []uint) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		before, err := GrantedFormIDs(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&FormGrant{}).Error; err != nil {
+			return err
+		}
+		existing := []uint{}
+		if len(formIDs) > 0 {
+			if err := tx.Model(&Form{}).Where("id IN ?", formIDs).Pluck("id", &existing).Error; err != nil {
+				return err
+			}
+		}
+		if len(existing) > 0 {
+			grants := make([]FormGrant, len(existing))
+			for i, formID := range existing {
+				grants[i] = FormGrant{UserID: userID, FormID: formID}
+			}
+			if err := tx.Create(&grants).Error; err != nil {
+				return err
+			}
+		}
+
+		sort.Slice(existing, func(i, j int) bool { return existing[i] < existing[j] })
+		changes := audit.Changes(map[string]any{"form_ids": before}, map[string]any{"form_ids": existing})
+		if len(changes) == 0 {
+			return nil
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionUserFormGrantsChanged,
+			TargetType: "user",
+			TargetID:   userID,
+			Details:    changes,
+		})
+	})
+}
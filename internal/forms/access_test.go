@@ -0,0 +1,98 @@
+package forms_test
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"formlander/internal/accounts"
+	"formlander/internal/audit"
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormGrants(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	admin := &accounts.User{Email: "admin@example.com", PasswordHash: "x", Role: accounts.RoleAdmin}
+	viewer := &accounts.User{Email: "viewer@example.com", PasswordHash: "x", Role: accounts.RoleViewer}
+	require.NoError(t, db.Create(admin).Error)
+	require.NoError(t, db.Create(viewer).Error)
+	granted := &forms.Form{Name: "Granted", Slug: "granted"}
+	other := &forms.Form{Name: "Other", Slug: "other"}
+	require.NoError(t, db.Create(granted).Error)
+	require.NoError(t, db.Create(other).Error)
+	require.NoError(t, db.Create(&forms.Submission{FormID: granted.ID, DataJSON: "{}"}).Error)
+	require.NoError(t, db.Create(&forms.Submission{FormID: other.ID, DataJSON: "{}"}).Error)
+
+	visibleSubmissions := func(user *accounts.User) int64 {
+		var count int64
+		require.NoError(t, forms.ScopeSubmissions(db.Model(&forms.Submission{}), user).Count(&count).Error)
+		return count
+	}
+
+	t.Run("without grants a viewer sees nothing", func(t *testing.T) {
+		assert.Zero(t, visibleSubmissions(viewer))
+		list, err := forms.List(forms.ScopeForms(db, viewer))
+		require.NoError(t, err)
+		assert.Empty(t, list)
+	})
+
+	t.Run("grants scope forms and submissions", func(t *testing.T) {
+		require.NoError(t, forms.SetFormGrants(logger, db, viewer.ID, []uint{granted.ID, 9999}))
+		ids, err := forms.GrantedFormIDs(db, viewer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{granted.ID}, ids, "unknown forms are ignored")
+
+		assert.Equal(t, int64(1), visibleSubmissions(viewer))
+		list, err := forms.List(forms.ScopeForms(db, viewer))
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, granted.ID, list[0].ID)
+
+		ok, err := forms.CanAccessForm(db, viewer, granted.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = forms.CanAccessForm(db, viewer, other.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("admins see every form", func(t *testing.T) {
+		assert.Equal(t, int64(2), visibleSubmissions(admin))
+		ok, err := forms.CanAccessForm(db, admin, other.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("grant changes are audited", func(t *testing.T) {
+		var entry audit.Entry
+		require.NoError(t, db.Where("action = ?", audit.ActionUserFormGrantsChanged).Last(&entry).Error)
+		assert.Equal(t, viewer.ID, entry.TargetID)
+		assert.Equal(t, []string{fmt.Sprintf("form_ids: [] → [%d]", granted.ID)}, entry.DetailLines())
+
+		var before int64
+		db.Model(&audit.Entry{}).Count(&before)
+		require.NoError(t, forms.SetFormGrants(logger, db, viewer.ID, []uint{granted.ID}))
+		var after int64
+		db.Model(&audit.Entry{}).Count(&after)
+		assert.Equal(t, before, after, "saving the same grants records nothing")
+	})
+
+	t.Run("replacing and detaching clear grants", func(t *testing.T) {
+		require.NoError(t, forms.SetFormGrants(logger, db, viewer.ID, []uint{other.ID}))
+		ids, err := forms.GrantedFormIDs(db, viewer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{other.ID}, ids)
+
+		require.NoError(t, forms.DetachUser(db, viewer.ID))
+		ids, err = forms.GrantedFormIDs(db, viewer.ID)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
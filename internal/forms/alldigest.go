@@ -0,0 +1,147 @@
+package forms
+
+import (
+	"errors"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// AllFormsDigest is the instance-wide digest: one scheduled summary of every
+// form's submissions, sent to a single address. It runs alongside each form's
+// own email forwarding, and there is at most one row.
+type AllFormsDigest struct {
+	ID              uint                        `gorm:"primaryKey"`
+	MailerProfileID *uint                       `gorm:"index"`
+	MailerProfile   *integrations.MailerProfile `gorm:"constraint:OnDelete:SET NULL"`
+	Recipient       string                      `gorm:"size:255"`
+	DigestMode      string                      `gorm:"size:16;not null;default:'instant'"` // instant (off) | daily | weekly
+	DigestHour      int                         `gorm:"not null;default:9"`
+	DigestWeekday   int                         `gorm:"not null;default:1"`
+	DigestTimezone  string                      `gorm:"size:64"`
+	LastDigestAt    *time.Time                  // End of the last digest window that was sent
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// AllFormsDigestParams holds the settings for the all-forms digest.
+type AllFormsDigestParams struct {
+	MailerProfileID *uint
+	Recipient       string
+	DigestMode      string
+	DigestHour      int
+	DigestWeekday   int
+	DigestTimezone  string
+}
+
+// Enabled reports whether the all-forms digest is switched on.
+func (d *AllFormsDigest) Enabled() bool {
+	return d.Schedule().IsDigest()
+}
+
+// ProfileID returns the chosen mailer profile's ID, or 0 when none is set.
+func (d *AllFormsDigest) ProfileID() uint {
+	return derefUint(d.MailerProfileID)
+}
+
+// Schedule returns the digest's timing as an email delivery, so both kinds of
+// digest share one set of window calculations.
+func (d *AllFormsDigest) Schedule() *EmailDelivery {
+	return &EmailDelivery{
+		DigestMode:     d.DigestMode,
+		DigestHour:     d.DigestHour,
+		DigestWeekday:  d.DigestWeekday,
+		DigestTimezone: d.DigestTimezone,
+	}
+}
+
+// GetAllFormsDigest returns the all-forms digest settings, switched off when
+// they were never saved.
+func GetAllFormsDigest(db *gorm.DB) (*AllFormsDigest, error) {
+	var digest AllFormsDigest
+	err := db.Order("id ASC").First(&digest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &AllFormsDigest{DigestMode: DigestModeInstant, DigestHour: 9, DigestWeekday: 1}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+// UpdateAllFormsDigest saves the all-forms digest settings. Turning the
+// digest on starts its first window now.
+func UpdateAllFormsDigest(logger *slog.Logger, db *gorm.DB, params AllFormsDigestParams) (*AllFormsDigest, error) {
+	mode, timezone, err := normalizeDigestSettings(params.DigestMode, params.DigestHour, params.DigestWeekday, params.DigestTimezone)
+	if err != nil {
+		return nil, err
+	}
+	recipient := strings.TrimSpace(params.Recipient)
+	if mode != DigestModeInstant {
+		if params.MailerProfileID == nil || recipient == "" {
+			return nil, &ValidationError{Field: "digest", Message: "Mailer profile and recipient required for the all-forms digest"}
+		}
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return nil, &ValidationError{Field: "digest_recipient", Message: "Recipient must be a valid email address"}
+		}
+	}
+
+	current, err := GetAllFormsDigest(db)
+	if err != nil {
+		return nil, err
+	}
+	digest := *current
+	digest.MailerProfileID = params.MailerProfileID
+	digest.Recipient = recipient
+	digest.DigestMode = mode
+	digest.DigestHour = params.DigestHour
+	digest.DigestWeekday = params.DigestWeekday
+	digest.DigestTimezone = timezone
+	if digest.Enabled() && !current.Enabled() {
+		now := time.Now().UTC()
+		digest.LastDigestAt = &now
+	}
+
+	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := tx.Omit("MailerProfile").Save(&digest).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionAllFormsDigestChanged,
+			TargetType: "mailer_profile",
+			TargetID:   derefUint(digest.MailerProfileID),
+			Details:    map[string]any{"mode": mode, "recipient": recipient},
+		})
+	}); err != nil {
+		logger.Error("failed to update all-forms digest", slog.Any("error", err))
+		return nil, err
+	}
+	return &digest, nil
+}
+
+// GetAllSubmissionsBetween retrieves every form's submissions received in
+// [start, end), with their forms, counted the same way as GetSubmissionsBetween.
+func GetAllSubmissionsBetween(db *gorm.DB, start, end time.Time) ([]Submission, error) {
+	var submissions []Submission
+	if err := db.Preload("Form").
+		Where("COALESCE(confirmed_at, created_at) >= ? AND COALESCE(confirmed_at, created_at) < ?", start, end).
+		Order("form_id ASC, COALESCE(confirmed_at, created_at) ASC").
+		Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+func derefUint(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
@@ -0,0 +1,670 @@
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/pkg/dbtxn"
+)
+
+// Analytics intervals.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+// RollupWindow is how far back the rollup job recomputes hours it already
+// stored, so deliveries that settle late are still counted.
+const RollupWindow = 24 * time.Hour
+
+// analyticsTopLimit caps the origin, user agent and field value lists.
+const analyticsTopLimit = 8
+
+// rollupHourFormat is how SQLite buckets timestamps by hour.
+const rollupHourFormat = "2006-01-02 15:04:05"
+
+// analyticsRanges maps the selectable ranges to their length and whether
+// hourly buckets are offered.
+var analyticsRanges = map[string]struct {
+	hours  int
+	hourly bool
+}{
+	"24h": {24, true},
+	"7d":  {7 * 24, true},
+	"30d": {30 * 24, false},
+	"90d": {90 * 24, false},
+}
+
+// AnalyticsRange is the window and bucket size of an analytics page.
+type AnalyticsRange struct {
+	Name     string // 24h | 7d | 30d | 90d
+	Interval string // hour | day
+	From     time.Time
+	To       time.Time
+}
+
+// ParseAnalyticsRange resolves a range and interval from the query string,
+// defaulting to 30 days by day. Hourly buckets are only offered up to 7 days;
+// 24h defaults to them.
+func ParseAnalyticsRange(name, interval string, now time.Time) AnalyticsRange {
+	spec, ok := analyticsRanges[name]
+	if !ok {
+		name, spec = "30d", analyticsRanges["30d"]
+	}
+	switch {
+	case interval == IntervalHour && spec.hourly:
+	case interval == "" && name == "24h":
+		interval = IntervalHour
+	default:
+		interval = IntervalDay
+	}
+
+	now = now.UTC()
+	r := AnalyticsRange{Name: name, Interval: interval, To: now}
+	if interval == IntervalHour {
+		r.From = now.Truncate(time.Hour).Add(-time.Duration(spec.hours-1) * time.Hour)
+	} else {
+		r.From = startOfDay(now).AddDate(0, 0, -(spec.hours/24 - 1))
+	}
+	return r
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// AnalyticsTotals sums submission and delivery counts over a range.
+type AnalyticsTotals struct {
+	Submissions      int
+	Spam             int
+	WebhookDelivered int
+	WebhookFailed    int
+	WebhookLatencyMs int64
+	EmailDelivered   int
+	EmailFailed      int
+	EmailLatencyMs   int64
+}
+
+func (t *AnalyticsTotals) add(r *SubmissionRollup) {
+	t.Submissions += r.Submissions
+	t.Spam += r.Spam
+	t.WebhookDelivered += r.WebhookDelivered
+	t.WebhookFailed += r.WebhookFailed
+	t.WebhookLatencyMs += r.WebhookLatencyMs
+	t.EmailDelivered += r.EmailDelivered
+	t.EmailFailed += r.EmailFailed
+	t.EmailLatencyMs += r.EmailLatencyMs
+}
+
+// SpamRate returns the share of submissions flagged as spam.
+func (t AnalyticsTotals) SpamRate() string {
+	return percent(t.Spam, t.Submissions)
+}
+
+// WebhookSuccessRate returns the share of settled webhook deliveries that
+// succeeded.
+func (t AnalyticsTotals) WebhookSuccessRate() string {
+	return percent(t.WebhookDelivered, t.WebhookDelivered+t.WebhookFailed)
+}
+
+// EmailSuccessRate returns the share of settled email deliveries that
+// succeeded.
+func (t AnalyticsTotals) EmailSuccessRate() string {
+	return percent(t.EmailDelivered, t.EmailDelivered+t.EmailFailed)
+}
+
+// WebhookLatency returns the mean time from submission to webhook delivery.
+func (t AnalyticsTotals) WebhookLatency() string {
+	return meanLatency(t.WebhookLatencyMs, t.WebhookDelivered)
+}
+
+// EmailLatency returns the mean time from submission to email delivery.
+func (t AnalyticsTotals) EmailLatency() string {
+	return meanLatency(t.EmailLatencyMs, t.EmailDelivered)
+}
+
+func percent(part, whole int) string {
+	if whole == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(whole))
+}
+
+func meanLatency(totalMs int64, count int) string {
+	if count == 0 {
+		return "—"
+	}
+	mean := time.Duration(totalMs/int64(count)) * time.Millisecond
+	if mean < time.Second {
+		return mean.String()
+	}
+	return mean.Round(100 * time.Millisecond).String()
+}
+
+// AnalyticsPoint is one bucket of the submissions series. Height is the
+// bucket's share of the busiest bucket, for drawing bars. Views are counted
+// per day, so they are only set for daily buckets.
+type AnalyticsPoint struct {
+	Start       time.Time
+	Submissions int
+	Spam        int
+	Views       int
+	Height      int
+}
+
+// SpamHeight returns the spam share of the bucket, for stacking bars.
+func (p AnalyticsPoint) SpamHeight() int {
+	if p.Submissions == 0 {
+		return 0
+	}
+	return p.Spam * 100 / p.Submissions
+}
+
+// AnalyticsCount is one row of a top-N list. Share is a percentage of the
+// list's base count. Views is only set for origins.
+type AnalyticsCount struct {
+	Label string
+	Count int
+	Share int
+	Views int
+}
+
+// ConversionRate returns the share of the row's views that led to a
+// submission.
+func (c AnalyticsCount) ConversionRate() string {
+	return percent(c.Count, c.Views)
+}
+
+// FieldBreakdown counts the chosen values of a select, radio or checkbox
+// field. Responses is how many submissions answered it; checkbox groups can
+// add up to more than that.
+type FieldBreakdown struct {
+	Name      string
+	Label     string
+	Responses int
+	Values    []AnalyticsCount
+}
+
+// Analytics is the analytics page of one form.
+type Analytics struct {
+	Range      AnalyticsRange
+	Series     []AnalyticsPoint
+	Totals     AnalyticsTotals
+	Conversion Conversion
+	Origins    []AnalyticsCount
+	UserAgents []AnalyticsCount
+	Fields     []FieldBreakdown
+}
+
+// FormAnalytics computes a form's analytics over a range. Complete hours come
+// from the rollup table; hours the rollup job hasn't reached are aggregated
+// from submissions directly.
+func FormAnalytics(db *gorm.DB, form *Form, r AnalyticsRange) (*Analytics, error) {
+	cutoff := r.From
+	var last SubmissionRollup
+	err := db.Where("form_id = ?", form.ID).Order("hour DESC").First(&last).Error
+	switch {
+	case err == nil:
+		if next := last.Hour.UTC().Add(time.Hour); next.After(cutoff) {
+			cutoff = next
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+	if cutoff.After(r.To) {
+		cutoff = r.To
+	}
+
+	var rollups []SubmissionRollup
+	if cutoff.After(r.From) {
+		if err := db.Where("form_id = ? AND hour >= ? AND hour < ?", form.ID, r.From, cutoff).Find(&rollups).Error; err != nil {
+			return nil, err
+		}
+	}
+	live, err := aggregateHours(db, form.ID, cutoff, r.To)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range live {
+		rollups = append(rollups, *row)
+	}
+
+	a := &Analytics{Range: r}
+	a.Series = buildSeries(r, rollups)
+	for i := range rollups {
+		a.Totals.add(&rollups[i])
+	}
+
+	conversion, err := ConversionStats(db, []uint{form.ID}, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	a.Conversion = conversion[form.ID]
+	if r.Interval == IntervalDay {
+		views, err := viewsByDay(db, form.ID, r.From, r.To)
+		if err != nil {
+			return nil, err
+		}
+		for i := range a.Series {
+			a.Series[i].Views = views[a.Series[i].Start]
+		}
+	}
+
+	if a.Origins, err = topOrigins(db, form.ID, r); err != nil {
+		return nil, err
+	}
+	if a.UserAgents, err = topUserAgents(db, form.ID, r); err != nil {
+		return nil, err
+	}
+	if a.Fields, err = fieldBreakdowns(db, form, r); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// buildSeries spreads hourly rows over the range's buckets, including empty
+// ones.
+func buildSeries(r AnalyticsRange, rows []SubmissionRollup) []AnalyticsPoint {
+	step := func(t time.Time) time.Time { return t.Add(time.Hour) }
+	bucket := func(t time.Time) time.Time { return t.UTC().Truncate(time.Hour) }
+	if r.Interval == IntervalDay {
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+		bucket = func(t time.Time) time.Time { return startOfDay(t.UTC()) }
+	}
+
+	var points []AnalyticsPoint
+	index := map[time.Time]int{}
+	for t := r.From; t.Before(r.To); t = step(t) {
+		index[t] = len(points)
+		points = append(points, AnalyticsPoint{Start: t})
+	}
+	for _, row := range rows {
+		if i, ok := index[bucket(row.Hour)]; ok {
+			points[i].Submissions += row.Submissions
+			points[i].Spam += row.Spam
+		}
+	}
+
+	peak := 0
+	for _, p := range points {
+		peak = max(peak, p.Submissions)
+	}
+	for i := range points {
+		if peak > 0 {
+			points[i].Height = points[i].Submissions * 100 / peak
+		}
+	}
+	return points
+}
+
+// RefreshRollups recomputes the rollup hours of every form from just before
+// its latest stored hour (at most RollupWindow back) through the last
+// complete hour. Forms without rollups are backfilled from their first
+// submission.
+func RefreshRollups(logger *slog.Logger, db *gorm.DB, now time.Time) error {
+	to := now.UTC().Truncate(time.Hour)
+
+	var formIDs []uint
+	if err := db.Model(&Form{}).Pluck("id", &formIDs).Error; err != nil {
+		return err
+	}
+	for _, formID := range formIDs {
+		var from time.Time
+		var last SubmissionRollup
+		err := db.Where("form_id = ?", formID).Order("hour DESC").First(&last).Error
+		switch {
+		case err == nil:
+			from = last.Hour.UTC().Add(time.Hour)
+			if recent := to.Add(-RollupWindow); recent.Before(from) {
+				from = recent
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			var first Submission
+			err := db.Select("created_at").Where("form_id = ?", formID).Order("created_at ASC").First(&first).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			from = first.CreatedAt.UTC().Truncate(time.Hour)
+		default:
+			return err
+		}
+
+		if from.Before(to) {
+			if err := RollupSubmissions(logger, db, formID, from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RollupSubmissions replaces a form's rollup rows for the hours in
+// [from, to) with fresh aggregates. Hours without submissions store no row.
+func RollupSubmissions(logger *slog.Logger, db *gorm.DB, formID uint, from, to time.Time) error {
+	from = from.UTC().Truncate(time.Hour)
+	to = to.UTC().Truncate(time.Hour)
+	rows, err := aggregateHours(db, formID, from, to)
+	if err != nil {
+		return err
+	}
+
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := tx.Where("form_id = ? AND hour >= ? AND hour < ?", formID, from, to).Delete(&SubmissionRollup{}).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			row.ID = 0
+			if err := tx.Create(row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("submission rollup refreshed",
+		slog.Uint64("form_id", uint64(formID)),
+		slog.Time("from", from),
+		slog.Time("to", to),
+		slog.Int("hours", len(rows)),
+	)
+	return nil
+}
+
+// aggregateHours counts a form's submissions and their deliveries per hour
+// of submission in [from, to). Only hours with submissions are returned.
+func aggregateHours(db *gorm.DB, formID uint, from, to time.Time) (map[time.Time]*SubmissionRollup, error) {
+	rows := map[time.Time]*SubmissionRollup{}
+	if !from.Before(to) {
+		return rows, nil
+	}
+	from, to = from.UTC(), to.UTC()
+	row := func(hour string) (*SubmissionRollup, error) {
+		t, err := time.Parse(rollupHourFormat, hour)
+		if err != nil {
+			return nil, fmt.Errorf("parse rollup hour %q: %w", hour, err)
+		}
+		if rows[t] == nil {
+			rows[t] = &SubmissionRollup{FormID: formID, Hour: t}
+		}
+		return rows[t], nil
+	}
+
+	var counts []struct {
+		Hour        string
+		Submissions int
+		Spam        int
+	}
+	err := db.Raw(`SELECT strftime('%Y-%m-%d %H:00:00', created_at) AS hour,
+			COUNT(*) AS submissions,
+			SUM(CASE WHEN is_spam THEN 1 ELSE 0 END) AS spam
+		FROM submissions
+		WHERE form_id = ? AND created_at >= ? AND created_at < ?
+		GROUP BY hour`, formID, from, to).Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		r, err := row(c.Hour)
+		if err != nil {
+			return nil, err
+		}
+		r.Submissions, r.Spam = c.Submissions, c.Spam
+	}
+
+	for _, table := range []string{"webhook_events", "email_events"} {
+		var deliveries []struct {
+			Hour      string
+			Delivered int
+			Failed    int
+			LatencyMs int64
+		}
+		err := db.Raw(`SELECT strftime('%Y-%m-%d %H:00:00', s.created_at) AS hour,
+				SUM(CASE WHEN e.status = ? THEN 1 ELSE 0 END) AS delivered,
+				SUM(CASE WHEN e.status = ? THEN 1 ELSE 0 END) AS failed,
+				SUM(CASE WHEN e.status = ? AND e.last_attempt_at IS NOT NULL
+					THEN MAX(CAST(ROUND((julianday(e.last_attempt_at) - julianday(s.created_at)) * 86400000) AS INTEGER), 0)
+					ELSE 0 END) AS latency_ms
+			FROM `+table+` e
+			JOIN submissions s ON s.id = e.submission_id
+			WHERE s.form_id = ? AND s.created_at >= ? AND s.created_at < ?
+			GROUP BY hour`,
+			WebhookStatusDelivered, WebhookStatusFailed, WebhookStatusDelivered, formID, from, to).Scan(&deliveries).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deliveries {
+			r, err := row(d.Hour)
+			if err != nil {
+				return nil, err
+			}
+			if table == "webhook_events" {
+				r.WebhookDelivered, r.WebhookFailed, r.WebhookLatencyMs = d.Delivered, d.Failed, d.LatencyMs
+			} else {
+				r.EmailDelivered, r.EmailFailed, r.EmailLatencyMs = d.Delivered, d.Failed, d.LatencyMs
+			}
+		}
+	}
+	return rows, nil
+}
+
+// topOrigins lists the domains that sent the most non-spam submissions,
+// with their views. Origins that were viewed but never submitted from are
+// kept: the form may be broken there.
+func topOrigins(db *gorm.DB, formID uint, r AnalyticsRange) ([]AnalyticsCount, error) {
+	var rows []struct {
+		Origin string
+		Count  int
+	}
+	err := db.Model(&Submission{}).
+		Select("origin, COUNT(*) AS count").
+		Where("form_id = ? AND is_spam = ? AND created_at >= ? AND created_at < ?", formID, false, r.From, r.To).
+		Group("origin").
+		Order("count DESC").
+		Limit(originScanLimit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	views, err := viewsByOrigin(db, formID, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+
+	byOrigin := map[string]*AnalyticsCount{}
+	entry := func(origin string) *AnalyticsCount {
+		if byOrigin[origin] == nil {
+			label := origin
+			if label == "" {
+				label = "Direct / unknown"
+			}
+			byOrigin[origin] = &AnalyticsCount{Label: label}
+		}
+		return byOrigin[origin]
+	}
+	total := 0
+	for _, row := range rows {
+		entry(row.Origin).Count = row.Count
+		total += row.Count
+	}
+	for origin, n := range views {
+		entry(origin).Views = n
+	}
+
+	counts := make([]AnalyticsCount, 0, len(byOrigin))
+	for _, c := range byOrigin {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := max(counts[i].Count, counts[i].Views), max(counts[j].Count, counts[j].Views)
+		if a != b {
+			return a > b
+		}
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Label < counts[j].Label
+	})
+	if len(counts) > analyticsTopLimit {
+		counts = counts[:analyticsTopLimit]
+	}
+	return withShares(counts, total), nil
+}
+
+// originScanLimit caps the distinct origins read for the origins list.
+const originScanLimit = 500
+
+// userAgentScanLimit caps the distinct user agents read before grouping
+// them into browser families.
+const userAgentScanLimit = 500
+
+// topUserAgents groups non-spam submissions by browser family.
+func topUserAgents(db *gorm.DB, formID uint, r AnalyticsRange) ([]AnalyticsCount, error) {
+	var rows []struct {
+		UserAgent string
+		Count     int
+	}
+	err := db.Model(&Submission{}).
+		Select("user_agent, COUNT(*) AS count").
+		Where("form_id = ? AND is_spam = ? AND created_at >= ? AND created_at < ?", formID, false, r.From, r.To).
+		Group("user_agent").
+		Order("count DESC").
+		Limit(userAgentScanLimit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	families := map[string]int{}
+	total := 0
+	for _, row := range rows {
+		families[UserAgentFamily(row.UserAgent)] += row.Count
+		total += row.Count
+	}
+	counts := make([]AnalyticsCount, 0, len(families))
+	for label, count := range families {
+		counts = append(counts, AnalyticsCount{Label: label, Count: count})
+	}
+	sortCounts(counts)
+	if len(counts) > analyticsTopLimit {
+		counts = counts[:analyticsTopLimit]
+	}
+	return withShares(counts, total), nil
+}
+
+// UserAgentFamily names the client behind a User-Agent header.
+func UserAgentFamily(ua string) string {
+	lower := strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return "Unknown"
+	case strings.HasPrefix(ua, "Formlander"):
+		return "Inbound email"
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "spider") || strings.Contains(lower, "crawl"):
+		return "Bot"
+	case strings.HasPrefix(lower, "curl/") || strings.HasPrefix(lower, "wget/") ||
+		strings.HasPrefix(lower, "python") || strings.HasPrefix(lower, "go-http-client"):
+		return "Script"
+	case strings.Contains(ua, "Edg/"):
+		return "Edge"
+	case strings.Contains(ua, "OPR/"):
+		return "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		return "Firefox"
+	case strings.Contains(ua, "Chrome/") || strings.Contains(ua, "CriOS/"):
+		return "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		return "Safari"
+	}
+	return "Other"
+}
+
+// fieldBreakdowns counts the values chosen in the form's select, radio and
+// checkbox fields by non-spam submissions.
+func fieldBreakdowns(db *gorm.DB, form *Form, r AnalyticsRange) ([]FieldBreakdown, error) {
+	var breakdowns []FieldBreakdown
+	for _, field := range form.Fields() {
+		if field.Type != "select" && field.Type != "radio" && field.Type != "checkbox" {
+			continue
+		}
+		path := jsonPath(strings.TrimSuffix(field.Name, "[]"))
+		if path == "" {
+			continue
+		}
+
+		scope := db.Model(&Submission{}).
+			Where("submissions.form_id = ? AND submissions.is_spam = ? AND submissions.created_at >= ? AND submissions.created_at < ?", form.ID, false, r.From, r.To)
+
+		var responses int64
+		if err := scope.Session(&gorm.Session{}).
+			Where("json_type(submissions.data_json, ?) IS NOT NULL", path).
+			Count(&responses).Error; err != nil {
+			return nil, err
+		}
+
+		var rows []struct {
+			Value string
+			Count int
+		}
+		err := scope.Session(&gorm.Session{}).
+			Select("json_each.value AS value, COUNT(*) AS count").
+			Joins("JOIN json_each(submissions.data_json, ?)", path).
+			Where("json_each.type NOT IN ('object', 'array')").
+			Group("json_each.value").
+			Order("count DESC, value ASC").
+			Limit(analyticsTopLimit).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		labels := map[string]string{}
+		for _, option := range field.Options {
+			labels[option.Value] = option.Label
+		}
+		values := make([]AnalyticsCount, 0, len(rows))
+		for _, row := range rows {
+			label := row.Value
+			if l := labels[row.Value]; l != "" {
+				label = l
+			}
+			values = append(values, AnalyticsCount{Label: label, Count: row.Count})
+		}
+		breakdowns = append(breakdowns, FieldBreakdown{
+			Name:      field.Name,
+			Label:     firstNonEmpty(field.Label, field.Name),
+			Responses: int(responses),
+			Values:    withShares(values, int(responses)),
+		})
+	}
+	return breakdowns, nil
+}
+
+func sortCounts(counts []AnalyticsCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Label < counts[j].Label
+	})
+}
+
+func withShares(counts []AnalyticsCount, total int) []AnalyticsCount {
+	for i := range counts {
+		if total > 0 {
+			counts[i].Share = counts[i].Count * 100 / total
+		}
+	}
+	return counts
+}
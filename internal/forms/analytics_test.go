@@ -0,0 +1,142 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnalyticsRange(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	r := forms.ParseAnalyticsRange("", "", now)
+	assert.Equal(t, "30d", r.Name)
+	assert.Equal(t, forms.IntervalDay, r.Interval)
+	assert.Equal(t, time.Date(2026, 2, 9, 0, 0, 0, 0, time.UTC), r.From)
+	assert.Equal(t, now, r.To)
+
+	r = forms.ParseAnalyticsRange("24h", "", now)
+	assert.Equal(t, forms.IntervalHour, r.Interval)
+	assert.Equal(t, time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC), r.From)
+
+	r = forms.ParseAnalyticsRange("90d", forms.IntervalHour, now)
+	assert.Equal(t, forms.IntervalDay, r.Interval, "hourly buckets stop at 7 days")
+}
+
+func TestFormAnalytics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	form := &forms.Form{Name: "Signup", Slug: "signup", GeneratedHTML: `<form>
+		<select name="plan"><option value="free">Free plan</option><option value="pro">Pro plan</option></select>
+		<fieldset><legend>Topics</legend>
+			<label><input type="checkbox" name="topics[]" value="news"> News</label>
+			<label><input type="checkbox" name="topics[]" value="events"> Events</label>
+		</fieldset>
+	</form>`}
+	require.NoError(t, db.Create(form).Error)
+
+	add := func(at time.Time, spam bool, origin, ua, data string) *forms.Submission {
+		sub := &forms.Submission{FormID: form.ID, DataJSON: data, IsSpam: spam, Origin: origin, UserAgent: ua, CreatedAt: at}
+		require.NoError(t, db.Create(sub).Error)
+		return sub
+	}
+	chrome := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+	first := add(time.Date(2026, 3, 10, 9, 10, 0, 0, time.UTC), false, "example.com", chrome, `{"plan":"pro","topics":["news","events"]}`)
+	add(time.Date(2026, 3, 10, 9, 40, 0, 0, time.UTC), true, "spam.test", "", `{"plan":"free"}`)
+	second := add(time.Date(2026, 3, 9, 15, 0, 0, 0, time.UTC), false, "example.com", firefox, `{"plan":"pro","topics":["news"]}`)
+
+	delivered := first.CreatedAt.Add(2 * time.Second)
+	require.NoError(t, db.Create(&forms.WebhookEvent{SubmissionID: first.ID, Status: forms.WebhookStatusDelivered, LastAttemptAt: &delivered}).Error)
+	require.NoError(t, db.Create(&forms.WebhookEvent{SubmissionID: second.ID, Status: forms.WebhookStatusFailed}).Error)
+
+	require.NoError(t, forms.RefreshRollups(logger, db, now))
+	var rollups []forms.SubmissionRollup
+	require.NoError(t, db.Where("form_id = ?", form.ID).Order("hour ASC").Find(&rollups).Error)
+	require.Len(t, rollups, 2)
+	assert.Equal(t, time.Date(2026, 3, 9, 15, 0, 0, 0, time.UTC), rollups[0].Hour.UTC())
+	assert.Equal(t, 2, rollups[1].Submissions)
+	assert.Equal(t, 1, rollups[1].Spam)
+	assert.Equal(t, 1, rollups[1].WebhookDelivered)
+	assert.Equal(t, int64(2000), rollups[1].WebhookLatencyMs)
+
+	// The current hour isn't rolled up yet and is read live.
+	add(time.Date(2026, 3, 10, 12, 5, 0, 0, time.UTC), false, "", chrome, `{"plan":"free","topics":"news"}`)
+
+	t.Run("combines rollups with live hours", func(t *testing.T) {
+		a, err := forms.FormAnalytics(db, form, forms.ParseAnalyticsRange("7d", "", now))
+		require.NoError(t, err)
+		assert.Equal(t, 4, a.Totals.Submissions)
+		assert.Equal(t, 1, a.Totals.Spam)
+		assert.Equal(t, "25.0%", a.Totals.SpamRate())
+		assert.Equal(t, "50.0%", a.Totals.WebhookSuccessRate())
+		assert.Equal(t, "2s", a.Totals.WebhookLatency())
+		assert.Equal(t, "—", a.Totals.EmailSuccessRate())
+
+		require.Len(t, a.Series, 7)
+		today, yesterday := a.Series[6], a.Series[5]
+		assert.Equal(t, 3, today.Submissions)
+		assert.Equal(t, 100, today.Height)
+		assert.Equal(t, 33, today.SpamHeight())
+		assert.Equal(t, 1, yesterday.Submissions)
+		assert.Equal(t, 33, yesterday.Height)
+	})
+
+	t.Run("reads rolled up hours from the rollup table", func(t *testing.T) {
+		require.NoError(t, db.Model(&forms.SubmissionRollup{}).Where("id = ?", rollups[0].ID).Update("submissions", 11).Error)
+		t.Cleanup(func() { require.NoError(t, forms.RollupSubmissions(logger, db, form.ID, rollups[0].Hour, now)) })
+
+		a, err := forms.FormAnalytics(db, form, forms.ParseAnalyticsRange("24h", "", now))
+		require.NoError(t, err)
+		require.Len(t, a.Series, 24)
+		assert.Equal(t, 11, a.Series[2].Submissions) // 15:00 yesterday
+		assert.Equal(t, 14, a.Totals.Submissions)
+	})
+
+	t.Run("lists origins, clients and field values", func(t *testing.T) {
+		a, err := forms.FormAnalytics(db, form, forms.ParseAnalyticsRange("7d", forms.IntervalHour, now))
+		require.NoError(t, err)
+		require.Len(t, a.Series, 7*24)
+
+		assert.Equal(t, []forms.AnalyticsCount{
+			{Label: "example.com", Count: 2, Share: 66},
+			{Label: "Direct / unknown", Count: 1, Share: 33},
+		}, a.Origins)
+		assert.Equal(t, []forms.AnalyticsCount{
+			{Label: "Chrome", Count: 2, Share: 66},
+			{Label: "Firefox", Count: 1, Share: 33},
+		}, a.UserAgents)
+
+		require.Len(t, a.Fields, 2)
+		assert.Equal(t, "plan", a.Fields[0].Name)
+		assert.Equal(t, 3, a.Fields[0].Responses)
+		assert.Equal(t, []forms.AnalyticsCount{
+			{Label: "Pro plan", Count: 2, Share: 66},
+			{Label: "Free plan", Count: 1, Share: 33},
+		}, a.Fields[0].Values)
+		assert.Equal(t, "Topics", a.Fields[1].Label)
+		assert.Equal(t, []forms.AnalyticsCount{
+			{Label: "News", Count: 3, Share: 100},
+			{Label: "Events", Count: 1, Share: 33},
+		}, a.Fields[1].Values)
+	})
+}
+
+func TestUserAgentFamily(t *testing.T) {
+	assert.Equal(t, "Unknown", forms.UserAgentFamily(""))
+	assert.Equal(t, "Inbound email", forms.UserAgentFamily("Formlander inbound email"))
+	assert.Equal(t, "Edge", forms.UserAgentFamily("Mozilla/5.0 Chrome/120.0 Safari/537.36 Edg/120.0"))
+	assert.Equal(t, "Safari", forms.UserAgentFamily("Mozilla/5.0 (Macintosh) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15"))
+	assert.Equal(t, "Script", forms.UserAgentFamily("curl/8.4.0"))
+	assert.Equal(t, "Bot", forms.UserAgentFamily("Googlebot/2.1"))
+}
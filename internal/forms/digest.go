@@ -0,0 +1,137 @@
+package forms
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IsDigest reports whether email forwarding is batched into scheduled digests
+// instead of one email per submission.
+func (e *EmailDelivery) IsDigest() bool {
+	if e == nil {
+		return false
+	}
+	return e.DigestMode == DigestModeDaily || e.DigestMode == DigestModeWeekly
+}
+
+// DigestLocation resolves the configured timezone, falling back to UTC when it
+// is empty or unknown.
+func (e *EmailDelivery) DigestLocation() *time.Location {
+	name := strings.TrimSpace(e.DigestTimezone)
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// digestPeriodDays returns the length of one digest window in calendar days.
+func (e *EmailDelivery) digestPeriodDays() int {
+	if e.DigestMode == DigestModeWeekly {
+		return 7
+	}
+	return 1
+}
+
+// DigestBoundary returns the most recent scheduled send time at or before t.
+// Boundaries are computed in the delivery's timezone so a "9:00" digest stays
+// at 9:00 local time across DST changes.
+func (e *EmailDelivery) DigestBoundary(t time.Time) time.Time {
+	loc := e.DigestLocation()
+	local := t.In(loc)
+
+	boundary := time.Date(local.Year(), local.Month(), local.Day(), e.DigestHour, 0, 0, 0, loc)
+	if e.DigestMode == DigestModeWeekly {
+		offset := (int(boundary.Weekday()) - e.DigestWeekday + 7) % 7
+		boundary = boundary.AddDate(0, 0, -offset)
+	}
+	if boundary.After(t) {
+		boundary = boundary.AddDate(0, 0, -e.digestPeriodDays())
+	}
+	return boundary.UTC()
+}
+
+// NextDigestBoundary returns the first scheduled send time strictly after t.
+func (e *EmailDelivery) NextDigestBoundary(t time.Time) time.Time {
+	boundary := e.DigestBoundary(t).In(e.DigestLocation())
+	return boundary.AddDate(0, 0, e.digestPeriodDays()).UTC()
+}
+
+// GetSubmissionsBetween retrieves a form's submissions received in [start, end).
+// Confirmed opt-in submissions count from when they were confirmed, so one
+// confirmed after its window was sent goes out in the next digest.
+func GetSubmissionsBetween(db *gorm.DB, formID uint, start, end time.Time) ([]Submission, error) {
+	var submissions []Submission
+	if err := db.Where("form_id = ? AND COALESCE(confirmed_at, created_at) >= ? AND COALESCE(confirmed_at, created_at) < ?", formID, start, end).
+		Order("COALESCE(confirmed_at, created_at) ASC").
+		Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// normalizeDigestSettings validates digest scheduling input, returning the
+// canonical mode and timezone.
+func normalizeDigestSettings(mode string, hour, weekday int, timezone string) (string, string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		mode = DigestModeInstant
+	case DigestModeInstant, DigestModeDaily, DigestModeWeekly:
+	default:
+		return "", "", &ValidationError{Field: "digest_mode", Message: "Unknown email delivery mode"}
+	}
+
+	if hour < 0 || hour > 23 {
+		return "", "", &ValidationError{Field: "digest_hour", Message: "Digest hour must be between 0 and 23"}
+	}
+	if weekday < 0 || weekday > 6 {
+		return "", "", &ValidationError{Field: "digest_weekday", Message: "Digest weekday is not valid"}
+	}
+
+	timezone = strings.TrimSpace(timezone)
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return "", "", &ValidationError{Field: "digest_timezone", Message: "Digest timezone is not a valid IANA zone"}
+		}
+	}
+
+	return mode, timezone, nil
+}
+
+// queuePendingDigest emails the submissions a digest window had collected one
+// by one, for a form switching from digest to instant forwarding. Form must
+// already carry the new instant settings so routing rules see them.
+func queuePendingDigest(tx *gorm.DB, form *Form, since, now time.Time) error {
+	submissions, err := GetSubmissionsBetween(tx, form.ID, since, now)
+	if err != nil {
+		return err
+	}
+	if len(submissions) == 0 {
+		return nil
+	}
+
+	rules, err := ListRules(tx, form.ID)
+	if err != nil {
+		return err
+	}
+	for _, sub := range submissions {
+		if sub.IsSpam || sub.IsDuplicate || sub.AwaitingConfirmation() {
+			continue
+		}
+		// Rule webhooks and extra recipients went out when the submission
+		// arrived; only the form's own email was held for the digest.
+		if !RouteSubmission(form, rules, FlattenDataJSON(sub.DataJSON)).Email {
+			continue
+		}
+		if err := tx.Create(NewEmailEvent(sub.ID, now)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestBoundary(t *testing.T) {
+	t.Run("daily boundary before send hour falls on previous day", func(t *testing.T) {
+		e := &forms.EmailDelivery{DigestMode: forms.DigestModeDaily, DigestHour: 9}
+		now := time.Date(2025, 3, 10, 8, 30, 0, 0, time.UTC)
+
+		assert.Equal(t, time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC), e.DigestBoundary(now))
+		assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), e.NextDigestBoundary(now))
+	})
+
+	t.Run("daily boundary includes the exact send time", func(t *testing.T) {
+		e := &forms.EmailDelivery{DigestMode: forms.DigestModeDaily, DigestHour: 9}
+		now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+		assert.Equal(t, now, e.DigestBoundary(now))
+	})
+
+	t.Run("weekly boundary lands on configured weekday", func(t *testing.T) {
+		// 2025-03-12 is a Wednesday; weekly digests go out Monday 09:00.
+		e := &forms.EmailDelivery{DigestMode: forms.DigestModeWeekly, DigestHour: 9, DigestWeekday: 1}
+		now := time.Date(2025, 3, 12, 12, 0, 0, 0, time.UTC)
+
+		assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), e.DigestBoundary(now))
+		assert.Equal(t, time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC), e.NextDigestBoundary(now))
+	})
+
+	t.Run("timezone shifts boundary and survives DST", func(t *testing.T) {
+		e := &forms.EmailDelivery{DigestMode: forms.DigestModeDaily, DigestHour: 9, DigestTimezone: "America/New_York"}
+
+		// Before DST (EST, UTC-5) and after (EDT, UTC-4).
+		before := e.DigestBoundary(time.Date(2025, 3, 8, 20, 0, 0, 0, time.UTC))
+		after := e.DigestBoundary(time.Date(2025, 3, 10, 20, 0, 0, 0, time.UTC))
+
+		assert.Equal(t, time.Date(2025, 3, 8, 14, 0, 0, 0, time.UTC), before)
+		assert.Equal(t, time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC), after)
+	})
+}
+
+func TestDigestSettingsValidation(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("stores digest schedule on create", func(t *testing.T) {
+		form, err := forms.Create(logger, db, forms.CreateParams{
+			Name:           "Digest Form",
+			Slug:           "digest-form",
+			AllowedOrigins: "*",
+			DigestMode:     "weekly",
+			DigestHour:     7,
+			DigestWeekday:  5,
+			DigestTimezone: "Europe/Madrid",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, form.EmailDelivery)
+		assert.True(t, form.EmailDelivery.IsDigest())
+		assert.Equal(t, 7, form.EmailDelivery.DigestHour)
+		assert.Equal(t, "Europe/Madrid", form.EmailDelivery.DigestTimezone)
+	})
+
+	t.Run("rejects unknown timezone", func(t *testing.T) {
+		_, err := forms.Create(logger, db, forms.CreateParams{
+			Name:           "Bad TZ",
+			Slug:           "bad-tz",
+			AllowedOrigins: "*",
+			DigestMode:     "daily",
+			DigestTimezone: "Mars/Olympus",
+		})
+		var valErr *forms.ValidationError
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "digest_timezone", valErr.Field)
+	})
+
+	t.Run("rejects out of range hour", func(t *testing.T) {
+		_, err := forms.Create(logger, db, forms.CreateParams{
+			Name:           "Bad Hour",
+			Slug:           "bad-hour",
+			AllowedOrigins: "*",
+			DigestMode:     "daily",
+			DigestHour:     24,
+		})
+		var valErr *forms.ValidationError
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "digest_hour", valErr.Field)
+	})
+}
+
+func TestDigestModeTransitions(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mailerID := uint(1)
+
+	createDigestForm := func(t *testing.T, slug string) *forms.Form {
+		form, err := forms.Create(logger, db, forms.CreateParams{
+			Name:            "Digest " + slug,
+			Slug:            slug,
+			AllowedOrigins:  "*",
+			EmailEnabled:    true,
+			MailerProfileID: &mailerID,
+			EmailRecipient:  "owner@example.com",
+			DigestMode:      forms.DigestModeDaily,
+			DigestHour:      9,
+		})
+		require.NoError(t, err)
+		return form
+	}
+	params := func(form *forms.Form, mode string, enabled bool) forms.UpdateParams {
+		return forms.UpdateParams{
+			ID:              form.ID,
+			Name:            form.Name,
+			AllowedOrigins:  "*",
+			EmailEnabled:    enabled,
+			MailerProfileID: &mailerID,
+			EmailRecipient:  "owner@example.com",
+			DigestMode:      mode,
+			DigestHour:      9,
+		}
+	}
+	emailEvents := func(t *testing.T, submissionID uint) int64 {
+		var count int64
+		require.NoError(t, db.Model(&forms.EmailEvent{}).Where("submission_id = ?", submissionID).Count(&count).Error)
+		return count
+	}
+
+	t.Run("switching to instant emails the open window", func(t *testing.T) {
+		form := createDigestForm(t, "to-instant")
+		pending, err := forms.CreateSubmission(logger, db, form, map[string]any{"name": "Waiting"}, "test")
+		require.NoError(t, err)
+		spam := &forms.Submission{FormID: form.ID, DataJSON: `{}`, IsSpam: true, CreatedAt: time.Now()}
+		require.NoError(t, db.Create(spam).Error)
+		require.Zero(t, emailEvents(t, pending.ID))
+
+		_, err = forms.Update(logger, db, params(form, forms.DigestModeInstant, true))
+		require.NoError(t, err)
+
+		assert.EqualValues(t, 1, emailEvents(t, pending.ID))
+		assert.Zero(t, emailEvents(t, spam.ID))
+	})
+
+	t.Run("turning a digest back on starts a new window", func(t *testing.T) {
+		form := createDigestForm(t, "re-enable")
+		old := time.Now().Add(-72 * time.Hour).UTC()
+		require.NoError(t, db.Model(form.EmailDelivery).Update("last_digest_at", old).Error)
+
+		_, err := forms.Update(logger, db, params(form, forms.DigestModeDaily, false))
+		require.NoError(t, err)
+		before := time.Now().UTC()
+		updated, err := forms.Update(logger, db, params(form, forms.DigestModeDaily, true))
+		require.NoError(t, err)
+
+		require.NotNil(t, updated.EmailDelivery.LastDigestAt)
+		assert.False(t, updated.EmailDelivery.LastDigestAt.Before(before.Truncate(time.Second)))
+	})
+}
@@ -0,0 +1,74 @@
+package forms
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxExportRows caps how many submissions one CSV export includes.
+const MaxExportRows = 10000
+
+// WriteSubmissionsCSV writes submissions as CSV: fixed metadata columns
+// followed by one column per field path, in order of first appearance.
+// Submissions need their Form loaded.
+func WriteSubmissionsCSV(w io.Writer, submissions []Submission) error {
+	rows := make([][]FlatField, len(submissions))
+	var paths []string
+	seen := map[string]bool{}
+	for i, sub := range submissions {
+		rows[i] = FlattenDataJSON(sub.DataJSON)
+		for _, field := range rows[i] {
+			if !seen[field.Path] {
+				seen[field.Path] = true
+				paths = append(paths, field.Path)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"id", "created_at", "form", "status", "spam", "tags"}
+	for _, path := range paths {
+		header = append(header, csvCell(path))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i, sub := range submissions {
+		formName := ""
+		if sub.Form != nil {
+			formName = sub.Form.Name
+		}
+		values := make(map[string]string, len(rows[i]))
+		for _, field := range rows[i] {
+			values[field.Path] = field.Value
+		}
+
+		record := []string{
+			strconv.FormatUint(uint64(sub.ID), 10),
+			sub.CreatedAt.UTC().Format(time.RFC3339),
+			csvCell(formName),
+			csvCell(sub.Status),
+			strconv.FormatBool(sub.IsSpam),
+			csvCell(sub.Tags),
+		}
+		for _, path := range paths {
+			record = append(record, csvCell(values[path]))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell defuses values a spreadsheet would evaluate as a formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,359 @@
+package forms
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Delivery filters of the submissions inbox.
+const (
+	DeliveryWebhookFailed  = "webhook_failed"
+	DeliveryWebhookPending = "webhook_pending"
+	DeliveryEmailFailed    = "email_failed"
+	DeliveryEmailPending   = "email_pending"
+)
+
+// Sort orders of the submissions inbox. A leading "-" sorts descending.
+const (
+	SortDate   = "date"
+	SortForm   = "form"
+	SortStatus = "status"
+)
+
+// MaxFieldFilters caps how many per-field value filters one query can have.
+const MaxFieldFilters = 5
+
+// filterDateLayout is the format of the From and To dates.
+const filterDateLayout = "2006-01-02"
+
+// fieldFilterPrefix marks per-field filters in a query string: f.country=DE.
+const fieldFilterPrefix = "f."
+
+// SubmissionFilter narrows and orders the submissions inbox. It round-trips
+// through a query string, which is also how saved views store it.
+type SubmissionFilter struct {
+	FormID       string
+	Range        string // 7d | 30d | 90d | all; ignored when From or To is set
+	From         string // YYYY-MM-DD, inclusive, UTC
+	To           string // YYYY-MM-DD, inclusive, UTC
+	Search       string
+	Confirmation string
+	Status       string // "" hides archived, "all" shows everything
+	Assignee     string // "", none, me or a user ID
+	Tag          string
+	Unread       bool
+	Spam         string // "", spam or clean
+	HasFiles     bool
+	Delivery     string
+	Fields       []FieldFilter
+	Sort         string
+}
+
+// FieldFilter matches submissions whose field at Path equals Value,
+// case-insensitively. For lists, any item may match.
+type FieldFilter struct {
+	Path  string
+	Value string
+}
+
+// ParseSubmissionFilter reads a filter from query parameters, dropping
+// values it doesn't recognize. A new field filter can be added with the
+// field_path and field_value pair.
+func ParseSubmissionFilter(values url.Values) SubmissionFilter {
+	f := SubmissionFilter{
+		FormID:       strings.TrimSpace(values.Get("form_id")),
+		Range:        values.Get("range"),
+		From:         values.Get("from"),
+		To:           values.Get("to"),
+		Search:       strings.TrimSpace(values.Get("q")),
+		Confirmation: values.Get("confirmation"),
+		Status:       values.Get("status"),
+		Assignee:     values.Get("assignee"),
+		Tag:          strings.ToLower(strings.TrimSpace(values.Get("tag"))),
+		Unread:       values.Get("unread") == "1",
+		Spam:         values.Get("spam"),
+		HasFiles:     values.Get("has_files") == "1",
+		Delivery:     values.Get("delivery"),
+		Sort:         values.Get("sort"),
+	}
+
+	if _, err := strconv.ParseUint(f.FormID, 10, 32); err != nil {
+		f.FormID = ""
+	}
+	switch f.Range {
+	case "7d", "30d", "90d", "all":
+	default:
+		f.Range = ""
+	}
+	if _, err := time.Parse(filterDateLayout, f.From); err != nil {
+		f.From = ""
+	}
+	if _, err := time.Parse(filterDateLayout, f.To); err != nil {
+		f.To = ""
+	}
+	switch f.Confirmation {
+	case ConfirmationPending, ConfirmationConfirmed, ConfirmationExpired:
+	default:
+		f.Confirmation = ""
+	}
+	if f.Status != "all" && !IsValidStatus(f.Status) {
+		f.Status = ""
+	}
+	switch f.Assignee {
+	case "", "none", "me":
+	default:
+		if _, err := strconv.ParseUint(f.Assignee, 10, 32); err != nil {
+			f.Assignee = ""
+		}
+	}
+	switch f.Spam {
+	case "spam", "clean":
+	default:
+		f.Spam = ""
+	}
+	switch f.Delivery {
+	case DeliveryWebhookFailed, DeliveryWebhookPending, DeliveryEmailFailed, DeliveryEmailPending:
+	default:
+		f.Delivery = ""
+	}
+	switch strings.TrimPrefix(f.Sort, "-") {
+	case SortDate, SortForm, SortStatus:
+	default:
+		f.Sort = ""
+	}
+
+	for key, vals := range values {
+		if strings.HasPrefix(key, fieldFilterPrefix) && len(vals) > 0 {
+			f.addField(strings.TrimPrefix(key, fieldFilterPrefix), vals[0])
+		}
+	}
+	f.addField(values.Get("field_path"), values.Get("field_value"))
+	sort.Slice(f.Fields, func(i, j int) bool { return f.Fields[i].Path < f.Fields[j].Path })
+	return f
+}
+
+func (f *SubmissionFilter) addField(path, value string) {
+	path = strings.TrimSpace(path)
+	value = strings.TrimSpace(value)
+	if path == "" || value == "" || len(f.Fields) >= MaxFieldFilters || jsonPath(path) == "" {
+		return
+	}
+	for i, existing := range f.Fields {
+		if existing.Path == path {
+			f.Fields[i].Value = value
+			return
+		}
+	}
+	f.Fields = append(f.Fields, FieldFilter{Path: path, Value: value})
+}
+
+// Values encodes the filter as query parameters, omitting defaults.
+func (f SubmissionFilter) Values() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"form_id": f.FormID, "range": f.Range, "from": f.From, "to": f.To,
+		"q": f.Search, "confirmation": f.Confirmation, "status": f.Status,
+		"assignee": f.Assignee, "tag": f.Tag, "spam": f.Spam,
+		"delivery": f.Delivery, "sort": f.Sort,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	if f.Unread {
+		values.Set("unread", "1")
+	}
+	if f.HasFiles {
+		values.Set("has_files", "1")
+	}
+	for _, field := range f.Fields {
+		values.Set(fieldFilterPrefix+field.Path, field.Value)
+	}
+	return values
+}
+
+// Encode returns the filter as a query string.
+func (f SubmissionFilter) Encode() string {
+	return f.Values().Encode()
+}
+
+// IsZero reports whether the filter narrows nothing beyond the default
+// inbox. Sorting alone doesn't count.
+func (f SubmissionFilter) IsZero() bool {
+	f.Sort = ""
+	return len(f.Values()) == 0
+}
+
+// WithoutField returns the query string of the filter minus one field filter.
+func (f SubmissionFilter) WithoutField(path string) string {
+	fields := make([]FieldFilter, 0, len(f.Fields))
+	for _, field := range f.Fields {
+		if field.Path != path {
+			fields = append(fields, field)
+		}
+	}
+	f.Fields = fields
+	return f.Encode()
+}
+
+// SortedBy returns the query string of the filter sorted by a column,
+// flipping the direction when it is already sorted by it. Dates start
+// newest first, other columns ascending.
+func (f SubmissionFilter) SortedBy(column string) string {
+	current := f.Sort
+	if current == "" {
+		current = "-" + SortDate
+	}
+	switch {
+	case current == column:
+		f.Sort = "-" + column
+	case current == "-"+column:
+		f.Sort = column
+	case column == SortDate:
+		f.Sort = "-" + SortDate
+	default:
+		f.Sort = column
+	}
+	if f.Sort == "-"+SortDate {
+		f.Sort = ""
+	}
+	return f.Encode()
+}
+
+// Apply narrows a submissions query to the filter. userID resolves the "me"
+// assignee. Columns are qualified so the query can be joined for sorting.
+func (f SubmissionFilter) Apply(query *gorm.DB, userID uint, now time.Time) *gorm.DB {
+	if f.FormID != "" {
+		query = query.Where("submissions.form_id = ?", f.FormID)
+	}
+
+	switch f.Status {
+	case "all":
+	case "":
+		query = query.Where("submissions.status <> ?", SubmissionStatusArchived)
+	default:
+		query = query.Where("submissions.status = ?", f.Status)
+	}
+	switch f.Assignee {
+	case "":
+	case "none":
+		query = query.Where("submissions.assignee_id IS NULL")
+	case "me":
+		query = query.Where("submissions.assignee_id = ?", userID)
+	default:
+		query = query.Where("submissions.assignee_id = ?", f.Assignee)
+	}
+	if f.Tag != "" {
+		query = TagFilter(query, f.Tag)
+	}
+	if f.Unread {
+		query = query.Where("submissions.read_at IS NULL")
+	}
+	if f.Confirmation != "" {
+		query = query.Where("submissions.confirmation_status = ?", f.Confirmation)
+	}
+	switch f.Spam {
+	case "spam":
+		query = query.Where("submissions.is_spam = ?", true)
+	case "clean":
+		query = query.Where("submissions.is_spam = ?", false)
+	}
+	if f.HasFiles {
+		query = query.Where("EXISTS (SELECT 1 FROM submission_files WHERE submission_files.submission_id = submissions.id)")
+	}
+	switch f.Delivery {
+	case DeliveryWebhookFailed:
+		query = query.Where("EXISTS (SELECT 1 FROM webhook_events WHERE webhook_events.submission_id = submissions.id AND webhook_events.status = ?)", WebhookStatusFailed)
+	case DeliveryWebhookPending:
+		query = query.Where("EXISTS (SELECT 1 FROM webhook_events WHERE webhook_events.submission_id = submissions.id AND webhook_events.status IN ?)", pendingDeliveryStates)
+	case DeliveryEmailFailed:
+		query = query.Where("EXISTS (SELECT 1 FROM email_events WHERE email_events.submission_id = submissions.id AND email_events.status = ?)", WebhookStatusFailed)
+	case DeliveryEmailPending:
+		query = query.Where("EXISTS (SELECT 1 FROM email_events WHERE email_events.submission_id = submissions.id AND email_events.status IN ?)", pendingDeliveryStates)
+	}
+
+	if f.Search != "" {
+		query = query.Where("submissions.data_json LIKE ?", "%"+f.Search+"%")
+	}
+	for _, field := range f.Fields {
+		query = query.Where(
+			"EXISTS (SELECT 1 FROM json_each(submissions.data_json, ?) WHERE lower(json_each.value) = lower(?))",
+			jsonPath(field.Path), field.Value,
+		)
+	}
+
+	if f.From != "" || f.To != "" {
+		if from, err := time.Parse(filterDateLayout, f.From); err == nil {
+			query = query.Where("submissions.created_at >= ?", from)
+		}
+		if to, err := time.Parse(filterDateLayout, f.To); err == nil {
+			query = query.Where("submissions.created_at < ?", to.AddDate(0, 0, 1))
+		}
+	} else {
+		var start time.Time
+		switch f.Range {
+		case "7d":
+			start = now.AddDate(0, 0, -7)
+		case "30d":
+			start = now.AddDate(0, 0, -30)
+		case "90d":
+			start = now.AddDate(0, 0, -90)
+		}
+		if !start.IsZero() {
+			query = query.Where("submissions.created_at >= ?", start)
+		}
+	}
+	return query
+}
+
+// pendingDeliveryStates are the event states still waiting to be delivered.
+var pendingDeliveryStates = []string{WebhookStatusPending, WebhookStatusRetrying, WebhookStatusDelivering}
+
+// Order sorts a filtered submissions query. Apply it after counting: sorting
+// by form joins the forms table.
+func (f SubmissionFilter) Order(query *gorm.DB) *gorm.DB {
+	desc := strings.HasPrefix(f.Sort, "-")
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	switch strings.TrimPrefix(f.Sort, "-") {
+	case SortForm:
+		return query.Select("submissions.*").
+			Joins("LEFT JOIN forms ON forms.id = submissions.form_id").
+			Order("forms.name" + dir).
+			Order("submissions.created_at DESC")
+	case SortStatus:
+		return query.Order("submissions.status" + dir).Order("submissions.created_at DESC")
+	case SortDate:
+		return query.Order("submissions.created_at" + dir)
+	}
+	return query.Order("submissions.created_at DESC")
+}
+
+// jsonPath converts a flattened field path (address.street, items[0].qty)
+// into an SQLite JSON path, or "" when the path can't be expressed.
+func jsonPath(path string) string {
+	segments, err := splitFieldName(path)
+	if err != nil || len(segments) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("$")
+	for i, seg := range segments {
+		if seg.appends || strings.ContainsAny(seg.name, "\"\\") {
+			return ""
+		}
+		if _, err := strconv.Atoi(seg.name); err == nil && i > 0 {
+			b.WriteString("[" + seg.name + "]")
+			continue
+		}
+		b.WriteString(".\"" + seg.name + "\"")
+	}
+	return b.String()
+}
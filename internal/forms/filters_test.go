@@ -0,0 +1,175 @@
+package forms_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"log/slog"
+	"net/url"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubmissionFilter(t *testing.T) {
+	values, err := url.ParseQuery("form_id=3&from=2025-01-01&to=bogus&spam=clean&delivery=nope&sort=-form&f.country=DE&field_path=plan&field_value=pro&has_files=1")
+	require.NoError(t, err)
+
+	f := forms.ParseSubmissionFilter(values)
+	assert.Equal(t, "3", f.FormID)
+	assert.Equal(t, "2025-01-01", f.From)
+	assert.Empty(t, f.To)
+	assert.Equal(t, "clean", f.Spam)
+	assert.Empty(t, f.Delivery)
+	assert.Equal(t, "-form", f.Sort)
+	assert.True(t, f.HasFiles)
+	assert.Equal(t, []forms.FieldFilter{{Path: "country", Value: "DE"}, {Path: "plan", Value: "pro"}}, f.Fields)
+
+	// The encoded filter parses back to itself.
+	again, err := url.ParseQuery(f.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, f, forms.ParseSubmissionFilter(again))
+	assert.Equal(t, "f.plan=pro&form_id=3&from=2025-01-01&has_files=1&sort=-form&spam=clean", f.WithoutField("country"))
+
+	assert.True(t, forms.ParseSubmissionFilter(url.Values{"sort": {"form"}}).IsZero())
+	assert.Equal(t, "sort=form", forms.SubmissionFilter{}.SortedBy(forms.SortForm))
+	assert.Equal(t, "sort=-form", forms.SubmissionFilter{Sort: "form"}.SortedBy(forms.SortForm))
+	assert.Equal(t, "sort=date", forms.SubmissionFilter{}.SortedBy(forms.SortDate))
+	assert.Equal(t, "", forms.SubmissionFilter{Sort: "date"}.SortedBy(forms.SortDate))
+}
+
+func TestSubmissionFilterApply(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	alpha := &forms.Form{Name: "Alpha", Slug: "alpha"}
+	beta := &forms.Form{Name: "Beta", Slug: "beta"}
+	require.NoError(t, db.Create(alpha).Error)
+	require.NoError(t, db.Create(beta).Error)
+
+	create := func(form *forms.Form, data string, created time.Time, spam bool) *forms.Submission {
+		t.Helper()
+		sub := &forms.Submission{FormID: form.ID, DataJSON: data, CreatedAt: created, IsSpam: spam, Status: forms.SubmissionStatusNew}
+		require.NoError(t, db.Create(sub).Error)
+		return sub
+	}
+	de := create(beta, `{"country":"DE","topics":["Sales","Support"],"address":{"city":"Berlin"}}`, now.Add(-48*time.Hour), false)
+	fr := create(alpha, `{"country":"fr","topics":["Support"]}`, now.Add(-10*24*time.Hour), false)
+	spam := create(alpha, `{"country":"de"}`, now.Add(-time.Hour), true)
+
+	require.NoError(t, db.Create(&forms.SubmissionFile{SubmissionID: fr.ID, FieldName: "cv", Filename: "cv.pdf", StoragePath: "x", ContentType: "application/pdf", Size: 1}).Error)
+	require.NoError(t, db.Create(&forms.WebhookEvent{SubmissionID: de.ID, Status: forms.WebhookStatusFailed}).Error)
+	require.NoError(t, db.Create(&forms.EmailEvent{SubmissionID: fr.ID, Status: forms.WebhookStatusRetrying}).Error)
+
+	ids := func(query string) []uint {
+		t.Helper()
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		filter := forms.ParseSubmissionFilter(values)
+		var subs []forms.Submission
+		q := filter.Apply(db.Model(&forms.Submission{}), 0, now)
+		require.NoError(t, filter.Order(q).Find(&subs).Error)
+		out := make([]uint, len(subs))
+		for i, s := range subs {
+			out[i] = s.ID
+		}
+		return out
+	}
+
+	assert.Equal(t, []uint{spam.ID, de.ID, fr.ID}, ids(""))
+	assert.Equal(t, []uint{fr.ID, de.ID, spam.ID}, ids("sort=date"))
+	assert.Equal(t, []uint{spam.ID, fr.ID, de.ID}, ids("sort=form"))
+	assert.Equal(t, []uint{de.ID, spam.ID, fr.ID}, ids("sort=-form"))
+	assert.Equal(t, []uint{de.ID, fr.ID}, ids("spam=clean"))
+	assert.Equal(t, []uint{spam.ID}, ids("spam=spam"))
+	assert.Equal(t, []uint{fr.ID}, ids("has_files=1"))
+	assert.Equal(t, []uint{de.ID}, ids("delivery=webhook_failed"))
+	assert.Equal(t, []uint{fr.ID}, ids("delivery=email_pending"))
+	assert.Empty(t, ids("delivery=email_failed"))
+	assert.Equal(t, []uint{de.ID}, ids("from=2025-06-13&to=2025-06-13"))
+	assert.Equal(t, []uint{spam.ID, de.ID}, ids("range=7d"))
+	assert.Equal(t, []uint{spam.ID, de.ID}, ids("f.country=DE"))
+	assert.Equal(t, []uint{de.ID, fr.ID}, ids("f.topics=support"))
+	assert.Equal(t, []uint{de.ID}, ids("f.address.city=berlin"))
+	assert.Empty(t, ids("f.missing=x"))
+}
+
+func TestSavedViews(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	ada := &accounts.User{Email: "ada@example.com", PasswordHash: "x"}
+	bob := &accounts.User{Email: "bob@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(ada).Error)
+	require.NoError(t, db.Create(bob).Error)
+
+	_, err := forms.CreateView(logger, db, ada.ID, "  ", forms.SubmissionFilter{}, false)
+	var valErr *forms.ValidationError
+	require.ErrorAs(t, err, &valErr)
+
+	failed, err := forms.CreateView(logger, db, ada.ID, "Failed webhooks", forms.SubmissionFilter{Delivery: forms.DeliveryWebhookFailed}, true)
+	require.NoError(t, err)
+	_, err = forms.CreateView(logger, db, ada.ID, "Germany", forms.SubmissionFilter{Fields: []forms.FieldFilter{{Path: "country", Value: "DE"}}}, false)
+	require.NoError(t, err)
+
+	// Saving under an existing name replaces the filter.
+	again, err := forms.CreateView(logger, db, ada.ID, "Failed webhooks", forms.SubmissionFilter{Delivery: forms.DeliveryEmailFailed}, true)
+	require.NoError(t, err)
+	assert.Equal(t, failed.ID, again.ID)
+
+	views, err := forms.ListViews(db, ada.ID)
+	require.NoError(t, err)
+	require.Len(t, views, 2)
+	assert.Equal(t, "Failed webhooks", views[0].Name)
+	assert.Equal(t, forms.DeliveryEmailFailed, views[0].Filter().Delivery)
+
+	pinned, err := forms.PinnedViews(db, ada.ID)
+	require.NoError(t, err)
+	require.Len(t, pinned, 1)
+
+	// Views belong to their owner.
+	assert.Error(t, forms.SetViewPinned(logger, db, bob.ID, failed.ID, false))
+	assert.Error(t, forms.DeleteView(logger, db, bob.ID, failed.ID))
+	_, err = forms.GetView(db, bob.ID, failed.ID)
+	assert.Error(t, err)
+
+	require.NoError(t, forms.SetViewPinned(logger, db, ada.ID, failed.ID, false))
+	pinned, err = forms.PinnedViews(db, ada.ID)
+	require.NoError(t, err)
+	assert.Empty(t, pinned)
+	require.NoError(t, forms.DeleteView(logger, db, ada.ID, failed.ID))
+}
+
+func TestWriteSubmissionsCSV(t *testing.T) {
+	form := &forms.Form{Name: "Contact"}
+	subs := []forms.Submission{
+		{ID: 1, Form: form, Status: "new", DataJSON: `{"name":"Ada","email":"ada@example.com"}`, CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{ID: 2, Form: form, Status: "done", Tags: "vip", DataJSON: `{"name":"=HYPERLINK(\"x\")","plan":"pro"}`, CreatedAt: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, forms.WriteSubmissionsCSV(&buf, subs))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"id", "created_at", "form", "status", "spam", "tags", "email", "name", "plan"}, records[0])
+	assert.Equal(t, []string{"1", "2025-01-02T03:04:05Z", "Contact", "new", "false", "", "ada@example.com", "Ada", ""}, records[1])
+	assert.Equal(t, `'=HYPERLINK("x")`, records[2][7])
+
+	t.Run("defuses tags, status and field names too", func(t *testing.T) {
+		subs := []forms.Submission{{ID: 3, Form: form, Status: "-1+1", Tags: "=cmd|'/c calc'!A0", DataJSON: `{"@sum":"1"}`}}
+		var buf bytes.Buffer
+		require.NoError(t, forms.WriteSubmissionsCSV(&buf, subs))
+		records, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "'@sum", records[0][6])
+		assert.Equal(t, "'-1+1", records[1][3])
+		assert.Equal(t, "'=cmd|'/c calc'!A0", records[1][5])
+	})
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"log/slog"
 	"gorm.io/gorm"
 
+	"formlander/internal/audit"
 	"formlander/internal/pkg/dbtxn"
 )
 
@@ -26,6 +28,15 @@ type CreateParams struct {
 	WebhookURL         string
 	WebhookSecret      string
 	WebhookHeadersJSON string
+	DigestMode         string
+	DigestHour         int
+	DigestWeekday      int
+	DigestTimezone     string
+	InboundAddress     string
+	Hosted             HostedPageParams
+	Availability       AvailabilityParams
+	Unique             UniqueParams
+	OptIn              OptInParams
 	TemplateID         string
 }
 
@@ -45,6 +56,15 @@ type UpdateParams struct {
 	WebhookURL         string
 	WebhookSecret      string
 	WebhookHeadersJSON string
+	DigestMode         string
+	DigestHour         int
+	DigestWeekday      int
+	DigestTimezone     string
+	InboundAddress     string
+	Hosted             HostedPageParams
+	Availability       AvailabilityParams
+	Unique             UniqueParams
+	OptIn              OptInParams
 }
 
 // ValidationError represents a validation error
@@ -96,6 +116,36 @@ func Create(logger *slog.Logger, db *gorm.DB, params CreateParams) (*Form, error
 		}
 	}
 
+	digestMode, digestTimezone, err := normalizeDigestSettings(params.DigestMode, params.DigestHour, params.DigestWeekday, params.DigestTimezone)
+	if err != nil {
+		return nil, err
+	}
+
+	inboundAddress, err := normalizeInboundAddress(db, params.InboundAddress, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	hosted, err := normalizeHostedPage(params.Hosted)
+	if err != nil {
+		return nil, err
+	}
+
+	availability, err := normalizeAvailability(params.Availability)
+	if err != nil {
+		return nil, err
+	}
+
+	unique, err := normalizeUnique(params.Unique)
+	if err != nil {
+		return nil, err
+	}
+
+	optIn, err := normalizeOptIn(params.OptIn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate webhook delivery settings
 	if params.WebhookEnabled && strings.TrimSpace(params.WebhookURL) == "" {
 		return nil, &ValidationError{
@@ -126,13 +176,28 @@ func Create(logger *slog.Logger, db *gorm.DB, params CreateParams) (*Form, error
 		UseSDK:           params.UseSDK,
 		GeneratedHTML:    strings.TrimSpace(params.GeneratedHTML),
 		CaptchaProfileID: params.CaptchaProfileID,
+		InboundAddress:   inboundAddress,
 	}
+	hosted.apply(form)
+	availability.apply(form)
+	unique.apply(form)
+	optIn.apply(form)
 
 	// Create delivery records
 	form.EmailDelivery = &EmailDelivery{
 		Enabled:         params.EmailEnabled,
 		MailerProfileID: params.MailerProfileID,
 		OverridesJSON:   emailOverridesJSON,
+		DigestMode:      digestMode,
+		DigestHour:      params.DigestHour,
+		DigestWeekday:   params.DigestWeekday,
+		DigestTimezone:  digestTimezone,
+	}
+	// A digest form counts from its creation, so submissions received before
+	// the first scheduled send are part of the first digest.
+	if digestMode != DigestModeInstant {
+		now := time.Now().UTC()
+		form.EmailDelivery.LastDigestAt = &now
 	}
 
 	form.WebhookDelivery = &WebhookDelivery{
@@ -144,7 +209,15 @@ func Create(logger *slog.Logger, db *gorm.DB, params CreateParams) (*Form, error
 
 	// Persist to database
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Create(form).Error
+		if err := tx.Create(form).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionFormCreated,
+			TargetType: "form",
+			TargetID:   form.ID,
+			Details:    map[string]any{"name": form.Name, "slug": form.Slug},
+		})
 	}); err != nil {
 		if isUniqueConstraint(err) {
 			return nil, &ValidationError{Field: "slug", Message: "Slug already exists"}
@@ -220,7 +293,22 @@ func GetEmailEvents(db *gorm.DB, formID uint, limit int) ([]EmailEvent, error) {
 // Delete deletes a form
 func Delete(logger *slog.Logger, db *gorm.DB, id uint) error {
 	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Delete(&Form{}, id).Error
+		var form Form
+		if err := tx.Select("id, name, slug").First(&form, id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Delete(&Form{}, id).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionFormDeleted,
+			TargetType: "form",
+			TargetID:   id,
+			Details:    map[string]any{"name": form.Name, "slug": form.Slug},
+		})
 	})
 }
 
@@ -238,6 +326,17 @@ func GetBySlug(db *gorm.DB, slug string) (*Form, error) {
 	return &form, nil
 }
 
+// GetByPublicID retrieves a form by its public ID
+func GetByPublicID(db *gorm.DB, publicID string) (*Form, error) {
+	var form Form
+	if err := db.Where("public_id = ?", publicID).
+		Preload("CaptchaProfile").
+		First(&form).Error; err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
 // EnsureDeliveryRecords creates delivery records if they don't exist
 func EnsureDeliveryRecords(logger *slog.Logger, db *gorm.DB, form *Form) error {
 	if form.EmailDelivery == nil {
@@ -299,6 +398,36 @@ func Update(logger *slog.Logger, db *gorm.DB, params UpdateParams) (*Form, error
 		}
 	}
 
+	digestMode, digestTimezone, err := normalizeDigestSettings(params.DigestMode, params.DigestHour, params.DigestWeekday, params.DigestTimezone)
+	if err != nil {
+		return nil, err
+	}
+
+	inboundAddress, err := normalizeInboundAddress(db, params.InboundAddress, params.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	hosted, err := normalizeHostedPage(params.Hosted)
+	if err != nil {
+		return nil, err
+	}
+
+	availability, err := normalizeAvailability(params.Availability)
+	if err != nil {
+		return nil, err
+	}
+
+	unique, err := normalizeUnique(params.Unique)
+	if err != nil {
+		return nil, err
+	}
+
+	optIn, err := normalizeOptIn(params.OptIn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate webhook delivery if enabled
 	if params.WebhookEnabled && strings.TrimSpace(params.WebhookURL) == "" {
 		return nil, &ValidationError{
@@ -321,30 +450,93 @@ func Update(logger *slog.Logger, db *gorm.DB, params UpdateParams) (*Form, error
 		webhookHeadersJSON = string(normalized)
 	}
 
+	emailValues := map[string]any{
+		"enabled":           params.EmailEnabled,
+		"mailer_profile_id": params.MailerProfileID,
+		"overrides_json":    emailOverridesJSON,
+		"digest_mode":       digestMode,
+		"digest_hour":       params.DigestHour,
+		"digest_weekday":    params.DigestWeekday,
+		"digest_timezone":   digestTimezone,
+	}
+	now := time.Now().UTC()
+	wasDigest := form.EmailDelivery.IsDigest() && form.EmailDelivery.Enabled
+	// Switching into digest mode, or turning a digest back on, starts the
+	// first window now: earlier submissions were either forwarded one by one
+	// or arrived while forwarding was off, and don't belong in a digest.
+	if digestMode != DigestModeInstant && params.EmailEnabled && !wasDigest {
+		emailValues["last_digest_at"] = now
+	}
+	// Leaving digest mode sends what the open window had collected so far.
+	var flush *Form
+	if wasDigest && digestMode == DigestModeInstant && params.EmailEnabled && form.EmailDelivery.LastDigestAt != nil {
+		delivery := *form.EmailDelivery
+		delivery.Enabled = true
+		delivery.DigestMode = DigestModeInstant
+		delivery.OverridesJSON = emailOverridesJSON
+		updated := *form
+		updated.EmailDelivery = &delivery
+		flush = &updated
+	}
+
 	// Update in transaction
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
 		// Update form fields
 		if err := tx.Model(&Form{}).
 			Where("id = ?", params.ID).
 			Updates(map[string]any{
-				"name":               strings.TrimSpace(params.Name),
-				"captcha_profile_id": params.CaptchaProfileID,
-				"allowed_origins":    strings.TrimSpace(params.AllowedOrigins),
-				"use_sdk":            params.UseSDK,
+				"name":                strings.TrimSpace(params.Name),
+				"captcha_profile_id":  params.CaptchaProfileID,
+				"allowed_origins":     strings.TrimSpace(params.AllowedOrigins),
+				"use_sdk":             params.UseSDK,
+				"inbound_address":     inboundAddress,
+				"hosted_enabled":      hosted.Enabled,
+				"hosted_title":        hosted.Title,
+				"hosted_description":  hosted.Description,
+				"hosted_logo_url":     hosted.LogoURL,
+				"hosted_accent_color": hosted.AccentColor,
+				"hosted_css":          hosted.CustomCSS,
+				"success_title":       hosted.SuccessTitle,
+				"success_message":     hosted.SuccessMessage,
+				"error_title":         hosted.ErrorTitle,
+				"error_message":       hosted.ErrorMessage,
+				"opens_at":            availability.OpensAt,
+				"closes_at":           availability.ClosesAt,
+				"max_submissions":     availability.MaxSubmissions,
+				"cap_ignores_spam":    availability.CapIgnoresSpam,
+				"closed_url":          availability.ClosedURL,
+				"closed_message":      availability.ClosedMessage,
+				"unique_field":        unique.Field,
+				"unique_action":       unique.Action,
+				"double_opt_in":       optIn.Enabled,
+				"opt_in_email_field":  optIn.EmailField,
+				"opt_in_expiry_hours": optIn.ExpiryHours,
+				"opt_in_subject":      optIn.Subject,
+				"opt_in_message":      optIn.Message,
 			}).Error; err != nil {
 			return err
 		}
 
+		// Earlier submissions become the originals for a new unique field.
+		if unique.Field != "" && unique.Field != form.UniqueField {
+			updated := *form
+			unique.apply(&updated)
+			if err := backfillUniqueKeys(tx, &updated); err != nil {
+				return err
+			}
+		}
+
 		// Update email delivery
 		if err := tx.Model(&EmailDelivery{}).
 			Where("id = ?", form.EmailDelivery.ID).
-			Updates(map[string]any{
-				"enabled":           params.EmailEnabled,
-				"mailer_profile_id": params.MailerProfileID,
-				"overrides_json":    emailOverridesJSON,
-			}).Error; err != nil {
+			Updates(emailValues).Error; err != nil {
 			return err
 		}
+		if flush != nil {
+			if err := queuePendingDigest(tx, flush, *form.EmailDelivery.LastDigestAt, now); err != nil {
+				return err
+			}
+		}
 
 		// Update webhook delivery
 		if err := tx.Model(&WebhookDelivery{}).
@@ -358,7 +550,12 @@ func Update(logger *slog.Logger, db *gorm.DB, params UpdateParams) (*Form, error
 			return err
 		}
 
-		return nil
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionFormUpdated,
+			TargetType: "form",
+			TargetID:   params.ID,
+			Details:    map[string]any{"name": strings.TrimSpace(params.Name), "slug": form.Slug},
+		})
 	}); err != nil {
 		logger.Error("failed to update form", slog.Any("error", err), slog.Uint64("form_id", uint64(params.ID)))
 		return nil, err
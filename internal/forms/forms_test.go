@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"formlander/internal/forms"
+	"formlander/internal/live"
 	"formlander/internal/pkg/testsupport"
 
 	"github.com/stretchr/testify/assert"
@@ -700,3 +702,25 @@ func TestDelete(t *testing.T) {
 		t.Logf("After delete - emails: %d, webhooks: %d", emailCount, webhookCount)
 	})
 }
+
+func TestCreateSubmissionPublishesLiveEvent(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	form := &forms.Form{Name: "Contact", Slug: "contact"}
+	require.NoError(t, db.Create(form).Error)
+
+	events, cancel := live.Subscribe(0)
+	defer cancel()
+	submission, err := forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"name": "Ada"}, "UA", "", nil)
+	require.NoError(t, err)
+
+	select {
+	case e := <-events:
+		assert.Equal(t, live.SubmissionCreated, e.Type)
+		assert.Equal(t, submission.ID, e.SubmissionID)
+		assert.Equal(t, form.ID, e.FormID)
+	case <-time.After(time.Second):
+		t.Fatal("no live event published")
+	}
+}
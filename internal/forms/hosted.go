@@ -0,0 +1,131 @@
+package forms
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Defaults for hosted pages when a form leaves a setting empty.
+const (
+	DefaultHostedAccentColor = "#2563eb"
+	DefaultSuccessTitle      = "Thank you!"
+	DefaultSuccessMessage    = "Your submission has been received."
+	DefaultErrorTitle        = "Something went wrong"
+	DefaultErrorMessage      = "We couldn't process your submission. Please go back and try again."
+)
+
+// maxHostedCSSSize caps custom CSS stored for a hosted page.
+const maxHostedCSSSize = 64 * 1024
+
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// HostedPageParams holds the hosted form page settings.
+type HostedPageParams struct {
+	Enabled     bool
+	Title       string
+	Description string
+	LogoURL     string
+	AccentColor string
+	CustomCSS   string
+
+	// Result pages shown to browser form posts that don't set a redirect.
+	SuccessTitle   string
+	SuccessMessage string
+	ErrorTitle     string
+	ErrorMessage   string
+}
+
+// apply copies the normalized settings onto a form.
+func (p HostedPageParams) apply(form *Form) {
+	form.HostedEnabled = p.Enabled
+	form.HostedTitle = p.Title
+	form.HostedDescription = p.Description
+	form.HostedLogoURL = p.LogoURL
+	form.HostedAccentColor = p.AccentColor
+	form.HostedCSS = p.CustomCSS
+	form.SuccessTitle = p.SuccessTitle
+	form.SuccessMessage = p.SuccessMessage
+	form.ErrorTitle = p.ErrorTitle
+	form.ErrorMessage = p.ErrorMessage
+}
+
+// HostedPath returns the public path of the form's hosted page.
+func (f *Form) HostedPath() string {
+	return "/f/" + f.PublicID
+}
+
+// HostedPageTitle returns the hosted page heading, falling back to the form name.
+func (f *Form) HostedPageTitle() string {
+	if title := strings.TrimSpace(f.HostedTitle); title != "" {
+		return title
+	}
+	return f.Name
+}
+
+// HostedAccent returns the accent color for the hosted page theme.
+func (f *Form) HostedAccent() string {
+	if f.HostedAccentColor != "" {
+		return f.HostedAccentColor
+	}
+	return DefaultHostedAccentColor
+}
+
+// SuccessPageTitle returns the thank-you page heading.
+func (f *Form) SuccessPageTitle() string {
+	return firstNonEmpty(f.SuccessTitle, DefaultSuccessTitle)
+}
+
+// SuccessPageMessage returns the thank-you page body.
+func (f *Form) SuccessPageMessage() string {
+	return firstNonEmpty(f.SuccessMessage, DefaultSuccessMessage)
+}
+
+// ErrorPageTitle returns the error page heading.
+func (f *Form) ErrorPageTitle() string {
+	return firstNonEmpty(f.ErrorTitle, DefaultErrorTitle)
+}
+
+// ErrorPageMessage returns the error page body.
+func (f *Form) ErrorPageMessage() string {
+	return firstNonEmpty(f.ErrorMessage, DefaultErrorMessage)
+}
+
+func firstNonEmpty(value, fallback string) string {
+	if strings.TrimSpace(value) != "" {
+		return value
+	}
+	return fallback
+}
+
+// normalizeHostedPage trims and validates hosted page settings.
+func normalizeHostedPage(p HostedPageParams) (HostedPageParams, error) {
+	p.Title = strings.TrimSpace(p.Title)
+	p.Description = strings.TrimSpace(p.Description)
+	p.LogoURL = strings.TrimSpace(p.LogoURL)
+	p.AccentColor = strings.ToLower(strings.TrimSpace(p.AccentColor))
+	p.CustomCSS = strings.TrimSpace(p.CustomCSS)
+	p.SuccessTitle = strings.TrimSpace(p.SuccessTitle)
+	p.SuccessMessage = strings.TrimSpace(p.SuccessMessage)
+	p.ErrorTitle = strings.TrimSpace(p.ErrorTitle)
+	p.ErrorMessage = strings.TrimSpace(p.ErrorMessage)
+
+	if p.LogoURL != "" {
+		u, err := url.Parse(p.LogoURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return p, &ValidationError{Field: "hosted_logo_url", Message: "Logo URL must be an absolute http(s) URL"}
+		}
+	}
+	if p.AccentColor != "" && !hexColorPattern.MatchString(p.AccentColor) {
+		return p, &ValidationError{Field: "hosted_accent_color", Message: "Accent color must be a hex color like #2563eb"}
+	}
+	if len(p.CustomCSS) > maxHostedCSSSize {
+		return p, &ValidationError{Field: "hosted_css", Message: "Custom CSS is too large (max 64KB)"}
+	}
+	// The CSS is emitted inside a <style> element; a closing tag would let it
+	// break out into markup.
+	if strings.Contains(strings.ToLower(p.CustomCSS), "</style") {
+		return p, &ValidationError{Field: "hosted_css", Message: "Custom CSS cannot contain </style>"}
+	}
+	return p, nil
+}
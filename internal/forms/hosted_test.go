@@ -0,0 +1,66 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostedPageSettings(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	create := func(slug string, hosted forms.HostedPageParams) (*forms.Form, error) {
+		return forms.Create(logger, db, forms.CreateParams{
+			Name:           "Hosted " + slug,
+			Slug:           slug,
+			AllowedOrigins: "*",
+			Hosted:         hosted,
+		})
+	}
+
+	t.Run("stores normalized settings", func(t *testing.T) {
+		form, err := create("hosted-ok", forms.HostedPageParams{
+			Enabled:     true,
+			Title:       "  Join the waitlist ",
+			AccentColor: "#FF5500",
+			LogoURL:     "https://example.com/logo.png",
+		})
+		require.NoError(t, err)
+		assert.True(t, form.HostedEnabled)
+		assert.Equal(t, "Join the waitlist", form.HostedPageTitle())
+		assert.Equal(t, "#ff5500", form.HostedAccent())
+		assert.Equal(t, "/f/"+form.PublicID, form.HostedPath())
+	})
+
+	t.Run("falls back to form name and default accent", func(t *testing.T) {
+		form, err := create("hosted-defaults", forms.HostedPageParams{Enabled: true})
+		require.NoError(t, err)
+		assert.Equal(t, "Hosted hosted-defaults", form.HostedPageTitle())
+		assert.Equal(t, forms.DefaultHostedAccentColor, form.HostedAccent())
+	})
+
+	invalid := []struct {
+		name   string
+		params forms.HostedPageParams
+		field  string
+	}{
+		{"non-hex accent", forms.HostedPageParams{AccentColor: "red"}, "hosted_accent_color"},
+		{"javascript logo", forms.HostedPageParams{LogoURL: "javascript:alert(1)"}, "hosted_logo_url"},
+		{"style breakout", forms.HostedPageParams{CustomCSS: "body{}</style><script>"}, "hosted_css"},
+	}
+	for i, tt := range invalid {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			_, err := create("hosted-bad-"+string(rune('a'+i)), tt.params)
+			var valErr *forms.ValidationError
+			require.ErrorAs(t, err, &valErr)
+			assert.Equal(t, tt.field, valErr.Field)
+		})
+	}
+}
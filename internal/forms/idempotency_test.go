@@ -0,0 +1,85 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateIdempotentSubmission(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("replays the first submission for a repeated key", func(t *testing.T) {
+		db := testsupport.SetupTestDB(t)
+		form := &forms.Form{
+			Name:            "Idempotent",
+			Slug:            "idempotent",
+			WebhookDelivery: &forms.WebhookDelivery{Enabled: true, URL: "https://hooks.example.com"},
+		}
+		require.NoError(t, db.Create(form).Error)
+
+		first, replayed, err := forms.CreateIdempotentSubmission(logger, db, form, "key-1", map[string]any{"name": "Ada"}, "UA", "", "", nil)
+		require.NoError(t, err)
+		assert.False(t, replayed)
+
+		second, replayed, err := forms.CreateIdempotentSubmission(logger, db, form, "key-1", map[string]any{"name": "Ada"}, "UA", "", "", nil)
+		require.NoError(t, err)
+		assert.True(t, replayed)
+		assert.Equal(t, first.ID, second.ID)
+
+		var submissions, events int64
+		db.Model(&forms.Submission{}).Count(&submissions)
+		db.Model(&forms.WebhookEvent{}).Count(&events)
+		assert.Equal(t, int64(1), submissions)
+		assert.Equal(t, int64(1), events, "a replayed post must not be forwarded again")
+	})
+
+	t.Run("keys are scoped to the form and the window", func(t *testing.T) {
+		db := testsupport.SetupTestDB(t)
+		formA := &forms.Form{Name: "A", Slug: "form-a"}
+		formB := &forms.Form{Name: "B", Slug: "form-b"}
+		require.NoError(t, db.Create(formA).Error)
+		require.NoError(t, db.Create(formB).Error)
+
+		first, _, err := forms.CreateIdempotentSubmission(logger, db, formA, "shared", map[string]any{"n": "1"}, "UA", "", "", nil)
+		require.NoError(t, err)
+
+		_, replayed, err := forms.CreateIdempotentSubmission(logger, db, formB, "shared", map[string]any{"n": "1"}, "UA", "", "", nil)
+		require.NoError(t, err)
+		assert.False(t, replayed, "same key on another form is a new submission")
+
+		expired := time.Now().UTC().Add(-forms.IdempotencyWindow - time.Hour)
+		require.NoError(t, db.Model(&forms.Submission{}).Where("id = ?", first.ID).Update("created_at", expired).Error)
+
+		_, replayed, err = forms.CreateIdempotentSubmission(logger, db, formA, "shared", map[string]any{"n": "1"}, "UA", "", "", nil)
+		require.NoError(t, err)
+		assert.False(t, replayed, "keys older than the window no longer deduplicate")
+	})
+
+	t.Run("empty key never deduplicates", func(t *testing.T) {
+		db := testsupport.SetupTestDB(t)
+		form := &forms.Form{Name: "Plain", Slug: "plain"}
+		require.NoError(t, db.Create(form).Error)
+
+		for i := 0; i < 2; i++ {
+			_, replayed, err := forms.CreateIdempotentSubmission(logger, db, form, "", map[string]any{"n": "1"}, "UA", "", "", nil)
+			require.NoError(t, err)
+			assert.False(t, replayed)
+		}
+	})
+}
+
+func TestValidIdempotencyKey(t *testing.T) {
+	assert.True(t, forms.ValidIdempotencyKey("6f1c2a4e-0a9b-4c1e-9f5d-2b7e8c3d1a00"))
+	assert.False(t, forms.ValidIdempotencyKey(""))
+	assert.False(t, forms.ValidIdempotencyKey("has space"))
+	assert.False(t, forms.ValidIdempotencyKey(strings.Repeat("k", forms.MaxIdempotencyKeyLength+1)))
+}
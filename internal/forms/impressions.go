@@ -0,0 +1,133 @@
+package forms
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/pkg/dbtxn"
+)
+
+// maxViewOriginLength matches the size of FormView.Origin.
+const maxViewOriginLength = 255
+
+// RecordView counts one impression of a form from an origin on the UTC day
+// of now.
+func RecordView(logger *slog.Logger, db *gorm.DB, formID uint, origin string, now time.Time) error {
+	origin = strings.ToLower(strings.TrimSpace(origin))
+	if len(origin) > maxViewOriginLength {
+		origin = origin[:maxViewOriginLength]
+	}
+	day := startOfDay(now.UTC())
+
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		res := tx.Model(&FormView{}).
+			Where("form_id = ? AND day = ? AND origin = ?", formID, day, origin).
+			UpdateColumn("views", gorm.Expr("views + 1"))
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		return tx.Create(&FormView{FormID: formID, Day: day, Origin: origin, Views: 1}).Error
+	})
+}
+
+// Conversion compares a form's views with the non-spam submissions it got
+// over the same days. Submissions posted without the view beacon (plain
+// HTML forms, API clients, visitors with Do-Not-Track) can push the rate
+// past 100%.
+type Conversion struct {
+	Views       int
+	Submissions int
+}
+
+// Rate returns the share of views that led to a submission.
+func (c Conversion) Rate() string {
+	return percent(c.Submissions, c.Views)
+}
+
+// Abandonment returns the share of views that didn't lead to a submission.
+func (c Conversion) Abandonment() string {
+	return percent(max(c.Views-c.Submissions, 0), c.Views)
+}
+
+// ConversionStats returns views and non-spam submissions per form from the
+// start of from's UTC day until to. Views are only counted per day, so the
+// submissions are counted over the same whole days.
+func ConversionStats(db *gorm.DB, formIDs []uint, from, to time.Time) (map[uint]Conversion, error) {
+	stats := map[uint]Conversion{}
+	if len(formIDs) == 0 {
+		return stats, nil
+	}
+	from, to = startOfDay(from.UTC()), to.UTC()
+
+	var views []struct {
+		FormID uint
+		Views  int
+	}
+	if err := db.Model(&FormView{}).
+		Select("form_id, SUM(views) AS views").
+		Where("form_id IN ? AND day >= ? AND day < ?", formIDs, from, to).
+		Group("form_id").
+		Scan(&views).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range views {
+		stats[row.FormID] = Conversion{Views: row.Views}
+	}
+
+	var submissions []struct {
+		FormID uint
+		Count  int
+	}
+	if err := db.Model(&Submission{}).
+		Select("form_id, COUNT(*) AS count").
+		Where("form_id IN ? AND is_spam = ? AND created_at >= ? AND created_at < ?", formIDs, false, from, to).
+		Group("form_id").
+		Scan(&submissions).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range submissions {
+		c := stats[row.FormID]
+		c.Submissions = row.Count
+		stats[row.FormID] = c
+	}
+	return stats, nil
+}
+
+// viewsByOrigin sums a form's views per origin from the start of from's UTC
+// day until to.
+func viewsByOrigin(db *gorm.DB, formID uint, from, to time.Time) (map[string]int, error) {
+	var rows []struct {
+		Origin string
+		Views  int
+	}
+	if err := db.Model(&FormView{}).
+		Select("origin, SUM(views) AS views").
+		Where("form_id = ? AND day >= ? AND day < ?", formID, startOfDay(from.UTC()), to.UTC()).
+		Group("origin").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	views := make(map[string]int, len(rows))
+	for _, row := range rows {
+		views[row.Origin] = row.Views
+	}
+	return views, nil
+}
+
+// viewsByDay sums a form's views per UTC day.
+func viewsByDay(db *gorm.DB, formID uint, from, to time.Time) (map[time.Time]int, error) {
+	var rows []FormView
+	if err := db.Select("day", "views").
+		Where("form_id = ? AND day >= ? AND day < ?", formID, startOfDay(from.UTC()), to.UTC()).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	views := map[time.Time]int{}
+	for _, row := range rows {
+		views[startOfDay(row.Day.UTC())] += row.Views
+	}
+	return views, nil
+}
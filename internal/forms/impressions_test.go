@@ -0,0 +1,69 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordViewAndConversion(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	form := &forms.Form{Name: "Contact", Slug: "contact"}
+	require.NoError(t, db.Create(form).Error)
+	quiet := &forms.Form{Name: "Quiet", Slug: "quiet"}
+	require.NoError(t, db.Create(quiet).Error)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, forms.RecordView(logger, db, form.ID, "Example.com", now))
+	}
+	require.NoError(t, forms.RecordView(logger, db, form.ID, "other.test", now))
+	require.NoError(t, forms.RecordView(logger, db, form.ID, "example.com", now.AddDate(0, 0, -1)))
+	require.NoError(t, forms.RecordView(logger, db, form.ID, "example.com", now.AddDate(0, 0, -40)))
+
+	var rows []forms.FormView
+	require.NoError(t, db.Where("form_id = ?", form.ID).Order("day DESC, origin ASC").Find(&rows).Error)
+	require.Len(t, rows, 4)
+	assert.Equal(t, "example.com", rows[0].Origin)
+	assert.Equal(t, 3, rows[0].Views)
+	assert.Equal(t, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), rows[0].Day.UTC())
+
+	for _, spam := range []bool{false, false, true} {
+		require.NoError(t, db.Create(&forms.Submission{FormID: form.ID, DataJSON: "{}", IsSpam: spam, Origin: "example.com", CreatedAt: now.Add(-time.Hour)}).Error)
+	}
+
+	stats, err := forms.ConversionStats(db, []uint{form.ID, quiet.ID}, now.AddDate(0, 0, -6), now)
+	require.NoError(t, err)
+	assert.Equal(t, forms.Conversion{Views: 5, Submissions: 2}, stats[form.ID])
+	assert.Equal(t, "40.0%", stats[form.ID].Rate())
+	assert.Equal(t, "60.0%", stats[form.ID].Abandonment())
+	assert.Equal(t, "—", stats[quiet.ID].Rate())
+
+	t.Run("submissions beyond views never abandon below zero", func(t *testing.T) {
+		c := forms.Conversion{Views: 2, Submissions: 3}
+		assert.Equal(t, "150.0%", c.Rate())
+		assert.Equal(t, "0.0%", c.Abandonment())
+	})
+
+	t.Run("analytics list viewed origins and daily views", func(t *testing.T) {
+		a, err := forms.FormAnalytics(db, form, forms.ParseAnalyticsRange("7d", "", now))
+		require.NoError(t, err)
+		assert.Equal(t, forms.Conversion{Views: 5, Submissions: 2}, a.Conversion)
+		assert.Equal(t, 3+1, a.Series[6].Views)
+		assert.Equal(t, 1, a.Series[5].Views)
+
+		require.Len(t, a.Origins, 2)
+		assert.Equal(t, forms.AnalyticsCount{Label: "example.com", Count: 2, Share: 100, Views: 4}, a.Origins[0])
+		assert.Equal(t, "50.0%", a.Origins[0].ConversionRate())
+		assert.Equal(t, forms.AnalyticsCount{Label: "other.test", Views: 1}, a.Origins[1])
+	})
+}
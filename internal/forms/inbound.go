@@ -0,0 +1,284 @@
+package forms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxInboundPartDepth bounds multipart nesting so a crafted message can't
+// recurse without limit.
+const maxInboundPartDepth = 8
+
+// maxInboundTextSize caps the stored text or HTML body of an inbound email.
+const maxInboundTextSize = 1 << 20 // 1MB
+
+// InboundMessage is an email reduced to the parts stored on a submission.
+type InboundMessage struct {
+	From      string // Bare sender address
+	FromName  string // Sender display name, if any
+	Subject   string
+	Text      string
+	HTML      string
+	MessageID string
+	Spam      bool // The receiving mail server flagged the message as spam
+	Files     []*UploadedFile
+	Skipped   []string // Attachment filenames rejected by upload rules
+}
+
+// Payload converts the message into submission fields.
+func (m *InboundMessage) Payload() map[string]any {
+	payload := map[string]any{
+		"email":   m.From,
+		"subject": m.Subject,
+		"message": m.Text,
+	}
+	if m.Text == "" && m.HTML != "" {
+		payload["message"] = m.HTML
+	}
+	if m.FromName != "" {
+		payload["name"] = m.FromName
+	}
+	if m.MessageID != "" {
+		payload["message_id"] = m.MessageID
+	}
+	if len(m.Skipped) > 0 {
+		payload["skipped_attachments"] = m.Skipped
+	}
+	if m.Spam {
+		// Reuse the honeypot path: the submission is stored as spam and
+		// never forwarded to webhooks or email.
+		payload[HoneypotField] = "inbound-spam-flag"
+	}
+	return payload
+}
+
+// ApplySpamHeader marks the message as spam when a receiving server's spam
+// verdict header (X-Mailgun-Sflag, X-Spam-Flag) says so.
+func (m *InboundMessage) ApplySpamHeader(name, value string) {
+	switch textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name)) {
+	case "X-Mailgun-Sflag", "X-Spam-Flag":
+		if strings.EqualFold(strings.TrimSpace(value), "yes") {
+			m.Spam = true
+		}
+	}
+}
+
+// SetSender parses a From header value into address and display name.
+func (m *InboundMessage) SetSender(raw string) {
+	raw = strings.TrimSpace(raw)
+	if addr, err := mail.ParseAddress(raw); err == nil {
+		m.From = strings.ToLower(addr.Address)
+		m.FromName = addr.Name
+		return
+	}
+	m.From = raw
+}
+
+// AddAttachment keeps an attachment when it passes the same extension, size
+// and count rules as web uploads; otherwise its name is recorded as skipped.
+func (m *InboundMessage) AddAttachment(filename, contentType string, data []byte) {
+	filename = sanitizeFilename(filename)
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !allowedExtensions[ext] || len(data) > MaxFileSize || len(m.Files) >= MaxTotalFiles {
+		m.Skipped = append(m.Skipped, filename)
+		return
+	}
+	m.Files = append(m.Files, &UploadedFile{
+		FieldName:   "attachments",
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Data:        bytes.NewReader(data),
+	})
+}
+
+// ParseInboundMIME parses a raw RFC 5322 message into an InboundMessage.
+func ParseInboundMIME(r io.Reader) (*InboundMessage, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email message: %w", err)
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	m := &InboundMessage{
+		Subject:   strings.TrimSpace(subject),
+		MessageID: strings.TrimSpace(msg.Header.Get("Message-ID")),
+	}
+	m.SetSender(msg.Header.Get("From"))
+	for name, values := range msg.Header {
+		for _, value := range values {
+			m.ApplySpamHeader(name, value)
+		}
+	}
+
+	header := textproto.MIMEHeader(msg.Header)
+	body := decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err := m.walkPart(header, body, 0); err != nil {
+		CloseFiles(m.Files)
+		return nil, err
+	}
+	return m, nil
+}
+
+// walkPart collects text bodies and attachments from one MIME part,
+// descending into multipart containers.
+func (m *InboundMessage) walkPart(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxInboundPartDepth {
+		return errors.New("email message nested too deeply")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			return errors.New("multipart email without boundary")
+		}
+		reader := multipart.NewReader(body, boundary)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid multipart email: %w", err)
+			}
+			// multipart.Reader already decodes quoted-printable parts.
+			partBody := decodeTransferEncoding(part.Header.Get("Content-Transfer-Encoding"), part)
+			if err := m.walkPart(part.Header, partBody, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	if disposition == "attachment" || filename != "" {
+		data, err := io.ReadAll(io.LimitReader(body, MaxFileSize+1))
+		if err != nil {
+			return fmt.Errorf("read attachment: %w", err)
+		}
+		if filename == "" {
+			filename = "attachment"
+		}
+		m.AddAttachment(filename, mediaType, data)
+		return nil
+	}
+
+	switch mediaType {
+	case "text/plain", "text/html":
+		data, err := io.ReadAll(io.LimitReader(body, maxInboundTextSize))
+		if err != nil {
+			return fmt.Errorf("read email body: %w", err)
+		}
+		text := strings.TrimSpace(string(data))
+		if mediaType == "text/plain" && m.Text == "" {
+			m.Text = text
+		} else if mediaType == "text/html" && m.HTML == "" {
+			m.HTML = text
+		}
+	}
+	return nil
+}
+
+// decodeTransferEncoding wraps body with a decoder for the part's
+// Content-Transfer-Encoding. Unknown encodings are passed through.
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+// canonicalMailbox lowercases an address and strips any "+tag" subaddress.
+func canonicalMailbox(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	local, domain, ok := strings.Cut(address, "@")
+	if !ok {
+		return address
+	}
+	if base, _, tagged := strings.Cut(local, "+"); tagged {
+		local = base
+	}
+	return local + "@" + domain
+}
+
+// GetByInboundAddress finds the form whose inbound mailbox matches the
+// recipient. Subaddresses ("support+urgent@...") route to the base mailbox.
+func GetByInboundAddress(db *gorm.DB, recipient string) (*Form, error) {
+	address := recipient
+	if parsed, err := mail.ParseAddress(recipient); err == nil {
+		address = parsed.Address
+	}
+	address = canonicalMailbox(address)
+	if address == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var form Form
+	if err := db.Where("inbound_address = ?", address).
+		Preload("WebhookDelivery").
+		Preload("EmailDelivery").
+		Preload("EmailDelivery.MailerProfile").
+		First(&form).Error; err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
+// normalizeInboundAddress validates an inbound mailbox and ensures no other
+// form already claims it. Empty input disables inbound email for the form.
+func normalizeInboundAddress(db *gorm.DB, raw string, excludeID uint) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+
+	parsed, err := mail.ParseAddress(raw)
+	if err != nil || parsed.Name != "" {
+		return "", &ValidationError{Field: "inbound_address", Message: "Inbound address must be a plain email address"}
+	}
+	address := strings.ToLower(parsed.Address)
+	if canonicalMailbox(address) != address {
+		return "", &ValidationError{Field: "inbound_address", Message: "Inbound address cannot contain a +tag"}
+	}
+
+	var count int64
+	if err := db.Model(&Form{}).
+		Where("inbound_address = ? AND id <> ?", address, excludeID).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", &ValidationError{Field: "inbound_address", Message: "Inbound address is already used by another form"}
+	}
+	return address, nil
+}
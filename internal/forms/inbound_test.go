@@ -0,0 +1,143 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const inboundMultipartMessage = "From: Alice Example <Alice@Example.com>\r\n" +
+	"To: support@in.example.com\r\n" +
+	"Subject: =?UTF-8?Q?Caf=C3=A9_order?=\r\n" +
+	"Message-ID: <abc123@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Two lattes, pl=\r\n" +
+	"ease.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Two lattes, please.</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"order.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"order.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream\r\n" +
+	"Content-Disposition: attachment; filename=\"run.exe\"\r\n" +
+	"\r\n" +
+	"MZ\r\n" +
+	"--outer--\r\n"
+
+func TestParseInboundMIME(t *testing.T) {
+	t.Run("extracts sender, subject, text and attachments", func(t *testing.T) {
+		msg, err := forms.ParseInboundMIME(strings.NewReader(inboundMultipartMessage))
+		require.NoError(t, err)
+
+		assert.Equal(t, "alice@example.com", msg.From)
+		assert.Equal(t, "Alice Example", msg.FromName)
+		assert.Equal(t, "Café order", msg.Subject)
+		assert.Equal(t, "Two lattes, please.", msg.Text)
+		assert.Equal(t, "<p>Two lattes, please.</p>", msg.HTML)
+		assert.Equal(t, "<abc123@example.com>", msg.MessageID)
+
+		require.Len(t, msg.Files, 1)
+		assert.Equal(t, "order.pdf", msg.Files[0].Filename)
+		assert.Equal(t, "attachments", msg.Files[0].FieldName)
+		data, err := io.ReadAll(msg.Files[0].Data)
+		require.NoError(t, err)
+		assert.Equal(t, "%PDF-1.4\n", string(data))
+
+		assert.Equal(t, []string{"run.exe"}, msg.Skipped)
+
+		payload := msg.Payload()
+		assert.Equal(t, "alice@example.com", payload["email"])
+		assert.Equal(t, "Two lattes, please.", payload["message"])
+		assert.NotContains(t, payload, forms.HoneypotField)
+	})
+
+	t.Run("handles single-part messages", func(t *testing.T) {
+		raw := "From: bob@example.com\r\nSubject: Hi\r\n\r\nHello there\r\n"
+		msg, err := forms.ParseInboundMIME(strings.NewReader(raw))
+		require.NoError(t, err)
+
+		assert.Equal(t, "bob@example.com", msg.From)
+		assert.Equal(t, "Hello there", msg.Text)
+		assert.Empty(t, msg.Files)
+	})
+
+	t.Run("provider spam flag routes through honeypot", func(t *testing.T) {
+		raw := "From: bot@example.com\r\nX-Mailgun-Sflag: Yes\r\nSubject: Win\r\n\r\nBuy now\r\n"
+		msg, err := forms.ParseInboundMIME(strings.NewReader(raw))
+		require.NoError(t, err)
+
+		assert.True(t, msg.Spam)
+		assert.Contains(t, msg.Payload(), forms.HoneypotField)
+	})
+
+	t.Run("rejects malformed input", func(t *testing.T) {
+		_, err := forms.ParseInboundMIME(strings.NewReader("not an email"))
+		assert.Error(t, err)
+	})
+}
+
+func TestInboundAddress(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	form, err := forms.Create(logger, db, forms.CreateParams{
+		Name:           "Support",
+		Slug:           "support",
+		AllowedOrigins: "*",
+		InboundAddress: " Support@In.Example.com ",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "support@in.example.com", form.InboundAddress)
+
+	t.Run("resolves recipient including +tag and display name", func(t *testing.T) {
+		found, err := forms.GetByInboundAddress(db, "Help Desk <support+urgent@in.example.com>")
+		require.NoError(t, err)
+		assert.Equal(t, form.ID, found.ID)
+	})
+
+	t.Run("rejects address already used by another form", func(t *testing.T) {
+		_, err := forms.Create(logger, db, forms.CreateParams{
+			Name:           "Other",
+			Slug:           "other",
+			AllowedOrigins: "*",
+			InboundAddress: "support@in.example.com",
+		})
+		var valErr *forms.ValidationError
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "inbound_address", valErr.Field)
+	})
+
+	t.Run("stores submission with attachment through the normal pipeline", func(t *testing.T) {
+		msg, err := forms.ParseInboundMIME(strings.NewReader(inboundMultipartMessage))
+		require.NoError(t, err)
+
+		sub, err := forms.CreateSubmissionWithFiles(logger, db, form, msg.Payload(), "inbound", t.TempDir(), msg.Files)
+		require.NoError(t, err)
+		assert.False(t, sub.IsSpam)
+		require.Len(t, sub.Files, 1)
+		assert.Equal(t, "order.pdf", sub.Files[0].Filename)
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"formlander/internal/accounts"
 	"formlander/internal/integrations"
 )
 
@@ -21,6 +22,10 @@ const (
 
 	// DefaultRetryLimit is the opinionated retry count for all deliveries
 	DefaultRetryLimit = 3
+
+	DigestModeInstant = "instant"
+	DigestModeDaily   = "daily"
+	DigestModeWeekly  = "weekly"
 )
 
 // Form denotes a configured form endpoint.
@@ -36,6 +41,30 @@ type Form struct {
 	CaptchaProfileID     *uint                        `gorm:"index"`     // Foreign key to CaptchaProfile
 	CaptchaProfile       *integrations.CaptchaProfile `gorm:"constraint:OnDelete:SET NULL"`
 	CaptchaOverridesJSON string                       `gorm:"type:text"` // JSON: {required, action, widget}
+	InboundAddress       string                       `gorm:"size:255;index"` // Mailbox whose inbound email becomes submissions (optional)
+	HostedEnabled        bool                         `gorm:"not null;default:false"` // Serve a public page at /f/:public_id
+	HostedTitle          string                       `gorm:"size:255"`               // Hosted page heading, defaults to Name
+	HostedDescription    string                       `gorm:"type:text"`
+	HostedLogoURL        string                       `gorm:"size:2048"`
+	HostedAccentColor    string                       `gorm:"size:16"`   // Hex color for buttons and links
+	HostedCSS            string                       `gorm:"type:text"` // Custom CSS appended to the hosted page theme
+	SuccessTitle         string                       `gorm:"size:255"`  // Thank-you page shown to browser posts without _success_url
+	SuccessMessage       string                       `gorm:"type:text"`
+	ErrorTitle           string                       `gorm:"size:255"` // Error page shown to browser posts without _error_url
+	ErrorMessage         string                       `gorm:"type:text"`
+	OpensAt              *time.Time                   // Submissions before this time are refused (optional)
+	ClosesAt             *time.Time                   // Submissions from this time on are refused (optional)
+	MaxSubmissions       int                          `gorm:"not null;default:0"` // 0 = unlimited
+	CapIgnoresSpam       bool                         `gorm:"not null;default:false"` // Count only non-spam submissions toward MaxSubmissions
+	ClosedURL            string                       `gorm:"size:2048"` // Redirect target when the form isn't accepting submissions
+	ClosedMessage        string                       `gorm:"type:text"`
+	UniqueField          string                       `gorm:"size:255"` // Submission field that must be unique per form (optional)
+	UniqueAction         string                       `gorm:"size:16"`  // reject, ignore or update
+	DoubleOptIn          bool                         `gorm:"not null;default:false"` // Forward submissions only after the submitter confirms by email
+	OptInEmailField      string                       `gorm:"size:255"`               // Field holding the submitter's address
+	OptInExpiryHours     int                          `gorm:"not null;default:0"`     // Confirmation link lifetime, 0 = default
+	OptInSubject         string                       `gorm:"size:255"`
+	OptInMessage         string                       `gorm:"type:text"`
 	CreatedAt            time.Time
 	UpdatedAt            time.Time
 
@@ -144,25 +173,142 @@ type EmailDelivery struct {
 	MailerProfileID *uint                       `gorm:"index"` // Foreign key to MailerProfile
 	MailerProfile   *integrations.MailerProfile `gorm:"constraint:OnDelete:SET NULL"`
 	OverridesJSON   string                      `gorm:"type:text"` // JSON: {to, cc, bcc, subject, template, tags, reply_to}
+	DigestMode      string                      `gorm:"size:16;not null;default:'instant'"` // instant | daily | weekly
+	DigestHour      int                         `gorm:"not null;default:9"`                 // Local hour (0-23) digests are sent
+	DigestWeekday   int                         `gorm:"not null;default:1"`                 // Weekly digests: 0=Sunday ... 6=Saturday
+	DigestTimezone  string                      `gorm:"size:64"`                            // IANA zone name, empty = UTC
+	LastDigestAt    *time.Time                  // End of the last digest window that was sent
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
 
 // Submission stores the payload received from a public form post.
 type Submission struct {
-	ID        uint   `gorm:"primaryKey"`
-	FormID    uint   `gorm:"index;not null"`
-	Form      *Form  `gorm:"constraint:OnDelete:CASCADE"`
-	DataJSON  string `gorm:"type:text;not null"`
-	IPHash    string `gorm:"size:128;index"`
-	UserAgent string `gorm:"type:text"`
-	IsSpam    bool   `gorm:"index"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             uint   `gorm:"primaryKey"`
+	FormID         uint   `gorm:"index;not null"`
+	Form           *Form  `gorm:"constraint:OnDelete:CASCADE"`
+	DataJSON       string `gorm:"type:text;not null"`
+	IPHash         string `gorm:"size:128;index"`
+	UserAgent      string `gorm:"type:text"`
+	Origin         string `gorm:"size:255;index"` // Domain of the page that posted, from Origin or Referer
+	IsSpam         bool   `gorm:"index"`
+	IdempotencyKey string `gorm:"size:128;index"` // Client-supplied key; retried posts with the same key are deduplicated
+	UniqueKey      string `gorm:"size:64;index"`  // Fingerprint of the form's unique field value
+	IsDuplicate    bool   `gorm:"not null;default:false;index"`
+	DuplicateOfID  *uint  // Earlier submission this one repeats
+	DuplicateCount int    `gorm:"not null;default:0"` // Repeats received for this submission's unique value
+	// Double opt-in state: pending until the submitter follows the emailed link.
+	ConfirmationStatus string `gorm:"size:16;index"`
+	ConfirmationSentAt *time.Time
+	ConfirmationError  string `gorm:"type:text"` // Why the confirmation email couldn't be sent
+	ConfirmedAt        *time.Time
+	// Triage workflow, worked by admins from the submissions inbox.
+	Status     string         `gorm:"size:16;not null;default:'new';index"`
+	AssigneeID *uint          `gorm:"index"`
+	Assignee   *accounts.User `gorm:"constraint:OnDelete:SET NULL"`
+	Tags       string         `gorm:"type:text"` // Comma-separated, lowercased
+	ReadAt     *time.Time     // First opened by an admin; nil = unread
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 
 	WebhookEvents []WebhookEvent
 	EmailEvents   []EmailEvent
 	Files         []*SubmissionFile
+	Notes         []SubmissionNote
+	Replies       []SubmissionReply
+}
+
+// SubmissionNote is an internal note left on a submission by an admin.
+type SubmissionNote struct {
+	ID           uint        `gorm:"primaryKey"`
+	SubmissionID uint        `gorm:"index;not null"`
+	Submission   *Submission `gorm:"constraint:OnDelete:CASCADE"`
+	AuthorID     *uint       `gorm:"index"`
+	AuthorEmail  string      `gorm:"size:255"` // Kept so notes stay attributed if the user goes away
+	Body         string      `gorm:"type:text;not null"`
+	CreatedAt    time.Time
+}
+
+// SubmissionReply is an email sent to the submitter from the admin UI.
+// Replies to one submission form a thread: each carries its own Message-ID
+// and points at the previous message with In-Reply-To/References.
+type SubmissionReply struct {
+	ID              uint                        `gorm:"primaryKey"`
+	SubmissionID    uint                        `gorm:"index;not null"`
+	Submission      *Submission                 `gorm:"constraint:OnDelete:CASCADE"`
+	MailerProfileID *uint                       `gorm:"index"`
+	MailerProfile   *integrations.MailerProfile `gorm:"constraint:OnDelete:SET NULL"`
+	AuthorID        *uint                       `gorm:"index"`
+	AuthorEmail     string                      `gorm:"size:255"`
+	To              string                      `gorm:"size:255;not null"`
+	Subject         string                      `gorm:"size:255;not null"`
+	Body            string                      `gorm:"type:text;not null"`
+	MessageID       string                      `gorm:"size:255;uniqueIndex;not null"` // Without angle brackets
+	InReplyTo       string                      `gorm:"size:255"`
+	References      string                      `gorm:"type:text"` // Space-separated thread ancestry, oldest first
+	Status          string                      `gorm:"size:32;index;not null"` // Same states as email events
+	AttemptCount    int                         `gorm:"not null;default:0"`
+	LastAttemptErr  string                      `gorm:"type:text"`
+	NextAttemptAt   *time.Time
+	LastAttemptAt   *time.Time
+	SentAt          *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// SavedView is a named submissions filter saved by an admin. Pinned views
+// are listed next to the inbox.
+type SavedView struct {
+	ID        uint           `gorm:"primaryKey"`
+	UserID    uint           `gorm:"not null;uniqueIndex:idx_saved_views_user_name"`
+	User      *accounts.User `gorm:"constraint:OnDelete:CASCADE"`
+	Name      string         `gorm:"size:100;not null;uniqueIndex:idx_saved_views_user_name"`
+	Query     string         `gorm:"type:text;not null"` // Encoded SubmissionFilter
+	Pinned    bool           `gorm:"not null;default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// FormGrant gives an editor or viewer access to one form. Owners and admins
+// see every form and need no grants.
+type FormGrant struct {
+	ID        uint           `gorm:"primaryKey"`
+	UserID    uint           `gorm:"not null;uniqueIndex:idx_form_grants_user_form"`
+	User      *accounts.User `gorm:"constraint:OnDelete:CASCADE"`
+	FormID    uint           `gorm:"not null;uniqueIndex:idx_form_grants_user_form;index"`
+	Form      *Form          `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time
+}
+
+// SubmissionRollup holds one hour of a form's submission and delivery
+// counts, so analytics over long ranges don't scan every submission.
+// Latencies are summed milliseconds; divide by the delivered count.
+type SubmissionRollup struct {
+	ID               uint      `gorm:"primaryKey"`
+	FormID           uint      `gorm:"not null;uniqueIndex:idx_submission_rollups_form_hour"`
+	Form             *Form     `gorm:"constraint:OnDelete:CASCADE"`
+	Hour             time.Time `gorm:"not null;uniqueIndex:idx_submission_rollups_form_hour"` // UTC start of the hour
+	Submissions      int       `gorm:"not null;default:0"`
+	Spam             int       `gorm:"not null;default:0"`
+	WebhookDelivered int       `gorm:"not null;default:0"`
+	WebhookFailed    int       `gorm:"not null;default:0"`
+	WebhookLatencyMs int64     `gorm:"not null;default:0"`
+	EmailDelivered   int       `gorm:"not null;default:0"`
+	EmailFailed      int       `gorm:"not null;default:0"`
+	EmailLatencyMs   int64     `gorm:"not null;default:0"`
+	UpdatedAt        time.Time
+}
+
+// FormView counts the impressions of a form per UTC day and origin, as
+// reported by the view beacon. No visitor data is kept.
+type FormView struct {
+	ID        uint      `gorm:"primaryKey"`
+	FormID    uint      `gorm:"not null;uniqueIndex:idx_form_views_form_day_origin"`
+	Form      *Form     `gorm:"constraint:OnDelete:CASCADE"`
+	Day       time.Time `gorm:"not null;uniqueIndex:idx_form_views_form_day_origin"` // UTC midnight
+	Origin    string    `gorm:"size:255;not null;default:'';uniqueIndex:idx_form_views_form_day_origin"`
+	Views     int       `gorm:"not null;default:0"`
+	UpdatedAt time.Time
 }
 
 // WebhookEvent captures delivery attempts for a submission.
@@ -171,6 +317,7 @@ type WebhookEvent struct {
 	SubmissionID   uint        `gorm:"index;not null"`
 	Submission     *Submission `gorm:"constraint:OnDelete:CASCADE"`
 	Status         string      `gorm:"size:32;index;not null"`
+	URL            string      `gorm:"type:text"` // Set by a routing rule; empty posts to the form's webhook
 	AttemptCount   int         `gorm:"not null;default:0"`
 	LastAttemptErr string      `gorm:"type:text"`
 	NextAttemptAt  *time.Time
@@ -196,6 +343,7 @@ type EmailEvent struct {
 	SubmissionID   uint        `gorm:"index;not null"`
 	Submission     *Submission `gorm:"constraint:OnDelete:CASCADE"`
 	Status         string      `gorm:"size:32;index;not null"`
+	Recipient      string      `gorm:"size:255"` // Set by a routing rule; empty sends to the form's recipient
 	AttemptCount   int         `gorm:"not null;default:0"`
 	LastAttemptErr string      `gorm:"type:text"`
 	NextAttemptAt  *time.Time
@@ -204,6 +352,24 @@ type EmailEvent struct {
 	UpdatedAt      time.Time
 }
 
+// RoutingRule decides, per submission, which deliveries get events. Rules run
+// in position order; each compares one field and adds or skips a delivery.
+type RoutingRule struct {
+	ID        uint   `gorm:"primaryKey"`
+	FormID    uint   `gorm:"index;not null"`
+	Form      *Form  `gorm:"constraint:OnDelete:CASCADE"`
+	Position  int    `gorm:"not null;default:0"`
+	Name      string `gorm:"size:255"`
+	Field     string `gorm:"size:255;not null"` // Field path such as department or contact.country
+	Operator  string `gorm:"size:16;not null"`
+	Value     string `gorm:"type:text"` // Comma-separated list for in / not_in
+	Action    string `gorm:"size:16;not null"`
+	Target    string `gorm:"type:text"`              // Address or URL for email / webhook actions
+	Stop      bool   `gorm:"not null;default:false"` // Skip later rules when this one matches
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 // SubmissionFile stores metadata for uploaded files.
 type SubmissionFile struct {
 	ID           uint        `gorm:"primaryKey"`
@@ -0,0 +1,295 @@
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxFieldDepth bounds how deeply a bracket/dot field name may nest
+// (address[street] is depth 2).
+const MaxFieldDepth = 5
+
+// FieldDecoder builds a nested payload from urlencoded or multipart field
+// names using bracket and dot notation:
+//
+//	address[street]=Main   -> {"address": {"street": "Main"}}
+//	address.city=Oslo      -> {"address": {"city": "Oslo"}}
+//	items[0][qty]=2        -> {"items": [{"qty": "2"}]}
+//	tags[]=a&tags[]=b      -> {"tags": ["a", "b"]}
+//
+// Plain names behave as before: one value is a string, repeated values a
+// list. A numeric key at or past the field limit is an object key
+// (year[2025]) unless the field is already a list, where it is rejected.
+type FieldDecoder struct {
+	maxFields int
+	count     int
+	root      *fieldNode
+}
+
+type fieldNode struct {
+	values   []string
+	children map[string]*fieldNode
+	keyed    bool // has a child that isn't an array index, so builds an object
+}
+
+func (n *fieldNode) isBranch() bool { return n.children != nil }
+
+// NewFieldDecoder returns a decoder that accepts at most maxFields values.
+func NewFieldDecoder(maxFields int) *FieldDecoder {
+	return &FieldDecoder{
+		maxFields: maxFields,
+		root:      &fieldNode{children: map[string]*fieldNode{}, keyed: true},
+	}
+}
+
+// Add records the values posted under one field name.
+func (d *FieldDecoder) Add(name string, values ...string) error {
+	segments, err := splitFieldName(name)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		d.count++
+		if d.maxFields > 0 && d.count > d.maxFields {
+			return fmt.Errorf("too many fields")
+		}
+		if err := d.insert(name, segments, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Result returns the decoded payload.
+func (d *FieldDecoder) Result() map[string]any {
+	out := make(map[string]any, len(d.root.children))
+	for key, child := range d.root.children {
+		out[key] = child.build()
+	}
+	return out
+}
+
+func (d *FieldDecoder) insert(name string, segments []fieldSegment, value string) error {
+	node := d.root
+	for i, seg := range segments {
+		key := seg.name
+		if seg.appends {
+			key = strconv.Itoa(len(node.children))
+		}
+		if !node.keyed {
+			idx, isIndex := arrayIndex(key)
+			if isIndex && d.maxFields > 0 && idx >= d.maxFields {
+				if len(node.children) > 0 {
+					return fmt.Errorf("field %q: index too large", name)
+				}
+				isIndex = false
+			}
+			node.keyed = !isIndex
+		}
+
+		child, exists := node.children[key]
+		last := i == len(segments)-1
+		if last {
+			if exists && child.isBranch() {
+				return fmt.Errorf("field %q conflicts with a nested field", name)
+			}
+			if !exists {
+				child = &fieldNode{}
+				node.children[key] = child
+			}
+			child.values = append(child.values, value)
+			return nil
+		}
+
+		if exists && !child.isBranch() {
+			return fmt.Errorf("field %q conflicts with a plain field", name)
+		}
+		if !exists {
+			child = &fieldNode{children: map[string]*fieldNode{}}
+			node.children[key] = child
+		}
+		node = child
+	}
+	return nil
+}
+
+// build converts a node into JSON-ready values. Branches whose keys are all
+// array indexes become lists ordered by index; gaps are closed.
+func (n *fieldNode) build() any {
+	if !n.isBranch() {
+		if len(n.values) == 1 {
+			return n.values[0]
+		}
+		return append([]string(nil), n.values...)
+	}
+
+	if !n.keyed {
+		indexes := make([]int, 0, len(n.children))
+		for key := range n.children {
+			idx, _ := arrayIndex(key)
+			indexes = append(indexes, idx)
+		}
+		sort.Ints(indexes)
+		list := make([]any, 0, len(indexes))
+		for _, idx := range indexes {
+			list = append(list, n.children[strconv.Itoa(idx)].build())
+		}
+		return list
+	}
+
+	out := make(map[string]any, len(n.children))
+	for key, child := range n.children {
+		out[key] = child.build()
+	}
+	return out
+}
+
+// arrayIndex reports whether key reads as an array index such as "0" or "12".
+func arrayIndex(key string) (int, bool) {
+	idx, err := strconv.Atoi(key)
+	if err != nil || idx < 0 || strconv.Itoa(idx) != key {
+		return 0, false
+	}
+	return idx, true
+}
+
+type fieldSegment struct {
+	name    string
+	appends bool // "[]": add a new element
+}
+
+// splitFieldName parses "a[b][0].c" into its segments. Names that aren't
+// well-formed nested names (unclosed brackets, empty segments) are kept as a
+// single literal key, matching how they were stored before nesting existed.
+func splitFieldName(name string) ([]fieldSegment, error) {
+	literal := []fieldSegment{{name: name}}
+
+	head := strings.IndexAny(name, "[.")
+	if head <= 0 {
+		return literal, nil
+	}
+	segments := []fieldSegment{{name: name[:head]}}
+
+	rest := name[head:]
+	for rest != "" {
+		switch rest[0] {
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return literal, nil
+			}
+			inner := rest[1:end]
+			if strings.ContainsAny(inner, "[.") {
+				return literal, nil
+			}
+			segments = append(segments, fieldSegment{name: inner, appends: inner == ""})
+			rest = rest[end+1:]
+		case '.':
+			end := strings.IndexAny(rest[1:], "[.")
+			part := rest[1:]
+			if end >= 0 {
+				part = rest[1 : end+1]
+			}
+			if part == "" {
+				return literal, nil
+			}
+			segments = append(segments, fieldSegment{name: part})
+			rest = rest[1+len(part):]
+		default:
+			return literal, nil
+		}
+	}
+
+	if len(segments) > MaxFieldDepth {
+		return nil, fmt.Errorf("field %q is nested too deeply", name)
+	}
+	return segments, nil
+}
+
+// FlatField is one leaf of a submission payload, addressed by its path
+// (address.street, items[0].qty).
+type FlatField struct {
+	Path  string
+	Value string
+}
+
+// FlattenData lists the leaves of a decoded payload in a stable order for
+// display in emails, exports and the admin. Lists of plain values are
+// joined with ", " instead of being indexed.
+func FlattenData(data any) []FlatField {
+	var out []FlatField
+	flattenInto(&out, "", data)
+	return out
+}
+
+// FlattenDataJSON decodes a stored DataJSON and flattens it. Undecodable
+// payloads come back as a single "raw" field.
+func FlattenDataJSON(dataJSON string) []FlatField {
+	var data any
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		return []FlatField{{Path: "raw", Value: dataJSON}}
+	}
+	return FlattenData(data)
+}
+
+func flattenInto(out *[]FlatField, path string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			flattenInto(out, child, v[key])
+		}
+	case []any:
+		if scalars, ok := joinScalars(v); ok {
+			*out = append(*out, FlatField{Path: path, Value: scalars})
+			return
+		}
+		for i, item := range v {
+			flattenInto(out, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case []string:
+		*out = append(*out, FlatField{Path: path, Value: strings.Join(v, ", ")})
+	case nil:
+		*out = append(*out, FlatField{Path: path})
+	default:
+		*out = append(*out, FlatField{Path: path, Value: scalarString(v)})
+	}
+}
+
+func joinScalars(list []any) (string, bool) {
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		switch item.(type) {
+		case map[string]any, []any:
+			return "", false
+		}
+		parts = append(parts, scalarString(item))
+	}
+	return strings.Join(parts, ", "), true
+}
+
+func scalarString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	default:
+		encoded, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Sprintf("%v", s)
+		}
+		return string(encoded)
+	}
+}
@@ -0,0 +1,117 @@
+package forms_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"formlander/internal/forms"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldDecoder(t *testing.T) {
+	decode := func(t *testing.T, maxFields int, pairs ...[2]string) (map[string]any, error) {
+		t.Helper()
+		d := forms.NewFieldDecoder(maxFields)
+		for _, p := range pairs {
+			if err := d.Add(p[0], p[1]); err != nil {
+				return nil, err
+			}
+		}
+		return d.Result(), nil
+	}
+	asJSON := func(v any) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+
+	t.Run("decodes brackets, dots, indexes and appends", func(t *testing.T) {
+		got, err := decode(t, 100,
+			[2]string{"name", "Ada"},
+			[2]string{"address[street]", "Main St"},
+			[2]string{"address.city", "Oslo"},
+			[2]string{"items[1][qty]", "5"},
+			[2]string{"items[0][qty]", "2"},
+			[2]string{"items[0][sku]", "A-1"},
+			[2]string{"tags[]", "red"},
+			[2]string{"tags[]", "blue"},
+			[2]string{"single[]", "only"},
+		)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"name": "Ada",
+			"address": {"street": "Main St", "city": "Oslo"},
+			"items": [{"qty": "2", "sku": "A-1"}, {"qty": "5"}],
+			"tags": ["red", "blue"],
+			"single": ["only"]
+		}`, asJSON(got))
+	})
+
+	t.Run("repeated plain names still become lists", func(t *testing.T) {
+		got, err := decode(t, 100, [2]string{"color", "a"}, [2]string{"color", "b"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, got["color"])
+	})
+
+	t.Run("malformed names stay literal", func(t *testing.T) {
+		got, err := decode(t, 100,
+			[2]string{"weird[key", "1"},
+			[2]string{"[lead]", "2"},
+			[2]string{"trailing.", "3"},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "1", got["weird[key"])
+		assert.Equal(t, "2", got["[lead]"])
+		assert.Equal(t, "3", got["trailing."])
+	})
+
+	t.Run("enforces limits", func(t *testing.T) {
+		_, err := decode(t, 100, [2]string{"a[b][c][d][e][f]", "deep"})
+		assert.ErrorContains(t, err, "nested too deeply")
+
+		_, err = decode(t, 10, [2]string{"items[0]", "x"}, [2]string{"items[99999999]", "y"})
+		assert.ErrorContains(t, err, "index too large")
+
+		_, err = decode(t, 2, [2]string{"a", "1"}, [2]string{"b", "2"}, [2]string{"c", "3"})
+		assert.ErrorContains(t, err, "too many fields")
+	})
+
+	t.Run("numeric keys past the field limit are object keys", func(t *testing.T) {
+		got, err := decode(t, 10,
+			[2]string{"2025", "top"},
+			[2]string{"year[2025]", "a"},
+			[2]string{"year[2026]", "b"},
+			[2]string{"year[3]", "c"},
+			[2]string{"items[99999999]", "x"},
+		)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"2025": "top",
+			"year": {"2025": "a", "2026": "b", "3": "c"},
+			"items": {"99999999": "x"}
+		}`, asJSON(got))
+	})
+
+	t.Run("rejects conflicting shapes", func(t *testing.T) {
+		_, err := decode(t, 100, [2]string{"address", "x"}, [2]string{"address[street]", "y"})
+		assert.Error(t, err)
+
+		_, err = decode(t, 100, [2]string{"address[street]", "y"}, [2]string{"address", "x"})
+		assert.Error(t, err)
+	})
+}
+
+func TestFlattenDataJSON(t *testing.T) {
+	fields := forms.FlattenDataJSON(`{"name":"Ada","address":{"city":"Oslo"},"items":[{"qty":"2"}],"tags":["a","b"],"n":3}`)
+
+	assert.Equal(t, []forms.FlatField{
+		{Path: "address.city", Value: "Oslo"},
+		{Path: "items[0].qty", Value: "2"},
+		{Path: "n", Value: "3"},
+		{Path: "name", Value: "Ada"},
+		{Path: "tags", Value: "a, b"},
+	}, fields)
+
+	assert.Equal(t, []forms.FlatField{{Path: "raw", Value: "not json"}}, forms.FlattenDataJSON("not json"))
+}
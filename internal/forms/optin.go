@@ -0,0 +1,259 @@
+package forms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/pkg/dbtxn"
+)
+
+// Confirmation states of a submission on a double opt-in form. Submissions
+// on other forms leave the state empty.
+const (
+	ConfirmationPending   = "pending"
+	ConfirmationConfirmed = "confirmed"
+	ConfirmationExpired   = "expired"
+)
+
+// Double opt-in defaults.
+const (
+	DefaultOptInEmailField  = "email"
+	DefaultOptInExpiryHours = 72
+	MaxOptInExpiryHours     = 30 * 24
+	DefaultOptInSubject     = "Please confirm your submission"
+
+	DefaultPendingTitle     = "Check your inbox"
+	DefaultPendingMessage   = "We've sent you an email with a link to confirm your submission."
+	DefaultConfirmTitle     = "Confirm your submission"
+	DefaultConfirmedTitle   = "Thanks for confirming!"
+	DefaultConfirmedMessage = "Your submission is confirmed."
+)
+
+// Errors returned by the double opt-in flow.
+var (
+	ErrOptInAddressMissing = errors.New("a valid email address is required")
+	ErrConfirmationInvalid = errors.New("invalid confirmation link")
+	ErrConfirmationExpired = errors.New("confirmation link has expired")
+)
+
+// OptInParams holds a form's double opt-in settings.
+type OptInParams struct {
+	Enabled     bool
+	EmailField  string // Field holding the submitter's address
+	ExpiryHours int    // How long the confirmation link stays valid
+	Subject     string
+	Message     string // Shown above the link in the confirmation email
+}
+
+// apply copies the normalized settings onto a form.
+func (p OptInParams) apply(form *Form) {
+	form.DoubleOptIn = p.Enabled
+	form.OptInEmailField = p.EmailField
+	form.OptInExpiryHours = p.ExpiryHours
+	form.OptInSubject = p.Subject
+	form.OptInMessage = p.Message
+}
+
+// normalizeOptIn trims and validates double opt-in settings.
+func normalizeOptIn(p OptInParams) (OptInParams, error) {
+	p.EmailField = strings.TrimSpace(p.EmailField)
+	p.Subject = strings.TrimSpace(p.Subject)
+	p.Message = strings.TrimSpace(p.Message)
+	if p.EmailField == "" {
+		p.EmailField = DefaultOptInEmailField
+	}
+	if p.ExpiryHours == 0 {
+		p.ExpiryHours = DefaultOptInExpiryHours
+	}
+	if p.ExpiryHours < 1 || p.ExpiryHours > MaxOptInExpiryHours {
+		return p, &ValidationError{Field: "opt_in_expiry_hours", Message: fmt.Sprintf("Confirmation links must expire within 1 to %d hours", MaxOptInExpiryHours)}
+	}
+	return p, nil
+}
+
+// OptInExpiry returns how long a confirmation link stays valid.
+func (f *Form) OptInExpiry() time.Duration {
+	hours := f.OptInExpiryHours
+	if hours <= 0 {
+		hours = DefaultOptInExpiryHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// OptInEmailSubject returns the subject of the confirmation email.
+func (f *Form) OptInEmailSubject() string {
+	return firstNonEmpty(f.OptInSubject, DefaultOptInSubject)
+}
+
+// OptInAddress returns the submitter's address from a stored payload, or ""
+// when the opt-in field is missing or not a valid address.
+func (f *Form) OptInAddress(dataJSON string) string {
+	return f.optInAddress(FlattenDataJSON(dataJSON))
+}
+
+func (f *Form) optInAddress(fields []FlatField) string {
+	name := firstNonEmpty(f.OptInEmailField, DefaultOptInEmailField)
+	for _, field := range fields {
+		if field.Path != name {
+			continue
+		}
+		addr, err := mail.ParseAddress(strings.TrimSpace(field.Value))
+		if err != nil {
+			return ""
+		}
+		return addr.Address
+	}
+	return ""
+}
+
+// AwaitingConfirmation reports whether the submission was never confirmed
+// and so must not be forwarded.
+func (s *Submission) AwaitingConfirmation() bool {
+	return s.ConfirmationStatus == ConfirmationPending || s.ConfirmationStatus == ConfirmationExpired
+}
+
+// ConfirmationToken signs a confirmation link token for a submission. The
+// token carries its own expiry so links can be checked without a lookup.
+func ConfirmationToken(secret string, submissionID uint, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d", submissionID, expires.Unix())
+	return payload + "." + confirmationSignature(secret, payload)
+}
+
+// ParseConfirmationToken verifies a token and returns its submission ID.
+func ParseConfirmationToken(secret, token string, now time.Time) (uint, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrConfirmationInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(confirmationSignature(secret, payload))) {
+		return 0, ErrConfirmationInvalid
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, ErrConfirmationInvalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrConfirmationInvalid
+	}
+	if !now.Before(time.Unix(expires, 0)) {
+		return 0, ErrConfirmationExpired
+	}
+	return uint(id), nil
+}
+
+func confirmationSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("optin:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ConfirmSubmission marks a pending submission confirmed and queues its
+// webhook and email deliveries. Confirming twice is harmless: the second call
+// reports alreadyConfirmed and queues nothing.
+func ConfirmSubmission(logger *slog.Logger, db *gorm.DB, submissionID uint, now time.Time) (submission *Submission, alreadyConfirmed bool, err error) {
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var sub Submission
+		if err := tx.Preload("Form.WebhookDelivery").Preload("Form.EmailDelivery").First(&sub, submissionID).Error; err != nil {
+			return err
+		}
+		submission = &sub
+		alreadyConfirmed = false
+
+		switch sub.ConfirmationStatus {
+		case ConfirmationConfirmed:
+			alreadyConfirmed = true
+			return nil
+		case ConfirmationPending:
+		case ConfirmationExpired:
+			return ErrConfirmationExpired
+		default:
+			return ErrConfirmationInvalid
+		}
+
+		if err := tx.Model(&sub).Updates(map[string]any{
+			"confirmation_status": ConfirmationConfirmed,
+			"confirmed_at":        now,
+		}).Error; err != nil {
+			return err
+		}
+		sub.ConfirmationStatus = ConfirmationConfirmed
+		sub.ConfirmedAt = &now
+
+		return queueDeliveries(tx, sub.Form, sub.ID, FlattenDataJSON(sub.DataJSON), now)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if !alreadyConfirmed {
+		logger.Info("submission confirmed",
+			slog.Uint64("form_id", uint64(submission.FormID)),
+			slog.Uint64("submission_id", uint64(submission.ID)),
+		)
+	}
+	return submission, alreadyConfirmed, nil
+}
+
+// PendingConfirmations returns unconfirmed submissions whose confirmation
+// email has not been sent yet, oldest first.
+func PendingConfirmations(db *gorm.DB, limit int) ([]Submission, error) {
+	var submissions []Submission
+	err := db.Preload("Form.EmailDelivery").
+		Where("confirmation_status = ? AND confirmation_sent_at IS NULL AND (confirmation_error IS NULL OR confirmation_error = '')", ConfirmationPending).
+		Order("id ASC").
+		Limit(limit).
+		Find(&submissions).Error
+	return submissions, err
+}
+
+// RecordConfirmationSent stores when the confirmation email went out, or why
+// it can't be sent. A recorded error stops further attempts.
+func RecordConfirmationSent(logger *slog.Logger, db *gorm.DB, submissionID uint, sentAt time.Time, sendErr string) error {
+	values := map[string]any{"confirmation_error": sendErr}
+	if sendErr == "" {
+		values["confirmation_sent_at"] = sentAt
+	}
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		return tx.Model(&Submission{}).Where("id = ?", submissionID).Updates(values).Error
+	})
+}
+
+// ExpireUnconfirmed marks pending submissions whose confirmation window has
+// passed as expired. It returns how many were expired.
+func ExpireUnconfirmed(logger *slog.Logger, db *gorm.DB, now time.Time) (int64, error) {
+	var pendingForms []Form
+	if err := db.Select("id", "opt_in_expiry_hours").
+		Where("id IN (?)", db.Model(&Submission{}).Select("form_id").Where("confirmation_status = ?", ConfirmationPending)).
+		Find(&pendingForms).Error; err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	for _, form := range pendingForms {
+		err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+			result := tx.Model(&Submission{}).
+				Where("form_id = ? AND confirmation_status = ? AND created_at < ?", form.ID, ConfirmationPending, now.Add(-form.OptInExpiry())).
+				Update("confirmation_status", ConfirmationExpired)
+			if result.Error != nil {
+				return result.Error
+			}
+			expired += result.RowsAffected
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,103 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmationToken(t *testing.T) {
+	now := time.Now()
+	token := forms.ConfirmationToken("secret", 42, now.Add(time.Hour))
+
+	id, err := forms.ParseConfirmationToken("secret", token, now)
+	require.NoError(t, err)
+	assert.Equal(t, uint(42), id)
+
+	_, err = forms.ParseConfirmationToken("other", token, now)
+	assert.ErrorIs(t, err, forms.ErrConfirmationInvalid)
+
+	_, err = forms.ParseConfirmationToken("secret", "43"+token[2:], now)
+	assert.ErrorIs(t, err, forms.ErrConfirmationInvalid, "the ID is covered by the signature")
+
+	_, err = forms.ParseConfirmationToken("secret", token, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, forms.ErrConfirmationExpired)
+}
+
+func TestDoubleOptIn(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	setup := func(t *testing.T) (*gorm.DB, *forms.Form) {
+		t.Helper()
+		db := testsupport.SetupTestDB(t)
+		form := &forms.Form{
+			Name:            "Newsletter",
+			Slug:            "newsletter",
+			DoubleOptIn:     true,
+			WebhookDelivery: &forms.WebhookDelivery{Enabled: true, URL: "https://hooks.example.com"},
+		}
+		require.NoError(t, db.Create(form).Error)
+		return db, form
+	}
+	events := func(db *gorm.DB) int64 {
+		var n int64
+		db.Model(&forms.WebhookEvent{}).Count(&n)
+		return n
+	}
+
+	t.Run("forwards only once confirmed", func(t *testing.T) {
+		db, form := setup(t)
+		sub, err := forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"email": "ada@example.com"}, "UA", "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, forms.ConfirmationPending, sub.ConfirmationStatus)
+		assert.Equal(t, int64(0), events(db))
+
+		confirmed, already, err := forms.ConfirmSubmission(logger, db, sub.ID, time.Now().UTC())
+		require.NoError(t, err)
+		assert.False(t, already)
+		assert.Equal(t, forms.ConfirmationConfirmed, confirmed.ConfirmationStatus)
+		assert.Equal(t, int64(1), events(db))
+
+		_, already, err = forms.ConfirmSubmission(logger, db, sub.ID, time.Now().UTC())
+		require.NoError(t, err)
+		assert.True(t, already)
+		assert.Equal(t, int64(1), events(db), "confirming twice queues nothing")
+	})
+
+	t.Run("requires a valid address", func(t *testing.T) {
+		db, form := setup(t)
+		_, err := forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"email": "not an address"}, "UA", "", nil)
+		assert.ErrorIs(t, err, forms.ErrOptInAddressMissing)
+
+		var count int64
+		db.Model(&forms.Submission{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("unconfirmed submissions expire", func(t *testing.T) {
+		db, form := setup(t)
+		sub, err := forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"email": "ada@example.com"}, "UA", "", nil)
+		require.NoError(t, err)
+
+		expired, err := forms.ExpireUnconfirmed(logger, db, time.Now().UTC())
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), expired)
+
+		expired, err = forms.ExpireUnconfirmed(logger, db, time.Now().UTC().Add(form.OptInExpiry()+time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), expired)
+
+		_, _, err = forms.ConfirmSubmission(logger, db, sub.ID, time.Now().UTC())
+		assert.ErrorIs(t, err, forms.ErrConfirmationExpired)
+		assert.Equal(t, int64(0), events(db))
+	})
+}
@@ -0,0 +1,233 @@
+package forms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// Reply limits.
+const (
+	MaxReplySubjectLength = 255
+	MaxReplyBodyLength    = 50000
+)
+
+// ErrReplyAddressMissing is returned when a submission has no address to
+// reply to.
+var ErrReplyAddressMissing = errors.New("submission has no email address to reply to")
+
+// ReplyParams holds an outbound reply composed by an admin.
+type ReplyParams struct {
+	MailerProfileID uint
+	Subject         string
+	Body            string
+}
+
+// ReplyAddress returns the submitter's address: the first email-typed field
+// of the form that holds a valid address, then the double opt-in field. It
+// returns "" when the payload has none.
+func (f *Form) ReplyAddress(fields []FlatField) string {
+	for _, field := range f.Fields() {
+		if field.Type != "email" {
+			continue
+		}
+		if addr := fieldAddress(fields, flatPath(field.Name)); addr != "" {
+			return addr
+		}
+	}
+	return f.optInAddress(fields)
+}
+
+// flatPath maps an input name in bracket or dot notation to the path its
+// value is flattened under (contact[email] -> contact.email).
+func flatPath(name string) string {
+	segments, err := splitFieldName(name)
+	if err != nil {
+		return name
+	}
+	var b strings.Builder
+	for i, seg := range segments {
+		if _, err := strconv.Atoi(seg.name); err == nil && i > 0 {
+			b.WriteString("[" + seg.name + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(seg.name)
+	}
+	return b.String()
+}
+
+func fieldAddress(fields []FlatField, path string) string {
+	value, found := ruleFieldValue(fields, path)
+	if !found || value == "" {
+		return ""
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return ""
+	}
+	return addr.Address
+}
+
+// DefaultReplySubject suggests a subject for the first reply: the subject
+// of an inbound email, or the form name.
+func (s *Submission) DefaultReplySubject() string {
+	subject := ""
+	if s.Form != nil && s.Form.InboundAddress != "" {
+		subject, _ = ruleFieldValue(FlattenDataJSON(s.DataJSON), "subject")
+	}
+	if subject == "" && s.Form != nil {
+		subject = s.Form.Name
+	}
+	return replySubject(subject)
+}
+
+// replySubject prefixes a subject with "Re: " unless it already has it.
+func replySubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if strings.HasPrefix(strings.ToLower(subject), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// threadRoot returns the Message-ID the first reply answers. Only submissions
+// that arrived by email have one.
+func (s *Submission) threadRoot() string {
+	if s.Form == nil || s.Form.InboundAddress == "" {
+		return ""
+	}
+	id, _ := ruleFieldValue(FlattenDataJSON(s.DataJSON), "message_id")
+	id = strings.Trim(id, "<>")
+	if strings.ContainsAny(id, " \t\r\n<>") {
+		return ""
+	}
+	return id
+}
+
+// QueueReply records a reply to the submitter for the reply dispatcher to
+// send. The reply continues the submission's thread.
+func QueueReply(logger *slog.Logger, db *gorm.DB, submissionID uint, author *accounts.User, params ReplyParams) (*SubmissionReply, error) {
+	subject := strings.TrimSpace(params.Subject)
+	body := strings.TrimSpace(params.Body)
+	if subject == "" || len(subject) > MaxReplySubjectLength || strings.ContainsAny(subject, "\r\n") {
+		return nil, &ValidationError{Field: "subject", Message: "Enter a single-line subject"}
+	}
+	if body == "" {
+		return nil, &ValidationError{Field: "body", Message: "Reply can't be empty"}
+	}
+	if len(body) > MaxReplyBodyLength {
+		return nil, &ValidationError{Field: "body", Message: "Reply is too long"}
+	}
+
+	var sub Submission
+	if err := db.Preload("Form").First(&sub, submissionID).Error; err != nil {
+		return nil, err
+	}
+	to := sub.Form.ReplyAddress(FlattenDataJSON(sub.DataJSON))
+	if to == "" {
+		return nil, ErrReplyAddressMissing
+	}
+
+	profile, err := integrations.GetMailerProfileByID(db, params.MailerProfileID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ValidationError{Field: "mailer_profile_id", Message: "Choose a mailer profile to send from"}
+		}
+		return nil, err
+	}
+	if profile.DefaultFromEmail == "" {
+		return nil, &ValidationError{Field: "mailer_profile_id", Message: "The mailer profile has no sender address"}
+	}
+
+	reply := &SubmissionReply{
+		SubmissionID:    sub.ID,
+		MailerProfileID: &profile.ID,
+		To:              to,
+		Subject:         subject,
+		Body:            body,
+		MessageID:       newMessageID(profile.DefaultFromEmail),
+		Status:          WebhookStatusPending,
+	}
+	if author != nil {
+		reply.AuthorID = &author.ID
+		reply.AuthorEmail = author.Email
+	}
+
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var previous []SubmissionReply
+		if err := tx.Select("message_id").Where("submission_id = ?", sub.ID).Order("id ASC").Find(&previous).Error; err != nil {
+			return err
+		}
+		var refs []string
+		if root := sub.threadRoot(); root != "" {
+			refs = append(refs, root)
+		}
+		for _, p := range previous {
+			refs = append(refs, p.MessageID)
+		}
+		if len(refs) > 0 {
+			reply.InReplyTo = refs[len(refs)-1]
+			reply.References = strings.Join(refs, " ")
+		}
+		return tx.Create(reply).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("submission reply queued",
+		slog.Uint64("submission_id", uint64(sub.ID)),
+		slog.Uint64("reply_id", uint64(reply.ID)),
+	)
+	return reply, nil
+}
+
+// newMessageID builds a unique Message-ID on the sender's domain.
+func newMessageID(from string) string {
+	domain := "formlander.local"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf) + "@" + domain
+}
+
+// PendingReplies returns replies due for a send attempt.
+func PendingReplies(db *gorm.DB, now time.Time, limit int) ([]SubmissionReply, error) {
+	var replies []SubmissionReply
+	err := db.Preload("MailerProfile").
+		Where("status IN ?", []string{WebhookStatusPending, WebhookStatusRetrying}).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("id ASC").
+		Limit(limit).
+		Find(&replies).Error
+	return replies, err
+}
+
+// ThreadHeaders returns the In-Reply-To and References header values of the
+// reply, with angle brackets.
+func (r *SubmissionReply) ThreadHeaders() (inReplyTo, references string) {
+	if r.InReplyTo == "" {
+		return "", ""
+	}
+	ids := strings.Fields(r.References)
+	for i, id := range ids {
+		ids[i] = "<" + id + ">"
+	}
+	return "<" + r.InReplyTo + ">", strings.Join(ids, " ")
+}
@@ -0,0 +1,96 @@
+package forms_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"formlander/internal/accounts"
+	"formlander/internal/forms"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplyAddress(t *testing.T) {
+	form := &forms.Form{GeneratedHTML: `<form><input name="name"><input type="email" name="contact[email]"></form>`}
+	fields := forms.FlattenDataJSON(`{"name":"Ada","contact":{"email":" Ada <ada@example.com> "}}`)
+	assert.Equal(t, "ada@example.com", form.ReplyAddress(fields))
+
+	assert.Empty(t, form.ReplyAddress(forms.FlattenDataJSON(`{"contact":{"email":"not an address"}}`)))
+
+	// Forms without markup use the default fields, whose email field is "email".
+	assert.Equal(t, "bob@example.com", (&forms.Form{}).ReplyAddress(forms.FlattenDataJSON(`{"email":"bob@example.com"}`)))
+}
+
+func TestQueueReply(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	profile := &integrations.MailerProfile{Name: "Support", Provider: "smtp", DefaultFromEmail: "support@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+	user := &accounts.User{Email: "ops@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(user).Error)
+	form := &forms.Form{Name: "Contact", Slug: "contact"}
+	require.NoError(t, db.Create(form).Error)
+	sub := &forms.Submission{FormID: form.ID, DataJSON: `{"email":"ada@example.com","message":"Hi"}`}
+	require.NoError(t, db.Create(sub).Error)
+
+	params := forms.ReplyParams{MailerProfileID: profile.ID, Subject: "Re: Contact", Body: "Thanks for reaching out"}
+
+	t.Run("validates input", func(t *testing.T) {
+		var valErr *forms.ValidationError
+		_, err := forms.QueueReply(logger, db, sub.ID, user, forms.ReplyParams{MailerProfileID: profile.ID, Subject: "Hi\r\nBcc: x@example.com", Body: "x"})
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "subject", valErr.Field)
+
+		_, err = forms.QueueReply(logger, db, sub.ID, user, forms.ReplyParams{MailerProfileID: 9999, Subject: "Hi", Body: "x"})
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "mailer_profile_id", valErr.Field)
+
+		noAddress := &forms.Submission{FormID: form.ID, DataJSON: `{"message":"Hi"}`}
+		require.NoError(t, db.Create(noAddress).Error)
+		_, err = forms.QueueReply(logger, db, noAddress.ID, user, params)
+		assert.True(t, errors.Is(err, forms.ErrReplyAddressMissing))
+	})
+
+	t.Run("threads follow-ups", func(t *testing.T) {
+		first, err := forms.QueueReply(logger, db, sub.ID, user, params)
+		require.NoError(t, err)
+		assert.Equal(t, "ada@example.com", first.To)
+		assert.Equal(t, forms.WebhookStatusPending, first.Status)
+		assert.Equal(t, "ops@example.com", first.AuthorEmail)
+		assert.True(t, strings.HasSuffix(first.MessageID, "@example.com"))
+		assert.Empty(t, first.InReplyTo)
+
+		second, err := forms.QueueReply(logger, db, sub.ID, user, params)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.MessageID, second.MessageID)
+		assert.Equal(t, first.MessageID, second.InReplyTo)
+
+		third, err := forms.QueueReply(logger, db, sub.ID, nil, params)
+		require.NoError(t, err)
+		inReplyTo, references := third.ThreadHeaders()
+		assert.Equal(t, "<"+second.MessageID+">", inReplyTo)
+		assert.Equal(t, "<"+first.MessageID+"> <"+second.MessageID+">", references)
+	})
+
+	t.Run("answers inbound email in its thread", func(t *testing.T) {
+		inbound := &forms.Form{Name: "Support inbox", Slug: "support", InboundAddress: "support@in.example.com"}
+		require.NoError(t, db.Create(inbound).Error)
+		mailed := &forms.Submission{FormID: inbound.ID, DataJSON: `{"email":"eve@example.com","subject":"Broken login","message_id":"<abc@mail.example.com>"}`}
+		require.NoError(t, db.Create(mailed).Error)
+
+		require.NoError(t, db.Preload("Form").First(mailed, mailed.ID).Error)
+		assert.Equal(t, "Re: Broken login", mailed.DefaultReplySubject())
+
+		reply, err := forms.QueueReply(logger, db, mailed.ID, user, params)
+		require.NoError(t, err)
+		assert.Equal(t, "abc@mail.example.com", reply.InReplyTo)
+		assert.Equal(t, "abc@mail.example.com", reply.References)
+	})
+}
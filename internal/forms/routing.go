@@ -0,0 +1,348 @@
+package forms
+
+import (
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/audit"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// Routing rule operators.
+const (
+	RuleOpEquals    = "equals"
+	RuleOpNotEquals = "not_equals"
+	RuleOpContains  = "contains"
+	RuleOpIn        = "in"
+	RuleOpNotIn     = "not_in"
+	RuleOpPresent   = "present"
+	RuleOpBlank     = "blank"
+)
+
+// Routing rule actions.
+const (
+	RuleActionEmail       = "email"        // Also email the target address
+	RuleActionWebhook     = "webhook"      // Also post to the target URL
+	RuleActionSkipEmail   = "skip_email"   // Don't email the form's recipient
+	RuleActionSkipWebhook = "skip_webhook" // Don't post to the form's webhook
+)
+
+// MaxRoutingRules caps how many rules a form can have.
+const MaxRoutingRules = 50
+
+// RuleParams holds the settings of a routing rule.
+type RuleParams struct {
+	Name     string
+	Field    string
+	Operator string
+	Value    string
+	Action   string
+	Target   string
+	Stop     bool
+}
+
+// normalizeRule trims and validates routing rule settings.
+func normalizeRule(p RuleParams) (RuleParams, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Field = strings.TrimSpace(p.Field)
+	p.Operator = strings.TrimSpace(p.Operator)
+	p.Value = strings.TrimSpace(p.Value)
+	p.Action = strings.TrimSpace(p.Action)
+	p.Target = strings.TrimSpace(p.Target)
+
+	if p.Field == "" || len(p.Field) > 255 || strings.ContainsAny(p.Field, " \t\r\n") {
+		return p, &ValidationError{Field: "field", Message: "Rules need a field name without spaces"}
+	}
+	switch p.Operator {
+	case RuleOpPresent, RuleOpBlank:
+		p.Value = ""
+	case RuleOpEquals, RuleOpNotEquals, RuleOpContains, RuleOpIn, RuleOpNotIn:
+		if p.Value == "" {
+			return p, &ValidationError{Field: "value", Message: "Enter a value to compare against"}
+		}
+	default:
+		return p, &ValidationError{Field: "operator", Message: "Unknown rule condition"}
+	}
+	switch p.Action {
+	case RuleActionEmail:
+		addr, err := mail.ParseAddress(p.Target)
+		if err != nil {
+			return p, &ValidationError{Field: "target", Message: "Enter the email address to send to"}
+		}
+		p.Target = addr.Address
+	case RuleActionWebhook:
+		u, err := url.Parse(p.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return p, &ValidationError{Field: "target", Message: "Enter the http(s) URL to post to"}
+		}
+	case RuleActionSkipEmail, RuleActionSkipWebhook:
+		p.Target = ""
+	default:
+		return p, &ValidationError{Field: "action", Message: "Unknown rule action"}
+	}
+	return p, nil
+}
+
+// ListRules returns a form's routing rules in evaluation order.
+func ListRules(db *gorm.DB, formID uint) ([]RoutingRule, error) {
+	var rules []RoutingRule
+	err := db.Where("form_id = ?", formID).Order("position ASC, id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// CreateRule appends a routing rule to a form.
+func CreateRule(logger *slog.Logger, db *gorm.DB, formID uint, params RuleParams) (*RoutingRule, error) {
+	params, err := normalizeRule(params)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &RoutingRule{
+		FormID:   formID,
+		Name:     params.Name,
+		Field:    params.Field,
+		Operator: params.Operator,
+		Value:    params.Value,
+		Action:   params.Action,
+		Target:   params.Target,
+		Stop:     params.Stop,
+	}
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&RoutingRule{}).Where("form_id = ?", formID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxRoutingRules {
+			return &ValidationError{Field: "field", Message: "This form already has the maximum number of rules"}
+		}
+		var last struct{ Position int }
+		if err := tx.Model(&RoutingRule{}).Select("COALESCE(MAX(position), 0) AS position").Where("form_id = ?", formID).Scan(&last).Error; err != nil {
+			return err
+		}
+		rule.Position = last.Position + 1
+		if err := tx.Create(rule).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionRoutingRuleCreated,
+			TargetType: "routing_rule",
+			TargetID:   rule.ID,
+			Details:    ruleAuditDetails(rule),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("routing rule created", slog.Uint64("form_id", uint64(formID)), slog.Uint64("rule_id", uint64(rule.ID)))
+	return rule, nil
+}
+
+// DeleteRule removes one of a form's routing rules.
+func DeleteRule(logger *slog.Logger, db *gorm.DB, formID, ruleID uint) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var rule RoutingRule
+		if err := tx.Where("form_id = ?", formID).First(&rule, ruleID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionRoutingRuleDeleted,
+			TargetType: "routing_rule",
+			TargetID:   ruleID,
+			Details:    ruleAuditDetails(&rule),
+		})
+	})
+}
+
+// ruleAuditDetails describes a rule in the audit log. Targets are left out,
+// as webhook URLs may carry credentials.
+func ruleAuditDetails(r *RoutingRule) map[string]any {
+	return map[string]any{"form_id": r.FormID, "name": r.Name, "field": r.Field, "operator": r.Operator, "action": r.Action}
+}
+
+// Matches reports whether the rule's condition holds for a submission's
+// fields. Values are compared trimmed and case-insensitively; a missing field
+// counts as blank.
+func (r *RoutingRule) Matches(fields []FlatField) bool {
+	value, _ := ruleFieldValue(fields, r.Field)
+	value = strings.ToLower(value)
+	want := strings.ToLower(r.Value)
+
+	switch r.Operator {
+	case RuleOpEquals:
+		return value == want
+	case RuleOpNotEquals:
+		return value != want
+	case RuleOpContains:
+		return strings.Contains(value, want)
+	case RuleOpIn:
+		return ruleListContains(want, value)
+	case RuleOpNotIn:
+		return !ruleListContains(want, value)
+	case RuleOpPresent:
+		return value != ""
+	case RuleOpBlank:
+		return value == ""
+	}
+	return false
+}
+
+// Describe renders the rule as a sentence for the admin UI.
+func (r *RoutingRule) Describe() string {
+	var b strings.Builder
+	b.WriteString("If ")
+	b.WriteString(r.Field)
+	switch r.Operator {
+	case RuleOpEquals:
+		b.WriteString(" is " + r.Value)
+	case RuleOpNotEquals:
+		b.WriteString(" is not " + r.Value)
+	case RuleOpContains:
+		b.WriteString(" contains " + r.Value)
+	case RuleOpIn:
+		b.WriteString(" is one of " + r.Value)
+	case RuleOpNotIn:
+		b.WriteString(" is none of " + r.Value)
+	case RuleOpPresent:
+		b.WriteString(" is filled in")
+	case RuleOpBlank:
+		b.WriteString(" is blank")
+	}
+	switch r.Action {
+	case RuleActionEmail:
+		b.WriteString(", also email " + r.Target)
+	case RuleActionWebhook:
+		b.WriteString(", also post to " + r.Target)
+	case RuleActionSkipEmail:
+		b.WriteString(", don't send the email notification")
+	case RuleActionSkipWebhook:
+		b.WriteString(", don't post to the webhook")
+	}
+	if r.Stop {
+		b.WriteString(", then stop")
+	}
+	return b.String()
+}
+
+// ruleFieldValue returns the trimmed value of a field path.
+func ruleFieldValue(fields []FlatField, path string) (string, bool) {
+	for _, field := range fields {
+		if field.Path == path {
+			return strings.TrimSpace(field.Value), true
+		}
+	}
+	return "", false
+}
+
+// ruleListContains reports whether value is one of the comma-separated,
+// lowercased items in list.
+func ruleListContains(list, value string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailRecipient returns the address the form's email forwarding sends to.
+func (f *Form) EmailRecipient() string {
+	return extractEmailRecipient(f.EmailDelivery)
+}
+
+// RuleResult records how one rule fared against a submission.
+type RuleResult struct {
+	Rule      RoutingRule
+	Value     string // The submission's value for the rule's field
+	Found     bool   // Whether the submission has the field at all
+	Matched   bool
+	Evaluated bool // False when an earlier matching rule stopped evaluation
+}
+
+// Route lists the deliveries a submission gets.
+type Route struct {
+	Webhook       bool     // Post to the form's webhook
+	Email         bool     // Email the form's recipient right away
+	ExtraWebhooks []string // URLs added by rules
+	ExtraEmails   []string // Addresses added by rules
+	Results       []RuleResult
+}
+
+// RouteSubmission evaluates a form's rules against a submission's fields and
+// decides its deliveries. Without rules this is the form's webhook and email
+// settings as configured.
+func RouteSubmission(form *Form, rules []RoutingRule, fields []FlatField) Route {
+	webhook := form.WebhookDelivery
+	email := form.EmailDelivery
+	defaultRecipient := form.EmailRecipient()
+
+	route := Route{
+		Webhook: webhook != nil && webhook.Enabled && webhook.URL != "",
+		// Digest forms are summarized on a schedule by the digest job
+		// instead of one email per submission.
+		Email: email != nil && email.Enabled && !email.IsDigest() && defaultRecipient != "",
+	}
+
+	skipWebhook, skipEmail := false, false
+	stopped := false
+	for _, rule := range rules {
+		value, found := ruleFieldValue(fields, rule.Field)
+		result := RuleResult{Rule: rule, Value: value, Found: found, Evaluated: !stopped}
+		if !stopped && rule.Matches(fields) {
+			result.Matched = true
+			switch rule.Action {
+			case RuleActionEmail:
+				route.ExtraEmails = appendUnique(route.ExtraEmails, rule.Target)
+			case RuleActionWebhook:
+				route.ExtraWebhooks = appendUnique(route.ExtraWebhooks, rule.Target)
+			case RuleActionSkipEmail:
+				skipEmail = true
+			case RuleActionSkipWebhook:
+				skipWebhook = true
+			}
+			stopped = rule.Stop
+		}
+		route.Results = append(route.Results, result)
+	}
+
+	route.Webhook = route.Webhook && !skipWebhook
+	route.Email = route.Email && !skipEmail
+
+	// Don't send the same submission twice to one destination.
+	if route.Webhook {
+		route.ExtraWebhooks = removeItem(route.ExtraWebhooks, webhook.URL)
+	}
+	if route.Email {
+		route.ExtraEmails = removeItem(route.ExtraEmails, defaultRecipient)
+	}
+	return route
+}
+
+func appendUnique(list []string, item string) []string {
+	for _, existing := range list {
+		if strings.EqualFold(existing, item) {
+			return list
+		}
+	}
+	return append(list, item)
+}
+
+func removeItem(list []string, item string) []string {
+	out := list[:0]
+	for _, existing := range list {
+		if !strings.EqualFold(existing, item) {
+			out = append(out, existing)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
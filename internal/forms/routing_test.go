@@ -0,0 +1,128 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"formlander/internal/audit"
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRoutingRules(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	form := &forms.Form{
+		Name:            "Contact",
+		Slug:            "contact",
+		WebhookDelivery: &forms.WebhookDelivery{Enabled: true, URL: "https://crm.example.com/hook"},
+		EmailDelivery:   &forms.EmailDelivery{Enabled: true, OverridesJSON: `{"to":"team@example.com"}`},
+	}
+	require.NoError(t, db.Create(form).Error)
+
+	rules := []forms.RuleParams{
+		{Field: "department", Operator: forms.RuleOpEquals, Value: "sales", Action: forms.RuleActionEmail, Target: "sales@example.com"},
+		{Field: "priority", Operator: forms.RuleOpEquals, Value: "urgent", Action: forms.RuleActionWebhook, Target: "https://oncall.example.com/hook"},
+		{Field: "country", Operator: forms.RuleOpIn, Value: "DE, FR", Action: forms.RuleActionSkipWebhook},
+	}
+	for _, params := range rules {
+		_, err := forms.CreateRule(logger, db, form.ID, params)
+		require.NoError(t, err)
+	}
+
+	submit := func(payload map[string]any) (webhooks, emails []string) {
+		t.Helper()
+		sub, err := forms.CreateSubmissionWithFiles(logger, db, form, payload, "UA", "", nil)
+		require.NoError(t, err)
+
+		var webhookEvents []forms.WebhookEvent
+		var emailEvents []forms.EmailEvent
+		require.NoError(t, db.Where("submission_id = ?", sub.ID).Order("id").Find(&webhookEvents).Error)
+		require.NoError(t, db.Where("submission_id = ?", sub.ID).Order("id").Find(&emailEvents).Error)
+		for _, e := range webhookEvents {
+			webhooks = append(webhooks, e.URL)
+		}
+		for _, e := range emailEvents {
+			emails = append(emails, e.Recipient)
+		}
+		return webhooks, emails
+	}
+
+	webhooks, emails := submit(map[string]any{"department": "Sales", "priority": "urgent", "country": "US"})
+	assert.Equal(t, []string{"", "https://oncall.example.com/hook"}, webhooks)
+	assert.Equal(t, []string{"", "sales@example.com"}, emails)
+
+	webhooks, emails = submit(map[string]any{"department": "support", "country": "fr"})
+	assert.Empty(t, webhooks, "the CRM webhook is skipped")
+	assert.Equal(t, []string{""}, emails)
+
+	t.Run("stop ends evaluation", func(t *testing.T) {
+		stop := []forms.RoutingRule{
+			{Field: "vip", Operator: forms.RuleOpPresent, Action: forms.RuleActionSkipEmail, Stop: true},
+			{Field: "department", Operator: forms.RuleOpEquals, Value: "sales", Action: forms.RuleActionEmail, Target: "sales@example.com"},
+		}
+		route := forms.RouteSubmission(form, stop, []forms.FlatField{{Path: "vip", Value: "yes"}, {Path: "department", Value: "sales"}})
+		assert.False(t, route.Email)
+		assert.Empty(t, route.ExtraEmails)
+		require.Len(t, route.Results, 2)
+		assert.True(t, route.Results[0].Matched)
+		assert.False(t, route.Results[1].Evaluated)
+	})
+
+	t.Run("rule changes are audited without their targets", func(t *testing.T) {
+		lastEntry := func() audit.Entry {
+			t.Helper()
+			var entry audit.Entry
+			require.NoError(t, db.Last(&entry).Error)
+			return entry
+		}
+
+		rule, err := forms.CreateRule(logger, db, form.ID, forms.RuleParams{Name: "On call", Field: "priority", Operator: forms.RuleOpEquals, Value: "p1", Action: forms.RuleActionWebhook, Target: "https://hooks.example.com/hook?token=s3cret"})
+		require.NoError(t, err)
+		entry := lastEntry()
+		assert.Equal(t, audit.ActionRoutingRuleCreated, entry.Action)
+		assert.Equal(t, rule.ID, entry.TargetID)
+		assert.Contains(t, entry.DetailLines(), "name: On call")
+		assert.NotContains(t, entry.Details, "s3cret")
+
+		require.NoError(t, forms.DeleteRule(logger, db, form.ID, rule.ID))
+		entry = lastEntry()
+		assert.Equal(t, audit.ActionRoutingRuleDeleted, entry.Action)
+		assert.Equal(t, rule.ID, entry.TargetID)
+		assert.ErrorIs(t, forms.DeleteRule(logger, db, form.ID, rule.ID), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("rule targets equal to the form's are not sent twice", func(t *testing.T) {
+		same := []forms.RoutingRule{{Field: "a", Operator: forms.RuleOpBlank, Action: forms.RuleActionEmail, Target: "TEAM@example.com"}}
+		route := forms.RouteSubmission(form, same, nil)
+		assert.True(t, route.Email)
+		assert.Empty(t, route.ExtraEmails)
+	})
+}
+
+func TestRoutingRuleValidation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	form := &forms.Form{Name: "Contact", Slug: "contact"}
+	require.NoError(t, db.Create(form).Error)
+
+	cases := map[string]forms.RuleParams{
+		"field":    {Field: "", Operator: forms.RuleOpPresent, Action: forms.RuleActionSkipEmail},
+		"operator": {Field: "a", Operator: "matches", Action: forms.RuleActionSkipEmail},
+		"value":    {Field: "a", Operator: forms.RuleOpEquals, Action: forms.RuleActionSkipEmail},
+		"action":   {Field: "a", Operator: forms.RuleOpPresent, Action: "forward"},
+		"target":   {Field: "a", Operator: forms.RuleOpPresent, Action: forms.RuleActionWebhook, Target: "ftp://example.com"},
+	}
+	for field, params := range cases {
+		_, err := forms.CreateRule(logger, db, form.ID, params)
+		var validationErr *forms.ValidationError
+		require.ErrorAs(t, err, &validationErr, field)
+		assert.Equal(t, field, validationErr.Field)
+	}
+}
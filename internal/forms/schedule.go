@@ -0,0 +1,236 @@
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Form availability states, shown as badges in the admin.
+const (
+	FormStatusOpen      = "open"
+	FormStatusScheduled = "scheduled" // opens_at is in the future
+	FormStatusClosed    = "closed"    // closes_at has passed
+	FormStatusFull      = "full"      // max_submissions reached
+)
+
+// Errors returned when a submission arrives while the form isn't accepting
+// entries. Handlers answer them with the form's closed response.
+var (
+	ErrFormNotOpen = errors.New("form is not open yet")
+	ErrFormClosed  = errors.New("form is closed")
+	ErrFormFull    = errors.New("form has reached its submission limit")
+)
+
+// Defaults for the closed response.
+const (
+	DefaultNotOpenTitle   = "Not open yet"
+	DefaultNotOpenMessage = "This form isn't accepting submissions yet."
+	DefaultClosedTitle    = "Submissions closed"
+	DefaultClosedMessage  = "This form is no longer accepting submissions."
+)
+
+// AvailabilityParams holds a form's schedule and submission cap.
+type AvailabilityParams struct {
+	OpensAt        *time.Time
+	ClosesAt       *time.Time
+	MaxSubmissions int  // 0 = unlimited
+	CapIgnoresSpam bool // Only non-spam submissions count toward MaxSubmissions
+	ClosedURL      string
+	ClosedMessage  string
+}
+
+// apply copies the normalized settings onto a form.
+func (p AvailabilityParams) apply(form *Form) {
+	form.OpensAt = p.OpensAt
+	form.ClosesAt = p.ClosesAt
+	form.MaxSubmissions = p.MaxSubmissions
+	form.CapIgnoresSpam = p.CapIgnoresSpam
+	form.ClosedURL = p.ClosedURL
+	form.ClosedMessage = p.ClosedMessage
+}
+
+// normalizeAvailability trims and validates schedule and cap settings.
+func normalizeAvailability(p AvailabilityParams) (AvailabilityParams, error) {
+	p.ClosedURL = strings.TrimSpace(p.ClosedURL)
+	p.ClosedMessage = strings.TrimSpace(p.ClosedMessage)
+
+	if p.OpensAt != nil && p.ClosesAt != nil && !p.ClosesAt.After(*p.OpensAt) {
+		return p, &ValidationError{Field: "closes_at", Message: "Close time must be after the open time"}
+	}
+	if p.MaxSubmissions < 0 {
+		return p, &ValidationError{Field: "max_submissions", Message: "Submission limit must be zero (unlimited) or more"}
+	}
+	if p.ClosedURL != "" {
+		u, err := url.Parse(p.ClosedURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return p, &ValidationError{Field: "closed_url", Message: "Closed URL must be an absolute http(s) URL"}
+		}
+	}
+	return p, nil
+}
+
+// ScheduleError reports whether the form's open/close window admits a
+// submission at now. The submission cap is checked separately, atomically
+// with the insert.
+func (f *Form) ScheduleError(now time.Time) error {
+	if f.OpensAt != nil && now.Before(*f.OpensAt) {
+		return ErrFormNotOpen
+	}
+	if f.ClosesAt != nil && !now.Before(*f.ClosesAt) {
+		return ErrFormClosed
+	}
+	return nil
+}
+
+// HasSubmissionCap reports whether the form limits its number of submissions.
+func (f *Form) HasSubmissionCap() bool {
+	return f.MaxSubmissions > 0
+}
+
+// CheckAvailability reports whether the form accepts submissions at now,
+// returning ErrFormNotOpen, ErrFormClosed or ErrFormFull when it doesn't.
+// It is a cheap pre-check; the cap is enforced again when the submission
+// is stored.
+func CheckAvailability(db *gorm.DB, form *Form, now time.Time) error {
+	if err := form.ScheduleError(now); err != nil {
+		return err
+	}
+	if !form.HasSubmissionCap() {
+		return nil
+	}
+	count, err := countTowardCap(db, form)
+	if err != nil {
+		return err
+	}
+	if count >= int64(form.MaxSubmissions) {
+		return ErrFormFull
+	}
+	return nil
+}
+
+// ClosedStatus maps a closed error to its availability status.
+func ClosedStatus(err error) string {
+	switch {
+	case errors.Is(err, ErrFormNotOpen):
+		return FormStatusScheduled
+	case errors.Is(err, ErrFormFull):
+		return FormStatusFull
+	case errors.Is(err, ErrFormClosed):
+		return FormStatusClosed
+	}
+	return FormStatusOpen
+}
+
+// ClosedPageTitle returns the heading shown when a submission is turned away.
+func (f *Form) ClosedPageTitle(reason error) string {
+	if errors.Is(reason, ErrFormNotOpen) {
+		return DefaultNotOpenTitle
+	}
+	return DefaultClosedTitle
+}
+
+// ClosedPageMessage returns the message shown when a submission is turned away.
+func (f *Form) ClosedPageMessage(reason error) string {
+	if errors.Is(reason, ErrFormNotOpen) {
+		return firstNonEmpty(f.ClosedMessage, DefaultNotOpenMessage)
+	}
+	return firstNonEmpty(f.ClosedMessage, DefaultClosedMessage)
+}
+
+// IsClosedError reports whether err means the form isn't accepting entries.
+func IsClosedError(err error) bool {
+	return errors.Is(err, ErrFormNotOpen) || errors.Is(err, ErrFormClosed) || errors.Is(err, ErrFormFull)
+}
+
+// countTowardCap counts the submissions that consume the form's cap.
+func countTowardCap(db *gorm.DB, form *Form) (int64, error) {
+	query := db.Model(&Submission{}).Where("form_id = ? AND is_duplicate = ?", form.ID, false)
+	if form.CapIgnoresSpam {
+		query = query.Where("is_spam = ?", false)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// Availability summarizes whether a form is accepting submissions.
+type Availability struct {
+	Status string
+	Count  int64 // Submissions counted toward the cap (only loaded for capped forms)
+	Max    int
+}
+
+// Label returns a short badge text such as "Open" or "Full · 100/100".
+func (a Availability) Label() string {
+	label := strings.ToUpper(a.Status[:1]) + a.Status[1:]
+	if a.Max > 0 {
+		return fmt.Sprintf("%s · %d/%d", label, a.Count, a.Max)
+	}
+	return label
+}
+
+// IsOpen reports whether the form is currently accepting submissions.
+func (a Availability) IsOpen() bool {
+	return a.Status == FormStatusOpen
+}
+
+// GetAvailability computes the availability of each form at now, keyed by
+// form ID. Cap counts are loaded in one grouped query.
+func GetAvailability(db *gorm.DB, forms []Form, now time.Time) (map[uint]Availability, error) {
+	var capped, cappedNoSpam []uint
+	for _, f := range forms {
+		if !f.HasSubmissionCap() {
+			continue
+		}
+		if f.CapIgnoresSpam {
+			cappedNoSpam = append(cappedNoSpam, f.ID)
+		} else {
+			capped = append(capped, f.ID)
+		}
+	}
+
+	counts := map[uint]int64{}
+	load := func(ids []uint, excludeSpam bool) error {
+		if len(ids) == 0 {
+			return nil
+		}
+		var rows []struct {
+			FormID uint
+			Count  int64
+		}
+		query := db.Model(&Submission{}).Select("form_id, COUNT(*) AS count").Where("form_id IN ? AND is_duplicate = ?", ids, false)
+		if excludeSpam {
+			query = query.Where("is_spam = ?", false)
+		}
+		if err := query.Group("form_id").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			counts[row.FormID] = row.Count
+		}
+		return nil
+	}
+	if err := load(capped, false); err != nil {
+		return nil, err
+	}
+	if err := load(cappedNoSpam, true); err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint]Availability, len(forms))
+	for _, f := range forms {
+		a := Availability{Status: FormStatusOpen, Count: counts[f.ID], Max: f.MaxSubmissions}
+		if err := f.ScheduleError(now); err != nil {
+			a.Status = ClosedStatus(err)
+		} else if f.HasSubmissionCap() && a.Count >= int64(f.MaxSubmissions) {
+			a.Status = FormStatusFull
+		}
+		result[f.ID] = a
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissionSchedule(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	future := time.Now().UTC().Add(time.Hour)
+	past := time.Now().UTC().Add(-time.Hour)
+
+	notOpen := &forms.Form{Name: "Soon", Slug: "soon", OpensAt: &future}
+	closed := &forms.Form{Name: "Over", Slug: "over", ClosesAt: &past}
+	open := &forms.Form{Name: "Now", Slug: "now", OpensAt: &past, ClosesAt: &future}
+	require.NoError(t, db.Create(notOpen).Error)
+	require.NoError(t, db.Create(closed).Error)
+	require.NoError(t, db.Create(open).Error)
+
+	_, err := forms.CreateSubmissionWithFiles(logger, db, notOpen, map[string]any{"n": "1"}, "UA", "", nil)
+	assert.ErrorIs(t, err, forms.ErrFormNotOpen)
+
+	_, err = forms.CreateSubmissionWithFiles(logger, db, closed, map[string]any{"n": "1"}, "UA", "", nil)
+	assert.ErrorIs(t, err, forms.ErrFormClosed)
+
+	_, err = forms.CreateSubmissionWithFiles(logger, db, open, map[string]any{"n": "1"}, "UA", "", nil)
+	assert.NoError(t, err)
+
+	var count int64
+	db.Model(&forms.Submission{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestSubmissionCap(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("refuses submissions past the limit", func(t *testing.T) {
+		db := testsupport.SetupTestDB(t)
+		form := &forms.Form{
+			Name:            "Limited",
+			Slug:            "limited",
+			MaxSubmissions:  2,
+			WebhookDelivery: &forms.WebhookDelivery{Enabled: true, URL: "https://hooks.example.com"},
+		}
+		require.NoError(t, db.Create(form).Error)
+
+		for i := 0; i < 2; i++ {
+			_, err := forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"n": "1"}, "UA", "", nil)
+			require.NoError(t, err)
+		}
+		_, err := forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"n": "3"}, "UA", "", nil)
+		assert.ErrorIs(t, err, forms.ErrFormFull)
+
+		var submissions, events int64
+		db.Model(&forms.Submission{}).Count(&submissions)
+		db.Model(&forms.WebhookEvent{}).Count(&events)
+		assert.Equal(t, int64(2), submissions, "the refused insert is rolled back")
+		assert.Equal(t, int64(2), events)
+		assert.ErrorIs(t, forms.CheckAvailability(db, form, time.Now().UTC()), forms.ErrFormFull)
+	})
+
+	t.Run("spam can be left out of the count", func(t *testing.T) {
+		db := testsupport.SetupTestDB(t)
+		form := &forms.Form{Name: "Clean", Slug: "clean", MaxSubmissions: 1, CapIgnoresSpam: true}
+		require.NoError(t, db.Create(form).Error)
+
+		spam := map[string]any{"n": "1", forms.HoneypotField: "bot"}
+		_, err := forms.CreateSubmissionWithFiles(logger, db, form, spam, "UA", "", nil)
+		require.NoError(t, err)
+
+		_, err = forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"n": "2"}, "UA", "", nil)
+		require.NoError(t, err, "spam doesn't use up the limit")
+
+		_, err = forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"n": "3"}, "UA", "", nil)
+		assert.ErrorIs(t, err, forms.ErrFormFull)
+	})
+}
+
+func TestGetAvailability(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	now := time.Now().UTC()
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+
+	list := []forms.Form{
+		{Name: "Open", Slug: "open", MaxSubmissions: 5},
+		{Name: "Scheduled", Slug: "scheduled", OpensAt: &future},
+		{Name: "Closed", Slug: "closed", ClosesAt: &past},
+		{Name: "Full", Slug: "full", MaxSubmissions: 1},
+	}
+	for i := range list {
+		require.NoError(t, db.Create(&list[i]).Error)
+	}
+	for _, i := range []int{0, 3} {
+		_, err := forms.CreateSubmissionWithFiles(logger, db, &list[i], map[string]any{"n": "1"}, "UA", "", nil)
+		require.NoError(t, err)
+	}
+
+	availability, err := forms.GetAvailability(db, list, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, forms.FormStatusOpen, availability[list[0].ID].Status)
+	assert.Equal(t, "Open · 1/5", availability[list[0].ID].Label())
+	assert.Equal(t, forms.FormStatusScheduled, availability[list[1].ID].Status)
+	assert.Equal(t, forms.FormStatusClosed, availability[list[2].ID].Status)
+	assert.Equal(t, forms.FormStatusFull, availability[list[3].ID].Status)
+}
+
+func TestAvailabilityValidation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+	opens := time.Now().UTC()
+	closes := opens.Add(-time.Minute)
+
+	cases := map[string]forms.AvailabilityParams{
+		"closes_at":       {OpensAt: &opens, ClosesAt: &closes},
+		"max_submissions": {MaxSubmissions: -1},
+		"closed_url":      {ClosedURL: "/relative"},
+	}
+	for field, params := range cases {
+		_, err := forms.Create(logger, db, forms.CreateParams{
+			Name:           "Scheduled",
+			Slug:           "scheduled",
+			AllowedOrigins: "*",
+			Availability:   params,
+		})
+		var validationErr *forms.ValidationError
+		require.ErrorAs(t, err, &validationErr, field)
+		assert.Equal(t, field, validationErr.Field)
+	}
+}
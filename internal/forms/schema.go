@@ -0,0 +1,348 @@
+package forms
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Field describes one input of a form, as declared in its markup. The embed
+// widget renders forms from this list instead of raw HTML.
+type Field struct {
+	Name        string        `json:"name"`
+	Type        string        `json:"type"` // text, email, textarea, select, radio, checkbox, file, hidden, ...
+	Label       string        `json:"label"`
+	Placeholder string        `json:"placeholder,omitempty"`
+	Value       string        `json:"value,omitempty"` // Default value (hidden inputs, prefilled text)
+	Required    bool          `json:"required,omitempty"`
+	Multiple    bool          `json:"multiple,omitempty"`
+	MinLength   int           `json:"minlength,omitempty"`
+	MaxLength   int           `json:"maxlength,omitempty"`
+	Min         string        `json:"min,omitempty"`
+	Max         string        `json:"max,omitempty"`
+	Step        string        `json:"step,omitempty"`
+	Pattern     string        `json:"pattern,omitempty"`
+	Accept      string        `json:"accept,omitempty"`
+	Rows        int           `json:"rows,omitempty"`
+	Options     []FieldOption `json:"options,omitempty"`
+}
+
+// FieldOption is one choice of a select, radio group or checkbox group.
+type FieldOption struct {
+	Value    string `json:"value"`
+	Label    string `json:"label"`
+	Selected bool   `json:"selected,omitempty"`
+}
+
+// DefaultSubmitLabel is used when the form markup has no submit button text.
+const DefaultSubmitLabel = "Send"
+
+// defaultFields mirrors the fallback form served for forms without
+// generated HTML.
+func defaultFields() []Field {
+	return []Field{
+		{Name: "name", Type: "text", Label: "Name", Required: true},
+		{Name: "email", Type: "email", Label: "Email", Required: true},
+		{Name: "message", Type: "textarea", Label: "Message", Rows: 4},
+	}
+}
+
+// Fields returns the form's inputs, parsed from its generated HTML. Forms
+// without markup (or whose markup declares no inputs) get the default
+// name/email/message fields.
+func (f *Form) Fields() []Field {
+	fields, _ := ParseFormMarkup(f.GeneratedHTML)
+	if len(fields) == 0 {
+		return defaultFields()
+	}
+	return fields
+}
+
+// SubmitLabel returns the text of the form's submit button.
+func (f *Form) SubmitLabel() string {
+	_, label := ParseFormMarkup(f.GeneratedHTML)
+	return firstNonEmpty(label, DefaultSubmitLabel)
+}
+
+// ParseFormMarkup extracts input fields and the submit button label from
+// form HTML. Radios and checkboxes sharing a name become a single field with
+// options. Buttons, captcha responses and the honeypot are skipped.
+func ParseFormMarkup(rawHTML string) ([]Field, string) {
+	if strings.TrimSpace(rawHTML) == "" {
+		return nil, ""
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(rawHTML), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return nil, ""
+	}
+
+	p := &markupParser{labelsFor: map[string]string{}, index: map[string]int{}}
+	for _, n := range nodes {
+		p.collectLabels(n)
+	}
+	for _, n := range nodes {
+		p.walk(n, "")
+	}
+	return p.fields, p.submitLabel
+}
+
+type markupParser struct {
+	labelsFor   map[string]string // label text by the id it points at
+	fields      []Field
+	index       map[string]int // field position by name, for grouping
+	submitLabel string
+}
+
+func (p *markupParser) collectLabels(n *html.Node) {
+	if n.Type == html.ElementNode && n.Data == "label" {
+		if id := attr(n, "for"); id != "" {
+			p.labelsFor[id] = labelText(n)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.collectLabels(c)
+	}
+}
+
+// walk visits n, carrying the text of the nearest enclosing label or
+// fieldset legend for controls that don't have their own.
+func (p *markupParser) walk(n *html.Node, context string) {
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "label":
+			context = labelText(n)
+		case "fieldset":
+			if legend := findChild(n, "legend"); legend != nil {
+				context = collapseSpace(textContent(legend))
+			}
+		case "button":
+			if t := attr(n, "type"); (t == "" || t == "submit") && p.submitLabel == "" {
+				p.submitLabel = collapseSpace(textContent(n))
+			}
+			return
+		case "input", "textarea", "select":
+			p.addControl(n, context)
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.walk(c, context)
+	}
+}
+
+func (p *markupParser) addControl(n *html.Node, context string) {
+	name := strings.TrimSpace(attr(n, "name"))
+	if name == "" || name == HoneypotField || strings.HasPrefix(name, "cf-turnstile") {
+		return
+	}
+
+	typ := n.Data
+	if typ == "input" {
+		typ = strings.ToLower(strings.TrimSpace(attr(n, "type")))
+		if typ == "" {
+			typ = "text"
+		}
+	}
+	switch typ {
+	case "submit", "button", "reset", "image":
+		if typ == "submit" && p.submitLabel == "" {
+			p.submitLabel = strings.TrimSpace(attr(n, "value"))
+		}
+		return
+	}
+
+	label := context
+	if id := attr(n, "id"); id != "" && p.labelsFor[id] != "" {
+		label = p.labelsFor[id]
+	}
+
+	if typ == "radio" || typ == "checkbox" {
+		p.addChoice(n, name, typ, label)
+		return
+	}
+
+	field := Field{
+		Name:        name,
+		Type:        typ,
+		Label:       firstNonEmpty(label, humanizeName(name)),
+		Placeholder: attr(n, "placeholder"),
+		Value:       attr(n, "value"),
+		Required:    hasAttr(n, "required"),
+		Multiple:    hasAttr(n, "multiple"),
+		MinLength:   intAttr(n, "minlength"),
+		MaxLength:   intAttr(n, "maxlength"),
+		Min:         attr(n, "min"),
+		Max:         attr(n, "max"),
+		Step:        attr(n, "step"),
+		Pattern:     attr(n, "pattern"),
+		Accept:      attr(n, "accept"),
+		Rows:        intAttr(n, "rows"),
+	}
+	switch typ {
+	case "textarea":
+		field.Value = textContent(n)
+	case "select":
+		field.Options = selectOptions(n)
+	}
+	p.append(field)
+}
+
+// addChoice folds radios and checkboxes into one field per name. The group
+// label comes from the enclosing fieldset; each option keeps its own label.
+func (p *markupParser) addChoice(n *html.Node, name, typ, label string) {
+	value := attr(n, "value")
+	if value == "" {
+		value = "on"
+	}
+	option := FieldOption{Value: value, Label: firstNonEmpty(label, value), Selected: hasAttr(n, "checked")}
+
+	if i, ok := p.index[name]; ok && p.fields[i].Type == typ {
+		p.fields[i].Options = append(p.fields[i].Options, option)
+		p.fields[i].Required = p.fields[i].Required || hasAttr(n, "required")
+		return
+	}
+
+	groupLabel := humanizeName(strings.TrimSuffix(name, "[]"))
+	if legend := enclosingLegend(n); legend != "" {
+		groupLabel = legend
+	}
+	p.append(Field{
+		Name:     name,
+		Type:     typ,
+		Label:    groupLabel,
+		Required: hasAttr(n, "required"),
+		Options:  []FieldOption{option},
+	})
+}
+
+func (p *markupParser) append(field Field) {
+	if _, exists := p.index[field.Name]; exists {
+		return
+	}
+	p.index[field.Name] = len(p.fields)
+	p.fields = append(p.fields, field)
+}
+
+func selectOptions(n *html.Node) []FieldOption {
+	var options []FieldOption
+	var visit func(*html.Node)
+	visit = func(c *html.Node) {
+		if c.Type == html.ElementNode && c.Data == "option" {
+			text := collapseSpace(textContent(c))
+			value := text
+			if hasAttr(c, "value") {
+				value = attr(c, "value")
+			}
+			options = append(options, FieldOption{Value: value, Label: text, Selected: hasAttr(c, "selected")})
+			return
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(n)
+	return options
+}
+
+func enclosingLegend(n *html.Node) string {
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		if parent.Type == html.ElementNode && parent.Data == "fieldset" {
+			if legend := findChild(parent, "legend"); legend != nil {
+				return collapseSpace(textContent(legend))
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// labelText returns a label's own text, ignoring nested controls.
+func labelText(n *html.Node) string {
+	var b strings.Builder
+	var visit func(*html.Node)
+	visit = func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+			b.WriteByte(' ')
+			return
+		}
+		if c.Type == html.ElementNode && (c.Data == "select" || c.Data == "textarea") {
+			return
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(n)
+	return strings.TrimSuffix(collapseSpace(b.String()), " *")
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var visit func(*html.Node)
+	visit = func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(n)
+	return b.String()
+}
+
+func findChild(n *html.Node, tag string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func intAttr(n *html.Node, key string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(attr(n, key)))
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// humanizeName turns "first_name" into "First name".
+func humanizeName(name string) string {
+	name = strings.NewReplacer("_", " ", "-", " ").Replace(name)
+	name = collapseSpace(name)
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,79 @@
+package forms_test
+
+import (
+	"testing"
+
+	"formlander/internal/forms"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormMarkup(t *testing.T) {
+	markup := `<form action="/forms/contact/submit">
+  <label for="name">Your name *</label>
+  <input id="name" name="name" required maxlength="80">
+  <label>Email <input type="email" name="email" placeholder="you@example.com" required></label>
+  <label>Topic
+    <select name="topic"><option value="">Pick one</option><option value="sales" selected>Sales</option><option>Support</option></select>
+  </label>
+  <fieldset>
+    <legend>Plan</legend>
+    <label><input type="radio" name="plan" value="free" required> Free</label>
+    <label><input type="radio" name="plan" value="pro"> Pro</label>
+  </fieldset>
+  <label><input type="checkbox" name="consent" value="yes"> I agree</label>
+  <textarea name="message" rows="6">Hi</textarea>
+  <input type="file" name="resume" accept=".pdf">
+  <input type="hidden" name="source" value="landing">
+  <input type="text" name="__fl_hp">
+  <div class="cf-turnstile"><input type="hidden" name="cf-turnstile-response"></div>
+  <button type="submit">Request demo</button>
+</form>`
+
+	fields, submit := forms.ParseFormMarkup(markup)
+	assert.Equal(t, "Request demo", submit)
+
+	byName := map[string]forms.Field{}
+	var names []string
+	for _, f := range fields {
+		byName[f.Name] = f
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"name", "email", "topic", "plan", "consent", "message", "resume", "source"}, names)
+
+	assert.Equal(t, "Your name", byName["name"].Label)
+	assert.True(t, byName["name"].Required)
+	assert.Equal(t, 80, byName["name"].MaxLength)
+	assert.Equal(t, "text", byName["name"].Type)
+
+	assert.Equal(t, "Email", byName["email"].Label)
+	assert.Equal(t, "email", byName["email"].Type)
+	assert.Equal(t, "you@example.com", byName["email"].Placeholder)
+
+	require.Len(t, byName["topic"].Options, 3)
+	assert.Equal(t, "Topic", byName["topic"].Label)
+	assert.True(t, byName["topic"].Options[1].Selected)
+	assert.Equal(t, "Support", byName["topic"].Options[2].Value)
+
+	plan := byName["plan"]
+	assert.Equal(t, "radio", plan.Type)
+	assert.Equal(t, "Plan", plan.Label)
+	assert.True(t, plan.Required)
+	require.Len(t, plan.Options, 2)
+	assert.Equal(t, forms.FieldOption{Value: "pro", Label: "Pro"}, plan.Options[1])
+
+	assert.Equal(t, "I agree", byName["consent"].Options[0].Label)
+	assert.Equal(t, "Hi", byName["message"].Value)
+	assert.Equal(t, 6, byName["message"].Rows)
+	assert.Equal(t, ".pdf", byName["resume"].Accept)
+	assert.Equal(t, "landing", byName["source"].Value)
+}
+
+func TestFormFieldsDefaults(t *testing.T) {
+	form := &forms.Form{}
+	fields := form.Fields()
+	require.Len(t, fields, 3)
+	assert.Equal(t, "email", fields[1].Type)
+	assert.Equal(t, forms.DefaultSubmitLabel, form.SubmitLabel())
+}
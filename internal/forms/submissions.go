@@ -2,6 +2,7 @@ package forms
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"formlander/internal/live"
 	"formlander/internal/pkg/dbtxn"
 )
 
@@ -19,6 +21,14 @@ import (
 // not forwarded to webhooks or email.
 const HoneypotField = "__fl_hp"
 
+// IdempotencyWindow is how long a client-supplied idempotency key keeps
+// deduplicating retried posts to the same form. The SDK's offline queue
+// drops unsent posts after the same period.
+const IdempotencyWindow = 7 * 24 * time.Hour
+
+// MaxIdempotencyKeyLength bounds client-supplied idempotency keys.
+const MaxIdempotencyKeyLength = 128
+
 // SubmissionParams holds parameters for creating a submission
 type SubmissionParams struct {
 	FormID    uint
@@ -51,6 +61,34 @@ func CreateSubmission(logger *slog.Logger, db *gorm.DB, form *Form, payload map[
 
 // CreateSubmissionWithFiles creates a submission with optional file uploads
 func CreateSubmissionWithFiles(logger *slog.Logger, db *gorm.DB, form *Form, payload map[string]any, userAgent string, dataDir string, files []*UploadedFile) (*Submission, error) {
+	submission, _, err := createSubmission(logger, db, form, payload, userAgent, "", dataDir, files, "")
+	return submission, err
+}
+
+// CreateIdempotentSubmission creates a submission unless one with the same
+// idempotency key was stored for the form within IdempotencyWindow. In that
+// case nothing is written, the uploaded files are not saved, and the earlier
+// submission is returned with replayed set. An empty key never deduplicates.
+// origin is the domain the post came from, kept for analytics.
+func CreateIdempotentSubmission(logger *slog.Logger, db *gorm.DB, form *Form, idempotencyKey string, payload map[string]any, userAgent string, origin string, dataDir string, files []*UploadedFile) (submission *Submission, replayed bool, err error) {
+	return createSubmission(logger, db, form, payload, userAgent, origin, dataDir, files, idempotencyKey)
+}
+
+// ValidIdempotencyKey reports whether a client-supplied key is usable:
+// non-empty, bounded, and limited to visible ASCII.
+func ValidIdempotencyKey(key string) bool {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func createSubmission(logger *slog.Logger, db *gorm.DB, form *Form, payload map[string]any, userAgent string, origin string, dataDir string, files []*UploadedFile, idempotencyKey string) (*Submission, bool, error) {
 	isSpam := checkHoneypot(payload)
 	if isSpam {
 		// Operator visibility into honeypot activity. Info-level so it
@@ -64,22 +102,114 @@ func CreateSubmissionWithFiles(logger *slog.Logger, db *gorm.DB, form *Form, pay
 	encoded, err := json.Marshal(payload)
 	if err != nil {
 		logger.Error("encode submission payload", slog.Any("error", err))
-		return nil, fmt.Errorf("failed to encode submission payload")
+		return nil, false, fmt.Errorf("failed to encode submission payload")
+	}
+
+	// Spam never claims a unique value, so bots can't lock out real entries.
+	uniqueKey := ""
+	confirmation := ""
+	var fields []FlatField
+	if !isSpam {
+		fields = FlattenData(payload)
+		uniqueKey = form.uniqueKey(fields)
+		if form.DoubleOptIn {
+			if form.optInAddress(fields) == "" {
+				CloseFiles(files)
+				return nil, false, ErrOptInAddressMissing
+			}
+			confirmation = ConfirmationPending
+		}
 	}
 
 	submission := &Submission{
-		FormID:    form.ID,
-		DataJSON:  string(encoded),
-		IPHash:    "", // Not stored for privacy - only used for rate limiting
-		UserAgent: userAgent,
-		IsSpam:    isSpam,
+		FormID:             form.ID,
+		DataJSON:           string(encoded),
+		IPHash:             "", // Not stored for privacy - only used for rate limiting
+		UserAgent:          userAgent,
+		Origin:             origin,
+		IsSpam:             isSpam,
+		IdempotencyKey:     idempotencyKey,
+		UniqueKey:          uniqueKey,
+		ConfirmationStatus: confirmation,
+		Status:             SubmissionStatusNew,
 	}
 
+	var replayed, merged *Submission
+	var rejected bool
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		// The lookup shares the write transaction, so a concurrent retry
+		// carrying the same key sees the first insert once it commits.
+		replayed = nil
+		if idempotencyKey != "" {
+			var existing Submission
+			err := tx.Where("form_id = ? AND idempotency_key = ? AND created_at > ?",
+				form.ID, idempotencyKey, time.Now().UTC().Add(-IdempotencyWindow)).
+				Order("id ASC").
+				First(&existing).Error
+			if err == nil {
+				replayed = &existing
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		if err := form.ScheduleError(time.Now().UTC()); err != nil {
+			return err
+		}
+
+		// A repeated unique value is rejected, stored without forwarding,
+		// or merged into the earlier submission, per the form's setting.
+		merged, rejected = nil, false
+		submission.IsDuplicate, submission.DuplicateOfID = false, nil
+		if uniqueKey != "" {
+			original, err := findOriginal(tx, form.ID, uniqueKey)
+			if err != nil {
+				return err
+			}
+			if original != nil {
+				if err := tx.Model(original).UpdateColumn("duplicate_count", gorm.Expr("duplicate_count + 1")).Error; err != nil {
+					return err
+				}
+				original.DuplicateCount++
+
+				switch form.UniqueAction {
+				case UniqueActionIgnore:
+					submission.IsDuplicate = true
+					submission.DuplicateOfID = &original.ID
+				case UniqueActionUpdate:
+					// The changed entry goes back to the inbox as unread.
+					if err := tx.Model(original).Updates(map[string]any{"data_json": submission.DataJSON, "read_at": nil}).Error; err != nil {
+						return err
+					}
+					original.DataJSON = submission.DataJSON
+					original.ReadAt = nil
+					merged = original
+					return nil
+				default:
+					rejected = true
+					return nil
+				}
+			}
+		}
+
 		if err := tx.Create(submission).Error; err != nil {
 			return err
 		}
 
+		// The cap is checked after the insert inside the same write
+		// transaction, so concurrent posts can't both take the last slot.
+		if form.HasSubmissionCap() && !submission.IsDuplicate && !(isSpam && form.CapIgnoresSpam) {
+			count, err := countTowardCap(tx, form)
+			if err != nil {
+				return err
+			}
+			if count > int64(form.MaxSubmissions) {
+				return ErrFormFull
+			}
+		}
+
 		// Save files to disk and create records.
 		// Spam submissions don't save files: the bot got its 2xx, but we
 		// don't want to give it a free disk-fill vector for forms that
@@ -99,27 +229,12 @@ func CreateSubmissionWithFiles(logger *slog.Logger, db *gorm.DB, form *Form, pay
 		}
 
 		// Spam submissions are stored but never forwarded — the bot sees a
-		// success response while the honeypot quietly contains it.
-		if !isSpam {
-			// Check webhook delivery
-			webhookDelivery := form.WebhookDelivery
-			if webhookDelivery != nil && webhookDelivery.Enabled && webhookDelivery.URL != "" {
-				event := NewWebhookEvent(submission.ID, time.Now().UTC())
-				if err := tx.Create(event).Error; err != nil {
-					return err
-				}
-			}
-
-			// Check email delivery
-			emailDelivery := form.EmailDelivery
-			if emailDelivery != nil && emailDelivery.Enabled {
-				recipient := extractEmailRecipient(emailDelivery)
-				if recipient != "" {
-					event := NewEmailEvent(submission.ID, time.Now().UTC())
-					if err := tx.Create(event).Error; err != nil {
-						return err
-					}
-				}
+		// success response while the honeypot quietly contains it. Ignored
+		// duplicates are kept for the record without being forwarded again,
+		// and double opt-in entries wait for their confirmation link.
+		if !isSpam && !submission.IsDuplicate && !submission.AwaitingConfirmation() {
+			if err := queueDeliveries(tx, form, submission.ID, fields, time.Now().UTC()); err != nil {
+				return err
 			}
 		}
 
@@ -129,11 +244,81 @@ func CreateSubmissionWithFiles(logger *slog.Logger, db *gorm.DB, form *Form, pay
 		if len(files) > 0 && dataDir != "" && submission.ID > 0 {
 			DeleteSubmissionFiles(dataDir, form.ID, submission.ID)
 		}
+		if IsClosedError(err) {
+			CloseFiles(files)
+			logger.Info("submission refused",
+				slog.Uint64("form_id", uint64(form.ID)),
+				slog.String("reason", err.Error()),
+			)
+			return nil, false, err
+		}
 		logger.Error("store submission failed", slog.Any("error", err))
-		return nil, fmt.Errorf("failed to save submission")
+		return nil, false, fmt.Errorf("failed to save submission")
+	}
+
+	if rejected {
+		CloseFiles(files)
+		logger.Info("duplicate submission rejected", slog.Uint64("form_id", uint64(form.ID)))
+		return nil, false, ErrDuplicateSubmission
+	}
+
+	if merged != nil {
+		CloseFiles(files)
+		logger.Info("duplicate submission merged",
+			slog.Uint64("form_id", uint64(form.ID)),
+			slog.Uint64("submission_id", uint64(merged.ID)),
+		)
+		return merged, false, nil
+	}
+
+	if replayed != nil {
+		CloseFiles(files)
+		logger.Info("duplicate submission ignored",
+			slog.Uint64("form_id", uint64(form.ID)),
+			slog.Uint64("submission_id", uint64(replayed.ID)),
+		)
+		return replayed, true, nil
 	}
 
-	return submission, nil
+	live.Publish(live.Event{Type: live.SubmissionCreated, SubmissionID: submission.ID, FormID: form.ID})
+	return submission, false, nil
+}
+
+// queueDeliveries creates the webhook and email events that forward a stored
+// submission, as decided by the form's routing rules.
+func queueDeliveries(tx *gorm.DB, form *Form, submissionID uint, fields []FlatField, now time.Time) error {
+	rules, err := ListRules(tx, form.ID)
+	if err != nil {
+		return err
+	}
+	route := RouteSubmission(form, rules, fields)
+
+	if route.Webhook {
+		if err := tx.Create(NewWebhookEvent(submissionID, now)).Error; err != nil {
+			return err
+		}
+	}
+	for _, url := range route.ExtraWebhooks {
+		event := NewWebhookEvent(submissionID, now)
+		event.URL = url
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+	}
+
+	if route.Email {
+		if err := tx.Create(NewEmailEvent(submissionID, now)).Error; err != nil {
+			return err
+		}
+	}
+	for _, to := range route.ExtraEmails {
+		event := NewEmailEvent(submissionID, now)
+		event.Recipient = to
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // extractEmailRecipient extracts the recipient email from email delivery overrides
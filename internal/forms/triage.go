@@ -0,0 +1,220 @@
+package forms
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// Triage states of a submission.
+const (
+	SubmissionStatusNew        = "new"
+	SubmissionStatusInProgress = "in_progress"
+	SubmissionStatusDone       = "done"
+	SubmissionStatusArchived   = "archived"
+)
+
+// SubmissionStatuses lists the triage states in workflow order.
+var SubmissionStatuses = []string{
+	SubmissionStatusNew,
+	SubmissionStatusInProgress,
+	SubmissionStatusDone,
+	SubmissionStatusArchived,
+}
+
+// Tag limits.
+const (
+	MaxSubmissionTags = 20
+	MaxTagLength      = 32
+	MaxNoteLength     = 10000
+)
+
+// TriageParams holds the workflow fields an admin sets on a submission.
+type TriageParams struct {
+	Status     string
+	AssigneeID *uint  // nil unassigns
+	Tags       string // Comma-separated
+}
+
+// StatusLabel returns a display name for a triage state.
+func StatusLabel(status string) string {
+	switch status {
+	case SubmissionStatusInProgress:
+		return "In progress"
+	case SubmissionStatusDone:
+		return "Done"
+	case SubmissionStatusArchived:
+		return "Archived"
+	}
+	return "New"
+}
+
+// IsValidStatus reports whether status is a known triage state.
+func IsValidStatus(status string) bool {
+	for _, s := range SubmissionStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags lowercases, trims and deduplicates a comma-separated tag
+// list, dropping empty entries.
+func NormalizeTags(raw string) ([]string, error) {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(raw, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > MaxTagLength {
+			return nil, &ValidationError{Field: "tags", Message: "Tags can be at most 32 characters"}
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) > MaxSubmissionTags {
+		return nil, &ValidationError{Field: "tags", Message: "A submission can have at most 20 tags"}
+	}
+	return tags, nil
+}
+
+// TagList returns the submission's tags.
+func (s *Submission) TagList() []string {
+	if s.Tags == "" {
+		return nil
+	}
+	return strings.Split(s.Tags, ",")
+}
+
+// IsUnread reports whether no admin has opened the submission yet.
+func (s *Submission) IsUnread() bool {
+	return s.ReadAt == nil
+}
+
+// StatusLabel returns the display name of the submission's triage state.
+func (s *Submission) StatusLabel() string {
+	return StatusLabel(s.Status)
+}
+
+// UpdateTriage sets a submission's status, assignee and tags.
+func UpdateTriage(logger *slog.Logger, db *gorm.DB, submissionID uint, params TriageParams) error {
+	params.Status = strings.TrimSpace(params.Status)
+	if !IsValidStatus(params.Status) {
+		return &ValidationError{Field: "status", Message: "Unknown status"}
+	}
+	tags, err := NormalizeTags(params.Tags)
+	if err != nil {
+		return err
+	}
+	if params.AssigneeID != nil {
+		if _, err := accounts.FindByID(db, *params.AssigneeID); err != nil {
+			if errors.Is(err, accounts.ErrUserNotFound) {
+				return &ValidationError{Field: "assignee_id", Message: "Assignee must be an admin user"}
+			}
+			return err
+		}
+	}
+
+	err = dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		result := tx.Model(&Submission{}).Where("id = ?", submissionID).Updates(map[string]any{
+			"status":      params.Status,
+			"assignee_id": params.AssigneeID,
+			"tags":        strings.Join(tags, ","),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("submission triaged",
+		slog.Uint64("submission_id", uint64(submissionID)),
+		slog.String("status", params.Status),
+	)
+	return nil
+}
+
+// MarkRead records that an admin opened the submission. Only the first read
+// is kept.
+func MarkRead(logger *slog.Logger, db *gorm.DB, submissionID uint, now time.Time) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		return tx.Model(&Submission{}).
+			Where("id = ? AND read_at IS NULL", submissionID).
+			Update("read_at", now).Error
+	})
+}
+
+// MarkUnread puts a submission back in the unread pile.
+func MarkUnread(logger *slog.Logger, db *gorm.DB, submissionID uint) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		return tx.Model(&Submission{}).Where("id = ?", submissionID).Update("read_at", nil).Error
+	})
+}
+
+// AddNote appends an internal note to a submission.
+func AddNote(logger *slog.Logger, db *gorm.DB, submissionID uint, author *accounts.User, body string) (*SubmissionNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, &ValidationError{Field: "body", Message: "Note can't be empty"}
+	}
+	if len(body) > MaxNoteLength {
+		return nil, &ValidationError{Field: "body", Message: "Note is too long"}
+	}
+
+	note := &SubmissionNote{SubmissionID: submissionID, Body: body}
+	if author != nil {
+		note.AuthorID = &author.ID
+		note.AuthorEmail = author.Email
+	}
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&Submission{}, submissionID).Error; err != nil {
+			return err
+		}
+		return tx.Create(note).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// DetachUser clears a removed user from submissions inside the caller's
+// transaction: assignments are dropped, notes and replies keep the author's
+// email but lose the link, and the user's saved views and form grants are
+// deleted.
+func DetachUser(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&Submission{}).Where("assignee_id = ?", userID).Update("assignee_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&SubmissionNote{}).Where("author_id = ?", userID).Update("author_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&SubmissionReply{}).Where("author_id = ?", userID).Update("author_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&FormGrant{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&SavedView{}).Error
+}
+
+// TagFilter narrows a submission query to those carrying a tag.
+func TagFilter(query *gorm.DB, tag string) *gorm.DB {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return query.Where("(',' || submissions.tags || ',') LIKE ?", "%,"+tag+",%")
+}
@@ -0,0 +1,144 @@
+package forms_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := forms.NormalizeTags(" Sales, urgent ,,sales, VIP ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sales", "urgent", "vip"}, tags)
+
+	_, err = forms.NormalizeTags(strings.Repeat("x", forms.MaxTagLength+1))
+	var valErr *forms.ValidationError
+	require.ErrorAs(t, err, &valErr)
+	assert.Equal(t, "tags", valErr.Field)
+}
+
+func TestSubmissionTriage(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	user := &accounts.User{Email: "ops@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(user).Error)
+	form := &forms.Form{Name: "Contact", Slug: "contact"}
+	require.NoError(t, db.Create(form).Error)
+	sub, err := forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"email": "a@example.com"}, "UA", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, forms.SubmissionStatusNew, sub.Status)
+	assert.True(t, sub.IsUnread())
+
+	t.Run("rejects unknown status and assignee", func(t *testing.T) {
+		var valErr *forms.ValidationError
+		err := forms.UpdateTriage(logger, db, sub.ID, forms.TriageParams{Status: "pending"})
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "status", valErr.Field)
+
+		missing := uint(9999)
+		err = forms.UpdateTriage(logger, db, sub.ID, forms.TriageParams{Status: forms.SubmissionStatusDone, AssigneeID: &missing})
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "assignee_id", valErr.Field)
+
+		err = forms.UpdateTriage(logger, db, 9999, forms.TriageParams{Status: forms.SubmissionStatusDone})
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	})
+
+	t.Run("sets status, assignee and tags", func(t *testing.T) {
+		require.NoError(t, forms.UpdateTriage(logger, db, sub.ID, forms.TriageParams{
+			Status:     forms.SubmissionStatusInProgress,
+			AssigneeID: &user.ID,
+			Tags:       "Sales, vip",
+		}))
+
+		var got forms.Submission
+		require.NoError(t, db.First(&got, sub.ID).Error)
+		assert.Equal(t, forms.SubmissionStatusInProgress, got.Status)
+		require.NotNil(t, got.AssigneeID)
+		assert.Equal(t, user.ID, *got.AssigneeID)
+		assert.Equal(t, []string{"sales", "vip"}, got.TagList())
+
+		var count int64
+		forms.TagFilter(db.Model(&forms.Submission{}), "VIP").Count(&count)
+		assert.Equal(t, int64(1), count)
+		forms.TagFilter(db.Model(&forms.Submission{}), "vi").Count(&count)
+		assert.Equal(t, int64(0), count)
+
+		require.NoError(t, forms.UpdateTriage(logger, db, sub.ID, forms.TriageParams{Status: forms.SubmissionStatusDone}))
+		require.NoError(t, db.First(&got, sub.ID).Error)
+		assert.Nil(t, got.AssigneeID)
+		assert.Empty(t, got.TagList())
+	})
+
+	t.Run("keeps the first read", func(t *testing.T) {
+		first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, forms.MarkRead(logger, db, sub.ID, first))
+		require.NoError(t, forms.MarkRead(logger, db, sub.ID, first.Add(time.Hour)))
+
+		var got forms.Submission
+		require.NoError(t, db.First(&got, sub.ID).Error)
+		require.NotNil(t, got.ReadAt)
+		assert.True(t, got.ReadAt.Equal(first))
+
+		require.NoError(t, forms.MarkUnread(logger, db, sub.ID))
+		var unread forms.Submission
+		require.NoError(t, db.First(&unread, sub.ID).Error)
+		assert.True(t, unread.IsUnread())
+	})
+
+	t.Run("adds notes", func(t *testing.T) {
+		_, err := forms.AddNote(logger, db, sub.ID, user, "   ")
+		var valErr *forms.ValidationError
+		require.ErrorAs(t, err, &valErr)
+		assert.Equal(t, "body", valErr.Field)
+
+		note, err := forms.AddNote(logger, db, sub.ID, user, " Called back, waiting on quote ")
+		require.NoError(t, err)
+		assert.Equal(t, "Called back, waiting on quote", note.Body)
+		assert.Equal(t, "ops@example.com", note.AuthorEmail)
+
+		_, err = forms.AddNote(logger, db, 9999, user, "hello")
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	})
+}
+
+func TestDetachUser(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	user := &accounts.User{Email: "ops@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(user).Error)
+	form := &forms.Form{Name: "Contact", Slug: "contact"}
+	require.NoError(t, db.Create(form).Error)
+	sub := &forms.Submission{FormID: form.ID, DataJSON: "{}", AssigneeID: &user.ID}
+	require.NoError(t, db.Create(sub).Error)
+	note, err := forms.AddNote(logger, db, sub.ID, user, "Called back")
+	require.NoError(t, err)
+	_, err = forms.CreateView(logger, db, user.ID, "Mine", forms.SubmissionFilter{Assignee: "me"}, true)
+	require.NoError(t, err)
+
+	require.NoError(t, forms.DetachUser(db, user.ID))
+
+	var stored forms.Submission
+	require.NoError(t, db.First(&stored, sub.ID).Error)
+	assert.Nil(t, stored.AssigneeID)
+	var storedNote forms.SubmissionNote
+	require.NoError(t, db.First(&storedNote, note.ID).Error)
+	assert.Nil(t, storedNote.AuthorID)
+	assert.Equal(t, "ops@example.com", storedNote.AuthorEmail)
+	views, err := forms.ListViews(db, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, views)
+}
@@ -0,0 +1,136 @@
+package forms
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// What happens when a submission repeats the value of a form's unique field.
+const (
+	UniqueActionReject = "reject" // Refuse the submission with an error
+	UniqueActionIgnore = "ignore" // Store it as a duplicate but don't forward it
+	UniqueActionUpdate = "update" // Overwrite the earlier submission's data
+)
+
+// ErrDuplicateSubmission is returned when a submission repeats a unique field
+// value on a form set to reject duplicates.
+var ErrDuplicateSubmission = errors.New("duplicate submission")
+
+// UniqueParams holds a form's unique field constraint.
+type UniqueParams struct {
+	Field  string // Field path such as email or contact.email; empty disables the constraint
+	Action string
+}
+
+// apply copies the normalized settings onto a form.
+func (p UniqueParams) apply(form *Form) {
+	form.UniqueField = p.Field
+	form.UniqueAction = p.Action
+}
+
+// normalizeUnique trims and validates the unique field settings.
+func normalizeUnique(p UniqueParams) (UniqueParams, error) {
+	p.Field = strings.TrimSpace(p.Field)
+	p.Action = strings.ToLower(strings.TrimSpace(p.Action))
+	if p.Field == "" {
+		return UniqueParams{}, nil
+	}
+	if len(p.Field) > 255 || strings.ContainsAny(p.Field, " \t\r\n") {
+		return p, &ValidationError{Field: "unique_field", Message: "Unique field must be a field name without spaces"}
+	}
+	switch p.Action {
+	case "":
+		p.Action = UniqueActionReject
+	case UniqueActionReject, UniqueActionIgnore, UniqueActionUpdate:
+	default:
+		return p, &ValidationError{Field: "unique_action", Message: "Duplicate handling must be reject, ignore or update"}
+	}
+	return p, nil
+}
+
+// HasUniqueField reports whether the form enforces a unique field.
+func (f *Form) HasUniqueField() bool {
+	return f.UniqueField != ""
+}
+
+// uniqueKey returns the fingerprint of the form's unique field among a
+// submission's fields, or "" when the form has no constraint or the field is
+// missing or blank. Values are compared trimmed and case-insensitively.
+func (f *Form) uniqueKey(fields []FlatField) string {
+	if !f.HasUniqueField() {
+		return ""
+	}
+	for _, field := range fields {
+		if field.Path != f.UniqueField {
+			continue
+		}
+		value := strings.ToLower(strings.TrimSpace(field.Value))
+		if value == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	return ""
+}
+
+// findOriginal returns the earliest non-duplicate submission of the form with
+// the given unique key, or nil. Expired opt-ins don't hold on to their value.
+func findOriginal(tx *gorm.DB, formID uint, key string) (*Submission, error) {
+	var original Submission
+	err := tx.Where("form_id = ? AND unique_key = ? AND is_duplicate = ? AND confirmation_status <> ?", formID, key, false, ConfirmationExpired).
+		Order("id ASC").
+		First(&original).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &original, nil
+}
+
+// backfillUniqueKeys recomputes unique keys for a form's stored submissions
+// after its unique field changes, so earlier entries count as originals.
+func backfillUniqueKeys(tx *gorm.DB, form *Form) error {
+	const batchSize = 500
+	var lastID uint
+	for {
+		var batch []Submission
+		if err := tx.Select("id", "data_json", "is_spam").
+			Where("form_id = ? AND id > ?", form.ID, lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&batch).Error; err != nil {
+			return err
+		}
+		for _, sub := range batch {
+			key := ""
+			if !sub.IsSpam {
+				key = form.uniqueKey(FlattenDataJSON(sub.DataJSON))
+			}
+			if err := tx.Model(&Submission{}).Where("id = ?", sub.ID).Update("unique_key", key).Error; err != nil {
+				return err
+			}
+			lastID = sub.ID
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+// DuplicateCount returns how many repeats of earlier submissions a form has
+// received, whether they were rejected, ignored or merged.
+func DuplicateCount(db *gorm.DB, formID uint) (int64, error) {
+	var total int64
+	err := db.Model(&Submission{}).
+		Select("COALESCE(SUM(duplicate_count), 0)").
+		Where("form_id = ?", formID).
+		Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,142 @@
+package forms_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniqueFieldConstraint(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	setup := func(t *testing.T, action string) (*gorm.DB, *forms.Form) {
+		t.Helper()
+		db := testsupport.SetupTestDB(t)
+		form := &forms.Form{
+			Name:            "Waitlist",
+			Slug:            "waitlist",
+			UniqueField:     "email",
+			UniqueAction:    action,
+			WebhookDelivery: &forms.WebhookDelivery{Enabled: true, URL: "https://hooks.example.com"},
+		}
+		require.NoError(t, db.Create(form).Error)
+		return db, form
+	}
+	submit := func(db *gorm.DB, form *forms.Form, email, name string) (*forms.Submission, error) {
+		return forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"email": email, "name": name}, "UA", "", nil)
+	}
+	count := func(db *gorm.DB, model any) int64 {
+		var n int64
+		db.Model(model).Count(&n)
+		return n
+	}
+
+	t.Run("reject refuses repeats, trimmed and case-insensitive", func(t *testing.T) {
+		db, form := setup(t, forms.UniqueActionReject)
+		first, err := submit(db, form, "ada@example.com", "Ada")
+		require.NoError(t, err)
+
+		_, err = submit(db, form, "  ADA@example.com ", "Ada again")
+		assert.ErrorIs(t, err, forms.ErrDuplicateSubmission)
+
+		_, err = submit(db, form, "grace@example.com", "Grace")
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(2), count(db, &forms.Submission{}))
+		assert.Equal(t, int64(2), count(db, &forms.WebhookEvent{}))
+
+		var original forms.Submission
+		require.NoError(t, db.First(&original, first.ID).Error)
+		assert.Equal(t, 1, original.DuplicateCount)
+
+		total, err := forms.DuplicateCount(db, form.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+	})
+
+	t.Run("ignore stores repeats without forwarding", func(t *testing.T) {
+		db, form := setup(t, forms.UniqueActionIgnore)
+		first, err := submit(db, form, "ada@example.com", "Ada")
+		require.NoError(t, err)
+
+		dup, err := submit(db, form, "Ada@Example.com", "Ada again")
+		require.NoError(t, err)
+		assert.True(t, dup.IsDuplicate)
+		require.NotNil(t, dup.DuplicateOfID)
+		assert.Equal(t, first.ID, *dup.DuplicateOfID)
+
+		assert.Equal(t, int64(2), count(db, &forms.Submission{}))
+		assert.Equal(t, int64(1), count(db, &forms.WebhookEvent{}), "duplicates are not forwarded")
+	})
+
+	t.Run("update merges into the earlier submission", func(t *testing.T) {
+		db, form := setup(t, forms.UniqueActionUpdate)
+		first, err := submit(db, form, "ada@example.com", "Ada")
+		require.NoError(t, err)
+
+		merged, err := submit(db, form, "ada@example.com", "Ada Lovelace")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, merged.ID)
+
+		var stored forms.Submission
+		require.NoError(t, db.First(&stored, first.ID).Error)
+		assert.JSONEq(t, `{"email":"ada@example.com","name":"Ada Lovelace"}`, stored.DataJSON)
+		assert.Equal(t, 1, stored.DuplicateCount)
+		assert.Equal(t, int64(1), count(db, &forms.Submission{}))
+	})
+
+	t.Run("blank values and spam don't claim a value", func(t *testing.T) {
+		db, form := setup(t, forms.UniqueActionReject)
+		for i := 0; i < 2; i++ {
+			_, err := submit(db, form, " ", "Anonymous")
+			require.NoError(t, err)
+		}
+
+		spam := map[string]any{"email": "ada@example.com", forms.HoneypotField: "bot"}
+		_, err := forms.CreateSubmissionWithFiles(logger, db, form, spam, "UA", "", nil)
+		require.NoError(t, err)
+
+		_, err = submit(db, form, "ada@example.com", "Ada")
+		assert.NoError(t, err)
+	})
+}
+
+func TestUniqueFieldBackfill(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := testsupport.SetupTestDB(t)
+
+	form, err := forms.Create(logger, db, forms.CreateParams{Name: "Signup", Slug: "signup", AllowedOrigins: "*"})
+	require.NoError(t, err)
+	_, err = forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"email": "ada@example.com"}, "UA", "", nil)
+	require.NoError(t, err)
+
+	form, err = forms.Update(logger, db, forms.UpdateParams{
+		ID:             form.ID,
+		Name:           form.Name,
+		AllowedOrigins: "*",
+		Unique:         forms.UniqueParams{Field: "email"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, forms.UniqueActionReject, form.UniqueAction)
+
+	_, err = forms.CreateSubmissionWithFiles(logger, db, form, map[string]any{"email": "ADA@example.com"}, "UA", "", nil)
+	assert.ErrorIs(t, err, forms.ErrDuplicateSubmission, "submissions stored before the constraint count as originals")
+
+	_, err = forms.Update(logger, db, forms.UpdateParams{
+		ID:             form.ID,
+		Name:           form.Name,
+		AllowedOrigins: "*",
+		Unique:         forms.UniqueParams{Field: "email", Action: "merge"},
+	})
+	var validationErr *forms.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "unique_action", validationErr.Field)
+}
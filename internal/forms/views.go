@@ -0,0 +1,109 @@
+package forms
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/pkg/dbtxn"
+)
+
+// MaxSavedViews caps how many views one admin can save.
+const MaxSavedViews = 50
+
+// Filter decodes the view's stored filter.
+func (v *SavedView) Filter() SubmissionFilter {
+	values, _ := url.ParseQuery(v.Query)
+	return ParseSubmissionFilter(values)
+}
+
+// ListViews returns an admin's saved views, pinned ones first.
+func ListViews(db *gorm.DB, userID uint) ([]SavedView, error) {
+	var views []SavedView
+	err := db.Where("user_id = ?", userID).Order("pinned DESC, name ASC").Find(&views).Error
+	return views, err
+}
+
+// PinnedViews returns the views an admin pinned next to the inbox.
+func PinnedViews(db *gorm.DB, userID uint) ([]SavedView, error) {
+	var views []SavedView
+	err := db.Where("user_id = ? AND pinned = ?", userID, true).Order("name ASC").Find(&views).Error
+	return views, err
+}
+
+// GetView loads one of an admin's saved views.
+func GetView(db *gorm.DB, userID, viewID uint) (*SavedView, error) {
+	var view SavedView
+	if err := db.Where("user_id = ?", userID).First(&view, viewID).Error; err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// CreateView saves a filter under a name. Saving over an existing name
+// replaces that view's filter.
+func CreateView(logger *slog.Logger, db *gorm.DB, userID uint, name string, filter SubmissionFilter, pinned bool) (*SavedView, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, &ValidationError{Field: "name", Message: "Give the view a name of up to 100 characters"}
+	}
+
+	view := &SavedView{UserID: userID, Name: name, Query: filter.Encode(), Pinned: pinned}
+	err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		var existing SavedView
+		err := tx.Where("user_id = ? AND name = ?", userID, name).First(&existing).Error
+		if err == nil {
+			view.ID = existing.ID
+			view.CreatedAt = existing.CreatedAt
+			return tx.Model(&existing).Updates(map[string]any{"query": view.Query, "pinned": pinned}).Error
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&SavedView{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxSavedViews {
+			return &ValidationError{Field: "name", Message: "You already have the maximum number of saved views"}
+		}
+		return tx.Create(view).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("submission view saved", slog.Uint64("user_id", uint64(userID)), slog.Uint64("view_id", uint64(view.ID)))
+	return view, nil
+}
+
+// SetViewPinned pins or unpins one of an admin's saved views.
+func SetViewPinned(logger *slog.Logger, db *gorm.DB, userID, viewID uint, pinned bool) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		result := tx.Model(&SavedView{}).Where("id = ? AND user_id = ?", viewID, userID).Update("pinned", pinned)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteView removes one of an admin's saved views.
+func DeleteView(logger *slog.Logger, db *gorm.DB, userID, viewID uint) error {
+	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&SavedView{}, viewID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/forms"
+)
+
+// RequireRole runs after RequireActiveUser and rejects users whose role is
+// below role.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, _ := c.Locals("current_user").(*accounts.User)
+		if user == nil || !user.HasRole(role) {
+			return fiber.ErrForbidden
+		}
+		return c.Next()
+	}
+}
+
+// RequireFormAccess guards routes for the form in the :id parameter. Forms
+// the user isn't granted are reported missing; edit additionally needs a
+// role that may change them.
+func RequireFormAccess(dbm cartridge.DBManager, edit bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		formID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+		return checkFormAccess(c, dbm.GetConnection().WithContext(c.UserContext()), uint(formID), edit)
+	}
+}
+
+// RequireSubmissionAccess guards routes for the submission in the :id
+// parameter by the access its form grants.
+func RequireSubmissionAccess(dbm cartridge.DBManager, edit bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		submissionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+		db := dbm.GetConnection().WithContext(c.UserContext())
+		var formIDs []uint
+		if err := db.Model(&forms.Submission{}).Where("id = ?", submissionID).Pluck("form_id", &formIDs).Error; err != nil {
+			return fiber.ErrInternalServerError
+		}
+		if len(formIDs) == 0 {
+			return fiber.ErrNotFound
+		}
+		return checkFormAccess(c, db, formIDs[0], edit)
+	}
+}
+
+func checkFormAccess(c *fiber.Ctx, db *gorm.DB, formID uint, edit bool) error {
+	user, _ := c.Locals("current_user").(*accounts.User)
+	if user == nil {
+		return fiber.ErrForbidden
+	}
+	visible, err := forms.CanAccessForm(db, user, formID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	if !visible {
+		return fiber.ErrNotFound
+	}
+	if edit && !user.CanEdit() {
+		return fiber.ErrForbidden
+	}
+	return c.Next()
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/forms"
+)
+
+// analyticsRangeOptions are the ranges offered on the analytics page.
+var analyticsRangeOptions = []string{"24h", "7d", "30d", "90d"}
+
+// AdminFormAnalytics shows submission, spam and delivery trends for a form.
+func AdminFormAnalytics(ctx *cartridge.Context) error {
+	form, err := routingForm(ctx)
+	if err != nil {
+		return err
+	}
+
+	r := forms.ParseAnalyticsRange(ctx.Query("range"), ctx.Query("interval"), time.Now())
+	analytics, err := forms.FormAnalytics(ctx.DB(), form, r)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	labelFormat := "Jan 2"
+	if r.Interval == forms.IntervalHour {
+		labelFormat = "Jan 2 15:00"
+	}
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":       "Analytics · " + form.Name,
+		"Form":        form,
+		"Analytics":   analytics,
+		"Ranges":      analyticsRangeOptions,
+		"HourlyOK":    r.Name == "24h" || r.Name == "7d",
+		"LabelFormat": labelFormat,
+		"ContentView": "admin/forms/analytics/content",
+	}, "")
+}
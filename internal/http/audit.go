@@ -0,0 +1,99 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/audit"
+)
+
+// auditDB returns the database with the signed-in user attached as the
+// actor of any audit entries the request writes.
+func auditDB(ctx *cartridge.Context) *gorm.DB {
+	return auditDBAs(ctx, CurrentUser(ctx))
+}
+
+// auditDBAs is auditDB for a given user, such as one who is signing in; a
+// nil user records just the client address.
+func auditDBAs(ctx *cartridge.Context, user *accounts.User) *gorm.DB {
+	actor := audit.Actor{IP: ctx.IP()}
+	if user != nil {
+		actor.UserID = user.ID
+		actor.Email = user.Email
+	}
+	return ctx.DB().WithContext(audit.WithActor(ctx.UserContext(), actor))
+}
+
+// recordLoginFailure adds a failed sign-in to the audit log. The sign-in has
+// already failed, so an entry that can't be written is only logged.
+func recordLoginFailure(ctx *cartridge.Context, method, reason string, details map[string]any) {
+	if details == nil {
+		details = map[string]any{}
+	}
+	details["method"] = method
+	details["reason"] = reason
+	if err := audit.Log(ctx.Logger, auditDBAs(ctx, nil), audit.Event{Action: audit.ActionLoginFailed, Details: details}); err != nil {
+		ctx.Logger.Error("failed to record failed sign-in", slog.Any("error", err))
+	}
+}
+
+// AdminAuditLog lists the audit log, newest first, with filters.
+func AdminAuditLog(ctx *cartridge.Context) error {
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	perPage := 50
+
+	filter := audit.ParseFilter(queryValues(ctx))
+	entries, total, err := audit.List(ctx.DB(), filter, perPage, (page-1)*perPage)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	totalPages := (int(total) + perPage - 1) / perPage
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":        "Audit log",
+		"Entries":      entries,
+		"Filter":       filter,
+		"Filtered":     !filter.IsZero(),
+		"FilterQuery":  template.URL(filter.Encode()),
+		"Actions":      audit.Actions,
+		"ActionGroups": audit.ActionGroups(),
+		"TotalCount":   total,
+		"Page":         page,
+		"TotalPages":   totalPages,
+		"HasPrev":      page > 1,
+		"HasNext":      page < totalPages,
+		"PrevPage":     page - 1,
+		"NextPage":     page + 1,
+		"ContentView":  "admin/audit/index",
+	}, "")
+}
+
+// AdminAuditExport downloads the filtered audit log as JSON.
+func AdminAuditExport(ctx *cartridge.Context) error {
+	filter := audit.ParseFilter(queryValues(ctx))
+	entries, _, err := audit.List(ctx.DB(), filter, audit.MaxExportEntries, 0)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	var buf strings.Builder
+	if err := audit.WriteJSON(&buf, entries); err != nil {
+		return fiber.ErrInternalServerError
+	}
+	filename := fmt.Sprintf("audit-log-%s.json", time.Now().UTC().Format("20060102"))
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return ctx.SendString(buf.String())
+}
@@ -3,21 +3,32 @@ package http
 import (
 	"errors"
 	"log/slog"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/karloscodes/cartridge"
 
 	"formlander/internal/accounts"
+	"formlander/internal/pkg/ratelimit"
+)
+
+// loginChallengeCookie carries a user who passed the password step to the
+// second-factor step. It is not a session.
+const loginChallengeCookie = "formlander_2fa"
+
+// secondFactorLimiter caps code guesses per user, on top of the per-IP
+// login limit, so six digits can't be brute-forced from many addresses.
+var secondFactorLimiter = ratelimit.NewLimiter()
+
+const (
+	secondFactorAttempts = 10
+	secondFactorWindow   = 15 * time.Minute
 )
 
 // AdminLoginPage renders the admin login form.
 func AdminLoginPage(ctx *cartridge.Context) error {
-	return ctx.Render("layouts/base", fiber.Map{
-		"Title":                  "Sign in",
-		"HideHeaderActions":      true,
-		"ContentView":            "admin/login/content",
-		"ShowDefaultCredentials": accounts.IsDefaultAdminActive(ctx.DB()),
-	}, "")
+	return renderLoginError(ctx, "")
 }
 
 // AdminLoginSubmit handles credential verification.
@@ -30,14 +41,26 @@ func AdminLoginSubmit(ctx *cartridge.Context) error {
 	result, err := accounts.Authenticate(ctx.Logger, db, email, password)
 	if err != nil {
 		if errors.Is(err, accounts.ErrInvalidCredentials) || errors.Is(err, accounts.ErrMissingFields) {
+			recordLoginFailure(ctx, "password", "invalid_credentials", map[string]any{"email": email})
 			return renderLoginError(ctx, "Invalid credentials")
 		}
+		if errors.Is(err, accounts.ErrUserDeactivated) {
+			recordLoginFailure(ctx, "password", "deactivated", map[string]any{"email": email})
+			return renderLoginError(ctx, "This account has been deactivated")
+		}
 		ctx.Logger.Error("authentication failed", slog.Any("error", err))
 		return fiber.ErrInternalServerError
 	}
 
-	if err := GetSession(ctx).SetSession(ctx.Ctx, result.User.ID); err != nil {
-		ctx.Logger.Error("failed to set session cookie", slog.Any("error", err), slog.Uint64("userID", uint64(result.User.ID)))
+	// With two-factor authentication on, the password alone doesn't start a
+	// session; the verify step does.
+	if result.User.TOTPEnabled() {
+		setLoginChallenge(ctx, result.User.ID)
+		return ctx.Redirect("/admin/login/verify")
+	}
+
+	if err := startSession(ctx, result.User, "password"); err != nil {
+		ctx.Logger.Error("failed to start session", slog.Any("error", err), slog.Uint64("userID", uint64(result.User.ID)))
 		return fiber.ErrInternalServerError
 	}
 
@@ -46,20 +69,187 @@ func AdminLoginSubmit(ctx *cartridge.Context) error {
 	return ctx.Redirect("/admin")
 }
 
-// AdminLogout destroys the session and redirects to login.
+// AdminLoginVerifyPage asks for the authenticator or recovery code after
+// the password step.
+func AdminLoginVerifyPage(ctx *cartridge.Context) error {
+	if _, err := loginChallengeUser(ctx); err != nil {
+		return ctx.Redirect("/admin/login")
+	}
+	return renderLoginVerify(ctx, "")
+}
+
+// AdminLoginVerifySubmit checks the second factor and starts the session.
+func AdminLoginVerifySubmit(ctx *cartridge.Context) error {
+	userID, err := loginChallengeUser(ctx)
+	if err != nil {
+		clearLoginChallenge(ctx)
+		return renderLoginError(ctx, "Your sign-in expired. Please sign in again.")
+	}
+	if !secondFactorLimiter.Allow(strconv.FormatUint(uint64(userID), 10), secondFactorAttempts, secondFactorWindow) {
+		return ctx.Status(fiber.StatusTooManyRequests).Render("layouts/base", fiber.Map{
+			"Title":             "Two-factor authentication",
+			"Error":             "Too many attempts. Please try again later.",
+			"HideHeaderActions": true,
+			"ContentView":       "admin/login/verify",
+		}, "")
+	}
+
+	user, err := accounts.VerifySecondFactor(ctx.Logger, ctx.DB(), userID, ctx.FormValue("code"), time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, accounts.ErrInvalidCode):
+			recordLoginFailure(ctx, "two_factor", "invalid_code", map[string]any{"user_id": userID})
+			return renderLoginVerify(ctx, "That code is not valid")
+		case errors.Is(err, accounts.ErrUserDeactivated):
+			clearLoginChallenge(ctx)
+			return renderLoginError(ctx, "This account has been deactivated")
+		case errors.Is(err, accounts.ErrUserNotFound), errors.Is(err, accounts.ErrTOTPNotEnabled):
+			// Removed, or 2FA reset since the password step: start over.
+			clearLoginChallenge(ctx)
+			return renderLoginError(ctx, "Your sign-in expired. Please sign in again.")
+		}
+		ctx.Logger.Error("second factor verification failed", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+
+	clearLoginChallenge(ctx)
+	if err := startSession(ctx, user, "two_factor"); err != nil {
+		ctx.Logger.Error("failed to start session", slog.Any("error", err), slog.Uint64("userID", uint64(user.ID)))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin")
+}
+
+// AdminLogout ends the session and redirects to login.
 func AdminLogout(ctx *cartridge.Context) error {
-	GetSession(ctx).ClearSession(ctx.Ctx)
+	if session := CurrentSession(ctx); session != nil {
+		if err := accounts.RevokeSession(ctx.Logger, auditDB(ctx), session.UserID, session.ID, time.Now()); err != nil && !errors.Is(err, accounts.ErrSessionNotFound) {
+			ctx.Logger.Error("failed to revoke session on logout", slog.Any("error", err))
+		}
+	}
+	endSession(ctx.Ctx)
 	return ctx.Redirect("/admin/login")
 }
 
+// RequireActiveUser runs after the session check on admin routes. The signed
+// session cookie stays valid until it expires, so this is where revoked and
+// timed-out sessions, and removed and deactivated users, lose access. The
+// user and session are kept in locals for CurrentUser and CurrentSession, and
+// the user's layout data is bound for the admin pages. Background requests
+// don't count as activity, so they don't hold off the idle timeout.
+func RequireActiveUser(dbm cartridge.DBManager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if userID, ok := GetSessionFromFiber(c).GetUserID(c); ok {
+			db := dbm.GetConnection().WithContext(c.UserContext())
+			user, err := accounts.FindByID(db, userID)
+			if err != nil && !errors.Is(err, accounts.ErrUserNotFound) {
+				return fiber.ErrInternalServerError
+			}
+			if err == nil && user.Active() {
+				idle := GetAppConfigFromFiber(c).Sessions.IdleTimeout()
+				token := c.Cookies(sessionTokenCookie)
+				var session *accounts.Session
+				if isBackgroundRequest(c) {
+					session, err = accounts.FindSession(db, user.ID, token, time.Now(), idle)
+				} else {
+					session, err = accounts.ValidateSession(slog.Default(), db, user.ID, token, time.Now(), idle)
+				}
+				if err != nil && !errors.Is(err, accounts.ErrSessionInvalid) {
+					return fiber.ErrInternalServerError
+				}
+				if err == nil {
+					c.Locals("current_user", user)
+					c.Locals("current_session", session)
+					bindLayoutData(c, db, user.ID)
+					return c.Next()
+				}
+			}
+		}
+
+		endSession(c)
+		if c.Get("HX-Request") == "true" {
+			return c.Status(fiber.StatusUnauthorized).SendString("authentication required")
+		}
+		return c.Redirect("/admin/login")
+	}
+}
+
+// BackgroundRequest marks the requests of a route as made by an open page on
+// its own, such as live updates, rather than by the person using it. It goes
+// before RequireActiveUser.
+func BackgroundRequest(c *fiber.Ctx) error {
+	c.Locals("background_request", true)
+	return c.Next()
+}
+
+func isBackgroundRequest(c *fiber.Ctx) bool {
+	background, _ := c.Locals("background_request").(bool)
+	return background
+}
+
+// CurrentUser returns the signed-in user loaded by RequireActiveUser.
+func CurrentUser(ctx *cartridge.Context) *accounts.User {
+	user, _ := ctx.Locals("current_user").(*accounts.User)
+	return user
+}
+
 func renderLoginError(ctx *cartridge.Context, message string) error {
-	return ctx.Render("layouts/base", fiber.Map{
+	return renderLogin(ctx, fiber.Map{"Error": message})
+}
+
+// renderLogin renders the login page with extra, such as an error or a
+// notice, on top of what the page always needs.
+func renderLogin(ctx *cartridge.Context, extra fiber.Map) error {
+	sso := GetAppConfig(ctx).OIDC
+	data := fiber.Map{
 		"Title":                  "Sign in",
-		"Error":                  message,
 		"HideHeaderActions":      true,
 		"ContentView":            "admin/login/content",
 		"ShowDefaultCredentials": accounts.IsDefaultAdminActive(ctx.DB()),
+		"SSOEnabled":             sso.Enabled(),
+		"SSOLabel":               sso.ButtonLabel,
+		"PasswordResets":         passwordResetsAvailable(ctx),
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	return ctx.Render("layouts/base", data, "")
+}
+
+func renderLoginVerify(ctx *cartridge.Context, message string) error {
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":             "Two-factor authentication",
+		"Error":             message,
+		"HideHeaderActions": true,
+		"ContentView":       "admin/login/verify",
 	}, "")
 }
 
+func setLoginChallenge(ctx *cartridge.Context, userID uint) {
+	expires := time.Now().Add(accounts.LoginChallengeTTL)
+	ctx.Cookie(&fiber.Cookie{
+		Name:     loginChallengeCookie,
+		Value:    accounts.LoginChallengeToken(GetAppConfig(ctx).SessionSecret, userID, expires),
+		Path:     "/admin/login",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   GetAppConfig(ctx).IsProduction(),
+		SameSite: "Lax",
+	})
+}
+
+func clearLoginChallenge(ctx *cartridge.Context) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     loginChallengeCookie,
+		Path:     "/admin/login",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+}
+
+func loginChallengeUser(ctx *cartridge.Context) (uint, error) {
+	return accounts.VerifyLoginChallenge(GetAppConfig(ctx).SessionSecret, ctx.Cookies(loginChallengeCookie), time.Now())
+}
+
 // Password changes are handled in the settings page (AdminSettingsUpdatePassword).
@@ -64,7 +64,7 @@ func CaptchaProfileNew(ctx *cartridge.Context) error {
 
 // CaptchaProfileCreate handles profile creation.
 func CaptchaProfileCreate(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 
 	logger := ctx.Logger
 	params := integrations.CaptchaProfileParams{
@@ -142,7 +142,7 @@ func CaptchaProfileEdit(ctx *cartridge.Context) error {
 
 // CaptchaProfileUpdate handles profile updates.
 func CaptchaProfileUpdate(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 
 	id := ctx.Params("id")
 	profileID, err := strconv.ParseUint(id, 10, 32)
@@ -183,7 +183,7 @@ func CaptchaProfileUpdate(ctx *cartridge.Context) error {
 
 // CaptchaProfileDelete removes a profile.
 func CaptchaProfileDelete(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 
 	id := ctx.Params("id")
 	profileID, err := strconv.ParseUint(id, 10, 32)
@@ -5,34 +5,39 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
 
 	"formlander/internal/forms"
 )
 
 // AdminDashboard shows the main dashboard with stats and recent activity
+// for the forms the user may see.
 func AdminDashboard(ctx *cartridge.Context) error {
 	db := ctx.DB()
+	user := CurrentUser(ctx)
+	scopedForms := func() *gorm.DB { return forms.ScopeForms(db.Model(&forms.Form{}), user) }
+	scopedSubmissions := func() *gorm.DB { return forms.ScopeSubmissions(db.Model(&forms.Submission{}), user) }
 
 	// Get total counts
 	var totalForms, totalSubmissions int64
-	db.Model(&forms.Form{}).Count(&totalForms)
-	db.Model(&forms.Submission{}).Count(&totalSubmissions)
+	scopedForms().Count(&totalForms)
+	scopedSubmissions().Count(&totalSubmissions)
 
 	// Get submissions from last 24 hours
 	yesterday := time.Now().Add(-24 * time.Hour)
 	var submissionsLast24h int64
-	db.Model(&forms.Submission{}).Where("created_at > ?", yesterday).Count(&submissionsLast24h)
+	scopedSubmissions().Where("submissions.created_at > ?", yesterday).Count(&submissionsLast24h)
 
 	// Get recent submissions (last 10)
 	var recentSubmissions []forms.Submission
-	db.Preload("Form").
+	scopedSubmissions().Preload("Form").
 		Order("created_at DESC").
 		Limit(10).
 		Find(&recentSubmissions)
 
 	// Get recent forms (last 5)
 	var recentForms []forms.Form
-	db.Order("created_at DESC").
+	scopedForms().Order("created_at DESC").
 		Limit(5).
 		Find(&recentForms)
 
@@ -42,7 +47,7 @@ func AdminDashboard(ctx *cartridge.Context) error {
 		SubmissionCount int64
 	}
 	var formsWithCounts []FormWithCount
-	db.Model(&forms.Form{}).
+	scopedForms().
 		Select("forms.*, COUNT(submissions.id) as submission_count").
 		Joins("LEFT JOIN submissions ON submissions.form_id = forms.id").
 		Group("forms.id").
@@ -60,6 +65,7 @@ func AdminDashboard(ctx *cartridge.Context) error {
 		"RecentSubmissions": recentSubmissions,
 		"RecentForms":       recentForms,
 		"TopForms":          formsWithCounts,
+		"CanManage":         user.SeesAllForms(),
 		"ContentView":       "admin/dashboard/content",
 	}, "")
 }
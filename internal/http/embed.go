@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// EmbedFormDefinition serves the public definition of a form for the embed
+// widget (<script src=".../assets/formlander.js" data-form="PUBLIC_ID">):
+// its fields, theme, captcha settings and messages. Cross-origin requests
+// must come from one of the form's allowed origins, same as submissions.
+func EmbedFormDefinition(ctx *cartridge.Context) error {
+	db := ctx.DB()
+	cfg := GetAppConfig(ctx)
+
+	form, err := forms.GetByPublicID(db, ctx.Params("public_id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jsonError(ctx, fiber.StatusNotFound, "form not found")
+		}
+		return jsonError(ctx, fiber.StatusInternalServerError, "form lookup failed")
+	}
+
+	// The answer depends on the origin, so shared caches must key on it.
+	ctx.Vary(fiber.HeaderOrigin)
+	if origin := ctx.Get("Origin"); origin != "" {
+		domain := extractDomain(origin)
+		if !form.IsOriginAllowed(domain) && !isHostedPageOrigin(ctx, form, domain) &&
+			!strings.EqualFold(domain, extractDomain(ctx.Hostname())) {
+			return jsonError(ctx, fiber.StatusForbidden, "origin not allowed")
+		}
+	}
+
+	definition := fiber.Map{
+		"public_id":       form.PublicID,
+		"title":           form.HostedPageTitle(),
+		"description":     form.HostedDescription,
+		"logo_url":        form.HostedLogoURL,
+		"accent_color":    form.HostedAccent(),
+		"custom_css":      form.HostedCSS,
+		"submit_url":      cfg.AbsoluteURL(liveFormAction(form.Slug, form.Token)),
+		"submit_label":    form.SubmitLabel(),
+		"fields":          form.Fields(),
+		"honeypot_field":  forms.HoneypotField,
+		"success_title":   form.SuccessPageTitle(),
+		"success_message": form.SuccessPageMessage(),
+		"error_message":   form.ErrorPageMessage(),
+		"captcha":         embedCaptcha(form),
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"ok":   true,
+		"form": definition,
+	})
+}
+
+// embedCaptcha describes the captcha widget the embed must render, or nil
+// when the form has none.
+func embedCaptcha(form *forms.Form) fiber.Map {
+	if form.CaptchaProfileID == nil || form.CaptchaProfile == nil {
+		return nil
+	}
+	if !strings.EqualFold(strings.TrimSpace(form.CaptchaProfile.Provider), "turnstile") {
+		return nil
+	}
+
+	policy, siteKey := resolveTurnstileSettings(form)
+	size := policy.Size
+	if size == "" && strings.EqualFold(policy.Widget, "invisible") {
+		size = "invisible"
+	}
+	return fiber.Map{
+		"provider": "turnstile",
+		"site_key": siteKey,
+		"action":   policy.Action,
+		"theme":    policy.Theme,
+		"language": policy.Language,
+		"size":     size,
+	}
+}
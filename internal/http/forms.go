@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/karloscodes/cartridge"
@@ -19,11 +20,12 @@ import (
 	"formlander/internal/pkg/dbtxn"
 )
 
-// AdminFormsIndex renders the list of forms.
+// AdminFormsIndex renders the list of forms the user may see.
 func AdminFormsIndex(ctx *cartridge.Context) error {
 	db := ctx.DB()
+	user := CurrentUser(ctx)
 
-	formsList, err := forms.List(db)
+	formsList, err := forms.List(forms.ScopeForms(db, user))
 	if err != nil {
 		return fiber.ErrInternalServerError
 	}
@@ -33,11 +35,31 @@ func AdminFormsIndex(ctx *cartridge.Context) error {
 		db.Preload("EmailDelivery").Preload("WebhookDelivery").First(&formsList[i], formsList[i].ID)
 	}
 
+	now := time.Now().UTC()
+	availability, err := forms.GetAvailability(db, formsList, now)
+	if err != nil {
+		ctx.Logger.Error("failed to load form availability", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+
+	formIDs := make([]uint, len(formsList))
+	for i := range formsList {
+		formIDs[i] = formsList[i].ID
+	}
+	conversions, err := forms.ConversionStats(db, formIDs, now.AddDate(0, 0, -29), now)
+	if err != nil {
+		ctx.Logger.Error("failed to load form conversions", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+
 	return ctx.Render("layouts/base", fiber.Map{
-		"Title":       "Forms",
-		"Forms":       formsList,
-		"CreateRoute": "/admin/forms/new",
-		"ContentView": "admin/forms/index/content",
+		"Title":        "Forms",
+		"Forms":        formsList,
+		"Availability": availability,
+		"Conversions":  conversions,
+		"CanManage":    user.SeesAllForms(),
+		"CreateRoute":  "/admin/forms/new",
+		"ContentView":  "admin/forms/index/content",
 	}, "")
 }
 
@@ -106,7 +128,7 @@ func AdminFormsNew(ctx *cartridge.Context) error {
 
 // AdminFormsCreate persists a new form configuration.
 func AdminFormsCreate(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 
 	templateID := strings.TrimSpace(ctx.FormValue("template_id"))
 	selectedTemplate := GetTemplateByID(templateID)
@@ -129,6 +151,11 @@ func AdminFormsCreate(ctx *cartridge.Context) error {
 		}
 	}
 
+	availability, valErr := availabilityParams(ctx)
+	if valErr != nil {
+		return renderFormError(ctx, valErr.Message, nil, nil, nil, false, selectedTemplate)
+	}
+
 	// Use forms context for business logic
 	params := forms.CreateParams{
 		Name:               ctx.FormValue("name"),
@@ -140,6 +167,15 @@ func AdminFormsCreate(ctx *cartridge.Context) error {
 		CaptchaProfileID:   captchaProfileID,
 		EmailRecipient:     ctx.FormValue("email_recipient"),
 		EmailEnabled:       ctx.FormValue("email_enabled") == "on",
+		DigestMode:         ctx.FormValue("email_digest_mode"),
+		DigestHour:         formIntValue(ctx, "email_digest_hour", 9),
+		DigestWeekday:      formIntValue(ctx, "email_digest_weekday", 1),
+		DigestTimezone:     ctx.FormValue("email_digest_timezone"),
+		InboundAddress:     ctx.FormValue("inbound_address"),
+		Hosted:             hostedPageParams(ctx),
+		Availability:       availability,
+		Unique:             uniqueParams(ctx),
+		OptIn:              optInParams(ctx),
 		WebhookEnabled:     ctx.FormValue("webhook_enabled") == "on",
 		WebhookURL:         ctx.FormValue("webhook_url"),
 		WebhookSecret:      ctx.FormValue("webhook_secret"),
@@ -211,6 +247,16 @@ func AdminFormShow(ctx *cartridge.Context) error {
 		return fiber.ErrInternalServerError
 	}
 
+	duplicateCount, err := forms.DuplicateCount(db, form.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	rules, err := forms.ListRules(db, form.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
 	// Extract email recipient from overrides for display
 	emailRecipient := ""
 	if form.EmailDelivery != nil && form.EmailDelivery.OverridesJSON != "" {
@@ -223,24 +269,10 @@ func AdminFormShow(ctx *cartridge.Context) error {
 	}
 
 	endpoint := fmt.Sprintf("/forms/%s/submit", form.Slug)
-	actionURL := liveFormAction(form.Slug, form.Token)
-	captchaEmbed := buildCaptchaEmbed(form)
-	formCode := ""
+	formCode := buildFormCode(logger, form)
 	hasGeneratedHTML := strings.TrimSpace(form.GeneratedHTML) != ""
-
-	if hasGeneratedHTML {
-		var prepared string
-		var err error
-		if prepared, err = normalizeFormHTML(form.GeneratedHTML, actionURL, form); err != nil {
-			if logger != nil {
-				logger.Warn("failed to normalize generated form HTML", slog.Any("error", err), slog.Uint64("form_id", uint64(form.ID)))
-			}
-			prepared = form.GeneratedHTML
-		}
-		formCode = injectCaptchaSnippet(prepared, captchaEmbed)
-	} else {
-		formCode = buildDefaultFormCode(actionURL, form, captchaEmbed)
-	}
+	embedSnippet := fmt.Sprintf(`<script src="%s" data-form="%s" async></script>`,
+		GetAppConfig(ctx).AbsoluteURL("/assets/formlander.js"), form.PublicID)
 
 	return ctx.Render("layouts/base", fiber.Map{
 		"Title":            form.Name,
@@ -253,6 +285,10 @@ func AdminFormShow(ctx *cartridge.Context) error {
 		"EmailRecipient":   emailRecipient,
 		"FormCode":         formCode,
 		"HasGeneratedHTML": hasGeneratedHTML,
+		"EmbedSnippet":     embedSnippet,
+		"DuplicateCount":   duplicateCount,
+		"RuleCount":        len(rules),
+		"CanEdit":          CurrentUser(ctx).CanEdit(),
 		"ContentView":      "admin/forms/show/content",
 	}, "")
 }
@@ -331,7 +367,7 @@ func AdminFormsEdit(ctx *cartridge.Context) error {
 
 // AdminFormsUpdate persists changes to an existing form.
 func AdminFormsUpdate(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 	logger := ctx.Logger
 
 	id, err := strconv.Atoi(ctx.Params("id"))
@@ -357,6 +393,15 @@ func AdminFormsUpdate(ctx *cartridge.Context) error {
 		}
 	}
 
+	availability, valErr := availabilityParams(ctx)
+	if valErr != nil {
+		form, err := forms.GetByID(db, uint(id))
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+		return renderFormError(ctx, valErr.Message, form, form.EmailDelivery, form.WebhookDelivery, true, nil)
+	}
+
 	params := forms.UpdateParams{
 		ID:                 uint(id),
 		Name:               ctx.FormValue("name"),
@@ -366,6 +411,15 @@ func AdminFormsUpdate(ctx *cartridge.Context) error {
 		CaptchaProfileID:   captchaProfileID,
 		EmailRecipient:     ctx.FormValue("email_recipient"),
 		EmailEnabled:       ctx.FormValue("email_enabled") == "on",
+		DigestMode:         ctx.FormValue("email_digest_mode"),
+		DigestHour:         formIntValue(ctx, "email_digest_hour", 9),
+		DigestWeekday:      formIntValue(ctx, "email_digest_weekday", 1),
+		DigestTimezone:     ctx.FormValue("email_digest_timezone"),
+		InboundAddress:     ctx.FormValue("inbound_address"),
+		Hosted:             hostedPageParams(ctx),
+		Availability:       availability,
+		Unique:             uniqueParams(ctx),
+		OptIn:              optInParams(ctx),
 		WebhookEnabled:     ctx.FormValue("webhook_enabled") == "on",
 		WebhookURL:         ctx.FormValue("webhook_url"),
 		WebhookSecret:      ctx.FormValue("webhook_secret"),
@@ -388,6 +442,95 @@ func AdminFormsUpdate(ctx *cartridge.Context) error {
 	return ctx.Redirect(fmt.Sprintf("/admin/forms/%d", updatedForm.ID))
 }
 
+// hostedPageParams reads the hosted page settings from the form editor.
+func hostedPageParams(ctx *cartridge.Context) forms.HostedPageParams {
+	return forms.HostedPageParams{
+		Enabled:     ctx.FormValue("hosted_enabled") == "on",
+		Title:       ctx.FormValue("hosted_title"),
+		Description: ctx.FormValue("hosted_description"),
+		LogoURL:     ctx.FormValue("hosted_logo_url"),
+		AccentColor: ctx.FormValue("hosted_accent_color"),
+		CustomCSS:   ctx.FormValue("hosted_css"),
+
+		SuccessTitle:   ctx.FormValue("success_title"),
+		SuccessMessage: ctx.FormValue("success_message"),
+		ErrorTitle:     ctx.FormValue("error_title"),
+		ErrorMessage:   ctx.FormValue("error_message"),
+	}
+}
+
+// uniqueParams reads the unique field constraint from the form editor.
+func uniqueParams(ctx *cartridge.Context) forms.UniqueParams {
+	return forms.UniqueParams{
+		Field:  ctx.FormValue("unique_field"),
+		Action: ctx.FormValue("unique_action"),
+	}
+}
+
+// optInParams reads the double opt-in settings from the form editor.
+func optInParams(ctx *cartridge.Context) forms.OptInParams {
+	return forms.OptInParams{
+		Enabled:     ctx.FormValue("opt_in_enabled") == "on",
+		EmailField:  ctx.FormValue("opt_in_email_field"),
+		ExpiryHours: formIntValue(ctx, "opt_in_expiry_hours", 0),
+		Subject:     ctx.FormValue("opt_in_subject"),
+		Message:     ctx.FormValue("opt_in_message"),
+	}
+}
+
+// scheduleTimeLayout is the value format of datetime-local inputs. Schedule
+// times are entered and shown in UTC.
+const scheduleTimeLayout = "2006-01-02T15:04"
+
+// availabilityParams reads the schedule and submission cap settings from the
+// form editor.
+func availabilityParams(ctx *cartridge.Context) (forms.AvailabilityParams, *forms.ValidationError) {
+	opensAt, ok := formTimeValue(ctx, "opens_at")
+	if !ok {
+		return forms.AvailabilityParams{}, &forms.ValidationError{Field: "opens_at", Message: "Open time must be a valid date and time"}
+	}
+	closesAt, ok := formTimeValue(ctx, "closes_at")
+	if !ok {
+		return forms.AvailabilityParams{}, &forms.ValidationError{Field: "closes_at", Message: "Close time must be a valid date and time"}
+	}
+	return forms.AvailabilityParams{
+		OpensAt:        opensAt,
+		ClosesAt:       closesAt,
+		MaxSubmissions: formIntValue(ctx, "max_submissions", 0),
+		CapIgnoresSpam: ctx.FormValue("cap_ignores_spam") == "on",
+		ClosedURL:      ctx.FormValue("closed_url"),
+		ClosedMessage:  ctx.FormValue("closed_message"),
+	}, nil
+}
+
+// formTimeValue parses a datetime-local field as UTC. An empty field yields
+// nil; ok is false when the value can't be parsed.
+func formTimeValue(ctx *cartridge.Context, key string) (*time.Time, bool) {
+	raw := strings.TrimSpace(ctx.FormValue(key))
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.ParseInLocation(scheduleTimeLayout, raw, time.UTC)
+	if err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+// formIntValue parses an integer form field, returning fallback when the field
+// is empty. Unparseable input yields -1 so range validation rejects it.
+func formIntValue(ctx *cartridge.Context, key string, fallback int) int {
+	raw := strings.TrimSpace(ctx.FormValue(key))
+	if raw == "" {
+		return fallback
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil {
+		return -1
+	}
+	return val
+}
+
 func renderFormError(ctx *cartridge.Context, message string, form *forms.Form, emailDelivery *forms.EmailDelivery, webhookDelivery *forms.WebhookDelivery, isEdit bool, template *FormTemplate) error {
 	// Load profiles for dropdowns
 	db := ctx.DB()
@@ -469,6 +612,26 @@ func renderFormError(ctx *cartridge.Context, message string, form *forms.Form, e
 	return ctx.Render("layouts/base", data, "")
 }
 
+// buildFormCode returns the form's markup wired to its live endpoint, with the
+// captcha embed injected. Forms without generated HTML get a default form.
+func buildFormCode(logger *slog.Logger, form *forms.Form) string {
+	actionURL := liveFormAction(form.Slug, form.Token)
+	captchaEmbed := buildCaptchaEmbed(form)
+
+	if strings.TrimSpace(form.GeneratedHTML) == "" {
+		return buildDefaultFormCode(actionURL, form, captchaEmbed)
+	}
+
+	prepared, err := normalizeFormHTML(form.GeneratedHTML, actionURL, form)
+	if err != nil {
+		if logger != nil {
+			logger.Warn("failed to normalize generated form HTML", slog.Any("error", err), slog.Uint64("form_id", uint64(form.ID)))
+		}
+		prepared = form.GeneratedHTML
+	}
+	return injectCaptchaSnippet(prepared, captchaEmbed)
+}
+
 func buildDefaultFormCode(actionURL string, form *forms.Form, embed *captchaEmbed) string {
 	if form == nil {
 		return ""
@@ -725,14 +888,11 @@ func buildCaptchaEmbed(form *forms.Form) *captchaEmbed {
 	}
 }
 
+// turnstileOrigin serves the Turnstile widget script and its challenge frame.
+const turnstileOrigin = "https://challenges.cloudflare.com"
+
 func buildTurnstileEmbed(form *forms.Form) *captchaEmbed {
-	profile := form.CaptchaProfile
-	policy := parseCaptchaPolicy(profile.PolicyJSON, form.CaptchaOverridesJSON)
-	siteKey := strings.TrimSpace(policy.SiteKey)
-	if siteKey == "" {
-		siteKeys := parseCaptchaSiteKeys(profile.SiteKeysJSON)
-		siteKey = selectCaptchaSiteKey(form, siteKeys)
-	}
+	policy, siteKey := resolveTurnstileSettings(form)
 	if siteKey == "" {
 		siteKey = "YOUR_TURNSTILE_SITE_KEY"
 	}
@@ -757,7 +917,7 @@ func buildTurnstileEmbed(form *forms.Form) *captchaEmbed {
         <div class="cf-turnstile" %s></div>
     </div>`, strings.Join(attrs, " "))
 
-	script := `<script src="https://challenges.cloudflare.com/turnstile/v0/api.js" async defer></script>`
+	script := `<script src="` + turnstileOrigin + `/turnstile/v0/api.js" async defer></script>`
 
 	return &captchaEmbed{
 		WidgetMarkup: widget,
@@ -765,6 +925,19 @@ func buildTurnstileEmbed(form *forms.Form) *captchaEmbed {
 	}
 }
 
+// resolveTurnstileSettings merges the profile policy with the form's
+// overrides and picks the site key for the form's origins.
+func resolveTurnstileSettings(form *forms.Form) (captchaPolicy, string) {
+	profile := form.CaptchaProfile
+	policy := parseCaptchaPolicy(profile.PolicyJSON, form.CaptchaOverridesJSON)
+	siteKey := strings.TrimSpace(policy.SiteKey)
+	if siteKey == "" {
+		siteKeys := parseCaptchaSiteKeys(profile.SiteKeysJSON)
+		siteKey = selectCaptchaSiteKey(form, siteKeys)
+	}
+	return policy, siteKey
+}
+
 type captchaSiteKeyEntry struct {
 	HostPattern string `json:"host_pattern"`
 	SiteKey     string `json:"site_key"`
@@ -935,6 +1108,12 @@ func liveFormAction(slug, token string) string {
 	return fmt.Sprintf("/forms/%s/submit?token=%s", slug, token)
 }
 
+// liveViewAction is the view beacon URL of the form posting to
+// liveFormAction.
+func liveViewAction(slug, token string) string {
+	return strings.Replace(liveFormAction(slug, token), "/submit?", "/view?", 1)
+}
+
 func isUniqueConstraint(err error) bool {
 	return strings.Contains(strings.ToLower(err.Error()), "unique")
 }
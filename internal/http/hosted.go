@@ -0,0 +1,238 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// HostedFormPage renders a form's stored HTML as a standalone public page so
+// it can be shared as a link. The page posts to the form's regular endpoint.
+func HostedFormPage(ctx *cartridge.Context) error {
+	db := ctx.DB()
+
+	form, err := forms.GetByPublicID(db, ctx.Params("public_id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	if !form.HostedEnabled {
+		return fiber.ErrNotFound
+	}
+
+	ctx.Set(fiber.HeaderXFrameOptions, "SAMEORIGIN")
+	if err := forms.CheckAvailability(db, form, time.Now().UTC()); forms.IsClosedError(err) {
+		return renderResult(ctx, fiber.StatusOK, form, form.ClosedPageTitle(err), form.ClosedPageMessage(err), "", false)
+	}
+	nonce, err := scriptNonce()
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	ctx.Set(fiber.HeaderContentSecurityPolicy, hostedPagePolicy(form, nonce))
+	return ctx.Render("hosted/form", fiber.Map{
+		"Title":       form.HostedPageTitle(),
+		"Description": form.HostedDescription,
+		"LogoURL":     form.HostedLogoURL,
+		"AccentColor": template.CSS(form.HostedAccent()),
+		"CustomCSS":   template.CSS(form.HostedCSS),
+		"FormCode":    template.HTML(buildFormCode(ctx.Logger, form)),
+		"ViewURL":     liveViewAction(form.Slug, form.Token),
+		"Nonce":       nonce,
+	}, "")
+}
+
+// hostedPagePolicy is the Content-Security-Policy of the hosted pages. They
+// load only from this host, plus the form's logo and captcha, and the only
+// inline script is the view beacon carrying nonce. Styles stay inline for the
+// theme and the form's custom CSS. Forms post to this host, and from there
+// may only be redirected within the form's allowed origins.
+func hostedPagePolicy(form *forms.Form, nonce string) string {
+	scripts := []string{"'self'"}
+	if nonce != "" {
+		scripts = append(scripts, "'nonce-"+nonce+"'")
+	}
+	frames := []string{"'none'"}
+	if embed := buildCaptchaEmbed(form); embed != nil && !embed.isEmpty() {
+		scripts = append(scripts, turnstileOrigin)
+		frames = []string{turnstileOrigin}
+	}
+
+	images := []string{"'self'"}
+	if logo, err := url.Parse(form.HostedLogoURL); err == nil && (logo.Scheme == "http" || logo.Scheme == "https") && logo.Host != "" {
+		images = append(images, logo.Scheme+"://"+logo.Host)
+	}
+
+	targets := []string{"'self'"}
+	if origins := strings.TrimSpace(form.AllowedOrigins); origins != "*" {
+		for _, origin := range strings.Split(origins, ",") {
+			if source := cspHostSource(origin); source != "" {
+				targets = append(targets, source)
+			}
+		}
+	}
+
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src " + strings.Join(scripts, " "),
+		"style-src 'self' 'unsafe-inline'",
+		"img-src " + strings.Join(images, " "),
+		"frame-src " + strings.Join(frames, " "),
+		"form-action " + strings.Join(targets, " "),
+		"frame-ancestors 'self'",
+		"base-uri 'none'",
+		"object-src 'none'",
+	}, "; ")
+}
+
+// cspHostSource turns an allowed origin entry (example.com, *.example.com or
+// https://example.com/path) into a CSP host source covering it and its
+// subdomains, as redirects to either are accepted.
+func cspHostSource(origin string) string {
+	host := strings.TrimSpace(origin)
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	if idx := strings.IndexAny(host, "/?#"); idx >= 0 {
+		host = host[:idx]
+	}
+	if host == "" || strings.ContainsAny(host, " ;,'") {
+		return ""
+	}
+	if strings.HasPrefix(host, "*.") {
+		return host
+	}
+	return host + " *." + host
+}
+
+// scriptNonce returns a fresh nonce for a page's inline script.
+func scriptNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// isHostedPageOrigin reports whether a request origin is this instance's own
+// hosted page for the form. Hosted pages are served from the Formlander host,
+// which is usually not in the form's AllowedOrigins.
+func isHostedPageOrigin(ctx *cartridge.Context, form *forms.Form, origin string) bool {
+	if form == nil || !form.HostedEnabled || origin == "" {
+		return false
+	}
+	return strings.EqualFold(origin, extractDomain(ctx.Hostname()))
+}
+
+// wantsHTMLResponse reports whether the request is a plain browser form post
+// (a page navigation). fetch/XHR and API clients keep getting JSON.
+func wantsHTMLResponse(ctx *cartridge.Context) bool {
+	if strings.Contains(ctx.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return false
+	}
+	if ctx.Get(fiber.HeaderXRequestedWith) != "" {
+		return false
+	}
+	if mode := ctx.Get("Sec-Fetch-Mode"); mode != "" && mode != "navigate" {
+		return false
+	}
+	return strings.Contains(ctx.Get(fiber.HeaderAccept), fiber.MIMETextHTML)
+}
+
+// submissionSucceeded answers a stored submission: a thank-you page for
+// browser form posts, JSON for everything else.
+func submissionSucceeded(ctx *cartridge.Context, form *forms.Form, submission *forms.Submission) error {
+	pending := submission.ConfirmationStatus == forms.ConfirmationPending
+	if wantsHTMLResponse(ctx) {
+		if pending {
+			return renderResult(ctx, fiber.StatusOK, form, forms.DefaultPendingTitle, forms.DefaultPendingMessage, "", false)
+		}
+		return renderResultPage(ctx, fiber.StatusOK, form, false, "")
+	}
+	response := fiber.Map{
+		"ok":            true,
+		"submission_id": submission.ID,
+		"received_at":   submission.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if submission.ConfirmationStatus != "" {
+		response["confirmation"] = submission.ConfirmationStatus
+	}
+	return ctx.Status(fiber.StatusOK).JSON(response)
+}
+
+// submissionFailed answers a rejected submission: an error page for browser
+// form posts, the JSON error shape for everything else. form may be nil when
+// the form itself could not be resolved.
+func submissionFailed(ctx *cartridge.Context, form *forms.Form, status int, message string) error {
+	if wantsHTMLResponse(ctx) {
+		return renderResultPage(ctx, status, form, true, message)
+	}
+	return jsonError(ctx, status, message)
+}
+
+// submissionClosed answers a post to a form that isn't accepting
+// submissions: a redirect to the form's closed URL when set, otherwise a
+// closed page for browsers or a JSON error carrying the reason.
+func submissionClosed(ctx *cartridge.Context, form *forms.Form, reason error) error {
+	if form.ClosedURL != "" {
+		return ctx.Redirect(form.ClosedURL)
+	}
+	message := form.ClosedPageMessage(reason)
+	if wantsHTMLResponse(ctx) {
+		return renderResult(ctx, fiber.StatusForbidden, form, form.ClosedPageTitle(reason), message, "", false)
+	}
+	return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"ok":     false,
+		"error":  message,
+		"reason": forms.ClosedStatus(reason),
+	})
+}
+
+func renderResultPage(ctx *cartridge.Context, status int, form *forms.Form, isError bool, detail string) error {
+	if form == nil {
+		form = &forms.Form{}
+	}
+
+	title, message := form.SuccessPageTitle(), form.SuccessPageMessage()
+	if isError {
+		title, message = form.ErrorPageTitle(), form.ErrorPageMessage()
+	}
+	return renderResult(ctx, status, form, title, message, detail, isError)
+}
+
+func renderResult(ctx *cartridge.Context, status int, form *forms.Form, title, message, detail string, isError bool) error {
+	ctx.Set(fiber.HeaderContentSecurityPolicy, hostedPagePolicy(form, ""))
+	return ctx.Status(status).Render("hosted/result", fiber.Map{
+		"Title":       title,
+		"Message":     message,
+		"Detail":      detail,
+		"IsError":     isError,
+		"BackURL":     refererBackURL(ctx),
+		"LogoURL":     form.HostedLogoURL,
+		"AccentColor": template.CSS(form.HostedAccent()),
+		"CustomCSS":   template.CSS(form.HostedCSS),
+	}, "")
+}
+
+// refererBackURL returns the page the form was posted from, for the
+// "back to site" link. Only absolute http(s) URLs are used.
+func refererBackURL(ctx *cartridge.Context) string {
+	referer := strings.TrimSpace(ctx.Get(fiber.HeaderReferer))
+	if referer == "" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
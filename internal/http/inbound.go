@@ -0,0 +1,225 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// inboundSignatureMaxAge bounds how old a signed inbound request may be, so a
+// captured request can't be replayed later.
+const inboundSignatureMaxAge = 5 * time.Minute
+
+// inboundTokens remembers the tokens of accepted signatures until they would
+// be too old anyway, so a captured request can't be replayed within the
+// signature window either.
+var inboundTokens = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: map[string]time.Time{}}
+
+// inboundUserAgent is stored on submissions received by email.
+const inboundUserAgent = "Formlander inbound email"
+
+// InboundEmail turns an email posted by a Mailgun-style inbound route into a
+// submission for the form that owns the recipient mailbox.
+//
+// Both route flavours are accepted: raw MIME in "body-mime" (routes ending in
+// "mime"), or pre-parsed fields (sender, subject, body-plain, attachment-N).
+// Requests are authenticated with the provider's HMAC signature over
+// timestamp+token; the endpoint is disabled until a signing key is configured.
+// Captcha and origin checks don't apply to email, but the honeypot/spam path
+// and delivery pipeline are the same as web posts.
+func InboundEmail(ctx *cartridge.Context) error {
+	db := ctx.DB()
+	cfg := GetAppConfig(ctx)
+	logger := ctx.Logger
+
+	signingKey := strings.TrimSpace(cfg.Inbound.SigningKey)
+	if signingKey == "" {
+		return jsonError(ctx, fiber.StatusNotFound, "inbound email disabled")
+	}
+
+	if !verifyInboundSignature(signingKey, ctx.FormValue("timestamp"), ctx.FormValue("token"), ctx.FormValue("signature"), time.Now()) {
+		return jsonError(ctx, fiber.StatusUnauthorized, "invalid signature")
+	}
+	token := ctx.FormValue("token")
+	if !claimInboundToken(token, ctx.FormValue("timestamp"), time.Now()) {
+		return jsonError(ctx, fiber.StatusUnauthorized, "signature already used")
+	}
+
+	form, err := findInboundForm(db, ctx.FormValue("recipient"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 406 tells Mailgun the message is rejected and must not be retried.
+			return jsonError(ctx, fiber.StatusNotAcceptable, "no form for recipient")
+		}
+		releaseInboundToken(token)
+		return jsonError(ctx, fiber.StatusInternalServerError, "form lookup failed")
+	}
+
+	msg, err := parseInboundRequest(ctx)
+	if err != nil {
+		return jsonError(ctx, fiber.StatusNotAcceptable, err.Error())
+	}
+
+	submission, err := forms.CreateSubmissionWithFiles(logger, db, form, msg.Payload(), inboundUserAgent, cfg.DataDirectory, msg.Files)
+	if err != nil {
+		forms.CloseFiles(msg.Files)
+		if forms.IsClosedError(err) || errors.Is(err, forms.ErrDuplicateSubmission) || errors.Is(err, forms.ErrOptInAddressMissing) {
+			return jsonError(ctx, fiber.StatusNotAcceptable, err.Error())
+		}
+		releaseInboundToken(token)
+		return jsonError(ctx, fiber.StatusInternalServerError, err.Error())
+	}
+
+	logger.Info("inbound email stored",
+		slog.Uint64("form_id", uint64(form.ID)),
+		slog.Uint64("submission_id", uint64(submission.ID)),
+		slog.Int("attachments", len(msg.Files)),
+	)
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"ok":            true,
+		"submission_id": submission.ID,
+		"received_at":   submission.CreatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// verifyInboundSignature checks the provider signature:
+// hex(HMAC-SHA256(signingKey, timestamp + token)).
+func verifyInboundSignature(signingKey, timestamp, token, signature string, now time.Time) bool {
+	if timestamp == "" || token == "" || signature == "" {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > inboundSignatureMaxAge || age < -inboundSignatureMaxAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(timestamp + token))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+// claimInboundToken marks the token of a verified signature as used and
+// reports whether it was still unused. Tokens are kept until their
+// timestamp leaves the signature window.
+func claimInboundToken(token, timestamp string, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	inboundTokens.Lock()
+	defer inboundTokens.Unlock()
+	for seen, expires := range inboundTokens.expires {
+		if now.After(expires) {
+			delete(inboundTokens.expires, seen)
+		}
+	}
+	if _, used := inboundTokens.expires[token]; used {
+		return false
+	}
+	inboundTokens.expires[token] = time.Unix(ts, 0).Add(inboundSignatureMaxAge)
+	return true
+}
+
+// releaseInboundToken forgets a token whose request failed on our side, so
+// the provider's retry of the same request is accepted.
+func releaseInboundToken(token string) {
+	inboundTokens.Lock()
+	defer inboundTokens.Unlock()
+	delete(inboundTokens.expires, token)
+}
+
+// findInboundForm resolves the first recipient that maps to a form. The
+// recipient field may list several comma-separated addresses.
+func findInboundForm(db *gorm.DB, recipients string) (*forms.Form, error) {
+	for _, recipient := range strings.Split(recipients, ",") {
+		if strings.TrimSpace(recipient) == "" {
+			continue
+		}
+		form, err := forms.GetByInboundAddress(db, recipient)
+		if err == nil {
+			return form, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// parseInboundRequest builds the message from raw MIME when present, falling
+// back to the provider's pre-parsed fields.
+func parseInboundRequest(ctx *cartridge.Context) (*forms.InboundMessage, error) {
+	if raw := ctx.FormValue("body-mime"); raw != "" {
+		return forms.ParseInboundMIME(strings.NewReader(raw))
+	}
+
+	msg := &forms.InboundMessage{
+		Subject:   strings.TrimSpace(ctx.FormValue("subject")),
+		Text:      strings.TrimSpace(ctx.FormValue("body-plain")),
+		HTML:      strings.TrimSpace(ctx.FormValue("body-html")),
+		MessageID: strings.TrimSpace(ctx.FormValue("Message-Id")),
+	}
+	sender := ctx.FormValue("from")
+	if sender == "" {
+		sender = ctx.FormValue("sender")
+	}
+	msg.SetSender(sender)
+
+	// message-headers is a JSON list of [name, value] pairs.
+	var headers [][]string
+	if err := json.Unmarshal([]byte(ctx.FormValue("message-headers")), &headers); err == nil {
+		for _, h := range headers {
+			if len(h) == 2 {
+				msg.ApplySpamHeader(h[0], h[1])
+			}
+		}
+	}
+
+	if msg.From == "" && msg.Text == "" && msg.HTML == "" {
+		return nil, errors.New("email message empty")
+	}
+
+	if multipartForm, err := ctx.MultipartForm(); err == nil && multipartForm != nil {
+		for _, fileHeaders := range multipartForm.File {
+			for _, header := range fileHeaders {
+				file, err := header.Open()
+				if err != nil {
+					forms.CloseFiles(msg.Files)
+					return nil, errors.New("failed to read attachment")
+				}
+				data, err := io.ReadAll(io.LimitReader(file, forms.MaxFileSize+1))
+				file.Close()
+				if err != nil {
+					forms.CloseFiles(msg.Files)
+					return nil, errors.New("failed to read attachment")
+				}
+				msg.AddAttachment(header.Filename, header.Header.Get("Content-Type"), data)
+			}
+		}
+	}
+
+	return msg, nil
+}
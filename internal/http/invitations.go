@@ -0,0 +1,85 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/accounts"
+)
+
+// InvitationPage asks an invited user to choose a password.
+func InvitationPage(ctx *cartridge.Context) error {
+	invitation, err := accounts.FindInvitation(ctx.DB(), GetAppConfig(ctx).SessionSecret, ctx.Params("token"), time.Now())
+	if err != nil {
+		return renderInvitationError(ctx, err)
+	}
+	return renderInvitation(ctx, invitation.Email, "")
+}
+
+// InvitationAccept creates the invited user's account and signs them in.
+func InvitationAccept(ctx *cartridge.Context) error {
+	db := ctx.DB()
+	secret := GetAppConfig(ctx).SessionSecret
+	token := ctx.Params("token")
+	now := time.Now()
+
+	invitation, err := accounts.FindInvitation(db, secret, token, now)
+	if err != nil {
+		return renderInvitationError(ctx, err)
+	}
+	if ctx.FormValue("password") != ctx.FormValue("confirm_password") {
+		return renderInvitation(ctx, invitation.Email, "Passwords do not match")
+	}
+
+	user, err := accounts.AcceptInvitation(ctx.Logger, auditDBAs(ctx, nil), secret, token, ctx.FormValue("password"), now)
+	if err != nil {
+		if errors.Is(err, accounts.ErrWeakPassword) {
+			return renderInvitation(ctx, invitation.Email, "Password must be at least 8 characters long")
+		}
+		if errors.Is(err, accounts.ErrDuplicateEmail) {
+			return renderInvitation(ctx, invitation.Email, "An account with this email already exists. Sign in instead.")
+		}
+		return renderInvitationError(ctx, err)
+	}
+
+	if err := startSession(ctx, user, "invitation"); err != nil {
+		ctx.Logger.Error("failed to start session", slog.Any("error", err), slog.Uint64("userID", uint64(user.ID)))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin")
+}
+
+func renderInvitation(ctx *cartridge.Context, email, errMsg string) error {
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":             "Accept invitation",
+		"Email":             email,
+		"Error":             errMsg,
+		"HideHeaderActions": true,
+		"ContentView":       "admin/invitation/content",
+	}, "")
+}
+
+func renderInvitationError(ctx *cartridge.Context, err error) error {
+	var message string
+	switch {
+	case errors.Is(err, accounts.ErrInvitationExpired):
+		message = "This invitation has expired. Ask the person who invited you to send a new one."
+	case errors.Is(err, accounts.ErrInvitationAccepted):
+		message = "This invitation was already used. Sign in with the password you chose."
+	case errors.Is(err, accounts.ErrInvitationInvalid):
+		message = "This invitation link is not valid. It may have been revoked or replaced by a newer one."
+	default:
+		ctx.Logger.Error("invitation lookup failed", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Status(fiber.StatusNotFound).Render("layouts/base", fiber.Map{
+		"Title":             "Invitation",
+		"InvalidMessage":    message,
+		"HideHeaderActions": true,
+		"ContentView":       "admin/invitation/content",
+	}, "")
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// layoutData is what the admin layout shows on every page. It is bound to
+// the request once the user is known and loads when a page renders it, at
+// most once per request, so JSON and redirect responses don't pay for it.
+type layoutData struct {
+	db     *gorm.DB
+	userID uint
+
+	once   sync.Once
+	pinned []forms.SavedView
+}
+
+// bindLayoutData makes the layout data of user available to every template
+// rendered for this request as .Layout.
+func bindLayoutData(c *fiber.Ctx, db *gorm.DB, userID uint) {
+	data := &layoutData{db: db, userID: userID}
+	c.Locals("layout_data", data)
+	_ = c.Bind(fiber.Map{"Layout": data})
+}
+
+// currentLayoutData returns the layout data bound by RequireActiveUser.
+func currentLayoutData(c *fiber.Ctx) *layoutData {
+	data, _ := c.Locals("layout_data").(*layoutData)
+	return data
+}
+
+// pinnedViews returns the signed-in admin's pinned saved views. A failed load
+// leaves them out of the page rather than failing it.
+func (d *layoutData) pinnedViews() []forms.SavedView {
+	if d == nil {
+		return nil
+	}
+	d.once.Do(func() {
+		views, err := forms.PinnedViews(d.db, d.userID)
+		if err != nil {
+			slog.Default().Warn("failed to load pinned views", slog.Any("error", err))
+			return
+		}
+		d.pinned = views
+	})
+	return d.pinned
+}
+
+// PinnedViews returns the pinned saved views as nav links.
+func (d *layoutData) PinnedViews() []viewLink {
+	return viewLinks(d.pinnedViews(), "")
+}
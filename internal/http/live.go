@@ -0,0 +1,100 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/forms"
+	"formlander/internal/live"
+)
+
+const (
+	// liveStreamDuration bounds one event stream below the server's write
+	// timeout. Browsers reconnect on their own and resume after the last
+	// event ID they saw.
+	liveStreamDuration = 25 * time.Second
+	// liveHeartbeat keeps idle streams from being closed by proxies and
+	// notices clients that went away.
+	liveHeartbeat = 10 * time.Second
+	// liveRetry is how long browsers wait before reconnecting.
+	liveRetry = time.Second
+)
+
+// AdminLiveFeed streams new submissions and delivery status changes to a
+// signed-in admin as Server-Sent Events. Only events of forms the user may
+// see are sent; grants are read once per stream.
+func AdminLiveFeed(ctx *cartridge.Context) error {
+	visible, err := visibleForms(ctx)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	lastID, _ := strconv.ParseUint(ctx.Get("Last-Event-ID"), 10, 64)
+	events, cancel := live.Subscribe(lastID)
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		streamLiveEvents(w, events, visible, time.After(liveStreamDuration))
+	})
+	return nil
+}
+
+// visibleForms reports which forms' events the current user may receive.
+func visibleForms(ctx *cartridge.Context) (func(formID uint) bool, error) {
+	user := CurrentUser(ctx)
+	if user.SeesAllForms() {
+		return func(uint) bool { return true }, nil
+	}
+	ids, err := forms.GrantedFormIDs(ctx.DB(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	granted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		granted[id] = true
+	}
+	return func(formID uint) bool { return granted[formID] }, nil
+}
+
+// streamLiveEvents writes the visible events until the deadline or until the
+// client is gone.
+func streamLiveEvents(w *bufio.Writer, events <-chan live.Event, visible func(formID uint) bool, deadline <-chan time.Time) {
+	fmt.Fprintf(w, "retry: %d\n: connected\n\n", liveRetry.Milliseconds())
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e := <-events:
+			if !visible(e.FormID) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-deadline:
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -1,13 +1,16 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
 
+	"formlander/internal/accounts"
 	"formlander/internal/forms"
 	"formlander/internal/integrations"
 )
@@ -24,6 +27,12 @@ func parseSMTPPort(s string) int {
 
 // MailerProfileList shows all mailer profiles.
 func MailerProfileList(ctx *cartridge.Context) error {
+	return renderMailerProfiles(ctx, nil, "")
+}
+
+// renderMailerProfiles shows the profile list with the system email and
+// all-forms digest settings. A rejected digest change is shown as submitted.
+func renderMailerProfiles(ctx *cartridge.Context, digest *forms.AllFormsDigest, digestErr string) error {
 	db := ctx.DB()
 
 	var profiles []integrations.MailerProfile
@@ -31,13 +40,73 @@ func MailerProfileList(ctx *cartridge.Context) error {
 		return fiber.ErrInternalServerError
 	}
 
+	var systemMailerID uint
+	if system, err := accounts.SystemMailerProfile(db); err == nil {
+		systemMailerID = system.ID
+	}
+
+	if digest == nil {
+		var err error
+		if digest, err = forms.GetAllFormsDigest(db); err != nil {
+			return fiber.ErrInternalServerError
+		}
+	}
+
 	return ctx.Render("layouts/base", fiber.Map{
-		"Title":       "Mailer Profiles",
-		"Profiles":    profiles,
-		"ContentView": "admin/mailers/index",
+		"Title":          "Mailer Profiles",
+		"Profiles":       profiles,
+		"SystemMailerID": systemMailerID,
+		"Digest":         digest,
+		"DigestError":    digestErr,
+		"ContentView":    "admin/mailers/index",
 	}, "")
 }
 
+// AllFormsDigestUpdate saves the schedule and recipient of the digest that
+// summarizes every form.
+func AllFormsDigestUpdate(ctx *cartridge.Context) error {
+	params := forms.AllFormsDigestParams{
+		Recipient:      ctx.FormValue("recipient"),
+		DigestMode:     ctx.FormValue("digest_mode"),
+		DigestHour:     formIntValue(ctx, "digest_hour", 9),
+		DigestWeekday:  formIntValue(ctx, "digest_weekday", 1),
+		DigestTimezone: ctx.FormValue("digest_timezone"),
+	}
+	if id, err := strconv.ParseUint(ctx.FormValue("mailer_profile_id"), 10, 32); err == nil && id > 0 {
+		profileID := uint(id)
+		params.MailerProfileID = &profileID
+	}
+
+	if _, err := forms.UpdateAllFormsDigest(ctx.Logger, auditDB(ctx), params); err != nil {
+		if valErr, ok := err.(*forms.ValidationError); ok {
+			return renderMailerProfiles(ctx, &forms.AllFormsDigest{
+				MailerProfileID: params.MailerProfileID,
+				Recipient:       params.Recipient,
+				DigestMode:      params.DigestMode,
+				DigestHour:      params.DigestHour,
+				DigestWeekday:   params.DigestWeekday,
+				DigestTimezone:  params.DigestTimezone,
+			}, valErr.Message)
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin/settings/mailers")
+}
+
+// SystemMailerUpdate chooses the mailer profile that sends account emails.
+func SystemMailerUpdate(ctx *cartridge.Context) error {
+	// An empty choice turns system emails off.
+	profileID, _ := strconv.ParseUint(ctx.FormValue("mailer_profile_id"), 10, 32)
+	if err := accounts.SetSystemMailerProfile(ctx.Logger, auditDB(ctx), uint(profileID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrBadRequest
+		}
+		ctx.Logger.Error("failed to set system mailer", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin/settings/mailers")
+}
+
 // MailerProfileNew shows the create form.
 func MailerProfileNew(ctx *cartridge.Context) error {
 	return ctx.Render("layouts/base", fiber.Map{
@@ -48,7 +117,7 @@ func MailerProfileNew(ctx *cartridge.Context) error {
 
 // MailerProfileCreate handles profile creation.
 func MailerProfileCreate(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 
 	logger := ctx.Logger
 	params := integrations.MailerProfileParams{
@@ -126,7 +195,7 @@ func MailerProfileEdit(ctx *cartridge.Context) error {
 
 // MailerProfileUpdate handles profile updates.
 func MailerProfileUpdate(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 
 	id := ctx.Params("id")
 	profileID, err := strconv.ParseUint(id, 10, 32)
@@ -174,7 +243,7 @@ func MailerProfileUpdate(ctx *cartridge.Context) error {
 
 // MailerProfileDelete removes a profile.
 func MailerProfileDelete(ctx *cartridge.Context) error {
-	db := ctx.DB()
+	db := auditDB(ctx)
 
 	id := ctx.Params("id")
 	profileID, err := strconv.ParseUint(id, 10, 32)
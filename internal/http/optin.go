@@ -0,0 +1,86 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// ConfirmSubmissionPage shows the landing page of an emailed double opt-in
+// link. Confirming takes a button press: mail scanners that prefetch links
+// must not confirm on the submitter's behalf.
+func ConfirmSubmissionPage(ctx *cartridge.Context) error {
+	submission, err := confirmationSubmission(ctx)
+	if err != nil {
+		return confirmationFailed(ctx, err)
+	}
+	form := submission.Form
+	if submission.ConfirmationStatus == forms.ConfirmationConfirmed {
+		return renderResult(ctx, fiber.StatusOK, form, forms.DefaultConfirmedTitle, forms.DefaultConfirmedMessage, "", false)
+	}
+	if submission.ConfirmationStatus != forms.ConfirmationPending {
+		return confirmationFailed(ctx, forms.ErrConfirmationExpired)
+	}
+
+	ctx.Set(fiber.HeaderContentSecurityPolicy, hostedPagePolicy(form, ""))
+	return ctx.Render("hosted/confirm", fiber.Map{
+		"Title":       forms.DefaultConfirmTitle,
+		"Message":     "Confirm your submission to " + form.HostedPageTitle() + ".",
+		"Action":      ctx.OriginalURL(),
+		"LogoURL":     form.HostedLogoURL,
+		"AccentColor": template.CSS(form.HostedAccent()),
+		"CustomCSS":   template.CSS(form.HostedCSS),
+	}, "")
+}
+
+// ConfirmSubmission confirms a double opt-in submission and queues its
+// deliveries.
+func ConfirmSubmission(ctx *cartridge.Context) error {
+	submission, err := confirmationSubmission(ctx)
+	if err != nil {
+		return confirmationFailed(ctx, err)
+	}
+
+	confirmed, _, err := forms.ConfirmSubmission(ctx.Logger, ctx.DB(), submission.ID, time.Now().UTC())
+	if err != nil {
+		return confirmationFailed(ctx, err)
+	}
+	return renderResult(ctx, fiber.StatusOK, confirmed.Form, forms.DefaultConfirmedTitle, forms.DefaultConfirmedMessage, "", false)
+}
+
+// confirmationSubmission verifies the link token and loads its submission.
+func confirmationSubmission(ctx *cartridge.Context) (*forms.Submission, error) {
+	cfg := GetAppConfig(ctx)
+	id, err := forms.ParseConfirmationToken(cfg.SessionSecret, ctx.Params("token"), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var submission forms.Submission
+	if err := ctx.DB().Preload("Form").First(&submission, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, forms.ErrConfirmationInvalid
+		}
+		return nil, err
+	}
+	if submission.Form == nil {
+		return nil, forms.ErrConfirmationInvalid
+	}
+	return &submission, nil
+}
+
+func confirmationFailed(ctx *cartridge.Context, err error) error {
+	switch {
+	case errors.Is(err, forms.ErrConfirmationExpired):
+		return renderResult(ctx, fiber.StatusGone, &forms.Form{}, "Link expired", "This confirmation link has expired. Please submit the form again.", "", true)
+	case errors.Is(err, forms.ErrConfirmationInvalid):
+		return renderResult(ctx, fiber.StatusNotFound, &forms.Form{}, "Link not valid", "This confirmation link is not valid.", "", true)
+	}
+	ctx.Logger.Error("confirm submission", "error", err)
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,228 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/accounts"
+	"formlander/internal/auth"
+)
+
+// passkeyChallengeCookie holds the signed challenge of a passkey ceremony
+// between its options and response requests.
+const passkeyChallengeCookie = "formlander_passkey"
+
+// AdminPasskeyOptions starts registering a passkey for the signed-in user.
+func AdminPasskeyOptions(ctx *cartridge.Context) error {
+	user := CurrentUser(ctx)
+	challenge, err := auth.NewChallenge()
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	existing, err := accounts.PasskeyCredentialIDs(ctx.DB(), user.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	setPasskeyChallenge(ctx, "/admin/settings/passkeys", auth.PurposeRegister, user.ID, challenge)
+	entity := auth.UserEntity{ID: passkeyUserHandle(user.ID), Name: user.Email, DisplayName: user.Email}
+	return ctx.JSON(fiber.Map{"publicKey": relyingParty(ctx).CreationOptions(challenge, entity, existing)})
+}
+
+// AdminPasskeyCreate stores the passkey the browser created.
+func AdminPasskeyCreate(ctx *cartridge.Context) error {
+	user := CurrentUser(ctx)
+	userID, challenge, err := redeemPasskeyChallenge(ctx, "/admin/settings/passkeys", auth.PurposeRegister)
+	if err != nil || userID != user.ID {
+		return jsonError(ctx, fiber.StatusBadRequest, "Passkey setup expired. Please try again.")
+	}
+
+	var body struct {
+		Name       string                    `json:"name"`
+		Credential auth.RegistrationResponse `json:"credential"`
+	}
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return jsonError(ctx, fiber.StatusBadRequest, "Passkey response is not valid")
+	}
+	credential, err := relyingParty(ctx).VerifyRegistration(challenge, body.Credential)
+	if err != nil {
+		ctx.Logger.Warn("passkey registration rejected", slog.Any("error", err), slog.Uint64("userID", uint64(user.ID)))
+		return jsonError(ctx, fiber.StatusBadRequest, "Passkey could not be verified")
+	}
+
+	if _, err := accounts.AddPasskey(ctx.Logger, auditDB(ctx), user.ID, body.Name, credential.ID, credential.PublicKey, credential.SignCount); err != nil {
+		if errors.Is(err, accounts.ErrPasskeyExists) {
+			return jsonError(ctx, fiber.StatusConflict, "This passkey is already registered")
+		}
+		ctx.Logger.Error("failed to store passkey", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(fiber.Map{"ok": true})
+}
+
+// AdminPasskeyRename changes the label of one of the user's passkeys.
+func AdminPasskeyRename(ctx *cartridge.Context) error {
+	id, err := paramID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := accounts.RenamePasskey(ctx.Logger, ctx.DB(), CurrentUser(ctx).ID, id, ctx.FormValue("name")); err != nil {
+		return passkeyError(ctx, err)
+	}
+	return renderSettingsSuccess(ctx, "Passkey renamed")
+}
+
+// AdminPasskeyRevoke removes one of the user's passkeys.
+func AdminPasskeyRevoke(ctx *cartridge.Context) error {
+	id, err := paramID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := accounts.RevokePasskey(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, id); err != nil {
+		return passkeyError(ctx, err)
+	}
+	return renderSettingsSuccess(ctx, "Passkey removed")
+}
+
+// AdminPasskeyLoginOptions starts a passkey sign-in. The user isn't known
+// until the browser answers with one of their credentials.
+func AdminPasskeyLoginOptions(ctx *cartridge.Context) error {
+	challenge, err := auth.NewChallenge()
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	setPasskeyChallenge(ctx, "/admin/login", auth.PurposeLogin, 0, challenge)
+	return ctx.JSON(fiber.Map{"publicKey": relyingParty(ctx).RequestOptions(challenge)})
+}
+
+// AdminPasskeyLogin signs in with a passkey. Passkeys verify the user on
+// the device, so they stand in for both the password and the second factor.
+func AdminPasskeyLogin(ctx *cartridge.Context) error {
+	_, challenge, err := redeemPasskeyChallenge(ctx, "/admin/login", auth.PurposeLogin)
+	if err != nil {
+		return jsonError(ctx, fiber.StatusBadRequest, "Passkey sign-in expired. Please try again.")
+	}
+	var resp auth.AssertionResponse
+	if err := json.Unmarshal(ctx.Body(), &resp); err != nil {
+		return jsonError(ctx, fiber.StatusBadRequest, "Passkey response is not valid")
+	}
+
+	db := ctx.DB()
+	passkey, err := accounts.FindPasskey(db, resp.RawID)
+	if err != nil {
+		if errors.Is(err, accounts.ErrPasskeyNotFound) {
+			recordLoginFailure(ctx, "passkey", "unknown_passkey", nil)
+			return jsonError(ctx, fiber.StatusUnauthorized, "This passkey is not registered here")
+		}
+		return fiber.ErrInternalServerError
+	}
+	if len(resp.Response.UserHandle) > 0 && !bytes.Equal(resp.Response.UserHandle, passkeyUserHandle(passkey.UserID)) {
+		recordLoginFailure(ctx, "passkey", "user_mismatch", map[string]any{"user_id": passkey.UserID})
+		return jsonError(ctx, fiber.StatusUnauthorized, "Passkey could not be verified")
+	}
+	signCount, err := relyingParty(ctx).VerifyAssertion(challenge, passkey.PublicKey, resp)
+	if err != nil {
+		ctx.Logger.Warn("passkey sign-in rejected", slog.Any("error", err), slog.Uint64("passkeyID", uint64(passkey.ID)))
+		recordLoginFailure(ctx, "passkey", "invalid_signature", map[string]any{"user_id": passkey.UserID})
+		return jsonError(ctx, fiber.StatusUnauthorized, "Passkey could not be verified")
+	}
+
+	user, err := accounts.RecordPasskeyLogin(ctx.Logger, db, passkey.ID, signCount, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, accounts.ErrUserDeactivated):
+			recordLoginFailure(ctx, "passkey", "deactivated", map[string]any{"user_id": passkey.UserID})
+			return jsonError(ctx, fiber.StatusForbidden, "This account has been deactivated")
+		case errors.Is(err, accounts.ErrPasskeyCloned):
+			ctx.Logger.Warn("passkey counter went backwards", slog.Uint64("passkeyID", uint64(passkey.ID)))
+			recordLoginFailure(ctx, "passkey", "cloned_passkey", map[string]any{"user_id": passkey.UserID})
+			return jsonError(ctx, fiber.StatusUnauthorized, "Passkey could not be verified")
+		}
+		ctx.Logger.Error("failed to record passkey sign-in", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+
+	if err := startSession(ctx, user, "passkey"); err != nil {
+		ctx.Logger.Error("failed to start session", slog.Any("error", err), slog.Uint64("userID", uint64(user.ID)))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(fiber.Map{"ok": true, "redirect": "/admin"})
+}
+
+// relyingParty identifies this instance to authenticators.
+func relyingParty(ctx *cartridge.Context) auth.RelyingParty {
+	parsed, err := url.Parse(instanceOrigin(ctx))
+	if err != nil {
+		return auth.RelyingParty{Name: "Formlander"}
+	}
+	return auth.RelyingParty{
+		ID:     parsed.Hostname(),
+		Name:   "Formlander",
+		Origin: parsed.Scheme + "://" + parsed.Host,
+	}
+}
+
+// instanceOrigin is where this instance is reached: the configured base URL
+// when set, otherwise the host the request came to.
+func instanceOrigin(ctx *cartridge.Context) string {
+	origin := strings.TrimRight(strings.TrimSpace(GetAppConfig(ctx).BaseURL), "/")
+	if origin == "" {
+		origin = ctx.Protocol() + "://" + ctx.Hostname()
+	}
+	return origin
+}
+
+// passkeyUserHandle is the WebAuthn user handle of a user: their ID, which
+// says nothing about them outside this instance.
+func passkeyUserHandle(userID uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userID), 10))
+}
+
+func setPasskeyChallenge(ctx *cartridge.Context, path, purpose string, userID uint, challenge []byte) {
+	expires := time.Now().Add(auth.ChallengeTTL)
+	ctx.Cookie(&fiber.Cookie{
+		Name:     passkeyChallengeCookie,
+		Value:    auth.SealChallenge(GetAppConfig(ctx).SessionSecret, purpose, userID, challenge, expires),
+		Path:     path,
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   GetAppConfig(ctx).IsProduction(),
+		SameSite: "Strict",
+	})
+}
+
+func clearPasskeyChallenge(ctx *cartridge.Context, path string) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     passkeyChallengeCookie,
+		Path:     path,
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: "Strict",
+	})
+}
+
+// redeemPasskeyChallenge takes the ceremony's challenge out of its cookie and
+// uses it up before the response is verified, so each challenge is answered
+// at most once whatever the outcome.
+func redeemPasskeyChallenge(ctx *cartridge.Context, path, purpose string) (uint, []byte, error) {
+	token := ctx.Cookies(passkeyChallengeCookie)
+	clearPasskeyChallenge(ctx, path)
+	return auth.RedeemChallenge(GetAppConfig(ctx).SessionSecret, purpose, token, time.Now())
+}
+
+func passkeyError(ctx *cartridge.Context, err error) error {
+	if errors.Is(err, accounts.ErrPasskeyNotFound) {
+		return fiber.ErrNotFound
+	}
+	ctx.Logger.Error("passkey update failed", slog.Any("error", err))
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/accounts"
+	"formlander/internal/pkg/ratelimit"
+)
+
+// passwordResetLimiter caps reset emails per address, on top of the per-IP
+// login limit, so nobody's inbox can be flooded from many addresses.
+var passwordResetLimiter = ratelimit.NewLimiter()
+
+const (
+	passwordResetRequests = 3
+	passwordResetWindow   = time.Hour
+)
+
+// forgotPasswordSent is shown whether or not the address has an account, so
+// the form doesn't tell who signs in here.
+const forgotPasswordSent = "If an account exists for that email, a link to reset its password is on its way. The link expires in an hour."
+
+// passwordResetsAvailable reports whether reset links can be emailed: a
+// system mailer is chosen and the instance knows its public URL.
+func passwordResetsAvailable(ctx *cartridge.Context) bool {
+	if strings.TrimSpace(GetAppConfig(ctx).BaseURL) == "" {
+		return false
+	}
+	_, err := accounts.SystemMailerProfile(ctx.DB())
+	return err == nil
+}
+
+// ForgotPasswordPage asks for the email of the account to reset.
+func ForgotPasswordPage(ctx *cartridge.Context) error {
+	return renderForgotPassword(ctx, "")
+}
+
+// ForgotPasswordSubmit emails a reset link to the account with the given
+// email, if there is one.
+func ForgotPasswordSubmit(ctx *cartridge.Context) error {
+	if !passwordResetsAvailable(ctx) {
+		return renderForgotPassword(ctx, "")
+	}
+	email := strings.ToLower(strings.TrimSpace(ctx.FormValue("email")))
+	if email == "" {
+		return renderForgotPassword(ctx, "")
+	}
+	audit := ctx.Logger.With(slog.String("email", email), slog.String("ip", ctx.IP()))
+
+	if !passwordResetLimiter.Allow(email, passwordResetRequests, passwordResetWindow) {
+		audit.Warn("password_reset.rate_limited")
+		return renderForgotPassword(ctx, forgotPasswordSent)
+	}
+
+	reset, _, err := accounts.RequestPasswordReset(ctx.Logger, auditDB(ctx), GetAppConfig(ctx).SessionSecret, email, ctx.IP(), time.Now())
+	switch {
+	case err == nil:
+	case errors.Is(err, accounts.ErrUserNotFound), errors.Is(err, accounts.ErrUserDeactivated):
+		audit.Info("password_reset.requested", slog.String("outcome", "no_active_account"))
+		return renderForgotPassword(ctx, forgotPasswordSent)
+	default:
+		ctx.Logger.Error("password reset request failed", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	// The job runner emails the link, so the request takes as long whether
+	// or not the account exists.
+	audit.Info("password_reset.requested",
+		slog.String("outcome", "queued"),
+		slog.Uint64("user_id", uint64(reset.UserID)),
+		slog.Uint64("reset_id", uint64(reset.ID)))
+	return renderForgotPassword(ctx, forgotPasswordSent)
+}
+
+// PasswordResetPage shows the new password form of a reset link.
+func PasswordResetPage(ctx *cartridge.Context) error {
+	reset, err := accounts.FindPasswordReset(ctx.DB(), ctx.Params("token"), time.Now())
+	if err != nil {
+		return renderPasswordResetError(ctx, err)
+	}
+	return renderPasswordReset(ctx, reset.User.Email, "")
+}
+
+// PasswordResetSubmit sets the new password and sends the user to sign in
+// with it. Second factors still apply, so the link alone doesn't sign in.
+func PasswordResetSubmit(ctx *cartridge.Context) error {
+	db := ctx.DB()
+	token := ctx.Params("token")
+	now := time.Now()
+
+	reset, err := accounts.FindPasswordReset(db, token, now)
+	if err != nil {
+		return renderPasswordResetError(ctx, err)
+	}
+	if ctx.FormValue("password") != ctx.FormValue("confirm_password") {
+		return renderPasswordReset(ctx, reset.User.Email, "Passwords do not match")
+	}
+
+	user, err := accounts.ResetPassword(ctx.Logger, auditDBAs(ctx, reset.User), token, ctx.FormValue("password"), now)
+	if err != nil {
+		if errors.Is(err, accounts.ErrWeakPassword) {
+			return renderPasswordReset(ctx, reset.User.Email, "Password must be at least 8 characters long")
+		}
+		return renderPasswordResetError(ctx, err)
+	}
+	ctx.Logger.Info("password_reset.completed",
+		slog.Uint64("user_id", uint64(user.ID)),
+		slog.Uint64("reset_id", uint64(reset.ID)),
+		slog.String("email", user.Email),
+		slog.String("ip", ctx.IP()))
+	return renderLogin(ctx, fiber.Map{"Notice": "Your password has been reset. Sign in with your new password."})
+}
+
+func renderForgotPassword(ctx *cartridge.Context, notice string) error {
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":             "Forgot password",
+		"Notice":            notice,
+		"Available":         passwordResetsAvailable(ctx),
+		"HideHeaderActions": true,
+		"ContentView":       "admin/password/forgot",
+	}, "")
+}
+
+func renderPasswordReset(ctx *cartridge.Context, email, errMsg string) error {
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":             "Reset password",
+		"Email":             email,
+		"Error":             errMsg,
+		"HideHeaderActions": true,
+		"ContentView":       "admin/password/reset",
+	}, "")
+}
+
+func renderPasswordResetError(ctx *cartridge.Context, err error) error {
+	if !errors.Is(err, accounts.ErrResetTokenInvalid) {
+		ctx.Logger.Error("password reset lookup failed", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Status(fiber.StatusNotFound).Render("layouts/base", fiber.Map{
+		"Title":             "Reset password",
+		"InvalidMessage":    "This reset link is not valid. It may have expired, been used, or been replaced by a newer one.",
+		"HideHeaderActions": true,
+		"ContentView":       "admin/password/reset",
+	}, "")
+}
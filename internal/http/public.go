@@ -3,7 +3,9 @@ package http
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +18,11 @@ import (
 	"formlander/internal/middleware"
 )
 
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
 // PublicFormSubmission accepts a submission for the given form slug.
 func PublicFormSubmission(ctx *cartridge.Context) error {
 	db := ctx.DB()
@@ -24,24 +31,42 @@ func PublicFormSubmission(ctx *cartridge.Context) error {
 
 	slug := ctx.Params("slug")
 	if slug == "" {
-		return jsonError(ctx, fiber.StatusNotFound, "form not found")
+		return submissionFailed(ctx, nil, fiber.StatusNotFound, "form not found")
 	}
 
 	form, err := forms.GetBySlug(db, slug)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return jsonError(ctx, fiber.StatusNotFound, "form not found")
+			return submissionFailed(ctx, nil, fiber.StatusNotFound, "form not found")
 		}
-		return jsonError(ctx, fiber.StatusInternalServerError, "form lookup failed")
+		return submissionFailed(ctx, nil, fiber.StatusInternalServerError, "form lookup failed")
 	}
 
 	if token := ctx.Query("token"); token == "" || token != form.Token {
-		return jsonError(ctx, fiber.StatusUnauthorized, "invalid token")
+		return submissionFailed(ctx, form, fiber.StatusUnauthorized, "invalid token")
 	}
 
-	// Check allowed origins (domain allowlisting)
-	if !form.IsOriginAllowed(getRequestOrigin(ctx)) {
-		return jsonError(ctx, fiber.StatusForbidden, "origin not allowed")
+	// Check allowed origins (domain allowlisting). The form's own hosted
+	// page is always allowed.
+	origin := getRequestOrigin(ctx)
+	if !form.IsOriginAllowed(origin) && !isHostedPageOrigin(ctx, form, origin) {
+		return submissionFailed(ctx, form, fiber.StatusForbidden, "origin not allowed")
+	}
+
+	// Idempotency-Key lets clients retry a post (or replay it from an
+	// offline queue) without creating duplicates.
+	idempotencyKey := strings.TrimSpace(ctx.Get(idempotencyKeyHeader))
+	if idempotencyKey != "" && !forms.ValidIdempotencyKey(idempotencyKey) {
+		return submissionFailed(ctx, form, fiber.StatusBadRequest, "invalid idempotency key")
+	}
+
+	// Refuse early when the form is scheduled shut or full, before reading
+	// uploads. Keyed posts skip this so a retry of an accepted submission
+	// still gets its original response; the store enforces the rules again.
+	if idempotencyKey == "" {
+		if err := forms.CheckAvailability(db, form, time.Now().UTC()); forms.IsClosedError(err) {
+			return submissionClosed(ctx, form, err)
+		}
 	}
 
 	payload, err := extractSubmissionPayload(ctx, cfg)
@@ -52,7 +77,7 @@ func PublicFormSubmission(ctx *cartridge.Context) error {
 				return ctx.Redirect(errorURL)
 			}
 		}
-		return jsonError(ctx, fiber.StatusBadRequest, err.Error())
+		return submissionFailed(ctx, form, fiber.StatusBadRequest, err.Error())
 	}
 
 	// Extract custom redirect URLs before saving (don't store them)
@@ -62,12 +87,12 @@ func PublicFormSubmission(ctx *cartridge.Context) error {
 	// Validate redirect URLs
 	if successURL != "" {
 		if err := form.ValidateRedirectURL(successURL); err != nil {
-			return jsonError(ctx, fiber.StatusBadRequest, "invalid success redirect URL")
+			return submissionFailed(ctx, form, fiber.StatusBadRequest, "invalid success redirect URL")
 		}
 	}
 	if errorURL != "" {
 		if err := form.ValidateRedirectURL(errorURL); err != nil {
-			return jsonError(ctx, fiber.StatusBadRequest, "invalid error redirect URL")
+			return submissionFailed(ctx, form, fiber.StatusBadRequest, "invalid error redirect URL")
 		}
 	}
 
@@ -75,7 +100,7 @@ func PublicFormSubmission(ctx *cartridge.Context) error {
 		if errorURL != "" {
 			return ctx.Redirect(errorURL)
 		}
-		return jsonError(ctx, fiber.StatusBadRequest, err.Error())
+		return submissionFailed(ctx, form, fiber.StatusBadRequest, err.Error())
 	}
 
 	// Remove special fields from payload
@@ -90,7 +115,7 @@ func PublicFormSubmission(ctx *cartridge.Context) error {
 			if errorURL != "" {
 				return ctx.Redirect(errorURL)
 			}
-			return jsonError(ctx, fiber.StatusBadRequest, err.Error())
+			return submissionFailed(ctx, form, fiber.StatusBadRequest, err.Error())
 		}
 	}
 
@@ -98,30 +123,39 @@ func PublicFormSubmission(ctx *cartridge.Context) error {
 	userAgent := ctx.Get(fiber.HeaderUserAgent)
 	dataDir := cfg.DataDirectory
 
-	submission, err := forms.CreateSubmissionWithFiles(logger, db, form, payload, userAgent, dataDir, uploadedFiles)
+	submission, replayed, err := forms.CreateIdempotentSubmission(logger, db, form, idempotencyKey, payload, userAgent, origin, dataDir, uploadedFiles)
 	if err != nil {
 		forms.CloseFiles(uploadedFiles) // Clean up on error
+		if forms.IsClosedError(err) {
+			return submissionClosed(ctx, form, err)
+		}
 		if errorURL != "" {
 			return ctx.Redirect(errorURL)
 		}
-		return jsonError(ctx, fiber.StatusInternalServerError, err.Error())
+		if errors.Is(err, forms.ErrDuplicateSubmission) {
+			return submissionFailed(ctx, form, fiber.StatusConflict, fmt.Sprintf("a submission with this %s already exists", form.UniqueField))
+		}
+		if errors.Is(err, forms.ErrOptInAddressMissing) {
+			return submissionFailed(ctx, form, fiber.StatusBadRequest, fmt.Sprintf("%s: %s", err.Error(), form.OptInEmailField))
+		}
+		return submissionFailed(ctx, form, fiber.StatusInternalServerError, err.Error())
 	}
 
-	// Check for custom success redirect
+	if replayed {
+		ctx.Set(idempotentReplayedHeader, "true")
+	}
+
+	// Check for custom success redirect; otherwise browsers get the form's
+	// thank-you page and fetch/XHR clients get JSON.
 	if successURL != "" {
 		return ctx.Redirect(successURL)
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"ok":            true,
-		"submission_id": submission.ID,
-		"received_at":   submission.CreatedAt.UTC().Format(time.RFC3339),
-	})
+	return submissionSucceeded(ctx, form, submission)
 }
 
 func extractSubmissionPayload(ctx *cartridge.Context, cfg *config.Config) (map[string]any, error) {
-	result := make(map[string]any)
-	fieldCount := 0
+	var result map[string]any
 
 	contentType := ctx.Get(fiber.HeaderContentType)
 	if strings.Contains(contentType, fiber.MIMEApplicationJSON) {
@@ -129,43 +163,38 @@ func extractSubmissionPayload(ctx *cartridge.Context, cfg *config.Config) (map[s
 			return nil, err
 		}
 	} else {
+		// Field names may use bracket/dot notation (address[street],
+		// items[0][qty], tags[]); they're decoded into nested values.
+		decoder := forms.NewFieldDecoder(cfg.MaxInputFields)
 		if form, err := ctx.MultipartForm(); err == nil && form != nil {
-			for key, values := range form.Value {
-				fieldCount += len(values)
-				if fieldCount > cfg.MaxInputFields {
-					return nil, errors.New("too many fields")
+			// Multipart values arrive as a map; sort names so "[]" appends
+			// land in a stable order.
+			keys := make([]string, 0, len(form.Value))
+			for key := range form.Value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if err := decoder.Add(key, form.Value[key]...); err != nil {
+					return nil, err
 				}
-				assignFormField(result, key, values)
 			}
 		} else {
 			args := ctx.Request().PostArgs()
 			if args == nil {
 				return nil, errors.New("submission payload empty")
 			}
+			var decodeErr error
 			args.VisitAll(func(key, value []byte) {
-				k := string(key)
-				v := string(value)
-				fieldCount++
-				if fieldCount > cfg.MaxInputFields {
-					result["__limit"] = true
-					return
-				}
-				if existing, ok := result[k]; ok {
-					switch current := existing.(type) {
-					case []string:
-						result[k] = append(current, v)
-					case string:
-						result[k] = []string{current, v}
-					}
-				} else {
-					result[k] = v
+				if decodeErr == nil {
+					decodeErr = decoder.Add(string(key), string(value))
 				}
 			})
-			if result["__limit"] == true {
-				return nil, errors.New("too many fields")
+			if decodeErr != nil {
+				return nil, decodeErr
 			}
-			delete(result, "__limit")
 		}
+		result = decoder.Result()
 	}
 
 	if len(result) == 0 {
@@ -175,18 +204,6 @@ func extractSubmissionPayload(ctx *cartridge.Context, cfg *config.Config) (map[s
 	return result, nil
 }
 
-func assignFormField(dst map[string]any, key string, values []string) {
-	if len(values) == 1 {
-		dst[key] = values[0]
-		return
-	}
-	array := make([]string, 0, len(values))
-	for _, v := range values {
-		array = append(array, v)
-	}
-	dst[key] = array
-}
-
 func extractRedirectURL(payload map[string]any, key string) string {
 	if payload == nil {
 		return ""
@@ -240,7 +257,7 @@ func enforceCaptchaIfNeeded(ctx *cartridge.Context, form *forms.Form, payload ma
 	// Validate that the captcha was solved on an allowed origin
 	// This prevents token reuse from other sites
 	if result.Hostname != "" && strings.TrimSpace(form.AllowedOrigins) != "" && form.AllowedOrigins != "*" {
-		if !form.IsOriginAllowed(result.Hostname) {
+		if !form.IsOriginAllowed(result.Hostname) && !isHostedPageOrigin(ctx, form, result.Hostname) {
 			if logger != nil {
 				logger.Warn("captcha hostname mismatch",
 					slog.Uint64("form_id", uint64(form.ID)),
@@ -291,6 +308,44 @@ func coerceToString(value any) string {
 	return ""
 }
 
+// PublicFormView counts one impression of a form. formlander.js and hosted
+// pages send it when a form is shown. Nothing about the visitor is kept and
+// no cookie is set; browsers sending Do-Not-Track or Global Privacy Control
+// are not counted.
+func PublicFormView(ctx *cartridge.Context) error {
+	if doNotTrack(ctx) {
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+
+	db := ctx.DB()
+	form, err := forms.GetBySlug(db, ctx.Params("slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jsonError(ctx, fiber.StatusNotFound, "form not found")
+		}
+		return jsonError(ctx, fiber.StatusInternalServerError, "form lookup failed")
+	}
+	if token := ctx.Query("token"); token == "" || token != form.Token {
+		return jsonError(ctx, fiber.StatusUnauthorized, "invalid token")
+	}
+
+	origin := getRequestOrigin(ctx)
+	if !form.IsOriginAllowed(origin) && !isHostedPageOrigin(ctx, form, origin) {
+		return jsonError(ctx, fiber.StatusForbidden, "origin not allowed")
+	}
+
+	if err := forms.RecordView(ctx.Logger, db, form.ID, origin, time.Now()); err != nil {
+		ctx.Logger.Error("failed to record form view", slog.Uint64("form_id", uint64(form.ID)), slog.Any("error", err))
+		return jsonError(ctx, fiber.StatusInternalServerError, "view not recorded")
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// doNotTrack reports whether the browser asked not to be tracked.
+func doNotTrack(ctx *cartridge.Context) bool {
+	return ctx.Get("DNT") == "1" || ctx.Get("Sec-GPC") == "1"
+}
+
 func jsonError(ctx *cartridge.Context, status int, message string) error {
 	return ctx.Status(status).JSON(fiber.Map{
 		"ok":    false,
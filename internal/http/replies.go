@@ -0,0 +1,42 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// AdminSubmissionReply queues an email reply to the submitter. The reply
+// dispatcher sends it on its next run.
+func AdminSubmissionReply(ctx *cartridge.Context) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+
+	profileID, _ := strconv.ParseUint(ctx.FormValue("mailer_profile_id"), 10, 32)
+	params := forms.ReplyParams{
+		MailerProfileID: uint(profileID),
+		Subject:         ctx.FormValue("subject"),
+		Body:            ctx.FormValue("body"),
+	}
+	if _, err := forms.QueueReply(ctx.Logger, ctx.DB(), uint(id), CurrentUser(ctx), params); err != nil {
+		if valErr, ok := err.(*forms.ValidationError); ok {
+			return renderSubmission(ctx, uint(id), valErr.Message, params)
+		}
+		if errors.Is(err, forms.ErrReplyAddressMissing) {
+			return renderSubmission(ctx, uint(id), "This submission has no email address to reply to", params)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect(fmt.Sprintf("/admin/submissions/%d#replies", id))
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// AdminFormRules lists a form's routing rules and tests them against a stored
+// submission picked with ?submission_id=.
+func AdminFormRules(ctx *cartridge.Context) error {
+	form, err := routingForm(ctx)
+	if err != nil {
+		return err
+	}
+	return renderRules(ctx, form, "", forms.RuleParams{})
+}
+
+// AdminFormRuleCreate adds a routing rule to a form.
+func AdminFormRuleCreate(ctx *cartridge.Context) error {
+	form, err := routingForm(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := forms.RuleParams{
+		Name:     ctx.FormValue("name"),
+		Field:    ctx.FormValue("field"),
+		Operator: ctx.FormValue("operator"),
+		Value:    ctx.FormValue("value"),
+		Action:   ctx.FormValue("action"),
+		Target:   ctx.FormValue("target"),
+		Stop:     ctx.FormValue("stop") == "on",
+	}
+	if _, err := forms.CreateRule(ctx.Logger, auditDB(ctx), form.ID, params); err != nil {
+		if valErr, ok := err.(*forms.ValidationError); ok {
+			return renderRules(ctx, form, valErr.Message, params)
+		}
+		return fiber.ErrInternalServerError
+	}
+
+	return ctx.Redirect(fmt.Sprintf("/admin/forms/%d/rules", form.ID))
+}
+
+// AdminFormRuleDelete removes a routing rule.
+func AdminFormRuleDelete(ctx *cartridge.Context) error {
+	form, err := routingForm(ctx)
+	if err != nil {
+		return err
+	}
+	ruleID, err := strconv.ParseUint(ctx.Params("rule_id"), 10, 32)
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+
+	if err := forms.DeleteRule(ctx.Logger, auditDB(ctx), form.ID, uint(ruleID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+
+	return ctx.Redirect(fmt.Sprintf("/admin/forms/%d/rules", form.ID))
+}
+
+func routingForm(ctx *cartridge.Context) (*forms.Form, error) {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return nil, fiber.ErrNotFound
+	}
+	form, err := forms.GetByID(ctx.DB(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.ErrNotFound
+		}
+		return nil, fiber.ErrInternalServerError
+	}
+	return form, nil
+}
+
+func renderRules(ctx *cartridge.Context, form *forms.Form, errMsg string, draft forms.RuleParams) error {
+	db := ctx.DB()
+
+	rules, err := forms.ListRules(db, form.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	submissions, err := forms.GetSubmissions(db, form.ID, 25)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	// Rule tester: replay the rules against a stored submission without
+	// queueing anything.
+	var tested *forms.Submission
+	var route *forms.Route
+	if raw := ctx.Query("submission_id"); raw != "" {
+		var sub forms.Submission
+		if err := db.Where("id = ? AND form_id = ?", raw, form.ID).First(&sub).Error; err == nil {
+			r := forms.RouteSubmission(form, rules, forms.FlattenDataJSON(sub.DataJSON))
+			tested, route = &sub, &r
+		}
+	}
+
+	if draft.Operator == "" {
+		draft.Operator = forms.RuleOpEquals
+	}
+	if draft.Action == "" {
+		draft.Action = forms.RuleActionEmail
+	}
+
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":       "Routing Rules · " + form.Name,
+		"Form":        form,
+		"Rules":       rules,
+		"Submissions": submissions,
+		"Tested":      tested,
+		"Route":       route,
+		"Draft":       draft,
+		"Error":       errMsg,
+		"ContentView": "admin/forms/rules/content",
+	}, "")
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/accounts"
+)
+
+// sessionTokenCookie carries the token of the user's session record. The
+// signed session cookie says who the user is; this one says the sign-in
+// hasn't been revoked or timed out.
+const sessionTokenCookie = "formlander_sid"
+
+// startSession signs user in on this browser by method: it records the
+// session and sets both cookies.
+func startSession(ctx *cartridge.Context, user *accounts.User, method string) error {
+	cfg := GetAppConfig(ctx)
+	session, token, err := accounts.StartSession(ctx.Logger, auditDBAs(ctx, user), user.ID, method, ctx.IP(), ctx.Get(fiber.HeaderUserAgent), time.Now(), cfg.Sessions.MaxAge())
+	if err != nil {
+		return err
+	}
+	ctx.Cookie(&fiber.Cookie{
+		Name:     sessionTokenCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HTTPOnly: true,
+		Secure:   cfg.IsProduction(),
+		SameSite: "Lax",
+	})
+	ctx.Locals("current_session", session)
+	return GetSession(ctx).SetSession(ctx.Ctx, user.ID)
+}
+
+// endSession signs this browser out.
+func endSession(c *fiber.Ctx) {
+	GetSessionFromFiber(c).ClearSession(c)
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionTokenCookie,
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+}
+
+// CurrentSession returns the session record loaded by RequireActiveUser, or
+// started by this request.
+func CurrentSession(ctx *cartridge.Context) *accounts.Session {
+	session, _ := ctx.Locals("current_session").(*accounts.Session)
+	return session
+}
+
+// AdminSessionRevoke signs one of the user's sessions out. Revoking the
+// current one signs out here too.
+func AdminSessionRevoke(ctx *cartridge.Context) error {
+	id, err := paramID(ctx)
+	if err != nil {
+		return err
+	}
+	user := CurrentUser(ctx)
+	if err := accounts.RevokeSession(ctx.Logger, auditDB(ctx), user.ID, id, time.Now()); err != nil {
+		if errors.Is(err, accounts.ErrSessionNotFound) {
+			return renderSettingsError(ctx, "That session has already ended")
+		}
+		ctx.Logger.Error("failed to revoke session", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	if current := CurrentSession(ctx); current != nil && current.ID == id {
+		endSession(ctx.Ctx)
+		return ctx.Redirect("/admin/login")
+	}
+	return renderSettingsSuccess(ctx, "Session signed out")
+}
+
+// AdminSessionsRevokeOthers signs the user out everywhere but here.
+func AdminSessionsRevokeOthers(ctx *cartridge.Context) error {
+	current := CurrentSession(ctx)
+	if err := accounts.RevokeOtherSessions(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, current.ID, time.Now()); err != nil {
+		ctx.Logger.Error("failed to revoke sessions", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return renderSettingsSuccess(ctx, "Signed out of all other sessions")
+}
+
+// addSessionData adds the user's live sessions to the settings page.
+func addSessionData(ctx *cartridge.Context, user *accounts.User, data fiber.Map) {
+	data["Sessions"], _ = accounts.ListSessions(ctx.DB(), user.ID, time.Now(), GetAppConfig(ctx).Sessions.IdleTimeout())
+	data["CurrentSessionID"] = uint(0)
+	if current := CurrentSession(ctx); current != nil {
+		data["CurrentSessionID"] = current.ID
+	}
+}
@@ -3,9 +3,11 @@ package http
 import (
 	"errors"
 	"log/slog"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
 
 	"formlander/internal/accounts"
 	"formlander/pkg/extension"
@@ -32,6 +34,8 @@ func AdminSettingsPage(ctx *cartridge.Context) error {
 		"ContentView": "admin/settings/content",
 		"User":        user,
 	}
+	addSignInData(db, user, data)
+	addSessionData(ctx, user, data)
 
 	// Allow pro to extend settings data
 	proData := extension.GetSettingsData()
@@ -70,7 +74,7 @@ func AdminSettingsUpdatePassword(ctx *cartridge.Context) error {
 		return fiber.ErrInternalServerError
 	}
 
-	if err := accounts.ChangePassword(ctx.Logger, db, user.Email, currentPassword, newPassword); err != nil {
+	if err := accounts.ChangePassword(ctx.Logger, auditDB(ctx), user.Email, currentPassword, newPassword); err != nil {
 		if errors.Is(err, accounts.ErrWeakPassword) {
 			return renderSettingsError(ctx, "Password must be at least 8 characters long")
 		}
@@ -80,6 +84,11 @@ func AdminSettingsUpdatePassword(ctx *cartridge.Context) error {
 		ctx.Logger.Error("password change failed in settings", slog.Any("error", err))
 		return fiber.ErrInternalServerError
 	}
+	// The change signed the user out everywhere; stay signed in here.
+	if err := startSession(ctx, user, "password_change"); err != nil {
+		ctx.Logger.Error("failed to start session", slog.Any("error", err), slog.Uint64("userID", uint64(user.ID)))
+		return fiber.ErrInternalServerError
+	}
 
 	return renderSettingsSuccess(ctx, "Password updated successfully")
 }
@@ -105,7 +114,7 @@ func AdminSettingsUpdateEmail(ctx *cartridge.Context) error {
 		return fiber.ErrInternalServerError
 	}
 
-	if err := accounts.ChangeEmail(ctx.Logger, db, user.Email, newEmail, currentPassword); err != nil {
+	if err := accounts.ChangeEmail(ctx.Logger, auditDB(ctx), user.Email, newEmail, currentPassword); err != nil {
 		if errors.Is(err, accounts.ErrInvalidEmail) {
 			return renderSettingsError(ctx, "Please enter a valid email address")
 		}
@@ -118,6 +127,14 @@ func AdminSettingsUpdateEmail(ctx *cartridge.Context) error {
 		ctx.Logger.Error("email change failed in settings", slog.Any("error", err))
 		return fiber.ErrInternalServerError
 	}
+	if strings.EqualFold(strings.TrimSpace(newEmail), user.Email) {
+		return renderSettingsSuccess(ctx, "Email updated successfully")
+	}
+	user.Email = strings.ToLower(strings.TrimSpace(newEmail))
+	if err := startSession(ctx, user, "email_change"); err != nil {
+		ctx.Logger.Error("failed to start session", slog.Any("error", err), slog.Uint64("userID", uint64(user.ID)))
+		return fiber.ErrInternalServerError
+	}
 
 	return renderSettingsSuccess(ctx, "Email updated successfully")
 }
@@ -133,29 +150,37 @@ func AdminSettingsUpdateTurnstile(ctx *cartridge.Context) error {
 }
 
 func renderSettingsError(ctx *cartridge.Context, message string) error {
-	db := ctx.DB()
-	userID, _ := GetSession(ctx).GetUserID(ctx.Ctx)
-
-	user, _ := accounts.FindByID(db, userID)
-
-	return ctx.Render("layouts/base", fiber.Map{
-		"Title":       "Settings",
-		"Error":       message,
-		"ContentView": "admin/settings/content",
-		"User":        user,
-	}, "")
+	return renderSettings(ctx, fiber.Map{"Error": message})
 }
 
 func renderSettingsSuccess(ctx *cartridge.Context, message string) error {
+	return renderSettings(ctx, fiber.Map{"Success": message})
+}
+
+// renderSettings renders the settings page for the signed-in user with data
+// on top, reloading the user so changes just made show up.
+func renderSettings(ctx *cartridge.Context, data fiber.Map) error {
 	db := ctx.DB()
 	userID, _ := GetSession(ctx).GetUserID(ctx.Ctx)
 
 	user, _ := accounts.FindByID(db, userID)
 
-	return ctx.Render("layouts/base", fiber.Map{
-		"Title":       "Settings",
-		"Success":     message,
-		"ContentView": "admin/settings/content",
-		"User":        user,
-	}, "")
+	data["Title"] = "Settings"
+	data["ContentView"] = "admin/settings/content"
+	data["User"] = user
+	if user != nil {
+		addSignInData(db, user, data)
+		addSessionData(ctx, user, data)
+	}
+	return ctx.Render("layouts/base", data, "")
+}
+
+// addSignInData adds what the settings page shows about how user signs in:
+// their passkeys and, with two-factor authentication on, how many recovery
+// codes they have left.
+func addSignInData(db *gorm.DB, user *accounts.User, data fiber.Map) {
+	data["Passkeys"], _ = accounts.ListPasskeys(db, user.ID)
+	if user.TOTPEnabled() {
+		data["RecoveryCodesLeft"], _ = accounts.RemainingRecoveryCodes(db, user.ID)
+	}
 }
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/accounts"
+	"formlander/internal/auth"
+	"formlander/internal/config"
+)
+
+// ssoRequestCookie holds the signed state, nonce and PKCE verifier of a
+// single sign-on while the user is at the identity provider.
+const ssoRequestCookie = "formlander_sso"
+
+// ssoProviders keeps one provider per configuration, so discovery and the
+// signing keys are cached across sign-ins.
+var ssoProviders = struct {
+	sync.Mutex
+	byConfig map[config.OIDCConfig]*auth.Provider
+}{byConfig: map[config.OIDCConfig]*auth.Provider{}}
+
+func ssoProvider(cfg config.OIDCConfig) *auth.Provider {
+	ssoProviders.Lock()
+	defer ssoProviders.Unlock()
+	provider, ok := ssoProviders.byConfig[cfg]
+	if !ok {
+		provider = auth.NewProvider(cfg.Issuer, cfg.ClientID, cfg.ClientSecret)
+		ssoProviders.byConfig[cfg] = provider
+	}
+	return provider
+}
+
+// AdminSSOLogin sends the user to the identity provider to sign in.
+func AdminSSOLogin(ctx *cartridge.Context) error {
+	cfg := GetAppConfig(ctx).OIDC
+	if !cfg.Enabled() {
+		return fiber.ErrNotFound
+	}
+	req, err := auth.NewAuthRequest()
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	authURL, err := ssoProvider(cfg).AuthCodeURL(ctx.UserContext(), req, ssoRedirectURI(ctx))
+	if err != nil {
+		ctx.Logger.Error("single sign-on unavailable", slog.Any("error", err))
+		return renderLoginError(ctx, "Single sign-on is unavailable right now. Please try again later.")
+	}
+
+	expires := time.Now().Add(auth.AuthRequestTTL)
+	ctx.Cookie(&fiber.Cookie{
+		Name:     ssoRequestCookie,
+		Value:    auth.SealAuthRequest(GetAppConfig(ctx).SessionSecret, req, expires),
+		Path:     "/admin/login/sso",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   GetAppConfig(ctx).IsProduction(),
+		// Lax, not Strict: the provider's redirect back is a cross-site
+		// navigation, and Strict cookies are left off it.
+		SameSite: "Lax",
+	})
+	return ctx.Redirect(authURL)
+}
+
+// AdminSSOCallback finishes a single sign-on when the identity provider
+// sends the user back. The provider is trusted to have checked every factor
+// it requires, so the local second factor isn't asked for.
+func AdminSSOCallback(ctx *cartridge.Context) error {
+	cfg := GetAppConfig(ctx).OIDC
+	if !cfg.Enabled() {
+		return fiber.ErrNotFound
+	}
+	req, err := auth.OpenAuthRequest(GetAppConfig(ctx).SessionSecret, ctx.Cookies(ssoRequestCookie), time.Now())
+	clearSSORequest(ctx)
+	if err != nil || subtle.ConstantTimeCompare([]byte(ctx.Query("state")), []byte(req.State)) != 1 {
+		return renderLoginError(ctx, "Your sign-in expired. Please sign in again.")
+	}
+	if ctx.Query("error") != "" {
+		ctx.Logger.Info("single sign-on declined", slog.String("error", ctx.Query("error")), slog.String("description", ctx.Query("error_description")))
+		return renderLoginError(ctx, "Single sign-on was cancelled.")
+	}
+
+	claims, err := ssoProvider(cfg).Exchange(ctx.UserContext(), req, ctx.Query("code"), ssoRedirectURI(ctx), time.Now())
+	if err != nil {
+		ctx.Logger.Warn("single sign-on rejected", slog.Any("error", err))
+		recordLoginFailure(ctx, "sso", "rejected", nil)
+		return renderLoginError(ctx, "Single sign-on failed. Please try again.")
+	}
+
+	user, err := accounts.SignInWithSSO(ctx.Logger, ctx.DB(), accounts.SSOLogin{
+		Issuer:         claims.Issuer,
+		Subject:        claims.Subject,
+		Email:          claims.Email,
+		EmailVerified:  claims.EmailVerified,
+		AllowedDomains: cfg.Domains(),
+		DefaultRole:    cfg.DefaultRole,
+	}, time.Now())
+	if err != nil {
+		details := map[string]any{"email": claims.Email}
+		switch {
+		case errors.Is(err, accounts.ErrSSODomainNotAllowed):
+			recordLoginFailure(ctx, "sso", "domain_not_allowed", details)
+			return renderLoginError(ctx, "Your account can't sign in here. Ask an admin for an invitation.")
+		case errors.Is(err, accounts.ErrSSOEmailUnverified):
+			recordLoginFailure(ctx, "sso", "email_unverified", details)
+			return renderLoginError(ctx, "Your identity provider hasn't verified your email address.")
+		case errors.Is(err, accounts.ErrUserDeactivated):
+			recordLoginFailure(ctx, "sso", "deactivated", details)
+			return renderLoginError(ctx, "This account has been deactivated")
+		}
+		ctx.Logger.Error("single sign-on failed", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+
+	if err := startSession(ctx, user, "sso"); err != nil {
+		ctx.Logger.Error("failed to start session", slog.Any("error", err), slog.Uint64("userID", uint64(user.ID)))
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin")
+}
+
+// ssoRedirectURI is the callback registered with the identity provider.
+func ssoRedirectURI(ctx *cartridge.Context) string {
+	return instanceOrigin(ctx) + "/admin/login/sso/callback"
+}
+
+func clearSSORequest(ctx *cartridge.Context) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     ssoRequestCookie,
+		Path:     "/admin/login/sso",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+}
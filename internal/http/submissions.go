@@ -2,6 +2,11 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -10,7 +15,10 @@ import (
 	"github.com/karloscodes/cartridge"
 	"gorm.io/gorm"
 
+	"formlander/internal/accounts"
+	"formlander/internal/audit"
 	"formlander/internal/forms"
+	"formlander/internal/integrations"
 )
 
 type submissionWithPreview struct {
@@ -18,9 +26,25 @@ type submissionWithPreview struct {
 	DataJSONPreview string
 }
 
+type statusOption struct {
+	Value string
+	Label string
+}
+
+// statusOptions lists the triage states for select inputs.
+func statusOptions() []statusOption {
+	options := make([]statusOption, len(forms.SubmissionStatuses))
+	for i, status := range forms.SubmissionStatuses {
+		options[i] = statusOption{Value: status, Label: forms.StatusLabel(status)}
+	}
+	return options
+}
+
 // SubmissionList shows all submissions with pagination and filters.
 func SubmissionList(ctx *cartridge.Context) error {
 	db := ctx.DB()
+	user := CurrentUser(ctx)
+	userID := user.ID
 
 	// Parse pagination
 	page, _ := strconv.Atoi(ctx.Query("page", "1"))
@@ -30,39 +54,8 @@ func SubmissionList(ctx *cartridge.Context) error {
 	perPage := 20
 	offset := (page - 1) * perPage
 
-	// Parse filters
-	formID := ctx.Query("form_id")
-	rangeFilter := ctx.Query("range")
-	search := strings.TrimSpace(ctx.Query("q"))
-
-	// Build query
-	query := db.Model(&forms.Submission{}).Preload("Form")
-
-	if formID != "" {
-		query = query.Where("form_id = ?", formID)
-	}
-
-	// Search in data_json
-	if search != "" {
-		query = query.Where("data_json LIKE ?", "%"+search+"%")
-	}
-
-	// Handle date range filter
-	if rangeFilter != "" && rangeFilter != "all" {
-		var startTime time.Time
-		now := time.Now()
-		switch rangeFilter {
-		case "7d":
-			startTime = now.AddDate(0, 0, -7)
-		case "30d":
-			startTime = now.AddDate(0, 0, -30)
-		case "90d":
-			startTime = now.AddDate(0, 0, -90)
-		}
-		if !startTime.IsZero() {
-			query = query.Where("created_at >= ?", startTime)
-		}
-	}
+	filter := forms.ParseSubmissionFilter(queryValues(ctx))
+	query := filter.Apply(forms.ScopeSubmissions(db.Model(&forms.Submission{}), user), userID, time.Now())
 
 	// Get total count for pagination
 	var totalCount int64
@@ -72,7 +65,9 @@ func SubmissionList(ctx *cartridge.Context) error {
 
 	// Get submissions for current page
 	var submissions []forms.Submission
-	if err := query.Order("created_at DESC").
+	if err := filter.Order(query).
+		Preload("Form").
+		Preload("Assignee").
 		Limit(perPage).
 		Offset(offset).
 		Find(&submissions).Error; err != nil {
@@ -94,9 +89,14 @@ func SubmissionList(ctx *cartridge.Context) error {
 		}
 	}
 
-	// Get all forms for filter dropdown
-	var forms []forms.Form
-	db.Select("id, name").Order("name ASC").Find(&forms)
+	// Get all forms, admins and views for the filter controls
+	users, err := accounts.ListUsers(db)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	views := currentLayoutData(ctx.Ctx).pinnedViews()
+	var formList []forms.Form
+	forms.ScopeForms(db.Select("id, name"), user).Order("name ASC").Find(&formList)
 
 	// Calculate pagination info
 	totalPages := (int(totalCount) + perPage - 1) / perPage
@@ -105,10 +105,11 @@ func SubmissionList(ctx *cartridge.Context) error {
 	nextPage := page + 1
 	prevPage := page - 1
 
+	filterQuery := filter.Encode()
 	return ctx.Render("layouts/base", fiber.Map{
 		"Title":       "Submissions",
 		"Submissions": submissionsWithPreview,
-		"Forms":       forms,
+		"Forms":       formList,
 		"Page":        page,
 		"NextPage":    nextPage,
 		"PrevPage":    prevPage,
@@ -116,30 +117,243 @@ func SubmissionList(ctx *cartridge.Context) error {
 		"TotalCount":  totalCount,
 		"HasNext":     hasNext,
 		"HasPrev":     hasPrev,
-		"FormID":      formID,
-		"Range":       rangeFilter,
-		"Search":      search,
+		"Filter":      filter,
+		"Users":       users,
+		"Statuses":    statusOptions(),
+		"Views":       viewLinks(views, filterQuery),
+		"FieldChips":  fieldChips(filter),
+		"Filtered":    !filter.IsZero(),
+		"FilterQuery": template.URL(filterQuery),
+		"RawQuery":    filterQuery,
+		"SortDate":    template.URL(filter.SortedBy(forms.SortDate)),
+		"SortForm":    template.URL(filter.SortedBy(forms.SortForm)),
+		"SortStatus":  template.URL(filter.SortedBy(forms.SortStatus)),
 		"ContentView": "admin/submissions/index/content",
 	}, "")
 }
 
-// AdminSubmissionShow renders a single submission payload.
-func AdminSubmissionShow(ctx *cartridge.Context) error {
+// fieldChip is an active per-field filter with the query that removes it.
+type fieldChip struct {
+	forms.FieldFilter
+	Remove template.URL
+}
+
+func fieldChips(filter forms.SubmissionFilter) []fieldChip {
+	chips := make([]fieldChip, len(filter.Fields))
+	for i, field := range filter.Fields {
+		chips[i] = fieldChip{FieldFilter: field, Remove: template.URL(filter.WithoutField(field.Path))}
+	}
+	return chips
+}
+
+// SubmissionExport downloads the filtered submissions as CSV.
+func SubmissionExport(ctx *cartridge.Context) error {
+	return exportSubmissions(ctx, forms.ParseSubmissionFilter(queryValues(ctx)), "submissions")
+}
+
+func exportSubmissions(ctx *cartridge.Context, filter forms.SubmissionFilter, name string) error {
 	db := ctx.DB()
+	user := CurrentUser(ctx)
+
+	var submissions []forms.Submission
+	query := filter.Apply(forms.ScopeSubmissions(db.Model(&forms.Submission{}), user), user.ID, time.Now())
+	if err := filter.Order(query).Preload("Form").Limit(forms.MaxExportRows).Find(&submissions).Error; err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	var buf strings.Builder
+	if err := forms.WriteSubmissionsCSV(&buf, submissions); err != nil {
+		return fiber.ErrInternalServerError
+	}
+	if err := audit.Log(ctx.Logger, auditDB(ctx), audit.Event{
+		Action:  audit.ActionSubmissionsExported,
+		Details: map[string]any{"name": name, "filter": filter.Encode(), "count": len(submissions)},
+	}); err != nil {
+		ctx.Logger.Error("failed to record export", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	filename := fmt.Sprintf("%s-%s.csv", exportSlug(name), time.Now().UTC().Format("20060102"))
+	ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return ctx.SendString(buf.String())
+}
+
+// exportSlug makes a name safe to use in a download filename.
+func exportSlug(name string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, name)
+	slug = strings.Trim(slug, "-")
+	if slug == "" {
+		return "submissions"
+	}
+	return slug
+}
+
+// queryValues returns the request's query parameters.
+func queryValues(ctx *cartridge.Context) url.Values {
+	values, _ := url.ParseQuery(string(ctx.Request().URI().QueryString()))
+	return values
+}
+
+// AdminSubmissionShow renders a single submission payload. Opening it marks
+// the submission read, and is recorded in the audit log.
+func AdminSubmissionShow(ctx *cartridge.Context) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+	if err := audit.Log(ctx.Logger, auditDB(ctx), audit.Event{Action: audit.ActionSubmissionViewed, TargetType: "submission", TargetID: uint(id)}); err != nil {
+		ctx.Logger.Error("failed to record submission view", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	if err := forms.MarkRead(ctx.Logger, ctx.DB(), uint(id), time.Now().UTC()); err != nil {
+		ctx.Logger.Error("mark submission read", slog.Any("error", err))
+	}
+	return renderSubmission(ctx, uint(id), "", forms.ReplyParams{})
+}
+
+// AdminSubmissionDeliveries renders only the delivery status of a submission,
+// for live updates of an open submission page. It carries none of the
+// submission's data, so it isn't a view.
+func AdminSubmissionDeliveries(ctx *cartridge.Context) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+
+	var submission forms.Submission
+	if err := ctx.DB().Select("id").
+		Preload("WebhookEvents").
+		Preload("EmailEvents").
+		Where("id = ?", id).
+		First(&submission).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Render("admin/submissions/deliveries", fiber.Map{"Submission": submission}, "")
+}
 
+// AdminSubmissionTriage updates a submission's status, assignee and tags.
+func AdminSubmissionTriage(ctx *cartridge.Context) error {
 	id, err := strconv.Atoi(ctx.Params("id"))
 	if err != nil {
 		return fiber.ErrNotFound
 	}
 
+	params := forms.TriageParams{
+		Status: ctx.FormValue("status"),
+		Tags:   ctx.FormValue("tags"),
+	}
+	if raw := ctx.FormValue("assignee_id"); raw != "" {
+		assigneeID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return renderSubmission(ctx, uint(id), "Assignee must be an admin user", forms.ReplyParams{})
+		}
+		uid := uint(assigneeID)
+		params.AssigneeID = &uid
+	}
+
+	if err := forms.UpdateTriage(ctx.Logger, ctx.DB(), uint(id), params); err != nil {
+		if valErr, ok := err.(*forms.ValidationError); ok {
+			return renderSubmission(ctx, uint(id), valErr.Message, forms.ReplyParams{})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect(fmt.Sprintf("/admin/submissions/%d", id))
+}
+
+// AdminSubmissionNoteCreate adds an internal note to a submission.
+func AdminSubmissionNoteCreate(ctx *cartridge.Context) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+
+	if _, err := forms.AddNote(ctx.Logger, ctx.DB(), uint(id), CurrentUser(ctx), ctx.FormValue("body")); err != nil {
+		if valErr, ok := err.(*forms.ValidationError); ok {
+			return renderSubmission(ctx, uint(id), valErr.Message, forms.ReplyParams{})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect(fmt.Sprintf("/admin/submissions/%d#notes", id))
+}
+
+// AdminSubmissionMarkUnread puts a submission back in the unread pile and
+// returns to the inbox.
+func AdminSubmissionMarkUnread(ctx *cartridge.Context) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+	if err := forms.MarkUnread(ctx.Logger, ctx.DB(), uint(id)); err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin/submissions")
+}
+
+func renderSubmission(ctx *cartridge.Context, id uint, errMsg string, reply forms.ReplyParams) error {
+	db := ctx.DB()
+
 	var submission forms.Submission
-	if err := db.Preload("Form").Preload("WebhookEvents").Preload("EmailEvents").Preload("Files").Where("id = ?", id).First(&submission).Error; err != nil {
+	if err := db.Preload("Form").
+		Preload("Form.EmailDelivery").
+		Preload("WebhookEvents").
+		Preload("EmailEvents").
+		Preload("Files").
+		Preload("Assignee").
+		Preload("Notes", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at ASC, id ASC") }).
+		Preload("Replies", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		Where("id = ?", id).
+		First(&submission).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return fiber.ErrNotFound
 		}
 		return fiber.ErrInternalServerError
 	}
 
+	users, err := accounts.ListUsers(db)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	mailerProfiles, err := integrations.ListMailerProfiles(db)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	// Reply composer: follow-ups keep the thread's subject, and the form's
+	// mailer profile is picked by default.
+	if reply.Subject == "" {
+		if n := len(submission.Replies); n > 0 {
+			reply.Subject = submission.Replies[n-1].Subject
+		} else {
+			reply.Subject = submission.DefaultReplySubject()
+		}
+	}
+	if reply.MailerProfileID == 0 && submission.Form.EmailDelivery != nil && submission.Form.EmailDelivery.MailerProfileID != nil {
+		reply.MailerProfileID = *submission.Form.EmailDelivery.MailerProfileID
+	}
+
+	var assigneeID uint
+	if submission.AssigneeID != nil {
+		assigneeID = *submission.AssigneeID
+	}
+
 	var prettyJSON string
 	if submission.DataJSON != "" {
 		var buf any
@@ -155,7 +369,16 @@ func AdminSubmissionShow(ctx *cartridge.Context) error {
 		"Title":       "Submission",
 		"Submission":  submission,
 		"JSON":        prettyJSON,
+		"Fields":      forms.FlattenDataJSON(submission.DataJSON),
 		"HasFiles":    len(submission.Files) > 0,
+		"Users":       users,
+		"AssigneeID":  assigneeID,
+		"ReplyTo":     submission.Form.ReplyAddress(forms.FlattenDataJSON(submission.DataJSON)),
+		"Reply":       reply,
+		"Mailers":     mailerProfiles,
+		"Statuses":    statusOptions(),
+		"CanEdit":     CurrentUser(ctx).CanEdit(),
+		"Error":       errMsg,
 		"ContentView": "admin/submissions/show/content",
 	}, "")
 }
@@ -183,6 +406,16 @@ func AdminSubmissionFileDownload(ctx *cartridge.Context) error {
 		return fiber.ErrInternalServerError
 	}
 
+	if err := audit.Log(ctx.Logger, auditDB(ctx), audit.Event{
+		Action:     audit.ActionSubmissionFileDownloaded,
+		TargetType: "submission",
+		TargetID:   uint(submissionID),
+		Details:    map[string]any{"file_id": file.ID, "filename": file.Filename},
+	}); err != nil {
+		ctx.Logger.Error("failed to record file download", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+
 	filePath := forms.GetFilePath(cfg.DataDirectory, &file)
 
 	// Set content disposition for download
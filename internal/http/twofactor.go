@@ -0,0 +1,89 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+
+	"formlander/internal/accounts"
+	"formlander/internal/pkg/qrcode"
+)
+
+// AdminTwoFactorSetup starts enrollment: a new secret, shown as a QR code
+// and as text, that the user confirms with a code from their app.
+func AdminTwoFactorSetup(ctx *cartridge.Context) error {
+	user := CurrentUser(ctx)
+	secret, err := accounts.BeginTOTPEnrollment(ctx.Logger, ctx.DB(), user.ID)
+	if err != nil {
+		return twoFactorError(ctx, err)
+	}
+	return renderTwoFactorSetup(ctx, user.Email, secret, "")
+}
+
+// AdminTwoFactorEnable confirms enrollment and shows the recovery codes,
+// once.
+func AdminTwoFactorEnable(ctx *cartridge.Context) error {
+	user := CurrentUser(ctx)
+	codes, err := accounts.EnableTOTP(ctx.Logger, auditDB(ctx), user.ID, ctx.FormValue("code"), time.Now())
+	if err != nil {
+		if errors.Is(err, accounts.ErrInvalidCode) {
+			// Show the same secret again; it was saved by the setup step.
+			return renderTwoFactorSetup(ctx, user.Email, user.TOTPSecret, "That code is not valid. Check your device's clock and try again.")
+		}
+		return twoFactorError(ctx, err)
+	}
+	return renderSettings(ctx, fiber.Map{
+		"Success":       "Two-factor authentication is on",
+		"RecoveryCodes": codes,
+	})
+}
+
+// AdminTwoFactorDisable turns two-factor authentication off after checking
+// the password.
+func AdminTwoFactorDisable(ctx *cartridge.Context) error {
+	if err := accounts.DisableTOTP(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, ctx.FormValue("current_password")); err != nil {
+		return twoFactorError(ctx, err)
+	}
+	return renderSettingsSuccess(ctx, "Two-factor authentication is off")
+}
+
+// AdminTwoFactorRecoveryCodes replaces the recovery codes after checking
+// the password.
+func AdminTwoFactorRecoveryCodes(ctx *cartridge.Context) error {
+	codes, err := accounts.RegenerateRecoveryCodes(ctx.Logger, ctx.DB(), CurrentUser(ctx).ID, ctx.FormValue("current_password"))
+	if err != nil {
+		return twoFactorError(ctx, err)
+	}
+	return renderSettings(ctx, fiber.Map{
+		"Success":       "New recovery codes created. Earlier codes no longer work.",
+		"RecoveryCodes": codes,
+	})
+}
+
+func renderTwoFactorSetup(ctx *cartridge.Context, email, secret, errMsg string) error {
+	code, err := qrcode.Encode(accounts.TOTPURI(email, secret))
+	if err != nil {
+		ctx.Logger.Error("failed to encode enrollment QR code", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return renderSettings(ctx, fiber.Map{
+		"Error":     errMsg,
+		"TOTPSetup": fiber.Map{"Secret": secret, "QRCode": code.SVG()},
+	})
+}
+
+func twoFactorError(ctx *cartridge.Context, err error) error {
+	switch {
+	case errors.Is(err, accounts.ErrPasswordMismatch):
+		return renderSettingsError(ctx, "Current password is incorrect")
+	case errors.Is(err, accounts.ErrTOTPEnabled):
+		return renderSettingsError(ctx, "Two-factor authentication is already on")
+	case errors.Is(err, accounts.ErrTOTPNotEnabled):
+		return renderSettingsError(ctx, "Two-factor authentication is not on")
+	}
+	ctx.Logger.Error("two-factor settings change failed", slog.Any("error", err))
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,311 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/forms"
+	"formlander/internal/integrations"
+)
+
+// accountErrorMessages are the user-facing messages for account errors the
+// admin can fix.
+var accountErrorMessages = map[error]string{
+	accounts.ErrInvalidEmail:       "Please enter a valid email address",
+	accounts.ErrDuplicateEmail:     "That email is already in use",
+	accounts.ErrWeakPassword:       "Password must be at least 8 characters long",
+	accounts.ErrSelfManagement:     "Manage your own account from Settings",
+	accounts.ErrLastActiveUser:     "At least one active user must remain",
+	accounts.ErrLastOwner:          "At least one active owner must remain",
+	accounts.ErrOwnerRequired:      "Only an owner can manage owners",
+	accounts.ErrInvalidRole:        "Choose a role",
+	accounts.ErrMailerRequired:     "Choose a mailer profile to send the invitation",
+	accounts.ErrInvitationAccepted: "That invitation was already accepted",
+}
+
+func accountErrorMessage(err error) (string, bool) {
+	for target, message := range accountErrorMessages {
+		if errors.Is(err, target) {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// AdminUsers lists users and pending invitations.
+func AdminUsers(ctx *cartridge.Context) error {
+	return renderUsers(ctx, "", "")
+}
+
+// AdminInvitationCreate invites someone by email. The invitation job sends
+// the link.
+func AdminInvitationCreate(ctx *cartridge.Context) error {
+	profileID, _ := strconv.ParseUint(ctx.FormValue("mailer_profile_id"), 10, 32)
+	invitation, err := accounts.InviteUser(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, ctx.FormValue("email"), ctx.FormValue("role"), uint(profileID), time.Now())
+	if err != nil {
+		if message, ok := accountErrorMessage(err); ok {
+			return renderUsers(ctx, message, "")
+		}
+		ctx.Logger.Error("failed to invite user", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return renderUsers(ctx, "", "Invitation to "+invitation.Email+" queued")
+}
+
+// AdminInvitationResend renews an invitation and sends it again.
+func AdminInvitationResend(ctx *cartridge.Context) error {
+	id, err := paramID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := accounts.ResendInvitation(ctx.Logger, auditDB(ctx), id, time.Now()); err != nil {
+		return usersActionError(ctx, err)
+	}
+	return renderUsers(ctx, "", "Invitation queued again")
+}
+
+// AdminInvitationRevoke cancels a pending invitation.
+func AdminInvitationRevoke(ctx *cartridge.Context) error {
+	id, err := paramID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := accounts.RevokeInvitation(ctx.Logger, auditDB(ctx), id); err != nil {
+		return usersActionError(ctx, err)
+	}
+	return ctx.Redirect("/admin/settings/users")
+}
+
+// AdminUserShow shows one user with their email, password and status
+// controls.
+func AdminUserShow(ctx *cartridge.Context) error {
+	return renderUser(ctx, "", "")
+}
+
+// AdminUserUpdateEmail changes another user's email.
+func AdminUserUpdateEmail(ctx *cartridge.Context) error {
+	id, self, err := managedUserID(ctx)
+	if err != nil || self {
+		return err
+	}
+	if err := accounts.UpdateUserEmail(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, id, ctx.FormValue("email")); err != nil {
+		return userActionError(ctx, err)
+	}
+	return renderUser(ctx, "", "Email updated")
+}
+
+// AdminUserSetPassword sets a new password for another user.
+func AdminUserSetPassword(ctx *cartridge.Context) error {
+	id, self, err := managedUserID(ctx)
+	if err != nil || self {
+		return err
+	}
+	if ctx.FormValue("new_password") != ctx.FormValue("confirm_password") {
+		return renderUser(ctx, "Passwords do not match", "")
+	}
+	if err := accounts.SetPassword(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, id, ctx.FormValue("new_password")); err != nil {
+		return userActionError(ctx, err)
+	}
+	return renderUser(ctx, "", "Password updated")
+}
+
+// AdminUserSetRole changes another user's role.
+func AdminUserSetRole(ctx *cartridge.Context) error {
+	id, self, err := managedUserID(ctx)
+	if err != nil || self {
+		return err
+	}
+	if err := accounts.SetUserRole(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, id, ctx.FormValue("role")); err != nil {
+		return userActionError(ctx, err)
+	}
+	return renderUser(ctx, "", "Role updated")
+}
+
+// AdminUserSetForms replaces the forms an editor or viewer is granted.
+func AdminUserSetForms(ctx *cartridge.Context) error {
+	id, self, err := managedUserID(ctx)
+	if err != nil || self {
+		return err
+	}
+	if _, err := accounts.FindByID(ctx.DB(), id); err != nil {
+		return userActionError(ctx, err)
+	}
+
+	var formIDs []uint
+	for _, raw := range ctx.Request().PostArgs().PeekMulti("form_id") {
+		if formID, err := strconv.ParseUint(string(raw), 10, 32); err == nil {
+			formIDs = append(formIDs, uint(formID))
+		}
+	}
+	if err := forms.SetFormGrants(ctx.Logger, auditDB(ctx), id, formIDs); err != nil {
+		ctx.Logger.Error("failed to update form access", slog.Any("error", err))
+		return fiber.ErrInternalServerError
+	}
+	return renderUser(ctx, "", "Form access updated")
+}
+
+// AdminUserDeactivate blocks a user from signing in and ends their sessions.
+func AdminUserDeactivate(ctx *cartridge.Context) error {
+	return setUserActive(ctx, false)
+}
+
+// AdminUserActivate lets a deactivated user sign in again.
+func AdminUserActivate(ctx *cartridge.Context) error {
+	return setUserActive(ctx, true)
+}
+
+// AdminUserDelete removes a user. Their notes and replies keep the author's
+// email.
+func AdminUserDelete(ctx *cartridge.Context) error {
+	id, err := paramID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := accounts.DeleteUser(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, id, forms.DetachUser); err != nil {
+		return usersActionError(ctx, err)
+	}
+	return ctx.Redirect("/admin/settings/users")
+}
+
+func setUserActive(ctx *cartridge.Context, active bool) error {
+	id, err := paramID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := accounts.SetUserActive(ctx.Logger, auditDB(ctx), CurrentUser(ctx).ID, id, active); err != nil {
+		return usersActionError(ctx, err)
+	}
+	return ctx.Redirect("/admin/settings/users")
+}
+
+func paramID(ctx *cartridge.Context) (uint, error) {
+	id, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return 0, fiber.ErrNotFound
+	}
+	return uint(id), nil
+}
+
+// managedUserID is the user being edited. Signed-in users are sent to
+// Settings instead, where changing their own account takes their password;
+// self reports that the redirect was written.
+func managedUserID(ctx *cartridge.Context) (id uint, self bool, err error) {
+	id, err = paramID(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	if id == CurrentUser(ctx).ID {
+		return id, true, ctx.Redirect("/admin/settings")
+	}
+	return id, false, nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, accounts.ErrUserNotFound) || errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+func usersActionError(ctx *cartridge.Context, err error) error {
+	if isNotFound(err) {
+		return fiber.ErrNotFound
+	}
+	if message, ok := accountErrorMessage(err); ok {
+		return renderUsers(ctx, message, "")
+	}
+	ctx.Logger.Error("user management failed", slog.Any("error", err))
+	return fiber.ErrInternalServerError
+}
+
+func userActionError(ctx *cartridge.Context, err error) error {
+	if isNotFound(err) {
+		return fiber.ErrNotFound
+	}
+	if message, ok := accountErrorMessage(err); ok {
+		return renderUser(ctx, message, "")
+	}
+	ctx.Logger.Error("user update failed", slog.Any("error", err))
+	return fiber.ErrInternalServerError
+}
+
+func renderUsers(ctx *cartridge.Context, errMsg, success string) error {
+	db := ctx.DB()
+	users, err := accounts.ListUsers(db)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	invitations, err := accounts.PendingInvitations(db)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	var mailers []integrations.MailerProfile
+	if err := db.Order("name ASC").Find(&mailers).Error; err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":       "Users",
+		"Users":       users,
+		"Invitations": invitations,
+		"Mailers":     mailers,
+		"Roles":       assignableRoles(CurrentUser(ctx)),
+		"CurrentUser": CurrentUser(ctx),
+		"Error":       errMsg,
+		"Success":     success,
+		"ContentView": "admin/users/index",
+	}, "")
+}
+
+func renderUser(ctx *cartridge.Context, errMsg, success string) error {
+	id, self, err := managedUserID(ctx)
+	if err != nil || self {
+		return err
+	}
+	db := ctx.DB()
+	user, err := accounts.FindByID(db, id)
+	if err != nil {
+		if errors.Is(err, accounts.ErrUserNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	formList, err := forms.List(db)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	grantedIDs, err := forms.GrantedFormIDs(db, user.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	granted := make(map[uint]bool, len(grantedIDs))
+	for _, formID := range grantedIDs {
+		granted[formID] = true
+	}
+
+	current := CurrentUser(ctx)
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":       user.Email,
+		"User":        user,
+		"Roles":       assignableRoles(current),
+		"CanManage":   user.Role != accounts.RoleOwner || current.HasRole(accounts.RoleOwner),
+		"Forms":       formList,
+		"Granted":     granted,
+		"Error":       errMsg,
+		"Success":     success,
+		"ContentView": "admin/users/show",
+	}, "")
+}
+
+// assignableRoles lists the roles actor may give out. Only owners make
+// owners.
+func assignableRoles(actor *accounts.User) []string {
+	if actor.HasRole(accounts.RoleOwner) {
+		return accounts.Roles
+	}
+	return accounts.Roles[1:]
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karloscodes/cartridge"
+	"gorm.io/gorm"
+
+	"formlander/internal/forms"
+)
+
+// AdminViews lists the current admin's saved submission views.
+func AdminViews(ctx *cartridge.Context) error {
+	return renderViews(ctx, "")
+}
+
+// AdminViewCreate saves the submitted filter query as a named view and opens
+// it.
+func AdminViewCreate(ctx *cartridge.Context) error {
+	user := CurrentUser(ctx)
+	if user == nil {
+		return fiber.ErrUnauthorized
+	}
+
+	values, _ := url.ParseQuery(ctx.FormValue("query"))
+	filter := forms.ParseSubmissionFilter(values)
+	pinned := ctx.FormValue("pinned") == "on"
+	if _, err := forms.CreateView(ctx.Logger, ctx.DB(), user.ID, ctx.FormValue("name"), filter, pinned); err != nil {
+		if valErr, ok := err.(*forms.ValidationError); ok {
+			return renderViews(ctx, valErr.Message)
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin/submissions?" + filter.Encode())
+}
+
+// AdminViewPin pins or unpins a saved view.
+func AdminViewPin(ctx *cartridge.Context) error {
+	userID, viewID, err := viewParams(ctx)
+	if err != nil {
+		return err
+	}
+	if err := forms.SetViewPinned(ctx.Logger, ctx.DB(), userID, viewID, ctx.FormValue("pinned") == "1"); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin/views")
+}
+
+// AdminViewDelete removes a saved view.
+func AdminViewDelete(ctx *cartridge.Context) error {
+	userID, viewID, err := viewParams(ctx)
+	if err != nil {
+		return err
+	}
+	if err := forms.DeleteView(ctx.Logger, ctx.DB(), userID, viewID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Redirect("/admin/views")
+}
+
+// AdminViewExport downloads a saved view's submissions as CSV.
+func AdminViewExport(ctx *cartridge.Context) error {
+	userID, viewID, err := viewParams(ctx)
+	if err != nil {
+		return err
+	}
+	view, err := forms.GetView(ctx.DB(), userID, viewID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return exportSubmissions(ctx, view.Filter(), view.Name)
+}
+
+// viewLink is a saved view as shown in the admin, with its query ready for
+// use in a link.
+type viewLink struct {
+	forms.SavedView
+	Query  template.URL
+	Active bool
+}
+
+// viewLinks prepares saved views for display. current is the encoded filter
+// on screen, which marks the matching view active.
+func viewLinks(views []forms.SavedView, current string) []viewLink {
+	links := make([]viewLink, len(views))
+	for i, view := range views {
+		query := view.Filter().Encode()
+		links[i] = viewLink{SavedView: view, Query: template.URL(query), Active: current != "" && query == current}
+	}
+	return links
+}
+
+func viewParams(ctx *cartridge.Context) (userID, viewID uint, err error) {
+	user := CurrentUser(ctx)
+	if user == nil {
+		return 0, 0, fiber.ErrUnauthorized
+	}
+	id, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return 0, 0, fiber.ErrNotFound
+	}
+	return user.ID, uint(id), nil
+}
+
+func renderViews(ctx *cartridge.Context, errMsg string) error {
+	user := CurrentUser(ctx)
+	if user == nil {
+		return fiber.ErrUnauthorized
+	}
+	views, err := forms.ListViews(ctx.DB(), user.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.Render("layouts/base", fiber.Map{
+		"Title":       "Saved Views",
+		"Views":       viewLinks(views, ""),
+		"Error":       errMsg,
+		"ContentView": "admin/views/content",
+	}, "")
+}
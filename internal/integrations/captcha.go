@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"gorm.io/gorm"
 
+	"formlander/internal/audit"
 	"formlander/internal/pkg/dbtxn"
 )
 
@@ -64,7 +65,15 @@ func CreateCaptchaProfile(logger *slog.Logger, db *gorm.DB, params CaptchaProfil
 	}
 
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Create(profile).Error
+		if err := tx.Create(profile).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionCaptchaCreated,
+			TargetType: "captcha_profile",
+			TargetID:   profile.ID,
+			Details:    audit.Changes(nil, profile.auditValues(), captchaSecrets...),
+		})
 	}); err != nil {
 		logger.Error("failed to create captcha profile", slog.Any("error", err))
 		return nil, fmt.Errorf("failed to create profile: %w", err)
@@ -114,14 +123,25 @@ func UpdateCaptchaProfile(logger *slog.Logger, db *gorm.DB, id uint, params Capt
 		}
 	}
 
+	values := map[string]any{
+		"name":           name,
+		"provider":       strings.TrimSpace(params.Provider),
+		"secret_key":     strings.TrimSpace(params.SecretKey),
+		"site_keys_json": siteKeysJSON,
+		"policy_json":    policyJSON,
+	}
+	changes := audit.Changes(profile.auditValues(), values, captchaSecrets...)
+
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Model(profile).Updates(map[string]any{
-			"name":           name,
-			"provider":       strings.TrimSpace(params.Provider),
-			"secret_key":     strings.TrimSpace(params.SecretKey),
-			"site_keys_json": siteKeysJSON,
-			"policy_json":    policyJSON,
-		}).Error
+		if err := tx.Model(profile).Updates(values).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionCaptchaUpdated,
+			TargetType: "captcha_profile",
+			TargetID:   id,
+			Details:    changes,
+		})
 	}); err != nil {
 		logger.Error("failed to update captcha profile", slog.Any("error", err), slog.Uint64("id", uint64(id)))
 		return nil, fmt.Errorf("failed to update profile: %w", err)
@@ -134,6 +154,28 @@ func UpdateCaptchaProfile(logger *slog.Logger, db *gorm.DB, id uint, params Capt
 // DeleteCaptchaProfile deletes a captcha profile
 func DeleteCaptchaProfile(logger *slog.Logger, db *gorm.DB, id uint) error {
 	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Delete(&CaptchaProfile{}, id).Error
+		res := tx.Delete(&CaptchaProfile{}, id)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionCaptchaDeleted,
+			TargetType: "captcha_profile",
+			TargetID:   id,
+		})
 	})
 }
+
+// captchaSecrets are the captcha profile columns the audit log masks.
+var captchaSecrets = []string{"secret_key"}
+
+// auditValues returns the profile's columns as the audit log compares them.
+func (p *CaptchaProfile) auditValues() map[string]any {
+	return map[string]any{
+		"name":           p.Name,
+		"provider":       p.Provider,
+		"secret_key":     p.SecretKey,
+		"site_keys_json": p.SiteKeysJSON,
+		"policy_json":    p.PolicyJSON,
+	}
+}
@@ -3,6 +3,7 @@ package integrations_test
 import (
 	"testing"
 
+	"formlander/internal/audit"
 	"formlander/internal/integrations"
 	"formlander/internal/pkg/testsupport"
 
@@ -326,3 +327,51 @@ func TestDeleteProfiles(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestProfileChangesAreAudited(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	lastEntry := func() audit.Entry {
+		t.Helper()
+		var entry audit.Entry
+		require.NoError(t, db.Last(&entry).Error)
+		return entry
+	}
+
+	mailer, err := integrations.CreateMailerProfile(logger, db, integrations.MailerProfileParams{
+		Name:         "Team",
+		Provider:     "smtp",
+		SMTPHost:     "smtp.example.com",
+		SMTPPassword: "hunter22",
+	})
+	require.NoError(t, err)
+	entry := lastEntry()
+	assert.Equal(t, audit.ActionMailerCreated, entry.Action)
+	assert.Equal(t, mailer.ID, entry.TargetID)
+	assert.Contains(t, entry.Details, `"smtp_password":"********"`)
+	assert.NotContains(t, entry.Details, "hunter22")
+
+	_, err = integrations.UpdateMailerProfile(logger, db, mailer.ID, integrations.MailerProfileParams{
+		Name:         "Support",
+		Provider:     "smtp",
+		SMTPHost:     "smtp.example.com",
+		SMTPPassword: "correct-horse",
+	})
+	require.NoError(t, err)
+	entry = lastEntry()
+	assert.Equal(t, audit.ActionMailerUpdated, entry.Action)
+	assert.Contains(t, entry.DetailLines(), "name: Team → Support")
+	assert.Contains(t, entry.DetailLines(), "smtp_password: ******** → ********")
+	assert.NotContains(t, entry.Details, "correct-horse")
+	assert.NotContains(t, entry.Details, "smtp_host", "unchanged columns are left out")
+
+	captcha, err := integrations.CreateCaptchaProfile(logger, db, integrations.CaptchaProfileParams{Name: "Turnstile", SecretKey: "0x-secret"})
+	require.NoError(t, err)
+	assert.NotContains(t, lastEntry().Details, "0x-secret")
+
+	require.NoError(t, integrations.DeleteCaptchaProfile(logger, db, captcha.ID))
+	entry = lastEntry()
+	assert.Equal(t, audit.ActionCaptchaDeleted, entry.Action)
+	assert.Equal(t, captcha.ID, entry.TargetID)
+}
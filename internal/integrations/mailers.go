@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"gorm.io/gorm"
 
+	"formlander/internal/audit"
 	"formlander/internal/pkg/dbtxn"
 )
 
@@ -79,7 +80,15 @@ func CreateMailerProfile(logger *slog.Logger, db *gorm.DB, params MailerProfileP
 	}
 
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Create(profile).Error
+		if err := tx.Create(profile).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionMailerCreated,
+			TargetType: "mailer_profile",
+			TargetID:   profile.ID,
+			Details:    audit.Changes(nil, profile.auditValues(), mailerSecrets...),
+		})
 	}); err != nil {
 		logger.Error("failed to create mailer profile", slog.Any("error", err))
 		return nil, fmt.Errorf("failed to create profile: %w", err)
@@ -120,21 +129,32 @@ func UpdateMailerProfile(logger *slog.Logger, db *gorm.DB, id uint, params Maile
 		}
 	}
 
+	values := map[string]any{
+		"name":               name,
+		"provider":           strings.TrimSpace(params.Provider),
+		"api_key":            strings.TrimSpace(params.APIKey),
+		"domain":             strings.TrimSpace(params.Domain),
+		"default_from_name":  strings.TrimSpace(params.DefaultFromName),
+		"default_from_email": strings.TrimSpace(params.DefaultFromEmail),
+		"defaults_json":      defaultsJSON,
+		"smtp_host":          strings.TrimSpace(params.SMTPHost),
+		"smtp_port":          params.SMTPPort,
+		"smtp_username":      strings.TrimSpace(params.SMTPUsername),
+		"smtp_password":      strings.TrimSpace(params.SMTPPassword),
+		"smtp_encryption":    strings.TrimSpace(params.SMTPEncryption),
+	}
+	changes := audit.Changes(profile.auditValues(), values, mailerSecrets...)
+
 	if err := dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Model(profile).Updates(map[string]any{
-			"name":               name,
-			"provider":           strings.TrimSpace(params.Provider),
-			"api_key":            strings.TrimSpace(params.APIKey),
-			"domain":             strings.TrimSpace(params.Domain),
-			"default_from_name":  strings.TrimSpace(params.DefaultFromName),
-			"default_from_email": strings.TrimSpace(params.DefaultFromEmail),
-			"defaults_json":      defaultsJSON,
-			"smtp_host":          strings.TrimSpace(params.SMTPHost),
-			"smtp_port":          params.SMTPPort,
-			"smtp_username":      strings.TrimSpace(params.SMTPUsername),
-			"smtp_password":      strings.TrimSpace(params.SMTPPassword),
-			"smtp_encryption":    strings.TrimSpace(params.SMTPEncryption),
-		}).Error
+		if err := tx.Model(profile).Updates(values).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionMailerUpdated,
+			TargetType: "mailer_profile",
+			TargetID:   id,
+			Details:    changes,
+		})
 	}); err != nil {
 		logger.Error("failed to update mailer profile", slog.Any("error", err), slog.Uint64("id", uint64(id)))
 		return nil, fmt.Errorf("failed to update profile: %w", err)
@@ -147,6 +167,35 @@ func UpdateMailerProfile(logger *slog.Logger, db *gorm.DB, id uint, params Maile
 // DeleteMailerProfile deletes a mailer profile
 func DeleteMailerProfile(logger *slog.Logger, db *gorm.DB, id uint) error {
 	return dbtxn.WithRetry(logger, db, func(tx *gorm.DB) error {
-		return tx.Delete(&MailerProfile{}, id).Error
+		res := tx.Delete(&MailerProfile{}, id)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return audit.Record(tx, audit.Event{
+			Action:     audit.ActionMailerDeleted,
+			TargetType: "mailer_profile",
+			TargetID:   id,
+		})
 	})
 }
+
+// mailerSecrets are the mailer profile columns the audit log masks.
+var mailerSecrets = []string{"api_key", "smtp_password"}
+
+// auditValues returns the profile's columns as the audit log compares them.
+func (p *MailerProfile) auditValues() map[string]any {
+	return map[string]any{
+		"name":               p.Name,
+		"provider":           p.Provider,
+		"api_key":            p.APIKey,
+		"domain":             p.Domain,
+		"default_from_name":  p.DefaultFromName,
+		"default_from_email": p.DefaultFromEmail,
+		"defaults_json":      p.DefaultsJSON,
+		"smtp_host":          p.SMTPHost,
+		"smtp_port":          p.SMTPPort,
+		"smtp_username":      p.SMTPUsername,
+		"smtp_password":      p.SMTPPassword,
+		"smtp_encryption":    p.SMTPEncryption,
+	}
+}
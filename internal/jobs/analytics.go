@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"log/slog"
+	"time"
+
+	"formlander/internal/forms"
+)
+
+// AnalyticsRollup keeps the hourly submission rollups that back the
+// analytics pages up to date.
+type AnalyticsRollup struct {
+	now func() time.Time
+}
+
+// NewAnalyticsRollup constructs the rollup job.
+func NewAnalyticsRollup() *AnalyticsRollup {
+	return &AnalyticsRollup{now: time.Now}
+}
+
+// ProcessBatch implements the Processor interface.
+func (r *AnalyticsRollup) ProcessBatch(ctx *JobContext) error {
+	if err := forms.RefreshRollups(ctx.Logger, ctx.DB, r.now()); err != nil {
+		ctx.Logger.Error("refresh submission rollups", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
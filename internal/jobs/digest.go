@@ -0,0 +1,292 @@
+package jobs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // digest timezones must resolve on minimal container images
+
+	"log/slog"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/config"
+	"formlander/internal/forms"
+	"formlander/internal/pkg/dbtxn"
+)
+
+// digestMaxListed caps how many submissions are itemized in one digest email.
+const digestMaxListed = 100
+
+// DigestDispatcher sends scheduled daily/weekly summaries for forms whose
+// email forwarding is in digest mode, and the all-forms digest when it is on.
+type DigestDispatcher struct {
+	cfg  *config.Config
+	http *http.Client
+	now  func() time.Time
+}
+
+// NewDigestDispatcher constructs a dispatcher for digest emails.
+func NewDigestDispatcher(cfg *config.Config) *DigestDispatcher {
+	return &DigestDispatcher{
+		cfg:  cfg,
+		http: &http.Client{Timeout: 15 * time.Second},
+		now:  time.Now,
+	}
+}
+
+// ProcessBatch implements the Processor interface.
+func (d *DigestDispatcher) ProcessBatch(ctx *JobContext) error {
+	db := ctx.DB
+	var deliveries []forms.EmailDelivery
+	if err := db.
+		Preload("Form").
+		Where("enabled = ? AND digest_mode IN ?", true, []string{forms.DigestModeDaily, forms.DigestModeWeekly}).
+		Find(&deliveries).Error; err != nil {
+		ctx.Logger.Error("query digest deliveries", slog.Any("error", err))
+		return err
+	}
+
+	now := d.now().UTC()
+	for i := range deliveries {
+		d.handleDelivery(ctx, db, &deliveries[i], now)
+	}
+	d.handleAllForms(ctx, db, now)
+	return nil
+}
+
+// handleDelivery sends every digest window that closed since the last run.
+func (d *DigestDispatcher) handleDelivery(ctx *JobContext, db *gorm.DB, delivery *forms.EmailDelivery, now time.Time) {
+	if delivery.Form == nil {
+		return
+	}
+	d.sendWindows(ctx, delivery, delivery.LastDigestAt, now,
+		func(start, end time.Time) error { return d.sendWindow(ctx, db, delivery, start, end) },
+		func(end time.Time) bool { return d.recordDigest(ctx, db, delivery, end) },
+		slog.Uint64("form_id", uint64(delivery.FormID)))
+}
+
+// handleAllForms sends the all-forms digest windows that closed since the
+// last run.
+func (d *DigestDispatcher) handleAllForms(ctx *JobContext, db *gorm.DB, now time.Time) {
+	digest, err := forms.GetAllFormsDigest(db)
+	if err != nil {
+		ctx.Logger.Error("query all-forms digest", slog.Any("error", err))
+		return
+	}
+	if !digest.Enabled() || digest.ID == 0 {
+		return
+	}
+	d.sendWindows(ctx, digest.Schedule(), digest.LastDigestAt, now,
+		func(start, end time.Time) error { return d.sendAllFormsWindow(ctx, db, digest, start, end) },
+		func(end time.Time) bool { return d.recordAllFormsDigest(ctx, db, digest, end) },
+		slog.String("digest", "all_forms"))
+}
+
+// sendWindows walks the windows of schedule between last and now. Windows are
+// sent oldest first so a restart never skips a period; a failed send stops
+// the loop and is retried on the next tick.
+func (d *DigestDispatcher) sendWindows(ctx *JobContext, schedule *forms.EmailDelivery, last *time.Time, now time.Time,
+	send func(start, end time.Time) error, record func(end time.Time) bool, attr slog.Attr) {
+	latest := schedule.DigestBoundary(now)
+	if last == nil {
+		// First run after enabling: start counting from the most recent boundary.
+		record(latest)
+		return
+	}
+
+	start := last.UTC()
+	for start.Before(latest) {
+		end := schedule.NextDigestBoundary(start)
+		if end.After(latest) {
+			end = latest
+		}
+
+		if err := send(start, end); err != nil {
+			ctx.Logger.Error("send digest", attr, slog.Time("window_start", start), slog.Any("error", err))
+			return
+		}
+		if !record(end) {
+			return
+		}
+		start = end
+	}
+}
+
+// sendWindow emails the summary for [start, end). Empty windows send nothing.
+func (d *DigestDispatcher) sendWindow(ctx *JobContext, db *gorm.DB, delivery *forms.EmailDelivery, start, end time.Time) error {
+	submissions, err := forms.GetSubmissionsBetween(db, delivery.FormID, start, end)
+	if err != nil {
+		return err
+	}
+	if len(submissions) == 0 {
+		return nil
+	}
+
+	profile, from, to := resolveProfileRecipients(db, delivery)
+	if profile == nil || from == "" || to == "" {
+		return fmt.Errorf("mailer configuration missing")
+	}
+
+	label := "Daily"
+	if delivery.DigestMode == forms.DigestModeWeekly {
+		label = "Weekly"
+	}
+	subject := fmt.Sprintf("%s digest · %s (%d submissions)", label, delivery.Form.Name, len(submissions))
+	body := d.renderDigestBody(delivery, submissions, start, end)
+
+	return sendWithProfile(ctx, d.http, profile, from, to, subject, body)
+}
+
+// recordDigest persists the end of the last processed window.
+func (d *DigestDispatcher) recordDigest(ctx *JobContext, db *gorm.DB, delivery *forms.EmailDelivery, end time.Time) bool {
+	err := dbtxn.WithRetry(ctx.Logger, db, func(tx *gorm.DB) error {
+		return tx.Model(&forms.EmailDelivery{}).
+			Where("id = ?", delivery.ID).
+			Update("last_digest_at", end).Error
+	})
+	if err != nil {
+		ctx.Logger.Error("update digest window", slog.Uint64("id", uint64(delivery.ID)), slog.Any("error", err))
+		return false
+	}
+	delivery.LastDigestAt = &end
+	return true
+}
+
+func (d *DigestDispatcher) renderDigestBody(delivery *forms.EmailDelivery, submissions []forms.Submission, start, end time.Time) string {
+	loc := delivery.DigestLocation()
+	const layout = "2006-01-02 15:04"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d new submissions\n", delivery.Form.Name, len(submissions))
+	fmt.Fprintf(&b, "Period: %s to %s (%s)\n", start.In(loc).Format(layout), end.In(loc).Format(layout), loc.String())
+	d.writeDigestItems(&b, submissions, loc)
+	fmt.Fprintf(&b, "View all submissions: %s\n", d.cfg.AbsoluteURL(fmt.Sprintf("/admin/forms/%d", delivery.FormID)))
+	return b.String()
+}
+
+// sendAllFormsWindow emails the all-forms summary for [start, end). Empty
+// windows send nothing.
+func (d *DigestDispatcher) sendAllFormsWindow(ctx *JobContext, db *gorm.DB, digest *forms.AllFormsDigest, start, end time.Time) error {
+	submissions, err := forms.GetAllSubmissionsBetween(db, start, end)
+	if err != nil {
+		return err
+	}
+	if len(submissions) == 0 {
+		return nil
+	}
+
+	profile, from, _ := resolveProfileRecipients(db, &forms.EmailDelivery{MailerProfileID: digest.MailerProfileID})
+	if profile == nil || from == "" || digest.Recipient == "" {
+		return fmt.Errorf("mailer configuration missing")
+	}
+
+	label := "Daily"
+	if digest.DigestMode == forms.DigestModeWeekly {
+		label = "Weekly"
+	}
+	subject := fmt.Sprintf("%s digest · All forms (%d submissions)", label, len(submissions))
+	body := d.renderAllFormsDigestBody(digest, submissions, start, end)
+
+	return sendWithProfile(ctx, d.http, profile, from, digest.Recipient, subject, body)
+}
+
+// recordAllFormsDigest persists the end of the last processed all-forms window.
+func (d *DigestDispatcher) recordAllFormsDigest(ctx *JobContext, db *gorm.DB, digest *forms.AllFormsDigest, end time.Time) bool {
+	err := dbtxn.WithRetry(ctx.Logger, db, func(tx *gorm.DB) error {
+		return tx.Model(&forms.AllFormsDigest{}).
+			Where("id = ?", digest.ID).
+			Update("last_digest_at", end).Error
+	})
+	if err != nil {
+		ctx.Logger.Error("update all-forms digest window", slog.Any("error", err))
+		return false
+	}
+	digest.LastDigestAt = &end
+	return true
+}
+
+// renderAllFormsDigestBody summarizes each form that received submissions,
+// in the same shape as a single form's digest.
+func (d *DigestDispatcher) renderAllFormsDigestBody(digest *forms.AllFormsDigest, submissions []forms.Submission, start, end time.Time) string {
+	loc := digest.Schedule().DigestLocation()
+	const layout = "2006-01-02 15:04"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "All forms: %d new submissions\n", len(submissions))
+	fmt.Fprintf(&b, "Period: %s to %s (%s)\n", start.In(loc).Format(layout), end.In(loc).Format(layout), loc.String())
+
+	// Submissions arrive ordered by form, so each run of one form is a section.
+	for i := 0; i < len(submissions); {
+		j := i
+		for j < len(submissions) && submissions[j].FormID == submissions[i].FormID {
+			j++
+		}
+		group := submissions[i:j]
+		name := fmt.Sprintf("Form #%d", group[0].FormID)
+		if group[0].Form != nil {
+			name = group[0].Form.Name
+		}
+		fmt.Fprintf(&b, "\n%s: %d new submissions\n", name, len(group))
+		d.writeDigestItems(&b, group, loc)
+		fmt.Fprintf(&b, "View all submissions: %s\n", d.cfg.AbsoluteURL(fmt.Sprintf("/admin/forms/%d", group[0].FormID)))
+		i = j
+	}
+	return b.String()
+}
+
+// writeDigestItems writes the counts of held-back submissions and a line per
+// forwarded one, capped at digestMaxListed.
+func (d *DigestDispatcher) writeDigestItems(b *strings.Builder, submissions []forms.Submission, loc *time.Location) {
+	const layout = "2006-01-02 15:04"
+
+	spam, duplicates, unconfirmed := 0, 0, 0
+	for _, sub := range submissions {
+		switch {
+		case sub.IsSpam:
+			spam++
+		case sub.IsDuplicate:
+			duplicates++
+		case sub.AwaitingConfirmation():
+			unconfirmed++
+		}
+	}
+	if spam > 0 {
+		fmt.Fprintf(b, "Flagged as spam: %d\n", spam)
+	}
+	if duplicates > 0 {
+		fmt.Fprintf(b, "Duplicates (not forwarded): %d\n", duplicates)
+	}
+	if unconfirmed > 0 {
+		fmt.Fprintf(b, "Awaiting confirmation: %d\n", unconfirmed)
+	}
+	b.WriteString("\n")
+
+	listed := 0
+	for _, sub := range submissions {
+		if sub.IsSpam || sub.IsDuplicate || sub.AwaitingConfirmation() {
+			continue
+		}
+		if listed == digestMaxListed {
+			break
+		}
+		listed++
+		fmt.Fprintf(b, "%s  %s\n", sub.CreatedAt.In(loc).Format(layout), summarizeSubmission(sub.DataJSON))
+		fmt.Fprintf(b, "  %s\n", d.cfg.AbsoluteURL(fmt.Sprintf("/admin/submissions/%d", sub.ID)))
+	}
+	if remaining := len(submissions) - spam - duplicates - unconfirmed - listed; remaining > 0 {
+		fmt.Fprintf(b, "\n...and %d more\n", remaining)
+	}
+	b.WriteString("\n")
+}
+
+// summarizeSubmission renders a one-line preview of a submission payload.
+func summarizeSubmission(dataJSON string) string {
+	const maxLen = 120
+	summary := strings.Join(strings.Fields(dataJSON), " ")
+	if runes := []rune(summary); len(runes) > maxLen {
+		return string(runes[:maxLen]) + "…"
+	}
+	return summary
+}
@@ -0,0 +1,295 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/config"
+	"formlander/internal/forms"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestDispatcher(t *testing.T) {
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	ctx := func(t *testing.T) *JobContext {
+		return &JobContext{
+			Context: context.Background(),
+			Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+			DB:      testsupport.SetupTestDB(t),
+		}
+	}
+
+	t.Run("sends summary for the closed window and advances it", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+		host, port, captured := startFakeSMTPServer(t)
+
+		profile := &integrations.MailerProfile{
+			Name:             "SMTP relay",
+			Provider:         "smtp",
+			DefaultFromEmail: "forms@example.com",
+			SMTPHost:         host,
+			SMTPPort:         port,
+			SMTPEncryption:   "none",
+		}
+		require.NoError(t, db.Create(profile).Error)
+
+		form := &forms.Form{Name: "Contact", AllowedOrigins: "*"}
+		require.NoError(t, db.Create(form).Error)
+
+		pid := profile.ID
+		last := time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC)
+		delivery := &forms.EmailDelivery{
+			FormID:          form.ID,
+			Enabled:         true,
+			MailerProfileID: &pid,
+			OverridesJSON:   `{"to":"owner@example.com"}`,
+			DigestMode:      forms.DigestModeDaily,
+			DigestHour:      9,
+			LastDigestAt:    &last,
+		}
+		require.NoError(t, db.Create(delivery).Error)
+
+		inWindow := &forms.Submission{FormID: form.ID, DataJSON: `{"name":"Alice"}`, CreatedAt: last.Add(2 * time.Hour)}
+		spam := &forms.Submission{FormID: form.ID, DataJSON: `{"name":"Bot"}`, IsSpam: true, CreatedAt: last.Add(3 * time.Hour)}
+		afterWindow := &forms.Submission{FormID: form.ID, DataJSON: `{"name":"Later"}`, CreatedAt: now.Add(-30 * time.Minute)}
+		require.NoError(t, db.Create(inWindow).Error)
+		require.NoError(t, db.Create(spam).Error)
+		require.NoError(t, db.Create(afterWindow).Error)
+
+		d := NewDigestDispatcher(&config.Config{BaseURL: "https://forms.example.com/"})
+		d.now = func() time.Time { return now }
+		require.NoError(t, d.ProcessBatch(jc))
+
+		var updated forms.EmailDelivery
+		require.NoError(t, db.First(&updated, delivery.ID).Error)
+		require.NotNil(t, updated.LastDigestAt)
+		assert.True(t, updated.LastDigestAt.Equal(time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)))
+
+		captured.mu.Lock()
+		defer captured.mu.Unlock()
+		assert.Contains(t, captured.to, "owner@example.com")
+		assert.Contains(t, captured.data, "Subject: Daily digest")
+		assert.Contains(t, captured.data, "Flagged as spam: 1")
+		assert.Contains(t, captured.data, "Alice")
+		assert.Contains(t, captured.data, "https://forms.example.com/admin/submissions/")
+		assert.NotContains(t, captured.data, "Later")
+	})
+
+	t.Run("first run starts at the latest boundary without sending", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+
+		form := &forms.Form{Name: "Fresh", AllowedOrigins: "*"}
+		require.NoError(t, db.Create(form).Error)
+		delivery := &forms.EmailDelivery{FormID: form.ID, Enabled: true, DigestMode: forms.DigestModeWeekly, DigestHour: 9, DigestWeekday: 1}
+		require.NoError(t, db.Create(delivery).Error)
+
+		d := NewDigestDispatcher(&config.Config{})
+		d.now = func() time.Time { return now }
+		require.NoError(t, d.ProcessBatch(jc))
+
+		var updated forms.EmailDelivery
+		require.NoError(t, db.First(&updated, delivery.ID).Error)
+		require.NotNil(t, updated.LastDigestAt)
+		assert.True(t, updated.LastDigestAt.Equal(time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("form created in digest mode includes submissions before the first send", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+		host, port, captured := startFakeSMTPServer(t)
+
+		profile := &integrations.MailerProfile{
+			Name:             "SMTP relay",
+			Provider:         "smtp",
+			DefaultFromEmail: "forms@example.com",
+			SMTPHost:         host,
+			SMTPPort:         port,
+			SMTPEncryption:   "none",
+		}
+		require.NoError(t, db.Create(profile).Error)
+
+		form, err := forms.Create(jc.Logger, db, forms.CreateParams{
+			Name:            "Early",
+			Slug:            "early",
+			AllowedOrigins:  "*",
+			EmailEnabled:    true,
+			MailerProfileID: &profile.ID,
+			EmailRecipient:  "owner@example.com",
+			DigestMode:      forms.DigestModeDaily,
+			DigestHour:      9,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, form.EmailDelivery.LastDigestAt)
+
+		_, err = forms.CreateSubmission(jc.Logger, db, form, map[string]any{"name": "First"}, "test")
+		require.NoError(t, err)
+
+		d := NewDigestDispatcher(&config.Config{})
+		d.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+		require.NoError(t, d.ProcessBatch(jc))
+
+		captured.mu.Lock()
+		defer captured.mu.Unlock()
+		assert.Contains(t, captured.data, "Subject: Daily digest")
+		assert.Contains(t, captured.data, "First")
+	})
+
+	t.Run("submission confirmed after its window goes out in the next digest", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+		host, port, captured := startFakeSMTPServer(t)
+
+		profile := &integrations.MailerProfile{
+			Name:             "SMTP relay",
+			Provider:         "smtp",
+			DefaultFromEmail: "forms@example.com",
+			SMTPHost:         host,
+			SMTPPort:         port,
+			SMTPEncryption:   "none",
+		}
+		require.NoError(t, db.Create(profile).Error)
+
+		form := &forms.Form{Name: "Newsletter", AllowedOrigins: "*", DoubleOptIn: true}
+		require.NoError(t, db.Create(form).Error)
+		pid := profile.ID
+		last := time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC)
+		delivery := &forms.EmailDelivery{
+			FormID:          form.ID,
+			Enabled:         true,
+			MailerProfileID: &pid,
+			OverridesJSON:   `{"to":"owner@example.com"}`,
+			DigestMode:      forms.DigestModeDaily,
+			DigestHour:      9,
+			LastDigestAt:    &last,
+		}
+		require.NoError(t, db.Create(delivery).Error)
+
+		pending := &forms.Submission{
+			FormID:             form.ID,
+			DataJSON:           `{"email":"late@example.com"}`,
+			ConfirmationStatus: forms.ConfirmationPending,
+			CreatedAt:          last.Add(2 * time.Hour),
+		}
+		require.NoError(t, db.Create(pending).Error)
+
+		d := NewDigestDispatcher(&config.Config{})
+		d.now = func() time.Time { return now }
+		require.NoError(t, d.ProcessBatch(jc))
+		captured.mu.Lock()
+		assert.Contains(t, captured.data, "Awaiting confirmation: 1")
+		captured.mu.Unlock()
+
+		_, _, err := forms.ConfirmSubmission(jc.Logger, db, pending.ID, now.Add(2*time.Hour))
+		require.NoError(t, err)
+
+		// The fake server takes one connection, so the next digest needs another.
+		host, port, captured = startFakeSMTPServer(t)
+		require.NoError(t, db.Model(profile).Updates(map[string]any{"smtp_host": host, "smtp_port": port}).Error)
+
+		d.now = func() time.Time { return now.Add(24 * time.Hour) }
+		require.NoError(t, d.ProcessBatch(jc))
+
+		captured.mu.Lock()
+		defer captured.mu.Unlock()
+		assert.Contains(t, captured.data, "Newsletter: 1 new submissions")
+		assert.Contains(t, captured.data, "late@example.com")
+		assert.NotContains(t, captured.data, "Awaiting confirmation")
+	})
+
+	t.Run("all-forms digest summarizes every form", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+		host, port, captured := startFakeSMTPServer(t)
+
+		profile := &integrations.MailerProfile{
+			Name:             "SMTP relay",
+			Provider:         "smtp",
+			DefaultFromEmail: "forms@example.com",
+			SMTPHost:         host,
+			SMTPPort:         port,
+			SMTPEncryption:   "none",
+		}
+		require.NoError(t, db.Create(profile).Error)
+
+		contact := &forms.Form{Name: "Contact", AllowedOrigins: "*"}
+		signup := &forms.Form{Name: "Signup", AllowedOrigins: "*"}
+		require.NoError(t, db.Create(contact).Error)
+		require.NoError(t, db.Create(signup).Error)
+
+		pid := profile.ID
+		last := time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC)
+		require.NoError(t, db.Create(&forms.AllFormsDigest{
+			MailerProfileID: &pid,
+			Recipient:       "admin@example.com",
+			DigestMode:      forms.DigestModeDaily,
+			DigestHour:      9,
+			LastDigestAt:    &last,
+		}).Error)
+
+		require.NoError(t, db.Create(&forms.Submission{FormID: contact.ID, DataJSON: `{"name":"Alice"}`, CreatedAt: last.Add(time.Hour)}).Error)
+		require.NoError(t, db.Create(&forms.Submission{FormID: signup.ID, DataJSON: `{"name":"Bob"}`, CreatedAt: last.Add(2 * time.Hour)}).Error)
+
+		d := NewDigestDispatcher(&config.Config{})
+		d.now = func() time.Time { return now }
+		require.NoError(t, d.ProcessBatch(jc))
+
+		digest, err := forms.GetAllFormsDigest(db)
+		require.NoError(t, err)
+		assert.True(t, digest.LastDigestAt.Equal(time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)))
+
+		captured.mu.Lock()
+		defer captured.mu.Unlock()
+		assert.Contains(t, captured.to, "admin@example.com")
+		assert.Contains(t, captured.data, "Subject: Daily digest · All forms (2 submissions)")
+		assert.Contains(t, captured.data, "Contact: 1 new submissions")
+		assert.Contains(t, captured.data, "Signup: 1 new submissions")
+		assert.Contains(t, captured.data, "Alice")
+		assert.Contains(t, captured.data, "Bob")
+	})
+
+	t.Run("keeps window open when sending fails", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+
+		form := &forms.Form{Name: "Broken", AllowedOrigins: "*"}
+		require.NoError(t, db.Create(form).Error)
+		last := time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC)
+		delivery := &forms.EmailDelivery{FormID: form.ID, Enabled: true, DigestMode: forms.DigestModeDaily, DigestHour: 9, LastDigestAt: &last}
+		require.NoError(t, db.Create(delivery).Error)
+		require.NoError(t, db.Create(&forms.Submission{FormID: form.ID, DataJSON: `{}`, CreatedAt: last.Add(time.Hour)}).Error)
+
+		d := NewDigestDispatcher(&config.Config{})
+		d.now = func() time.Time { return now }
+		require.NoError(t, d.ProcessBatch(jc))
+
+		var updated forms.EmailDelivery
+		require.NoError(t, db.First(&updated, delivery.ID).Error)
+		assert.True(t, updated.LastDigestAt.Equal(last), "window should not advance without a mailer")
+	})
+}
+
+func TestCreateSubmissionSkipsEmailEventForDigest(t *testing.T) {
+	db := testsupport.SetupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	form := &forms.Form{Name: "Digest", AllowedOrigins: "*"}
+	require.NoError(t, db.Create(form).Error)
+	require.NoError(t, db.Create(&forms.EmailDelivery{FormID: form.ID, Enabled: true, DigestMode: forms.DigestModeDaily}).Error)
+	require.NoError(t, db.Preload("EmailDelivery").First(form, form.ID).Error)
+
+	_, err := forms.CreateSubmission(logger, db, form, map[string]any{"name": "Alice"}, "test")
+	require.NoError(t, err)
+
+	var count int64
+	require.NoError(t, db.Model(&forms.EmailEvent{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
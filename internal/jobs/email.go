@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -71,12 +72,21 @@ func (d *EmailDispatcher) handleEvent(ctx *JobContext, db *gorm.DB, event *forms
 
 	form := event.Submission.Form
 	emailDelivery := form.EmailDelivery
-	if emailDelivery == nil || !emailDelivery.Enabled {
+	// Routing rule emails go out through the form's mailer profile even when
+	// forwarding to the form's own recipient is turned off.
+	if event.Recipient == "" && (emailDelivery == nil || !emailDelivery.Enabled) {
 		MarkEmailAsFinal(ctx, db, event, forms.WebhookStatusFailed, "email forwarding disabled")
 		return
 	}
+	if emailDelivery == nil {
+		MarkEmailAsFinal(ctx, db, event, forms.WebhookStatusFailed, "mailer configuration missing")
+		return
+	}
 
 	profile, from, to := resolveProfileRecipients(db, emailDelivery)
+	if event.Recipient != "" {
+		to = event.Recipient
+	}
 	if profile == nil || from == "" || to == "" {
 		MarkEmailAsFinal(ctx, db, event, forms.WebhookStatusFailed, "mailer configuration missing")
 		return
@@ -85,24 +95,11 @@ func (d *EmailDispatcher) handleEvent(ctx *JobContext, db *gorm.DB, event *forms
 	subject := fmt.Sprintf("New submission · %s", event.Submission.Form.Name)
 	body := bodyForEvent(event)
 
-	var sendErr error
-	switch profile.Provider {
-	case "mailgun":
-		if profile.APIKey == "" || profile.Domain == "" {
-			MarkEmailAsFinal(ctx, db, event, forms.WebhookStatusFailed, "mailgun configuration missing")
+	if sendErr := sendWithProfile(ctx, d.http, profile, from, to, subject, body); sendErr != nil {
+		if errors.Is(sendErr, errMailerConfigMissing) {
+			MarkEmailAsFinal(ctx, db, event, forms.WebhookStatusFailed, sendErr.Error())
 			return
 		}
-		sendErr = d.sendMailgun(ctx, profile, from, to, subject, body)
-	default: // smtp is the default provider
-		cfg := smtpConfigFromProfile(profile, from, to)
-		if cfg == nil {
-			MarkEmailAsFinal(ctx, db, event, forms.WebhookStatusFailed, "smtp configuration missing")
-			return
-		}
-		sendErr = sendSMTP(cfg, buildSMTPMessage(from, to, subject, body))
-	}
-
-	if sendErr != nil {
 		MarkEmailAsRetry(ctx, db, event, d.retry, sendErr)
 		return
 	}
@@ -161,13 +158,44 @@ func smtpConfigFromProfile(profile *integrations.MailerProfile, from, to string)
 	}
 }
 
+// errMailerConfigMissing marks provider settings that are incomplete; retrying
+// cannot succeed until the profile is fixed.
+var errMailerConfigMissing = errors.New("configuration missing")
+
+// sendWithProfile delivers one plain-text message through the profile's
+// provider. Incomplete provider settings wrap errMailerConfigMissing.
+func sendWithProfile(ctx *JobContext, client *http.Client, profile *integrations.MailerProfile, from, to, subject, body string) error {
+	return sendWithHeaders(ctx, client, profile, from, to, subject, body, nil)
+}
+
+// sendWithHeaders is sendWithProfile with extra message headers, such as the
+// threading headers of a reply.
+func sendWithHeaders(ctx *JobContext, client *http.Client, profile *integrations.MailerProfile, from, to, subject, body string, headers []mailHeader) error {
+	switch profile.Provider {
+	case "mailgun":
+		if profile.APIKey == "" || profile.Domain == "" {
+			return fmt.Errorf("mailgun %w", errMailerConfigMissing)
+		}
+		return sendMailgun(ctx, client, profile, from, to, subject, body, headers)
+	default: // smtp is the default provider
+		cfg := smtpConfigFromProfile(profile, from, to)
+		if cfg == nil {
+			return fmt.Errorf("smtp %w", errMailerConfigMissing)
+		}
+		return sendSMTP(cfg, buildSMTPMessage(from, to, subject, body, headers...))
+	}
+}
+
 // sendMailgun delivers the message through the Mailgun HTTP API.
-func (d *EmailDispatcher) sendMailgun(ctx *JobContext, profile *integrations.MailerProfile, from, to, subject, body string) error {
+func sendMailgun(ctx *JobContext, client *http.Client, profile *integrations.MailerProfile, from, to, subject, body string, headers []mailHeader) error {
 	values := url.Values{}
 	values.Set("from", from)
 	values.Set("to", to)
 	values.Set("subject", subject)
 	values.Set("text", body)
+	for _, h := range headers {
+		values.Set("h:"+h.Name, h.Value)
+	}
 
 	endpoint := fmt.Sprintf("https://api.mailgun.net/v3/%s/messages", profile.Domain)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(values.Encode()))
@@ -178,7 +206,7 @@ func (d *EmailDispatcher) sendMailgun(ctx *JobContext, profile *integrations.Mai
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("User-Agent", "Formlander/1.0")
 
-	resp, err := d.http.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -207,25 +235,23 @@ func (d *EmailDispatcher) markEmailDelivered(ctx *JobContext, db *gorm.DB, event
 }
 
 func bodyForEvent(event *forms.EmailEvent) string {
-	var payload map[string]any
-	if err := json.Unmarshal([]byte(event.Submission.DataJSON), &payload); err != nil {
-		payload = map[string]any{"raw": event.Submission.DataJSON}
-	}
-	return renderEmailBody(payload)
+	return renderEmailBody(forms.FlattenDataJSON(event.Submission.DataJSON))
 }
 
-func renderEmailBody(payload map[string]any) string {
-	lines := make([]string, 0, len(payload)*2+2)
+// renderEmailBody lists each submitted value on its own line. Nested fields
+// are addressed by path (address.street, items[0].qty).
+func renderEmailBody(fields []forms.FlatField) string {
+	lines := make([]string, 0, len(fields)+2)
 	lines = append(lines, "New submission received:")
 	lines = append(lines, "")
 
-	for key, value := range payload {
-		serialized, err := json.MarshalIndent(value, "", "  ")
-		if err != nil {
-			serialized = []byte(fmt.Sprintf("%v", value))
+	for _, field := range fields {
+		value := field.Value
+		if strings.Contains(value, "\n") {
+			// Multi-line values start on their own line, indented.
+			value = "\n  " + strings.ReplaceAll(value, "\n", "\n  ")
 		}
-		lines = append(lines, fmt.Sprintf("%s:\n%s", key, string(serialized)))
-		lines = append(lines, "")
+		lines = append(lines, fmt.Sprintf("%s: %s", field.Path, value))
 	}
 
 	return strings.Join(lines, "\n")
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/config"
+)
+
+// invitationBatchSize caps how many invitation emails go out per tick.
+const invitationBatchSize = 10
+
+// InvitationDispatcher emails invite links to new admin users.
+type InvitationDispatcher struct {
+	cfg  *config.Config
+	http *http.Client
+	now  func() time.Time
+}
+
+// NewInvitationDispatcher constructs a dispatcher for invitation emails.
+func NewInvitationDispatcher(cfg *config.Config) *InvitationDispatcher {
+	return &InvitationDispatcher{
+		cfg:  cfg,
+		http: &http.Client{Timeout: 15 * time.Second},
+		now:  time.Now,
+	}
+}
+
+// ProcessBatch implements the Processor interface.
+func (d *InvitationDispatcher) ProcessBatch(ctx *JobContext) error {
+	db := ctx.DB
+	now := d.now().UTC()
+
+	invitations, err := accounts.UnsentInvitations(db, now, invitationBatchSize)
+	if err != nil {
+		ctx.Logger.Error("query unsent invitations", slog.Any("error", err))
+		return err
+	}
+	for i := range invitations {
+		d.handleInvitation(ctx, db, &invitations[i], now)
+	}
+	return nil
+}
+
+// handleInvitation sends one invite link. Configuration problems and send
+// failures are recorded on the invitation, which the inviter can resend.
+func (d *InvitationDispatcher) handleInvitation(ctx *JobContext, db *gorm.DB, invitation *accounts.Invitation, now time.Time) {
+	record := func(reason string) {
+		if reason != "" {
+			ctx.Logger.Error("invitation email not sent",
+				slog.Uint64("invitation_id", uint64(invitation.ID)),
+				slog.String("reason", reason))
+		}
+		if err := accounts.RecordInvitationSent(ctx.Logger, db, invitation.ID, now, reason); err != nil {
+			ctx.Logger.Error("record invitation", slog.Uint64("invitation_id", uint64(invitation.ID)), slog.Any("error", err))
+		}
+	}
+
+	if strings.TrimSpace(d.cfg.BaseURL) == "" {
+		record("FORMLANDER_BASE_URL is not set, so no invite link can be built")
+		return
+	}
+	profile := invitation.MailerProfile
+	if profile == nil || profile.DefaultFromEmail == "" {
+		record("mailer configuration missing")
+		return
+	}
+
+	from := profile.DefaultFromEmail
+	if profile.DefaultFromName != "" {
+		from = fmt.Sprintf("%s <%s>", profile.DefaultFromName, profile.DefaultFromEmail)
+	}
+	link := d.cfg.AbsoluteURL("/invite/" + accounts.InvitationToken(d.cfg.SessionSecret, invitation))
+	body := renderInvitationBody(link, invitation.ExpiresAt)
+
+	err := sendWithProfile(ctx, d.http, profile, from, invitation.Email, "You're invited to Formlander", body)
+	record(TruncateError(err))
+}
+
+func renderInvitationBody(link string, expires time.Time) string {
+	var b strings.Builder
+	b.WriteString("You've been invited to manage forms on Formlander.\n\n")
+	b.WriteString("Open this link to choose a password and sign in:\n")
+	b.WriteString(link)
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "The link expires on %s UTC. If you weren't expecting this, ignore this email.\n", expires.UTC().Format("Jan 2, 2006 15:04"))
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/accounts"
+	"formlander/internal/config"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	cartridgeconfig "github.com/karloscodes/cartridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvitationDispatcher(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	db := jc.DB
+	host, port, captured := startFakeSMTPServer(t)
+
+	profile := &integrations.MailerProfile{
+		Name:             "Team",
+		Provider:         "smtp",
+		DefaultFromName:  "Formlander",
+		DefaultFromEmail: "team@example.com",
+		SMTPHost:         host,
+		SMTPPort:         port,
+		SMTPEncryption:   "none",
+	}
+	require.NoError(t, db.Create(profile).Error)
+	admin := &accounts.User{Email: "admin@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(admin).Error)
+	invitation, err := accounts.InviteUser(jc.Logger, db, admin.ID, "new@example.com", accounts.RoleEditor, profile.ID, now)
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Config:  &cartridgeconfig.Config{SessionSecret: "secret"},
+		BaseURL: "https://forms.example.com",
+	}
+	d := NewInvitationDispatcher(cfg)
+	d.now = func() time.Time { return now }
+	require.NoError(t, d.ProcessBatch(jc))
+
+	var stored accounts.Invitation
+	require.NoError(t, db.First(&stored, invitation.ID).Error)
+	require.NotNil(t, stored.SentAt)
+	assert.Empty(t, stored.SendError)
+	assert.Equal(t, "Sent", stored.Status())
+
+	captured.mu.Lock()
+	assert.Contains(t, captured.to, "new@example.com")
+	assert.Contains(t, captured.data, "From: Formlander <team@example.com>")
+	assert.Contains(t, captured.data, "https://forms.example.com/invite/"+accounts.InvitationToken(cfg.SessionSecret, invitation))
+	captured.mu.Unlock()
+
+	unsent, err := accounts.UnsentInvitations(db, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, unsent, "each invitation is sent once")
+}
+
+func TestInvitationDispatcherWithoutBaseURL(t *testing.T) {
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	db := jc.DB
+
+	profile := &integrations.MailerProfile{Name: "Team", Provider: "smtp", DefaultFromEmail: "team@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+	invitation, err := accounts.InviteUser(jc.Logger, db, 1, "new@example.com", accounts.RoleEditor, profile.ID, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, NewInvitationDispatcher(&config.Config{}).ProcessBatch(jc))
+
+	var stored accounts.Invitation
+	require.NoError(t, db.First(&stored, invitation.ID).Error)
+	assert.Contains(t, stored.SendError, "FORMLANDER_BASE_URL")
+	assert.Nil(t, stored.SentAt)
+	assert.Equal(t, "Not sent", stored.Status())
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/config"
+	"formlander/internal/forms"
+)
+
+// optInBatchSize caps how many confirmation emails go out per tick.
+const optInBatchSize = 10
+
+// OptInDispatcher sends double opt-in confirmation emails and expires
+// submissions that were never confirmed.
+type OptInDispatcher struct {
+	cfg  *config.Config
+	http *http.Client
+	now  func() time.Time
+}
+
+// NewOptInDispatcher constructs a dispatcher for confirmation emails.
+func NewOptInDispatcher(cfg *config.Config) *OptInDispatcher {
+	return &OptInDispatcher{
+		cfg:  cfg,
+		http: &http.Client{Timeout: 15 * time.Second},
+		now:  time.Now,
+	}
+}
+
+// ProcessBatch implements the Processor interface.
+func (d *OptInDispatcher) ProcessBatch(ctx *JobContext) error {
+	db := ctx.DB
+	now := d.now().UTC()
+
+	expired, err := forms.ExpireUnconfirmed(ctx.Logger, db, now)
+	if err != nil {
+		ctx.Logger.Error("expire unconfirmed submissions", slog.Any("error", err))
+		return err
+	}
+	if expired > 0 {
+		ctx.Logger.Info("unconfirmed submissions expired", slog.Int64("count", expired))
+	}
+
+	pending, err := forms.PendingConfirmations(db, optInBatchSize)
+	if err != nil {
+		ctx.Logger.Error("query pending confirmations", slog.Any("error", err))
+		return err
+	}
+	for i := range pending {
+		d.handleSubmission(ctx, db, &pending[i], now)
+	}
+	return nil
+}
+
+// handleSubmission emails one confirmation link. Configuration problems are
+// recorded on the submission so it isn't retried every tick; transient send
+// failures are retried on the next run until the link would expire.
+func (d *OptInDispatcher) handleSubmission(ctx *JobContext, db *gorm.DB, sub *forms.Submission, now time.Time) {
+	form := sub.Form
+	if form == nil {
+		return
+	}
+
+	fail := func(reason string) {
+		ctx.Logger.Error("confirmation email not sent",
+			slog.Uint64("submission_id", uint64(sub.ID)),
+			slog.String("reason", reason))
+		if err := forms.RecordConfirmationSent(ctx.Logger, db, sub.ID, now, reason); err != nil {
+			ctx.Logger.Error("record confirmation error", slog.Uint64("submission_id", uint64(sub.ID)), slog.Any("error", err))
+		}
+	}
+
+	if strings.TrimSpace(d.cfg.BaseURL) == "" {
+		fail("FORMLANDER_BASE_URL is not set, so no confirmation link can be built")
+		return
+	}
+	to := form.OptInAddress(sub.DataJSON)
+	if to == "" {
+		fail("submission has no valid email address")
+		return
+	}
+	if form.EmailDelivery == nil {
+		fail("mailer configuration missing")
+		return
+	}
+	profile, from, _ := resolveProfileRecipients(db, form.EmailDelivery)
+	if profile == nil || from == "" {
+		fail("mailer configuration missing")
+		return
+	}
+
+	expires := sub.CreatedAt.Add(form.OptInExpiry())
+	link := d.cfg.AbsoluteURL("/confirm/" + forms.ConfirmationToken(d.cfg.SessionSecret, sub.ID, expires))
+	body := renderConfirmationBody(form, link, expires)
+
+	if err := sendWithProfile(ctx, d.http, profile, from, to, form.OptInEmailSubject(), body); err != nil {
+		if errors.Is(err, errMailerConfigMissing) {
+			fail(err.Error())
+			return
+		}
+		ctx.Logger.Warn("send confirmation email", slog.Uint64("submission_id", uint64(sub.ID)), slog.Any("error", err))
+		return
+	}
+
+	if err := forms.RecordConfirmationSent(ctx.Logger, db, sub.ID, now, ""); err != nil {
+		ctx.Logger.Error("record confirmation sent", slog.Uint64("submission_id", uint64(sub.ID)), slog.Any("error", err))
+	}
+}
+
+func renderConfirmationBody(form *forms.Form, link string, expires time.Time) string {
+	var b strings.Builder
+	if form.OptInMessage != "" {
+		b.WriteString(form.OptInMessage)
+		b.WriteString("\n\n")
+	} else {
+		fmt.Fprintf(&b, "Thanks for signing up to %s.\n\n", form.Name)
+	}
+	b.WriteString("Please confirm by opening this link:\n")
+	b.WriteString(link)
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "The link expires on %s UTC. If you didn't submit this form, ignore this email.\n", expires.UTC().Format("Jan 2, 2006 15:04"))
+	return b.String()
+}
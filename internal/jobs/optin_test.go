@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"formlander/internal/config"
+	"formlander/internal/forms"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	cartridgeconfig "github.com/karloscodes/cartridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptInDispatcher(t *testing.T) {
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	db := jc.DB
+	host, port, captured := startFakeSMTPServer(t)
+
+	profile := &integrations.MailerProfile{
+		Name:             "SMTP relay",
+		Provider:         "smtp",
+		DefaultFromEmail: "forms@example.com",
+		SMTPHost:         host,
+		SMTPPort:         port,
+		SMTPEncryption:   "none",
+	}
+	require.NoError(t, db.Create(profile).Error)
+
+	form := &forms.Form{Name: "Newsletter", AllowedOrigins: "*", DoubleOptIn: true, OptInEmailField: "email", OptInExpiryHours: 24}
+	require.NoError(t, db.Create(form).Error)
+	pid := profile.ID
+	require.NoError(t, db.Create(&forms.EmailDelivery{FormID: form.ID, MailerProfileID: &pid}).Error)
+
+	fresh := &forms.Submission{FormID: form.ID, DataJSON: `{"email":"ada@example.com"}`, ConfirmationStatus: forms.ConfirmationPending, CreatedAt: now.Add(-time.Hour)}
+	stale := &forms.Submission{FormID: form.ID, DataJSON: `{"email":"old@example.com"}`, ConfirmationStatus: forms.ConfirmationPending, CreatedAt: now.Add(-48 * time.Hour)}
+	require.NoError(t, db.Create(fresh).Error)
+	require.NoError(t, db.Create(stale).Error)
+
+	cfg := &config.Config{
+		Config:  &cartridgeconfig.Config{SessionSecret: "secret"},
+		BaseURL: "https://forms.example.com",
+	}
+	d := NewOptInDispatcher(cfg)
+	d.now = func() time.Time { return now }
+	require.NoError(t, d.ProcessBatch(jc))
+
+	var sent, expired forms.Submission
+	require.NoError(t, db.First(&sent, fresh.ID).Error)
+	require.NoError(t, db.First(&expired, stale.ID).Error)
+	require.NotNil(t, sent.ConfirmationSentAt)
+	assert.Equal(t, forms.ConfirmationPending, sent.ConfirmationStatus)
+	assert.Equal(t, forms.ConfirmationExpired, expired.ConfirmationStatus)
+	assert.Nil(t, expired.ConfirmationSentAt, "expired submissions get no email")
+
+	token := forms.ConfirmationToken(cfg.SessionSecret, fresh.ID, fresh.CreatedAt.Add(24*time.Hour))
+	captured.mu.Lock()
+	assert.Contains(t, captured.to, "ada@example.com")
+	assert.Contains(t, captured.data, "Subject: "+forms.DefaultOptInSubject)
+	assert.Contains(t, captured.data, "https://forms.example.com/confirm/"+token)
+	captured.mu.Unlock()
+
+	pending, err := forms.PendingConfirmations(db, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "each confirmation email is sent once")
+}
+
+func TestOptInDispatcherWithoutBaseURL(t *testing.T) {
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	db := jc.DB
+
+	form := &forms.Form{Name: "Newsletter", AllowedOrigins: "*", DoubleOptIn: true}
+	require.NoError(t, db.Create(form).Error)
+	sub := &forms.Submission{FormID: form.ID, DataJSON: `{"email":"ada@example.com"}`, ConfirmationStatus: forms.ConfirmationPending}
+	require.NoError(t, db.Create(sub).Error)
+
+	require.NoError(t, NewOptInDispatcher(&config.Config{}).ProcessBatch(jc))
+
+	var stored forms.Submission
+	require.NoError(t, db.First(&stored, sub.ID).Error)
+	assert.Contains(t, stored.ConfirmationError, "FORMLANDER_BASE_URL")
+	assert.Nil(t, stored.ConfirmationSentAt)
+
+	pending, err := forms.PendingConfirmations(db, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "unsendable confirmations are not retried")
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/accounts"
+	"formlander/internal/config"
+)
+
+// passwordResetBatchSize caps how many reset emails go out per tick.
+const passwordResetBatchSize = 10
+
+// PasswordResetDispatcher emails password reset links through the system
+// mailer profile.
+type PasswordResetDispatcher struct {
+	cfg  *config.Config
+	http *http.Client
+	now  func() time.Time
+}
+
+// NewPasswordResetDispatcher constructs a dispatcher for reset emails.
+func NewPasswordResetDispatcher(cfg *config.Config) *PasswordResetDispatcher {
+	return &PasswordResetDispatcher{
+		cfg:  cfg,
+		http: &http.Client{Timeout: 15 * time.Second},
+		now:  time.Now,
+	}
+}
+
+// ProcessBatch implements the Processor interface.
+func (d *PasswordResetDispatcher) ProcessBatch(ctx *JobContext) error {
+	db := ctx.DB
+	now := d.now().UTC()
+
+	resets, err := accounts.UnsentPasswordResets(db, now, passwordResetBatchSize)
+	if err != nil {
+		ctx.Logger.Error("query unsent password resets", slog.Any("error", err))
+		return err
+	}
+	for i := range resets {
+		d.handleReset(ctx, db, &resets[i], now)
+	}
+	return nil
+}
+
+// handleReset sends one reset link. Configuration problems and send failures
+// are recorded on the reset; the user can ask for a new link.
+func (d *PasswordResetDispatcher) handleReset(ctx *JobContext, db *gorm.DB, reset *accounts.PasswordReset, now time.Time) {
+	record := func(reason string) {
+		if reason != "" {
+			ctx.Logger.Error("password reset email not sent",
+				slog.Uint64("reset_id", uint64(reset.ID)),
+				slog.String("reason", reason))
+		}
+		if err := accounts.RecordPasswordResetSent(ctx.Logger, db, reset.ID, now, reason); err != nil {
+			ctx.Logger.Error("record password reset", slog.Uint64("reset_id", uint64(reset.ID)), slog.Any("error", err))
+		}
+	}
+
+	// The link must not come from the request's Host header, which whoever
+	// asks for the reset controls.
+	if strings.TrimSpace(d.cfg.BaseURL) == "" {
+		record("FORMLANDER_BASE_URL is not set, so no reset link can be built")
+		return
+	}
+	if reset.User == nil {
+		record("user not found")
+		return
+	}
+	profile, err := accounts.SystemMailerProfile(db)
+	if err != nil {
+		record(TruncateError(err))
+		return
+	}
+	if profile.DefaultFromEmail == "" {
+		record("mailer configuration missing")
+		return
+	}
+
+	from := profile.DefaultFromEmail
+	if profile.DefaultFromName != "" {
+		from = fmt.Sprintf("%s <%s>", profile.DefaultFromName, profile.DefaultFromEmail)
+	}
+	link := d.cfg.AbsoluteURL("/admin/password/reset/" + accounts.PasswordResetToken(d.cfg.SessionSecret, reset))
+	body := renderPasswordResetBody(link, reset.ExpiresAt)
+
+	err = sendWithProfile(ctx, d.http, profile, from, reset.User.Email, "Reset your Formlander password", body)
+	record(TruncateError(err))
+}
+
+func renderPasswordResetBody(link string, expires time.Time) string {
+	var b strings.Builder
+	b.WriteString("Someone asked to reset the password of your Formlander account.\n\n")
+	b.WriteString("Open this link to choose a new password:\n")
+	b.WriteString(link)
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "The link works once and expires on %s UTC. If you didn't ask for this, ignore this email; your password stays the same.\n", expires.UTC().Format("Jan 2, 2006 15:04"))
+	return b.String()
+}
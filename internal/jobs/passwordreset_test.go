@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	cartridgeconfig "github.com/karloscodes/cartridge/config"
+
+	"formlander/internal/accounts"
+	"formlander/internal/config"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordResetDispatcher(t *testing.T) {
+	ctx := func(t *testing.T) *JobContext {
+		return &JobContext{
+			Context: context.Background(),
+			Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+			DB:      testsupport.SetupTestDB(t),
+		}
+	}
+
+	t.Run("emails the link through the system mailer", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+		host, port, captured := startFakeSMTPServer(t)
+
+		profile := &integrations.MailerProfile{
+			Name:             "System",
+			Provider:         "smtp",
+			DefaultFromName:  "Formlander",
+			DefaultFromEmail: "noreply@example.com",
+			SMTPHost:         host,
+			SMTPPort:         port,
+			SMTPEncryption:   "none",
+		}
+		require.NoError(t, db.Create(profile).Error)
+		require.NoError(t, accounts.SetSystemMailerProfile(jc.Logger, db, profile.ID))
+		require.NoError(t, db.Create(&accounts.User{Email: "ada@example.com", PasswordHash: "x"}).Error)
+		cfg := &config.Config{
+			Config:  &cartridgeconfig.Config{SessionSecret: "session-secret"},
+			BaseURL: "https://forms.example.com",
+		}
+
+		reset, token, err := accounts.RequestPasswordReset(jc.Logger, db, cfg.SessionSecret, "ada@example.com", "", time.Now())
+		require.NoError(t, err)
+		require.NoError(t, NewPasswordResetDispatcher(cfg).ProcessBatch(jc))
+
+		var stored accounts.PasswordReset
+		require.NoError(t, db.First(&stored, reset.ID).Error)
+		require.NotNil(t, stored.SentAt)
+		assert.Empty(t, stored.SendError)
+
+		captured.mu.Lock()
+		assert.Contains(t, captured.to, "ada@example.com")
+		assert.Contains(t, captured.data, "From: Formlander <noreply@example.com>")
+		assert.Contains(t, captured.data, "https://forms.example.com/admin/password/reset/"+token)
+		captured.mu.Unlock()
+
+		unsent, err := accounts.UnsentPasswordResets(db, time.Now(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, unsent, "a sent link isn't sent again")
+	})
+
+	t.Run("records why a link can't be sent", func(t *testing.T) {
+		jc := ctx(t)
+		db := jc.DB
+		require.NoError(t, db.Create(&accounts.User{Email: "ada@example.com", PasswordHash: "x"}).Error)
+
+		noMailer, _, err := accounts.RequestPasswordReset(jc.Logger, db, "", "ada@example.com", "", time.Now())
+		require.NoError(t, err)
+		require.NoError(t, NewPasswordResetDispatcher(&config.Config{BaseURL: "https://forms.example.com"}).ProcessBatch(jc))
+
+		var stored accounts.PasswordReset
+		require.NoError(t, db.First(&stored, noMailer.ID).Error)
+		assert.Equal(t, accounts.ErrNoSystemMailer.Error(), stored.SendError)
+		assert.Nil(t, stored.SentAt)
+
+		profile := &integrations.MailerProfile{Name: "System", Provider: "smtp", DefaultFromEmail: "noreply@example.com"}
+		require.NoError(t, db.Create(profile).Error)
+		require.NoError(t, accounts.SetSystemMailerProfile(jc.Logger, db, profile.ID))
+		noBaseURL, _, err := accounts.RequestPasswordReset(jc.Logger, db, "", "ada@example.com", "", time.Now())
+		require.NoError(t, err)
+		require.NoError(t, NewPasswordResetDispatcher(&config.Config{}).ProcessBatch(jc))
+
+		var unsent accounts.PasswordReset
+		require.NoError(t, db.First(&unsent, noBaseURL.ID).Error)
+		assert.Contains(t, unsent.SendError, "FORMLANDER_BASE_URL")
+		assert.Nil(t, unsent.SentAt)
+	})
+}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"formlander/internal/config"
+	"formlander/internal/forms"
+)
+
+// replyBatchSize caps how many submitter replies go out per tick.
+const replyBatchSize = 10
+
+// ReplyDispatcher sends replies composed in the admin UI to submitters.
+type ReplyDispatcher struct {
+	cfg   *config.Config
+	http  *http.Client
+	retry *RetryStrategy
+	now   func() time.Time
+}
+
+// NewReplyDispatcher constructs a dispatcher for submitter replies.
+func NewReplyDispatcher(cfg *config.Config) *ReplyDispatcher {
+	return &ReplyDispatcher{
+		cfg:   cfg,
+		http:  &http.Client{Timeout: 15 * time.Second},
+		retry: NewRetryStrategy(cfg),
+		now:   time.Now,
+	}
+}
+
+// ProcessBatch implements the Processor interface.
+func (d *ReplyDispatcher) ProcessBatch(ctx *JobContext) error {
+	db := ctx.DB
+	replies, err := forms.PendingReplies(db, d.now().UTC(), replyBatchSize)
+	if err != nil {
+		ctx.Logger.Error("query pending replies", slog.Any("error", err))
+		return err
+	}
+	for i := range replies {
+		d.handleReply(ctx, db, &replies[i])
+	}
+	return nil
+}
+
+func (d *ReplyDispatcher) handleReply(ctx *JobContext, db *gorm.DB, reply *forms.SubmissionReply) {
+	updater := NewEventUpdater(&forms.SubmissionReply{})
+	profile := reply.MailerProfile
+	if profile == nil || profile.DefaultFromEmail == "" {
+		if err := updater.Update(ctx, db, reply.ID, forms.WebhookStatusFailed, d.now(), "mailer configuration missing", WithNextAttempt(nil)); err != nil {
+			ctx.Logger.Error("finalize reply", slog.Uint64("id", uint64(reply.ID)), slog.Any("error", err))
+		}
+		return
+	}
+
+	from := profile.DefaultFromEmail
+	if profile.DefaultFromName != "" {
+		from = fmt.Sprintf("%s <%s>", profile.DefaultFromName, profile.DefaultFromEmail)
+	}
+	headers := []mailHeader{{Name: "Message-ID", Value: "<" + reply.MessageID + ">"}}
+	if inReplyTo, references := reply.ThreadHeaders(); inReplyTo != "" {
+		headers = append(headers,
+			mailHeader{Name: "In-Reply-To", Value: inReplyTo},
+			mailHeader{Name: "References", Value: references},
+		)
+	}
+
+	now := d.now()
+	attemptCount := reply.AttemptCount + 1
+	sendErr := sendWithHeaders(ctx, d.http, profile, from, reply.To, reply.Subject, reply.Body, headers)
+	if sendErr == nil {
+		sent := now.UTC()
+		if err := updater.Update(ctx, db, reply.ID, forms.WebhookStatusDelivered, now, "",
+			WithAttemptCount(attemptCount), WithNextAttempt(nil),
+			func(values map[string]any) { values["sent_at"] = sent },
+		); err != nil {
+			ctx.Logger.Error("update reply", slog.Uint64("id", uint64(reply.ID)), slog.Any("error", err))
+		}
+		return
+	}
+
+	status := forms.WebhookStatusRetrying
+	var next *time.Time
+	if errors.Is(sendErr, errMailerConfigMissing) || !d.retry.ShouldRetry(attemptCount) {
+		status = forms.WebhookStatusFailed
+	} else {
+		next = d.retry.NextRetry(attemptCount)
+	}
+	ctx.Logger.Warn("send reply", slog.Uint64("id", uint64(reply.ID)), slog.Any("error", sendErr))
+	if err := updater.Update(ctx, db, reply.ID, status, now, TruncateError(sendErr), WithAttemptCount(attemptCount), WithNextAttempt(next)); err != nil {
+		ctx.Logger.Error("update reply retry", slog.Uint64("id", uint64(reply.ID)), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"formlander/internal/accounts"
+	"formlander/internal/config"
+	"formlander/internal/forms"
+	"formlander/internal/integrations"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplyDispatcher(t *testing.T) {
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	db := jc.DB
+	host, port, captured := startFakeSMTPServer(t)
+
+	profile := &integrations.MailerProfile{
+		Name:             "Support",
+		Provider:         "smtp",
+		DefaultFromName:  "Support",
+		DefaultFromEmail: "support@example.com",
+		SMTPHost:         host,
+		SMTPPort:         port,
+		SMTPEncryption:   "none",
+	}
+	require.NoError(t, db.Create(profile).Error)
+	form := &forms.Form{Name: "Contact", Slug: "contact", AllowedOrigins: "*"}
+	require.NoError(t, db.Create(form).Error)
+	sub := &forms.Submission{FormID: form.ID, DataJSON: `{"email":"ada@example.com"}`}
+	require.NoError(t, db.Create(sub).Error)
+
+	author := &accounts.User{Email: "ops@example.com", PasswordHash: "x"}
+	params := forms.ReplyParams{MailerProfileID: profile.ID, Subject: "Re: Contact", Body: "Thanks!"}
+	first, err := forms.QueueReply(jc.Logger, db, sub.ID, author, params)
+	require.NoError(t, err)
+	// Only one message can be captured; mark the first as sent so the
+	// dispatcher picks up the follow-up.
+	require.NoError(t, db.Model(first).Update("status", forms.WebhookStatusDelivered).Error)
+	followUp, err := forms.QueueReply(jc.Logger, db, sub.ID, author, params)
+	require.NoError(t, err)
+
+	require.NoError(t, NewReplyDispatcher(&config.Config{}).ProcessBatch(jc))
+
+	var stored forms.SubmissionReply
+	require.NoError(t, db.First(&stored, followUp.ID).Error)
+	assert.Equal(t, forms.WebhookStatusDelivered, stored.Status)
+	assert.Equal(t, 1, stored.AttemptCount)
+	require.NotNil(t, stored.SentAt)
+
+	captured.mu.Lock()
+	defer captured.mu.Unlock()
+	assert.Contains(t, captured.to, "ada@example.com")
+	assert.Contains(t, captured.data, "From: Support <support@example.com>")
+	assert.Contains(t, captured.data, "Message-ID: <"+followUp.MessageID+">")
+	assert.Contains(t, captured.data, "In-Reply-To: <"+first.MessageID+">")
+	assert.Contains(t, captured.data, "References: <"+first.MessageID+">")
+}
+
+func TestReplyDispatcherWithoutProfile(t *testing.T) {
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	db := jc.DB
+
+	form := &forms.Form{Name: "Contact", Slug: "contact", AllowedOrigins: "*"}
+	require.NoError(t, db.Create(form).Error)
+	sub := &forms.Submission{FormID: form.ID, DataJSON: `{"email":"ada@example.com"}`}
+	require.NoError(t, db.Create(sub).Error)
+	reply := &forms.SubmissionReply{SubmissionID: sub.ID, To: "ada@example.com", Subject: "Re: Contact", Body: "Hi", MessageID: "x@example.com", Status: forms.WebhookStatusPending}
+	require.NoError(t, db.Create(reply).Error)
+
+	require.NoError(t, NewReplyDispatcher(&config.Config{}).ProcessBatch(jc))
+
+	var stored forms.SubmissionReply
+	require.NoError(t, db.First(&stored, reply.ID).Error)
+	assert.Equal(t, forms.WebhookStatusFailed, stored.Status)
+	assert.Equal(t, "mailer configuration missing", stored.LastAttemptErr)
+}
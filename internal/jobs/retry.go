@@ -10,6 +10,7 @@ import (
 
 	"formlander/internal/config"
 	"formlander/internal/forms"
+	"formlander/internal/live"
 	"formlander/internal/pkg/dbtxn"
 )
 
@@ -89,11 +90,47 @@ func (u *EventUpdater) Update(ctx *JobContext, db *gorm.DB, id uint, status stri
 		opt(values)
 	}
 
-	return dbtxn.WithRetry(ctx.Logger, db, func(tx *gorm.DB) error {
-		return tx.Model(u.model).
+	var submissionIDs, formIDs []uint
+	err := dbtxn.WithRetry(ctx.Logger, db, func(tx *gorm.DB) error {
+		if err := tx.Model(u.model).
 			Where("id = ?", id).
-			Updates(values).Error
+			Updates(values).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(u.model).Where("id = ?", id).Pluck("submission_id", &submissionIDs).Error; err != nil {
+			return err
+		}
+		if len(submissionIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&forms.Submission{}).Where("id = ?", submissionIDs[0]).Pluck("form_id", &formIDs).Error
 	})
+	if err != nil {
+		return err
+	}
+
+	if len(submissionIDs) > 0 && len(formIDs) > 0 {
+		live.Publish(live.Event{
+			Type:         live.DeliveryUpdated,
+			SubmissionID: submissionIDs[0],
+			FormID:       formIDs[0],
+			Delivery:     u.kind(),
+			DeliveryID:   id,
+			Status:       status,
+		})
+	}
+	return nil
+}
+
+// kind names the delivery the updater's model tracks, for live events.
+func (u *EventUpdater) kind() string {
+	switch u.model.(type) {
+	case *forms.EmailEvent:
+		return live.DeliveryEmail
+	case *forms.SubmissionReply:
+		return live.DeliveryReply
+	}
+	return live.DeliveryWebhook
 }
 
 // MarkAsRetry marks an event for retry with backoff.
@@ -1,12 +1,18 @@
 package jobs
 
 import (
+	"context"
 	"errors"
+	"io"
+	"log/slog"
 	"strings"
 	"testing"
 	"time"
 
 	"formlander/internal/config"
+	"formlander/internal/forms"
+	"formlander/internal/live"
+	"formlander/internal/pkg/testsupport"
 )
 
 func TestNewRetryStrategy(t *testing.T) {
@@ -263,6 +269,40 @@ func TestNewEventUpdater(t *testing.T) {
 	}
 }
 
+func TestEventUpdaterPublishesDelivery(t *testing.T) {
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	sub := &forms.Submission{FormID: 1, DataJSON: "{}"}
+	if err := jc.DB.Create(sub).Error; err != nil {
+		t.Fatal(err)
+	}
+	event := &forms.EmailEvent{SubmissionID: sub.ID, Status: forms.WebhookStatusPending}
+	if err := jc.DB.Create(event).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	events, cancel := live.Subscribe(0)
+	defer cancel()
+	if err := NewEventUpdater(&forms.EmailEvent{}).Update(jc, jc.DB, event.ID, forms.WebhookStatusDelivered, time.Now(), ""); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	select {
+	case e := <-events:
+		if e.Type != live.DeliveryUpdated || e.Delivery != live.DeliveryEmail {
+			t.Errorf("published %s/%s, want %s/%s", e.Type, e.Delivery, live.DeliveryUpdated, live.DeliveryEmail)
+		}
+		if e.SubmissionID != sub.ID || e.FormID != sub.FormID || e.DeliveryID != event.ID || e.Status != forms.WebhookStatusDelivered {
+			t.Errorf("published %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Update() published no event")
+	}
+}
+
 func TestRetryScheduleParsing(t *testing.T) {
 	tests := []struct {
 		name     string
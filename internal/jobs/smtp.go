@@ -95,14 +95,23 @@ func envelopeAddr(s string) string {
 	return strings.TrimSpace(s)
 }
 
+// mailHeader is an extra header added to an outgoing message.
+type mailHeader struct {
+	Name  string
+	Value string
+}
+
 // buildSMTPMessage assembles a minimal RFC 5322 plain-text email message.
 // Header and body line endings are normalized to CRLF as required by SMTP.
-func buildSMTPMessage(from, to, subject, body string) []byte {
+func buildSMTPMessage(from, to, subject, body string, headers ...mailHeader) []byte {
 	var b strings.Builder
 	b.WriteString("From: " + from + "\r\n")
 	b.WriteString("To: " + to + "\r\n")
 	b.WriteString("Subject: " + subject + "\r\n")
 	b.WriteString("Date: " + time.Now().UTC().Format(time.RFC1123Z) + "\r\n")
+	for _, h := range headers {
+		b.WriteString(h.Name + ": " + h.Value + "\r\n")
+	}
 	b.WriteString("MIME-Version: 1.0\r\n")
 	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
 	b.WriteString("\r\n")
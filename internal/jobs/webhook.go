@@ -76,10 +76,19 @@ func (d *WebhookDispatcher) handleEvent(ctx *JobContext, db *gorm.DB, event *for
 
 	form := event.Submission.Form
 	webhookDelivery := form.WebhookDelivery
-	if webhookDelivery == nil || !webhookDelivery.Enabled || webhookDelivery.URL == "" {
-		// Disable further attempts.
-		MarkWebhookAsFinal(ctx, db, event, forms.WebhookStatusFailed, "webhooks disabled for form")
-		return
+	// Routing rules set their own URL, which any editor can point anywhere,
+	// so the form's webhook secret and headers only go to the form's URL.
+	credentials := webhookDelivery
+	target := event.URL
+	if target != "" {
+		credentials = nil
+	} else {
+		if webhookDelivery == nil || !webhookDelivery.Enabled || webhookDelivery.URL == "" {
+			// Disable further attempts.
+			MarkWebhookAsFinal(ctx, db, event, forms.WebhookStatusFailed, "webhooks disabled for form")
+			return
+		}
+		target = webhookDelivery.URL
 	}
 
 	body, err := d.buildPayload(event)
@@ -88,7 +97,7 @@ func (d *WebhookDispatcher) handleEvent(ctx *JobContext, db *gorm.DB, event *for
 		return
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookDelivery.URL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
 	if err != nil {
 		MarkWebhookAsRetry(ctx, db, event, d.retry, err)
 		return
@@ -97,12 +106,12 @@ func (d *WebhookDispatcher) handleEvent(ctx *JobContext, db *gorm.DB, event *for
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Formlander/1.0")
 
-	for key, value := range d.resolveHeaders(ctx, webhookDelivery) {
+	for key, value := range d.resolveHeaders(ctx, credentials) {
 		req.Header.Set(key, value)
 	}
 
-	if webhookDelivery.Secret != "" {
-		signature := computeSignature(body, webhookDelivery.Secret)
+	if credentials != nil && credentials.Secret != "" {
+		signature := computeSignature(body, credentials.Secret)
 		req.Header.Set(d.cfg.Webhook.SignatureHeader, signature)
 	}
 
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"formlander/internal/config"
+	"formlander/internal/forms"
+	"formlander/internal/pkg/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDispatcherCredentials(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	jc := &JobContext{
+		Context: context.Background(),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:      testsupport.SetupTestDB(t),
+	}
+	db := jc.DB
+
+	form := &forms.Form{
+		Name:           "Leads",
+		AllowedOrigins: "*",
+		WebhookDelivery: &forms.WebhookDelivery{
+			Enabled:     true,
+			URL:         server.URL + "/form",
+			Secret:      "form-secret",
+			HeadersJSON: `{"Authorization":"Bearer form-token"}`,
+		},
+	}
+	require.NoError(t, db.Create(form).Error)
+	sub := &forms.Submission{FormID: form.ID, DataJSON: `{"name":"Ada"}`}
+	require.NoError(t, db.Create(sub).Error)
+
+	require.NoError(t, db.Create(forms.NewWebhookEvent(sub.ID, time.Now())).Error)
+	ruleEvent := forms.NewWebhookEvent(sub.ID, time.Now())
+	ruleEvent.URL = server.URL + "/rule"
+	require.NoError(t, db.Create(ruleEvent).Error)
+
+	cfg := &config.Config{}
+	cfg.Webhook.SignatureHeader = "X-Formlander-Signature"
+	require.NoError(t, NewWebhookDispatcher(cfg).ProcessBatch(jc))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Contains(t, received, "/form")
+	require.Contains(t, received, "/rule")
+
+	assert.Equal(t, "Bearer form-token", received["/form"].Get("Authorization"))
+	assert.NotEmpty(t, received["/form"].Get("X-Formlander-Signature"))
+
+	assert.Empty(t, received["/rule"].Get("Authorization"), "rule URLs must not receive the form's headers")
+	assert.Empty(t, received["/rule"].Get("X-Formlander-Signature"), "rule URLs must not be signed with the form's secret")
+}
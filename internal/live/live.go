@@ -0,0 +1,126 @@
+// Package live fans out submission and delivery changes to admin pages
+// subscribed over Server-Sent Events. The broker is in-process: admins see
+// the events published by the instance they are connected to.
+package live
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	SubmissionCreated = "submission.created"
+	DeliveryUpdated   = "delivery.updated"
+)
+
+// Delivery kinds of a DeliveryUpdated event.
+const (
+	DeliveryWebhook = "webhook"
+	DeliveryEmail   = "email"
+	DeliveryReply   = "reply"
+)
+
+const (
+	// replayBufferSize is how many recent events are kept for clients that
+	// reconnect with a Last-Event-ID.
+	replayBufferSize = 256
+	// subscriberBufferSize is how many events may queue for one subscriber
+	// before further events are dropped for it.
+	subscriberBufferSize = 64
+)
+
+// Event is one change pushed to subscribers.
+type Event struct {
+	ID           uint64    `json:"id"`
+	Type         string    `json:"type"`
+	SubmissionID uint      `json:"submission_id"`
+	FormID       uint      `json:"form_id,omitempty"`
+	Delivery     string    `json:"delivery,omitempty"`
+	DeliveryID   uint      `json:"delivery_id,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// Broker delivers published events to every subscriber. Slow subscribers
+// miss events rather than block publishers; pages treat events as hints to
+// refresh, so a dropped one is caught up by the next.
+type Broker struct {
+	mu     sync.Mutex
+	nextID uint64
+	recent []Event
+	subs   map[chan Event]struct{}
+}
+
+// NewBroker creates an empty broker.
+func NewBroker() *Broker {
+	return &Broker{subs: map[chan Event]struct{}{}}
+}
+
+// Publish numbers an event and sends it to the current subscribers.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	b.recent = append(b.recent, e)
+	if len(b.recent) > replayBufferSize {
+		b.recent = append([]Event(nil), b.recent[len(b.recent)-replayBufferSize:]...)
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of the events published from now on and a
+// function that ends the subscription. When lastID is set, buffered events
+// after it are replayed first, so a reconnecting client misses nothing.
+func (b *Broker) Subscribe(lastID uint64) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+
+	b.mu.Lock()
+	if lastID > 0 && lastID <= b.nextID {
+		var missed []Event
+		for _, e := range b.recent {
+			if e.ID > lastID {
+				missed = append(missed, e)
+			}
+		}
+		if len(missed) > subscriberBufferSize {
+			missed = missed[len(missed)-subscriberBufferSize:]
+		}
+		for _, e := range missed {
+			ch <- e
+		}
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+var defaultBroker = NewBroker()
+
+// Publish sends an event through the process-wide broker.
+func Publish(e Event) {
+	defaultBroker.Publish(e)
+}
+
+// Subscribe listens to the process-wide broker.
+func Subscribe(lastID uint64) (<-chan Event, func()) {
+	return defaultBroker.Subscribe(lastID)
+}
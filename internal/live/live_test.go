@@ -0,0 +1,84 @@
+package live_test
+
+import (
+	"testing"
+	"time"
+
+	"formlander/internal/live"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan live.Event) live.Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return live.Event{}
+	}
+}
+
+func TestBrokerPublishSubscribe(t *testing.T) {
+	b := live.NewBroker()
+	b.Publish(live.Event{Type: live.SubmissionCreated, SubmissionID: 1})
+
+	events, cancel := b.Subscribe(0)
+	defer cancel()
+	b.Publish(live.Event{Type: live.SubmissionCreated, SubmissionID: 2, FormID: 7})
+
+	e := receive(t, events)
+	assert.Equal(t, uint64(2), e.ID)
+	assert.Equal(t, uint(2), e.SubmissionID)
+	assert.Equal(t, uint(7), e.FormID)
+	assert.False(t, e.At.IsZero())
+	assert.Empty(t, events, "events before subscribing are not replayed without a last ID")
+
+	cancel()
+	cancel()
+	b.Publish(live.Event{Type: live.SubmissionCreated, SubmissionID: 3})
+	assert.Empty(t, events)
+}
+
+func TestBrokerReplaysAfterLastID(t *testing.T) {
+	b := live.NewBroker()
+	for i := 1; i <= 3; i++ {
+		b.Publish(live.Event{Type: live.DeliveryUpdated, SubmissionID: uint(i), Delivery: live.DeliveryWebhook})
+	}
+
+	events, cancel := b.Subscribe(1)
+	defer cancel()
+	assert.Equal(t, uint64(2), receive(t, events).ID)
+	assert.Equal(t, uint64(3), receive(t, events).ID)
+
+	t.Run("ids from another process are ignored", func(t *testing.T) {
+		events, cancel := b.Subscribe(99)
+		defer cancel()
+		assert.Empty(t, events)
+	})
+}
+
+func TestBrokerDropsEventsForSlowSubscribers(t *testing.T) {
+	b := live.NewBroker()
+	events, cancel := b.Subscribe(0)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 500; i++ {
+			b.Publish(live.Event{Type: live.SubmissionCreated, SubmissionID: uint(i)})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing blocked on a slow subscriber")
+	}
+
+	require.NotEmpty(t, events)
+	assert.Equal(t, uint64(1), receive(t, events).ID)
+	assert.Less(t, len(events), 500)
+}
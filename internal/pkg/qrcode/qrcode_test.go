@@ -0,0 +1,90 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %X, got %X", want, got)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got := formatBits(0); got != 0x5412 {
+		t.Fatalf("expected format bits 0x5412 for mask 0, got %#x", got)
+	}
+	if got := formatBits(5); got != 0x40CE {
+		t.Fatalf("expected format bits 0x40CE for mask 5, got %#x", got)
+	}
+	if got := versionBits(7); got != 0x07C94 {
+		t.Fatalf("expected version bits 0x07C94 for version 7, got %#x", got)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	t.Run("picks the smallest version that fits", func(t *testing.T) {
+		cases := []struct {
+			length int
+			size   int
+		}{
+			{1, 21},
+			{14, 21},
+			{15, 25},
+			{122, 45},
+			{123, 49},
+		}
+		for _, tc := range cases {
+			code, err := Encode(strings.Repeat("a", tc.length))
+			if err != nil {
+				t.Fatalf("encode %d bytes: %v", tc.length, err)
+			}
+			if code.Size() != tc.size {
+				t.Fatalf("expected size %d for %d bytes, got %d", tc.size, tc.length, code.Size())
+			}
+		}
+	})
+
+	t.Run("draws finder patterns in three corners", func(t *testing.T) {
+		code, err := Encode("otpauth://totp/Formlander:admin@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Formlander")
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		n := code.Size()
+		for _, corner := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+			x, y := corner[0], corner[1]
+			if !code.Dark(x, y) || !code.Dark(x+3, y+3) || code.Dark(x+1, y+1) {
+				t.Fatalf("expected finder pattern at %d,%d", x, y)
+			}
+		}
+		if !code.Dark(8, n-8) {
+			t.Fatalf("expected dark module next to the bottom-left finder")
+		}
+	})
+
+	t.Run("rejects text beyond version 40", func(t *testing.T) {
+		if _, err := Encode(strings.Repeat("a", 2400)); err != ErrTooLong {
+			t.Fatalf("expected ErrTooLong, got %v", err)
+		}
+	})
+
+	t.Run("renders svg with a quiet zone", func(t *testing.T) {
+		code, err := Encode("hello")
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		svg := code.SVG()
+		if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `viewBox="0 0 29 29"`) {
+			t.Fatalf("unexpected svg: %s", svg)
+		}
+		if !strings.Contains(svg, "M4,4h1v1h-1z") {
+			t.Fatalf("expected top-left finder module offset by the quiet zone")
+		}
+	})
+}
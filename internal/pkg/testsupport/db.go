@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"formlander/internal/accounts"
+	"formlander/internal/audit"
 	"formlander/internal/forms"
 	"formlander/internal/integrations"
 
@@ -23,17 +24,34 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 	err = db.AutoMigrate(
 		// Accounts
 		&accounts.User{},
+		&accounts.Invitation{},
+		&accounts.RecoveryCode{},
+		&accounts.Passkey{},
+		&accounts.Identity{},
+		&accounts.PasswordReset{},
+		&accounts.Session{},
+		&accounts.Settings{},
 		// Forms
 		&forms.Form{},
 		&forms.Submission{},
 		&forms.EmailDelivery{},
+		&forms.AllFormsDigest{},
 		&forms.WebhookDelivery{},
 		&forms.WebhookEvent{},
 		&forms.EmailEvent{},
 		&forms.SubmissionFile{},
+		&forms.RoutingRule{},
+		&forms.SubmissionNote{},
+		&forms.SubmissionReply{},
+		&forms.SavedView{},
+		&forms.FormGrant{},
+		&forms.SubmissionRollup{},
+		&forms.FormView{},
 		// Integrations
 		&integrations.MailerProfile{},
 		&integrations.CaptchaProfile{},
+		// Audit
+		&audit.Entry{},
 	)
 	require.NoError(t, err, "failed to migrate test database")
 
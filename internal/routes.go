@@ -8,6 +8,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/karloscodes/cartridge"
 
+	"formlander/internal/accounts"
 	"formlander/internal/config"
 	httphandlers "formlander/internal/http"
 )
@@ -37,6 +38,17 @@ func MountRoutes(s *cartridge.Server, cfg *config.Config) {
 	// Public demo page
 	s.Get("/_demo", httphandlers.DemoContactForm)
 
+	// Hosted form pages (shareable link per form)
+	s.Get("/f/:public_id", httphandlers.HostedFormPage)
+
+	// Double opt-in confirmation links. The signed token in the path is the
+	// credential, so fetch-metadata checks add nothing here.
+	s.Get("/confirm/:token", httphandlers.ConfirmSubmissionPage)
+	s.Post("/confirm/:token", httphandlers.ConfirmSubmission, &cartridge.RouteConfig{
+		EnableSecFetchSite: cartridge.Bool(false),
+		WriteConcurrency:   true,
+	})
+
 	// Build middleware chain for public routes (rate limiting disabled in dev/test)
 	publicMiddleware := []fiber.Handler{
 		limiter.New(limiter.Config{
@@ -61,9 +73,10 @@ func MountRoutes(s *cartridge.Server, cfg *config.Config) {
 		EnableSecFetchSite: cartridge.Bool(false), // Public APIs accept cross-origin requests
 		EnableCORS:         true,
 		CORSConfig: &cors.Config{
-			AllowOrigins: "*",
-			AllowMethods: "POST,OPTIONS",
-			AllowHeaders: "Content-Type, Authorization, User-Agent",
+			AllowOrigins:  "*",
+			AllowMethods:  "POST,OPTIONS",
+			AllowHeaders:  "Content-Type, Authorization, User-Agent, Idempotency-Key",
+			ExposeHeaders: "Idempotent-Replayed",
 		},
 		WriteConcurrency: true,
 		CustomMiddleware: publicMiddleware,
@@ -73,6 +86,32 @@ func MountRoutes(s *cartridge.Server, cfg *config.Config) {
 	s.Options("/forms/:slug/submit", func(ctx *cartridge.Context) error {
 		return ctx.SendStatus(fiber.StatusNoContent)
 	}, publicConfig)
+	s.Post("/forms/:slug/view", httphandlers.PublicFormView, publicConfig)
+	s.Options("/forms/:slug/view", func(ctx *cartridge.Context) error {
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}, publicConfig)
+
+	// Form definitions for the embed widget, fetched cross-origin
+	s.Get("/embed/:public_id", httphandlers.EmbedFormDefinition, &cartridge.RouteConfig{
+		EnableSecFetchSite: cartridge.Bool(false),
+		EnableCORS:         true,
+		CORSConfig: &cors.Config{
+			AllowOrigins: "*",
+			AllowMethods: "GET,OPTIONS",
+		},
+	})
+
+	// Inbound email gateway for Mailgun-style inbound routes. Authenticated
+	// by the provider's request signature, so browser CSRF checks don't apply.
+	s.Post("/inbound/email", httphandlers.InboundEmail, &cartridge.RouteConfig{
+		EnableSecFetchSite: cartridge.Bool(false),
+		WriteConcurrency:   true,
+	})
+
+	// Invite links. The signed token in the path is the credential; the
+	// password form posts back from the same page.
+	s.Get("/invite/:token", httphandlers.InvitationPage)
+	s.Post("/invite/:token", httphandlers.InvitationAccept, &cartridge.RouteConfig{WriteConcurrency: true})
 
 	s.Get("/admin/login", httphandlers.AdminLoginPage)
 
@@ -108,49 +147,162 @@ func MountRoutes(s *cartridge.Server, cfg *config.Config) {
 		CustomMiddleware:   []fiber.Handler{loginRateLimiter},
 	})
 
-	// Auth config for protected routes: a valid session.
-	authConfig := &cartridge.RouteConfig{
-		CustomMiddleware: []fiber.Handler{s.Session().Middleware()},
+	// Second step for users with two-factor authentication. The signed
+	// challenge cookie from the password step stands in for the session
+	// until a code checks out.
+	s.Get("/admin/login/verify", httphandlers.AdminLoginVerifyPage)
+	s.Post("/admin/login/verify", httphandlers.AdminLoginVerifySubmit, &cartridge.RouteConfig{
+		EnableSecFetchSite: cartridge.Bool(false),
+		WriteConcurrency:   true,
+		CustomMiddleware:   []fiber.Handler{loginRateLimiter},
+	})
+
+	// Passkey sign-in. Unlike the password form these keep Sec-Fetch-Site
+	// enforcement: every browser with WebAuthn sends fetch metadata.
+	s.Post("/admin/login/passkey/options", httphandlers.AdminPasskeyLoginOptions, &cartridge.RouteConfig{
+		CustomMiddleware: []fiber.Handler{loginRateLimiter},
+	})
+	s.Post("/admin/login/passkey", httphandlers.AdminPasskeyLogin, &cartridge.RouteConfig{
+		WriteConcurrency: true,
+		CustomMiddleware: []fiber.Handler{loginRateLimiter},
+	})
+
+	// OpenID Connect single sign-on. The callback is a cross-site redirect
+	// from the identity provider; the signed request cookie and the state
+	// parameter tie it to the browser that started the sign-in.
+	s.Get("/admin/login/sso", httphandlers.AdminSSOLogin)
+	s.Get("/admin/login/sso/callback", httphandlers.AdminSSOCallback, &cartridge.RouteConfig{
+		WriteConcurrency: true,
+		CustomMiddleware: []fiber.Handler{loginRateLimiter},
+	})
+
+	// Password reset by email. The single-use token in the path is the
+	// credential for the reset form, like invite links.
+	s.Get("/admin/password/forgot", httphandlers.ForgotPasswordPage)
+	s.Post("/admin/password/forgot", httphandlers.ForgotPasswordSubmit, &cartridge.RouteConfig{
+		WriteConcurrency: true,
+		CustomMiddleware: []fiber.Handler{loginRateLimiter},
+	})
+	s.Get("/admin/password/reset/:token", httphandlers.PasswordResetPage)
+	s.Post("/admin/password/reset/:token", httphandlers.PasswordResetSubmit, &cartridge.RouteConfig{
+		WriteConcurrency: true,
+		CustomMiddleware: []fiber.Handler{loginRateLimiter},
+	})
+
+	// Auth config for protected routes: a valid session of an active user.
+	// The other configs add role and per-form checks on top.
+	dbm := s.GetDBManager()
+	withAuth := func(checks ...fiber.Handler) *cartridge.RouteConfig {
+		middleware := []fiber.Handler{
+			s.Session().Middleware(),
+			httphandlers.RequireActiveUser(dbm),
+		}
+		return &cartridge.RouteConfig{CustomMiddleware: append(middleware, checks...)}
+	}
+	authConfig := withAuth()
+	// Live updates are requested by open pages on their own, so they don't
+	// keep an idle session alive.
+	withLiveAuth := func(checks ...fiber.Handler) *cartridge.RouteConfig {
+		config := withAuth(checks...)
+		config.CustomMiddleware = append([]fiber.Handler{httphandlers.BackgroundRequest}, config.CustomMiddleware...)
+		return config
 	}
+	liveConfig := withLiveAuth()
+	liveSubmissionConfig := withLiveAuth(httphandlers.RequireSubmissionAccess(dbm, false))
+	adminConfig := withAuth(httphandlers.RequireRole(accounts.RoleAdmin))
+	formViewConfig := withAuth(httphandlers.RequireFormAccess(dbm, false))
+	formEditConfig := withAuth(httphandlers.RequireFormAccess(dbm, true))
+	submissionViewConfig := withAuth(httphandlers.RequireSubmissionAccess(dbm, false))
+	submissionEditConfig := withAuth(httphandlers.RequireSubmissionAccess(dbm, true))
 
-	// Protected routes (require a logged-in session).
+	// Protected routes (require a logged-in session). Lists are narrowed to
+	// the user's forms by the handlers.
 	s.Get("/admin", httphandlers.AdminDashboard, authConfig)
 	s.Post("/admin/logout", httphandlers.AdminLogout, authConfig)
+	s.Get("/admin/live", httphandlers.AdminLiveFeed, liveConfig)
+	s.Get("/admin/live/dashboard", httphandlers.AdminDashboard, liveConfig)
+	s.Get("/admin/live/submissions", httphandlers.SubmissionList, liveConfig)
+	s.Get("/admin/live/submissions/:id/deliveries", httphandlers.AdminSubmissionDeliveries, liveSubmissionConfig)
 	s.Get("/admin/forms", httphandlers.AdminFormsIndex, authConfig)
-	s.Get("/admin/forms/new", httphandlers.AdminFormsNew, authConfig)
-	s.Post("/admin/forms", httphandlers.AdminFormsCreate, authConfig)
-	s.Get("/admin/forms/:id", httphandlers.AdminFormShow, authConfig)
-	s.Get("/admin/forms/:id/edit", httphandlers.AdminFormsEdit, authConfig)
-	s.Post("/admin/forms/:id", httphandlers.AdminFormsUpdate, authConfig)
-	s.Get("/admin/submissions/:id", httphandlers.AdminSubmissionShow, authConfig)
-	s.Get("/admin/submissions/:id/files/:file_id", httphandlers.AdminSubmissionFileDownload, authConfig)
+	s.Get("/admin/forms/new", httphandlers.AdminFormsNew, adminConfig)
+	s.Post("/admin/forms", httphandlers.AdminFormsCreate, adminConfig)
+	s.Get("/admin/forms/:id", httphandlers.AdminFormShow, formViewConfig)
+	s.Get("/admin/forms/:id/edit", httphandlers.AdminFormsEdit, formEditConfig)
+	s.Post("/admin/forms/:id", httphandlers.AdminFormsUpdate, formEditConfig)
+	s.Get("/admin/forms/:id/analytics", httphandlers.AdminFormAnalytics, formViewConfig)
+	s.Get("/admin/forms/:id/rules", httphandlers.AdminFormRules, formEditConfig)
+	s.Post("/admin/forms/:id/rules", httphandlers.AdminFormRuleCreate, formEditConfig)
+	s.Post("/admin/forms/:id/rules/:rule_id/delete", httphandlers.AdminFormRuleDelete, formEditConfig)
+	s.Get("/admin/submissions/export", httphandlers.SubmissionExport, authConfig)
+	s.Get("/admin/submissions/:id", httphandlers.AdminSubmissionShow, submissionViewConfig)
+	s.Post("/admin/submissions/:id/triage", httphandlers.AdminSubmissionTriage, submissionEditConfig)
+	s.Post("/admin/submissions/:id/notes", httphandlers.AdminSubmissionNoteCreate, submissionEditConfig)
+	s.Post("/admin/submissions/:id/replies", httphandlers.AdminSubmissionReply, submissionEditConfig)
+	s.Post("/admin/submissions/:id/unread", httphandlers.AdminSubmissionMarkUnread, submissionViewConfig)
+	s.Get("/admin/views", httphandlers.AdminViews, authConfig)
+	s.Post("/admin/views", httphandlers.AdminViewCreate, authConfig)
+	s.Post("/admin/views/:id/pin", httphandlers.AdminViewPin, authConfig)
+	s.Post("/admin/views/:id/delete", httphandlers.AdminViewDelete, authConfig)
+	s.Get("/admin/views/:id/export", httphandlers.AdminViewExport, authConfig)
+	s.Get("/admin/submissions/:id/files/:file_id", httphandlers.AdminSubmissionFileDownload, submissionViewConfig)
 
 	// Pro feature paywall pages
 
-	// Settings routes
+	// Settings routes. Everyone manages their own account; instance-wide
+	// settings are for owners and admins.
 	s.Get("/admin/settings", httphandlers.AdminSettingsPage, authConfig)
 	s.Post("/admin/settings/password", httphandlers.AdminSettingsUpdatePassword, authConfig)
 	s.Post("/admin/settings/email", httphandlers.AdminSettingsUpdateEmail, authConfig)
-	s.Post("/admin/settings/mailgun", httphandlers.AdminSettingsUpdateMailgun, authConfig)
-	s.Post("/admin/settings/turnstile", httphandlers.AdminSettingsUpdateTurnstile, authConfig)
+	s.Post("/admin/settings/2fa/setup", httphandlers.AdminTwoFactorSetup, authConfig)
+	s.Post("/admin/settings/2fa/enable", httphandlers.AdminTwoFactorEnable, authConfig)
+	s.Post("/admin/settings/2fa/disable", httphandlers.AdminTwoFactorDisable, authConfig)
+	s.Post("/admin/settings/2fa/recovery-codes", httphandlers.AdminTwoFactorRecoveryCodes, authConfig)
+	s.Post("/admin/settings/passkeys/options", httphandlers.AdminPasskeyOptions, authConfig)
+	s.Post("/admin/settings/passkeys", httphandlers.AdminPasskeyCreate, authConfig)
+	s.Post("/admin/settings/passkeys/:id/rename", httphandlers.AdminPasskeyRename, authConfig)
+	s.Post("/admin/settings/passkeys/:id/delete", httphandlers.AdminPasskeyRevoke, authConfig)
+	s.Post("/admin/settings/sessions/revoke-others", httphandlers.AdminSessionsRevokeOthers, authConfig)
+	s.Post("/admin/settings/sessions/:id/revoke", httphandlers.AdminSessionRevoke, authConfig)
+	s.Post("/admin/settings/mailgun", httphandlers.AdminSettingsUpdateMailgun, adminConfig)
+	s.Post("/admin/settings/turnstile", httphandlers.AdminSettingsUpdateTurnstile, adminConfig)
+
+	// User and invitation routes
+	s.Get("/admin/settings/users", httphandlers.AdminUsers, adminConfig)
+	s.Post("/admin/settings/users/invitations", httphandlers.AdminInvitationCreate, adminConfig)
+	s.Post("/admin/settings/users/invitations/:id/resend", httphandlers.AdminInvitationResend, adminConfig)
+	s.Post("/admin/settings/users/invitations/:id/revoke", httphandlers.AdminInvitationRevoke, adminConfig)
+	s.Get("/admin/settings/users/:id", httphandlers.AdminUserShow, adminConfig)
+	s.Post("/admin/settings/users/:id/email", httphandlers.AdminUserUpdateEmail, adminConfig)
+	s.Post("/admin/settings/users/:id/password", httphandlers.AdminUserSetPassword, adminConfig)
+	s.Post("/admin/settings/users/:id/role", httphandlers.AdminUserSetRole, adminConfig)
+	s.Post("/admin/settings/users/:id/forms", httphandlers.AdminUserSetForms, adminConfig)
+	s.Post("/admin/settings/users/:id/deactivate", httphandlers.AdminUserDeactivate, adminConfig)
+	s.Post("/admin/settings/users/:id/activate", httphandlers.AdminUserActivate, adminConfig)
+	s.Post("/admin/settings/users/:id/delete", httphandlers.AdminUserDelete, adminConfig)
+
+	// Audit log
+	s.Get("/admin/settings/audit", httphandlers.AdminAuditLog, adminConfig)
+	s.Get("/admin/settings/audit/export", httphandlers.AdminAuditExport, adminConfig)
 
 	// Mailer Profile routes
-	s.Get("/admin/settings/mailers", httphandlers.MailerProfileList, authConfig)
-	s.Get("/admin/settings/mailers/new", httphandlers.MailerProfileNew, authConfig)
-	s.Post("/admin/settings/mailers", httphandlers.MailerProfileCreate, authConfig)
-	s.Get("/admin/settings/mailers/:id", httphandlers.MailerProfileShow, authConfig)
-	s.Get("/admin/settings/mailers/:id/edit", httphandlers.MailerProfileEdit, authConfig)
-	s.Post("/admin/settings/mailers/:id", httphandlers.MailerProfileUpdate, authConfig)
-	s.Post("/admin/settings/mailers/:id/delete", httphandlers.MailerProfileDelete, authConfig)
+	s.Get("/admin/settings/mailers", httphandlers.MailerProfileList, adminConfig)
+	s.Get("/admin/settings/mailers/new", httphandlers.MailerProfileNew, adminConfig)
+	s.Post("/admin/settings/mailers", httphandlers.MailerProfileCreate, adminConfig)
+	s.Post("/admin/settings/mailers/system", httphandlers.SystemMailerUpdate, adminConfig)
+	s.Post("/admin/settings/mailers/digest", httphandlers.AllFormsDigestUpdate, adminConfig)
+	s.Get("/admin/settings/mailers/:id", httphandlers.MailerProfileShow, adminConfig)
+	s.Get("/admin/settings/mailers/:id/edit", httphandlers.MailerProfileEdit, adminConfig)
+	s.Post("/admin/settings/mailers/:id", httphandlers.MailerProfileUpdate, adminConfig)
+	s.Post("/admin/settings/mailers/:id/delete", httphandlers.MailerProfileDelete, adminConfig)
 
 	// Captcha Profile routes
-	s.Get("/admin/settings/captcha", httphandlers.CaptchaProfileList, authConfig)
-	s.Get("/admin/settings/captcha/new", httphandlers.CaptchaProfileNew, authConfig)
-	s.Post("/admin/settings/captcha", httphandlers.CaptchaProfileCreate, authConfig)
-	s.Get("/admin/settings/captcha/:id", httphandlers.CaptchaProfileShow, authConfig)
-	s.Get("/admin/settings/captcha/:id/edit", httphandlers.CaptchaProfileEdit, authConfig)
-	s.Post("/admin/settings/captcha/:id", httphandlers.CaptchaProfileUpdate, authConfig)
-	s.Post("/admin/settings/captcha/:id/delete", httphandlers.CaptchaProfileDelete, authConfig)
+	s.Get("/admin/settings/captcha", httphandlers.CaptchaProfileList, adminConfig)
+	s.Get("/admin/settings/captcha/new", httphandlers.CaptchaProfileNew, adminConfig)
+	s.Post("/admin/settings/captcha", httphandlers.CaptchaProfileCreate, adminConfig)
+	s.Get("/admin/settings/captcha/:id", httphandlers.CaptchaProfileShow, adminConfig)
+	s.Get("/admin/settings/captcha/:id/edit", httphandlers.CaptchaProfileEdit, adminConfig)
+	s.Post("/admin/settings/captcha/:id", httphandlers.CaptchaProfileUpdate, adminConfig)
+	s.Post("/admin/settings/captcha/:id/delete", httphandlers.CaptchaProfileDelete, adminConfig)
 
 	// Submissions routes
 	s.Get("/admin/submissions", httphandlers.SubmissionList, authConfig)
@@ -1,13 +1,32 @@
 package internal_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
 	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/gofiber/template/html/v2"
 	"github.com/karloscodes/cartridge"
 	cartridgeconfig "github.com/karloscodes/cartridge/config"
 	cartridgetestsupport "github.com/karloscodes/cartridge/testsupport"
@@ -17,9 +36,13 @@ import (
 
 	"formlander/internal"
 	"formlander/internal/accounts"
+	"formlander/internal/audit"
 	"formlander/internal/config"
 	"formlander/internal/forms"
 	"formlander/internal/integrations"
+	"formlander/internal/pkg/oidctest"
+	"formlander/internal/server"
+	"formlander/web"
 )
 
 // Smoke tests for the Sec-Fetch-Site boundary on /admin routes.
@@ -29,19 +52,38 @@ import (
 // browsers and reverse-proxied deploys can authenticate; every other
 // state-changing admin route must remain protected.
 
-func mountTestServer(t *testing.T) *cartridgetestsupport.TestServer {
+// mountTestServer mounts the routes on a fresh database. configure adjusts
+// the formlander config for tests of optional features.
+func mountTestServer(t *testing.T, configure ...func(*config.Config)) *cartridgetestsupport.TestServer {
 	t.Helper()
 
 	models := []any{
 		&accounts.User{},
+		&accounts.Invitation{},
+		&accounts.RecoveryCode{},
+		&accounts.Passkey{},
+		&accounts.Identity{},
+		&accounts.PasswordReset{},
+		&accounts.Session{},
+		&accounts.Settings{},
 		&forms.Form{},
 		&forms.Submission{},
 		&forms.EmailDelivery{},
+		&forms.AllFormsDigest{},
 		&forms.WebhookDelivery{},
 		&forms.WebhookEvent{},
 		&forms.EmailEvent{},
+		&forms.SubmissionFile{},
+		&forms.RoutingRule{},
+		&forms.SubmissionNote{},
+		&forms.SubmissionReply{},
+		&forms.SavedView{},
+		&forms.FormGrant{},
+		&forms.SubmissionRollup{},
+		&forms.FormView{},
 		&integrations.MailerProfile{},
 		&integrations.CaptchaProfile{},
+		&audit.Entry{},
 	}
 
 	flCfg := &config.Config{
@@ -52,10 +94,33 @@ func mountTestServer(t *testing.T) *cartridgetestsupport.TestServer {
 			SessionTimeout: 3600,
 		},
 		MaxInputFields: 200,
+		Inbound:        config.InboundConfig{SigningKey: testInboundSigningKey},
 	}
+	for _, fn := range configure {
+		fn(flCfg)
+	}
+
+	// Load the real templates so public pages render as in production.
+	// render mirrors the helper cartridge registers for the admin layout.
+	views := html.NewFileSystem(http.FS(web.Templates), ".html")
+	views.AddFunc("render", func(name string, data any) (template.HTML, error) {
+		tpl := views.Templates.Lookup(name)
+		if tpl == nil {
+			return "", fmt.Errorf("template %q not found", name)
+		}
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return template.HTML(buf.String()), nil
+	})
+	views.AddFuncMap(server.TemplateFuncs())
+	serverCfg := cartridge.DefaultServerConfig()
+	serverCfg.ViewsEngine = views
 
 	ts := cartridgetestsupport.NewTestServer(t, cartridgetestsupport.TestServerOptions{
-		Models: models,
+		Models:       models,
+		ServerConfig: serverCfg,
 		RouteMountFunc: func(s *cartridge.Server) {
 			s.SetSession(cartridge.NewSessionManager(cartridge.SessionConfig{
 				CookieName: "formlander_session",
@@ -97,6 +162,54 @@ func seedAdmin(t *testing.T, ts *cartridgetestsupport.TestServer, email, passwor
 	require.NoError(t, ts.DB.GetConnection().Create(user).Error)
 }
 
+// adminGet fetches an admin page as a signed-in admin.
+func adminGet(t *testing.T, ts *cartridgetestsupport.TestServer, path string) (status int, respBody string) {
+	t.Helper()
+	return adminRequest(t, ts, "GET", path, "")
+}
+
+// adminPost submits a same-origin admin form as a signed-in admin.
+func adminPost(t *testing.T, ts *cartridgetestsupport.TestServer, path, body string) (status int, respBody string) {
+	t.Helper()
+	return adminRequest(t, ts, "POST", path, body)
+}
+
+func adminRequest(t *testing.T, ts *cartridgetestsupport.TestServer, method, path, body string) (status int, respBody string) {
+	t.Helper()
+	var admins int64
+	ts.DB.GetConnection().Model(&accounts.User{}).Count(&admins)
+	if admins == 0 {
+		seedAdmin(t, ts, "admin@formlander.local", "formlander")
+	}
+	return userRequest(t, ts, "admin@formlander.local", "formlander", method, path, body)
+}
+
+// userRequest signs in with the given credentials and sends one request
+// with the session.
+func userRequest(t *testing.T, ts *cartridgetestsupport.TestServer, email, password, method, path, body string) (status int, respBody string) {
+	t.Helper()
+	login := httptest.NewRequest("POST", "/admin/login", strings.NewReader(url.Values{"email": {email}, "password": {password}}.Encode()))
+	login.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	login.Header.Set("Sec-Fetch-Site", "same-origin")
+	resp, err := ts.App.Test(login, -1)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if method != "GET" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+	}
+	for _, c := range resp.Cookies() {
+		req.AddCookie(c)
+	}
+	resp, err = ts.App.Test(req, -1)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
 // secFetchBlockedBody is cartridge's strict-middleware rejection body —
 // any route returning this without a Sec-Fetch-Site header was blocked
 // by CSRF protection.
@@ -111,12 +224,22 @@ const secFetchBlockedBody = "browser requests only"
 //
 //	OPEN (no Sec-Fetch-Site required)
 //	  - POST /admin/login              ← unauthenticated entry point
+//	  - POST /admin/login/verify       ← second sign-in step (signed challenge cookie)
 //	  - POST /forms/:slug/submit       ← public form ingestion (token + origin allowlist)
+//	  - POST /inbound/email            ← inbound email gateway (provider HMAC signature)
 //
 //	PROTECTED (Sec-Fetch-Site required for state-changing requests)
 //	  - POST /admin/logout
 //	  - POST /admin/forms
 //	  - POST /admin/settings/password
+//	  - POST /admin/settings/2fa/*
+//	  - POST /admin/settings/passkeys/*
+//	  - POST /admin/settings/sessions/*
+//	  - POST /admin/login/passkey/*    ← WebAuthn browsers always send fetch metadata
+//	  - POST /admin/settings/users/invitations
+//	  - POST /invite/:token
+//	  - POST /admin/password/forgot, /admin/password/reset/:token
+//	  - POST /admin/settings/mailers/system, /admin/settings/mailers/digest
 //
 // If a new state-changing admin route is added, add it to the protected
 // group below to prevent it from being accidentally exposed.
@@ -132,7 +255,9 @@ func TestRoutesSecFetchSiteBoundary(t *testing.T) {
 		body string
 	}{
 		{"POST /admin/login (issue #35)", "/admin/login", "email=admin@formlander.local&password=formlander"},
+		{"POST /admin/login/verify", "/admin/login/verify", "code=123456"},
 		{"POST /forms/:slug/submit (public form)", "/forms/does-not-exist/submit?token=x", "field=value"},
+		{"POST /inbound/email (provider-signed)", "/inbound/email", "recipient=x@example.com"},
 	}
 
 	protectedRoutes := []struct {
@@ -143,6 +268,24 @@ func TestRoutesSecFetchSiteBoundary(t *testing.T) {
 		{"POST /admin/logout", "/admin/logout", ""},
 		{"POST /admin/forms", "/admin/forms", "name=test"},
 		{"POST /admin/settings/password", "/admin/settings/password", ""},
+		{"POST /admin/settings/2fa/setup", "/admin/settings/2fa/setup", ""},
+		{"POST /admin/settings/2fa/enable", "/admin/settings/2fa/enable", "code=123456"},
+		{"POST /admin/settings/2fa/disable", "/admin/settings/2fa/disable", "current_password=formlander"},
+		{"POST /admin/settings/2fa/recovery-codes", "/admin/settings/2fa/recovery-codes", "current_password=formlander"},
+		{"POST /admin/settings/passkeys/options", "/admin/settings/passkeys/options", ""},
+		{"POST /admin/settings/passkeys", "/admin/settings/passkeys", ""},
+		{"POST /admin/settings/passkeys/:id/rename", "/admin/settings/passkeys/1/rename", "name=Laptop"},
+		{"POST /admin/settings/passkeys/:id/delete", "/admin/settings/passkeys/1/delete", ""},
+		{"POST /admin/settings/sessions/:id/revoke", "/admin/settings/sessions/1/revoke", ""},
+		{"POST /admin/settings/sessions/revoke-others", "/admin/settings/sessions/revoke-others", ""},
+		{"POST /admin/login/passkey/options", "/admin/login/passkey/options", ""},
+		{"POST /admin/login/passkey", "/admin/login/passkey", ""},
+		{"POST /admin/settings/users/invitations", "/admin/settings/users/invitations", "email=new@example.com"},
+		{"POST /invite/:token", "/invite/x.1.y", "password=password123"},
+		{"POST /admin/password/forgot", "/admin/password/forgot", "email=admin@formlander.local"},
+		{"POST /admin/password/reset/:token", "/admin/password/reset/x", "password=password123"},
+		{"POST /admin/settings/mailers/system", "/admin/settings/mailers/system", "mailer_profile_id="},
+		{"POST /admin/settings/mailers/digest", "/admin/settings/mailers/digest", "digest_mode=instant"},
 	}
 
 	t.Run("OPEN: accept POST without Sec-Fetch-Site", func(t *testing.T) {
@@ -261,3 +404,1894 @@ func TestPublicFormSubmissionGuards(t *testing.T) {
 		assert.Equal(t, 200, status)
 	})
 }
+
+const testInboundSigningKey = "inbound-test-key"
+
+// signedInboundBody builds a Mailgun-style inbound post with a valid signature.
+func signedInboundBody(fields url.Values) string {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	token := "tok-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	mac := hmac.New(sha256.New, []byte(testInboundSigningKey))
+	mac.Write([]byte(timestamp + token))
+	fields.Set("timestamp", timestamp)
+	fields.Set("token", token)
+	fields.Set("signature", hex.EncodeToString(mac.Sum(nil)))
+	return fields.Encode()
+}
+
+// TestInboundEmailGateway covers the provider-signed inbound endpoint: the
+// signature is the only guard, so unsigned or stale posts must never create
+// submissions.
+func TestInboundEmailGateway(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	seedForm := func(t *testing.T, ts *cartridgetestsupport.TestServer) *forms.Form {
+		t.Helper()
+		f := &forms.Form{
+			Name:           "Support",
+			Slug:           "support",
+			Token:          "secret-token",
+			AllowedOrigins: "example.com",
+			InboundAddress: "support@in.example.com",
+		}
+		require.NoError(t, ts.DB.GetConnection().Create(f).Error)
+		return f
+	}
+
+	t.Run("rejects unsigned request", func(t *testing.T) {
+		ts := mountTestServer(t)
+		seedForm(t, ts)
+
+		status, _ := formPost(t, ts, "/inbound/email",
+			"recipient=support@in.example.com&from=a@example.com&body-plain=hi", nil)
+
+		assert.Equal(t, 401, status)
+	})
+
+	t.Run("rejects unknown recipient without retry", func(t *testing.T) {
+		ts := mountTestServer(t)
+		seedForm(t, ts)
+
+		status, body := formPost(t, ts, "/inbound/email", signedInboundBody(url.Values{
+			"recipient":  {"nobody@in.example.com"},
+			"from":       {"a@example.com"},
+			"body-plain": {"hi"},
+		}), nil)
+
+		assert.Equal(t, 406, status)
+		assert.Contains(t, body, "no form for recipient")
+	})
+
+	t.Run("stores parsed email as submission", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts)
+
+		status, _ := formPost(t, ts, "/inbound/email", signedInboundBody(url.Values{
+			"recipient":  {"support+billing@in.example.com"},
+			"from":       {"Alice <alice@example.com>"},
+			"subject":    {"Invoice question"},
+			"body-plain": {"Where is my invoice?"},
+		}), nil)
+		require.Equal(t, 200, status)
+
+		var sub forms.Submission
+		require.NoError(t, ts.DB.GetConnection().Where("form_id = ?", f.ID).First(&sub).Error)
+		assert.Contains(t, sub.DataJSON, "alice@example.com")
+		assert.Contains(t, sub.DataJSON, "Where is my invoice?")
+		assert.False(t, sub.IsSpam)
+	})
+
+	t.Run("rejects a replayed request", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts)
+
+		body := signedInboundBody(url.Values{
+			"recipient":  {"support@in.example.com"},
+			"from":       {"a@example.com"},
+			"body-plain": {"hi"},
+		})
+		status, _ := formPost(t, ts, "/inbound/email", body, nil)
+		require.Equal(t, 200, status)
+
+		status, respBody := formPost(t, ts, "/inbound/email", body, nil)
+		assert.Equal(t, 401, status)
+		assert.Contains(t, respBody, "signature already used")
+
+		var count int64
+		ts.DB.GetConnection().Model(&forms.Submission{}).Where("form_id = ?", f.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("stores raw MIME email as submission", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts)
+
+		raw := "From: bob@example.com\r\nSubject: Hello\r\n\r\nRaw body\r\n"
+		status, _ := formPost(t, ts, "/inbound/email", signedInboundBody(url.Values{
+			"recipient": {"support@in.example.com"},
+			"body-mime": {raw},
+		}), nil)
+		require.Equal(t, 200, status)
+
+		var sub forms.Submission
+		require.NoError(t, ts.DB.GetConnection().Where("form_id = ?", f.ID).First(&sub).Error)
+		assert.Contains(t, sub.DataJSON, "Raw body")
+	})
+}
+
+// TestHostedFormPage covers the public hosted page and its same-host
+// submissions, which are accepted even when the Formlander host is not in
+// the form's AllowedOrigins.
+func TestHostedFormPage(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	// httptest requests target host "example.com".
+	seedForm := func(t *testing.T, ts *cartridgetestsupport.TestServer, enabled bool) *forms.Form {
+		t.Helper()
+		f := &forms.Form{
+			Name:              "Feedback",
+			Slug:              "feedback",
+			Token:             "secret-token",
+			AllowedOrigins:    "mysite.com",
+			GeneratedHTML:     `<form><input name="comment"><button>Send</button></form>`,
+			HostedEnabled:     enabled,
+			HostedTitle:       "Tell us what you think",
+			HostedDescription: "We read every message.",
+			HostedAccentColor: "#ff5500",
+		}
+		require.NoError(t, ts.DB.GetConnection().Create(f).Error)
+		return f
+	}
+
+	get := func(t *testing.T, ts *cartridgetestsupport.TestServer, path string) (int, string) {
+		t.Helper()
+		resp, err := ts.App.Test(httptest.NewRequest("GET", path, nil), -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	t.Run("renders themed page with form wired to the endpoint", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts, true)
+
+		status, body := get(t, ts, f.HostedPath())
+
+		require.Equal(t, 200, status)
+		assert.Contains(t, body, "Tell us what you think")
+		assert.Contains(t, body, "We read every message.")
+		assert.Contains(t, body, "#ff5500")
+		assert.Contains(t, body, `action="/forms/feedback/submit?token=secret-token"`)
+		assert.Contains(t, body, `name="comment"`)
+	})
+
+	t.Run("restricts what the page may load", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts, true)
+		db := ts.DB.GetConnection()
+		captcha := &integrations.CaptchaProfile{Name: "Turnstile", Provider: "turnstile"}
+		require.NoError(t, db.Create(captcha).Error)
+		require.NoError(t, db.Model(f).Updates(map[string]any{
+			"captcha_profile_id": captcha.ID,
+			"hosted_logo_url":    "https://cdn.example.net/logo.png",
+		}).Error)
+
+		resp, err := ts.App.Test(httptest.NewRequest("GET", f.HostedPath(), nil), -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		policy := resp.Header.Get("Content-Security-Policy")
+		assert.Contains(t, policy, "default-src 'self'")
+		assert.Contains(t, policy, "script-src 'self' 'nonce-")
+		assert.Contains(t, policy, "https://challenges.cloudflare.com")
+		assert.Contains(t, policy, "img-src 'self' https://cdn.example.net")
+		assert.Contains(t, policy, "form-action 'self' mysite.com *.mysite.com")
+		assert.Contains(t, policy, "object-src 'none'")
+
+		nonce := policy[strings.Index(policy, "'nonce-")+len("'nonce-"):]
+		nonce = nonce[:strings.Index(nonce, "'")]
+		assert.Contains(t, string(body), `<script nonce="`+nonce+`">`)
+	})
+
+	t.Run("returns 404 when hosted page is disabled", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts, false)
+
+		status, _ := get(t, ts, f.HostedPath())
+
+		assert.Equal(t, 404, status)
+	})
+
+	t.Run("accepts submissions from the hosted page origin", func(t *testing.T) {
+		ts := mountTestServer(t)
+		seedForm(t, ts, true)
+
+		status, _ := formPost(t, ts, "/forms/feedback/submit?token=secret-token", "comment=great",
+			map[string]string{"Origin": "http://example.com"})
+
+		assert.Equal(t, 200, status)
+	})
+
+	t.Run("rejects same-host origin when hosted page is disabled", func(t *testing.T) {
+		ts := mountTestServer(t)
+		seedForm(t, ts, false)
+
+		status, _ := formPost(t, ts, "/forms/feedback/submit?token=secret-token", "comment=great",
+			map[string]string{"Origin": "http://example.com"})
+
+		assert.Equal(t, 403, status)
+	})
+}
+
+func TestSubmissionResultPages(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	seedForm := func(t *testing.T, ts *cartridgetestsupport.TestServer) {
+		t.Helper()
+		f := &forms.Form{
+			Name:              "Feedback",
+			Slug:              "feedback",
+			Token:             "secret-token",
+			AllowedOrigins:    "mysite.com",
+			HostedAccentColor: "#ff5500",
+			SuccessTitle:      "Thanks a bunch",
+			SuccessMessage:    "We'll be in touch.",
+		}
+		require.NoError(t, ts.DB.GetConnection().Create(f).Error)
+	}
+
+	browser := map[string]string{
+		"Origin":         "https://mysite.com",
+		"Referer":        "https://mysite.com/contact",
+		"Accept":         "text/html,application/xhtml+xml,*/*;q=0.8",
+		"Sec-Fetch-Mode": "navigate",
+	}
+
+	t.Run("browser post gets themed thank-you page", func(t *testing.T) {
+		ts := mountTestServer(t)
+		seedForm(t, ts)
+
+		status, body := formPost(t, ts, "/forms/feedback/submit?token=secret-token", "comment=great", browser)
+
+		require.Equal(t, 200, status)
+		assert.Contains(t, body, "Thanks a bunch")
+		assert.Contains(t, body, "We&#39;ll be in touch.")
+		assert.Contains(t, body, "#ff5500")
+		assert.Contains(t, body, `href="https://mysite.com/contact"`)
+	})
+
+	t.Run("fetch post keeps JSON response", func(t *testing.T) {
+		ts := mountTestServer(t)
+		seedForm(t, ts)
+
+		status, body := formPost(t, ts, "/forms/feedback/submit?token=secret-token", "comment=great", map[string]string{
+			"Origin":         "https://mysite.com",
+			"Accept":         "application/json",
+			"Sec-Fetch-Mode": "cors",
+		})
+
+		require.Equal(t, 200, status)
+		assert.Contains(t, body, `"ok":true`)
+	})
+
+	t.Run("browser error gets error page with status", func(t *testing.T) {
+		ts := mountTestServer(t)
+		seedForm(t, ts)
+
+		status, body := formPost(t, ts, "/forms/feedback/submit?token=wrong", "comment=great", browser)
+
+		assert.Equal(t, 401, status)
+		assert.Contains(t, body, forms.DefaultErrorTitle)
+		assert.Contains(t, body, "invalid token")
+		assert.Contains(t, body, "Go back and try again")
+	})
+
+	t.Run("unknown form renders default error page", func(t *testing.T) {
+		ts := mountTestServer(t)
+
+		status, body := formPost(t, ts, "/forms/missing/submit?token=x", "comment=great", browser)
+
+		assert.Equal(t, 404, status)
+		assert.Contains(t, body, "form not found")
+	})
+}
+
+func TestEmbedFormDefinition(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	seedForm := func(t *testing.T, ts *cartridgetestsupport.TestServer) *forms.Form {
+		t.Helper()
+		f := &forms.Form{
+			Name:              "Waitlist",
+			Slug:              "waitlist",
+			Token:             "secret-token",
+			AllowedOrigins:    "mysite.com",
+			GeneratedHTML:     `<form><label>Email <input type="email" name="email" required></label><button>Join</button></form>`,
+			HostedAccentColor: "#ff5500",
+		}
+		require.NoError(t, ts.DB.GetConnection().Create(f).Error)
+		return f
+	}
+
+	get := func(t *testing.T, ts *cartridgetestsupport.TestServer, path, origin string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	t.Run("serves fields and theme to allowed origins", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts)
+
+		status, body := get(t, ts, "/embed/"+f.PublicID, "https://mysite.com")
+
+		require.Equal(t, 200, status)
+		def := body["form"].(map[string]any)
+		assert.Equal(t, "/forms/waitlist/submit?token=secret-token", def["submit_url"])
+		assert.Equal(t, "Join", def["submit_label"])
+		assert.Equal(t, "#ff5500", def["accent_color"])
+		assert.Equal(t, forms.HoneypotField, def["honeypot_field"])
+		fields := def["fields"].([]any)
+		require.Len(t, fields, 1)
+		assert.Equal(t, "email", fields[0].(map[string]any)["type"])
+	})
+
+	t.Run("rejects other origins", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts)
+
+		status, _ := get(t, ts, "/embed/"+f.PublicID, "https://evil.example")
+
+		assert.Equal(t, 403, status)
+	})
+
+	t.Run("cached definitions vary by origin", func(t *testing.T) {
+		ts := mountTestServer(t)
+		f := seedForm(t, ts)
+
+		req := httptest.NewRequest("GET", "/embed/"+f.PublicID, nil)
+		req.Header.Set("Origin", "https://mysite.com")
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Cache-Control"), "public")
+		assert.Contains(t, resp.Header.Values("Vary"), "Origin")
+	})
+
+	t.Run("returns 404 for unknown forms", func(t *testing.T) {
+		ts := mountTestServer(t)
+
+		status, _ := get(t, ts, "/embed/doesnotexist", "")
+
+		assert.Equal(t, 404, status)
+	})
+}
+
+func TestSubmissionIdempotencyKey(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	f := &forms.Form{Name: "Contact", Slug: "contact", Token: "secret-token", AllowedOrigins: "*"}
+	require.NoError(t, ts.DB.GetConnection().Create(f).Error)
+
+	post := func(key string) (int, map[string]any, string) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/forms/contact/submit?token=secret-token", strings.NewReader("name=Ada"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Idempotency-Key", key)
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body, resp.Header.Get("Idempotent-Replayed")
+	}
+
+	status, first, replayed := post("retry-123")
+	require.Equal(t, 200, status)
+	assert.Empty(t, replayed)
+
+	status, second, replayed := post("retry-123")
+	require.Equal(t, 200, status)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, first["submission_id"], second["submission_id"])
+
+	var count int64
+	ts.DB.GetConnection().Model(&forms.Submission{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	status, _, _ = post("bad key")
+	assert.Equal(t, 400, status)
+}
+
+func TestNestedFieldSubmission(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	f := &forms.Form{Name: "Order", Slug: "order", Token: "secret-token", AllowedOrigins: "*"}
+	require.NoError(t, ts.DB.GetConnection().Create(f).Error)
+
+	body := url.Values{
+		"address[street]": {"Main St"},
+		"items[0][qty]":   {"2"},
+		"tags[]":          {"red", "blue"},
+	}.Encode()
+	status, resp := formPost(t, ts, "/forms/order/submit?token=secret-token", body, nil)
+	require.Equal(t, 200, status, resp)
+
+	var sub forms.Submission
+	require.NoError(t, ts.DB.GetConnection().Where("form_id = ?", f.ID).First(&sub).Error)
+	assert.JSONEq(t, `{"address":{"street":"Main St"},"items":[{"qty":"2"}],"tags":["red","blue"]}`, sub.DataJSON)
+
+	status, _ = formPost(t, ts, "/forms/order/submit?token=secret-token", "a[b][c][d][e][f]=deep", nil)
+	assert.Equal(t, 400, status)
+}
+
+func TestClosedFormSubmission(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	past := time.Now().UTC().Add(-time.Hour)
+	require.NoError(t, db.Create(&forms.Form{Name: "Limited", Slug: "limited", Token: "secret-token", AllowedOrigins: "*", MaxSubmissions: 1}).Error)
+	require.NoError(t, db.Create(&forms.Form{Name: "Ended", Slug: "ended", Token: "ended-token", AllowedOrigins: "*", ClosesAt: &past, ClosedURL: "https://example.org/closed"}).Error)
+
+	status, body := formPost(t, ts, "/forms/limited/submit?token=secret-token", "name=Ada", nil)
+	require.Equal(t, 200, status, body)
+
+	status, body = formPost(t, ts, "/forms/limited/submit?token=secret-token", "name=Grace", nil)
+	assert.Equal(t, 403, status)
+	assert.Contains(t, body, `"reason":"full"`)
+
+	status, body = formPost(t, ts, "/forms/limited/submit?token=secret-token", "name=Grace", map[string]string{"Accept": "text/html"})
+	assert.Equal(t, 403, status)
+	assert.Contains(t, body, forms.DefaultClosedMessage)
+
+	req := httptest.NewRequest("POST", "/forms/ended/submit?token=ended-token", strings.NewReader("name=Ada"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := ts.App.Test(req, -1)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 302, resp.StatusCode)
+	assert.Equal(t, "https://example.org/closed", resp.Header.Get("Location"))
+
+	var count int64
+	db.Model(&forms.Submission{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	status, body = adminGet(t, ts, "/admin/forms")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Full · 1/1")
+	assert.Contains(t, body, "Closed")
+}
+
+func TestUniqueFieldSubmission(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	f := &forms.Form{Name: "Waitlist", Slug: "waitlist", Token: "secret-token", AllowedOrigins: "*", UniqueField: "email", UniqueAction: forms.UniqueActionReject}
+	require.NoError(t, ts.DB.GetConnection().Create(f).Error)
+
+	status, body := formPost(t, ts, "/forms/waitlist/submit?token=secret-token", "email=ada@example.com", nil)
+	require.Equal(t, 200, status, body)
+
+	status, body = formPost(t, ts, "/forms/waitlist/submit?token=secret-token", "email=Ada@Example.com", nil)
+	assert.Equal(t, 409, status)
+	assert.Contains(t, body, "a submission with this email already exists")
+
+	status, body = adminGet(t, ts, "/admin/forms/"+strconv.Itoa(int(f.ID)))
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "1 duplicate")
+}
+
+func TestDoubleOptInConfirmation(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	f := &forms.Form{
+		Name:            "Newsletter",
+		Slug:            "newsletter",
+		Token:           "optin-token",
+		AllowedOrigins:  "*",
+		DoubleOptIn:     true,
+		WebhookDelivery: &forms.WebhookDelivery{Enabled: true, URL: "https://hooks.example.com"},
+	}
+	require.NoError(t, db.Create(f).Error)
+
+	status, body := formPost(t, ts, "/forms/newsletter/submit?token=optin-token", "email=nope", nil)
+	assert.Equal(t, 400, status, body)
+
+	status, body = formPost(t, ts, "/forms/newsletter/submit?token=optin-token", "email=ada@example.com", nil)
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, `"confirmation":"pending"`)
+
+	var sub forms.Submission
+	require.NoError(t, db.Where("form_id = ?", f.ID).First(&sub).Error)
+	path := "/confirm/" + forms.ConfirmationToken("test-secret", sub.ID, sub.CreatedAt.Add(f.OptInExpiry()))
+
+	get := func(path string) (int, string) {
+		resp, err := ts.App.Test(httptest.NewRequest("GET", path, nil), -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	status, body = get(path)
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, forms.DefaultConfirmTitle)
+
+	var events int64
+	db.Model(&forms.WebhookEvent{}).Count(&events)
+	assert.Equal(t, int64(0), events, "opening the link doesn't confirm")
+
+	status, body = formPost(t, ts, path, "", nil)
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, forms.DefaultConfirmedTitle)
+	db.Model(&forms.WebhookEvent{}).Count(&events)
+	assert.Equal(t, int64(1), events)
+
+	status, _ = get("/confirm/" + forms.ConfirmationToken("wrong-secret", sub.ID, time.Now().Add(time.Hour)))
+	assert.Equal(t, 404, status)
+
+	status, body = adminGet(t, ts, "/admin/submissions?confirmation=confirmed")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "ada@example.com")
+}
+
+func TestRoutingRulesAdmin(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	f := &forms.Form{
+		Name:            "Contact",
+		Slug:            "contact",
+		Token:           "rules-token",
+		AllowedOrigins:  "*",
+		WebhookDelivery: &forms.WebhookDelivery{Enabled: true, URL: "https://crm.example.com/hook"},
+	}
+	require.NoError(t, db.Create(f).Error)
+	rulesPath := "/admin/forms/" + strconv.Itoa(int(f.ID)) + "/rules"
+
+	status, body := adminPost(t, ts, rulesPath, "field=country&operator=in&value=DE,FR&action=webhook&target=not-a-url")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Enter the http(s) URL to post to")
+
+	status, body = adminPost(t, ts, rulesPath, "field=country&operator=in&value=DE,FR&action=skip_webhook")
+	require.Equal(t, 302, status, body)
+
+	status, body = formPost(t, ts, "/forms/contact/submit?token=rules-token", "country=de", nil)
+	require.Equal(t, 200, status, body)
+
+	var sub forms.Submission
+	require.NoError(t, db.Where("form_id = ?", f.ID).First(&sub).Error)
+	var events int64
+	db.Model(&forms.WebhookEvent{}).Count(&events)
+	assert.Equal(t, int64(0), events)
+
+	status, body = adminGet(t, ts, rulesPath+"?submission_id="+strconv.Itoa(int(sub.ID)))
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "If country is one of DE,FR, don&#39;t post to the webhook")
+	assert.Contains(t, body, "matched")
+	assert.Contains(t, body, "Nothing would be delivered.")
+}
+
+func TestSubmissionTriage(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	f := &forms.Form{Name: "Contact", Slug: "contact", Token: "triage-token", AllowedOrigins: "*"}
+	require.NoError(t, db.Create(f).Error)
+
+	status, body := formPost(t, ts, "/forms/contact/submit?token=triage-token", "email=a@example.com", nil)
+	require.Equal(t, 200, status, body)
+	status, body = formPost(t, ts, "/forms/contact/submit?token=triage-token", "email=b@example.com", nil)
+	require.Equal(t, 200, status, body)
+	var subs []forms.Submission
+	require.NoError(t, db.Order("id ASC").Find(&subs).Error)
+	require.Len(t, subs, 2)
+	subPath := "/admin/submissions/" + strconv.Itoa(int(subs[0].ID))
+
+	status, body = adminGet(t, ts, "/admin/submissions?unread=1")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "2 total")
+
+	// Viewing marks the submission read.
+	status, body = adminGet(t, ts, subPath)
+	require.Equal(t, 200, status, body)
+	status, body = adminGet(t, ts, "/admin/submissions?unread=1")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "1 total")
+
+	var admin accounts.User
+	require.NoError(t, db.First(&admin).Error)
+
+	status, body = adminPost(t, ts, subPath+"/triage", "status=bogus")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Unknown status")
+
+	status, body = adminPost(t, ts, subPath+"/triage", "status=in_progress&assignee_id="+strconv.Itoa(int(admin.ID))+"&tags=Sales,VIP")
+	require.Equal(t, 302, status, body)
+	status, body = adminPost(t, ts, subPath+"/notes", "body=Called+back")
+	require.Equal(t, 302, status, body)
+
+	status, body = adminGet(t, ts, subPath)
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Called back")
+	assert.Contains(t, body, admin.Email)
+
+	status, body = adminGet(t, ts, "/admin/submissions?tag=vip&assignee=me&status=in_progress")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "1 total")
+	assert.Contains(t, body, "#sales")
+
+	// Archived submissions drop out of the default list.
+	status, body = adminPost(t, ts, subPath+"/triage", "status=archived")
+	require.Equal(t, 302, status, body)
+	status, body = adminGet(t, ts, "/admin/submissions")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "1 total")
+	status, body = adminGet(t, ts, "/admin/submissions?status=archived")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "1 total")
+
+	status, body = adminPost(t, ts, subPath+"/unread", "")
+	require.Equal(t, 302, status, body)
+	var got forms.Submission
+	require.NoError(t, db.First(&got, subs[0].ID).Error)
+	assert.True(t, got.IsUnread())
+}
+
+func TestSubmissionReply(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	profile := &integrations.MailerProfile{Name: "Support", Provider: "smtp", DefaultFromEmail: "support@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+	f := &forms.Form{Name: "Contact", Slug: "contact", Token: "reply-token", AllowedOrigins: "*"}
+	require.NoError(t, db.Create(f).Error)
+
+	status, body := formPost(t, ts, "/forms/contact/submit?token=reply-token", "name=Ada&email=ada@example.com&message=Hi", nil)
+	require.Equal(t, 200, status, body)
+	var sub forms.Submission
+	require.NoError(t, db.Where("form_id = ?", f.ID).First(&sub).Error)
+	subPath := "/admin/submissions/" + strconv.Itoa(int(sub.ID))
+
+	status, body = adminGet(t, ts, subPath)
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Emailed to ada@example.com")
+	assert.Contains(t, body, `value="Re: Contact"`)
+
+	profileID := strconv.Itoa(int(profile.ID))
+	status, body = adminPost(t, ts, subPath+"/replies", "mailer_profile_id="+profileID+"&subject=Re:+Contact&body=")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Reply can&#39;t be empty")
+
+	status, body = adminPost(t, ts, subPath+"/replies", "mailer_profile_id="+profileID+"&subject=Re:+Contact&body=Thanks+Ada")
+	require.Equal(t, 302, status, body)
+
+	var replies []forms.SubmissionReply
+	require.NoError(t, db.Where("submission_id = ?", sub.ID).Find(&replies).Error)
+	require.Len(t, replies, 1)
+	assert.Equal(t, "ada@example.com", replies[0].To)
+	assert.Equal(t, forms.WebhookStatusPending, replies[0].Status)
+
+	status, body = adminGet(t, ts, subPath)
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Thanks Ada")
+	assert.Contains(t, body, "Queued")
+}
+
+func TestSavedViewsAndExport(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	f := &forms.Form{Name: "Contact", Slug: "contact", Token: "views-token", AllowedOrigins: "*"}
+	require.NoError(t, db.Create(f).Error)
+
+	for _, body := range []string{"name=Ada&country=DE", "name=Bob&country=FR"} {
+		status, resp := formPost(t, ts, "/forms/contact/submit?token=views-token", body, nil)
+		require.Equal(t, 200, status, resp)
+	}
+
+	status, body := adminGet(t, ts, "/admin/submissions?field_path=country&field_value=de")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "1 total")
+	assert.Contains(t, body, `name="f.country" value="de"`)
+
+	status, body = adminPost(t, ts, "/admin/views", "name=Germany&pinned=on&query="+url.QueryEscape("f.country=de"))
+	require.Equal(t, 302, status, body)
+
+	status, body = adminGet(t, ts, "/admin/submissions")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, `href="/admin/submissions?f.country=de"`)
+	assert.Contains(t, body, ">Germany</a>")
+
+	// Pinned views are in the layout's nav on every admin page.
+	status, body = adminGet(t, ts, "/admin/forms")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, `href="/admin/submissions?f.country=de"`)
+	assert.Contains(t, body, "Manage views")
+
+	var view forms.SavedView
+	require.NoError(t, db.First(&view).Error)
+
+	status, body = adminGet(t, ts, "/admin/views/"+strconv.Itoa(int(view.ID))+"/export")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "id,created_at,form,status,spam,tags,country,name")
+	assert.Contains(t, body, "Contact,new,false,,DE,Ada")
+	assert.NotContains(t, body, "Bob")
+
+	status, body = adminGet(t, ts, "/admin/submissions/export?sort=date")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Ada")
+	assert.Contains(t, body, "Bob")
+}
+
+func TestFormAnalyticsPage(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	f := &forms.Form{Name: "Contact", Slug: "contact", Token: "analytics-token", AllowedOrigins: "*",
+		GeneratedHTML: `<form><select name="topic"><option value="sales">Sales</option></select></form>`}
+	require.NoError(t, db.Create(f).Error)
+
+	status, resp := formPost(t, ts, "/forms/contact/submit?token=analytics-token", "topic=sales", map[string]string{"Origin": "https://www.example.com"})
+	require.Equal(t, 200, status, resp)
+
+	var sub forms.Submission
+	require.NoError(t, db.First(&sub).Error)
+	assert.Equal(t, "www.example.com", sub.Origin)
+
+	status, body := adminGet(t, ts, "/admin/forms/"+strconv.Itoa(int(f.ID))+"/analytics?range=7d&interval=hour")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "Submissions per hour")
+	assert.Contains(t, body, "www.example.com")
+	assert.Contains(t, body, "Sales")
+
+	status, _ = adminGet(t, ts, "/admin/forms/9999/analytics")
+	assert.Equal(t, 404, status)
+}
+
+func TestFormViewBeacon(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	f := &forms.Form{Name: "Contact", Slug: "contact", Token: "beacon-token", AllowedOrigins: "example.com", PublicID: "beacon-form", HostedEnabled: true}
+	require.NoError(t, db.Create(f).Error)
+
+	site := map[string]string{"Origin": "https://example.com"}
+	status, body := formPost(t, ts, "/forms/contact/view?token=beacon-token", "", site)
+	assert.Equal(t, 204, status, body)
+	status, _ = formPost(t, ts, "/forms/contact/view?token=wrong", "", site)
+	assert.Equal(t, 401, status)
+	status, _ = formPost(t, ts, "/forms/contact/view?token=beacon-token", "", map[string]string{"Origin": "https://evil.test"})
+	assert.Equal(t, 403, status)
+	status, _ = formPost(t, ts, "/forms/contact/view?token=beacon-token", "", map[string]string{"Origin": "https://example.com", "DNT": "1"})
+	assert.Equal(t, 204, status)
+
+	var views []forms.FormView
+	require.NoError(t, db.Find(&views).Error)
+	require.Len(t, views, 1, "rejected and Do-Not-Track views aren't counted")
+	assert.Equal(t, "example.com", views[0].Origin)
+	assert.Equal(t, 1, views[0].Views)
+
+	req := httptest.NewRequest("GET", "/f/beacon-form", nil)
+	resp, err := ts.App.Test(req, -1)
+	require.NoError(t, err)
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(page), `fetch("/forms/contact/view?token=beacon-token"`)
+
+	status, body = adminGet(t, ts, "/admin/forms")
+	require.Equal(t, 200, status, body)
+	assert.Contains(t, body, "1 views · 0.0% conversion")
+}
+
+func TestAdminLiveFeed(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	f := &forms.Form{Name: "Contact", Slug: "contact", Token: "live-token", AllowedOrigins: "*"}
+	require.NoError(t, db.Create(f).Error)
+
+	// Signed-out clients are sent to the login page.
+	resp, err := ts.App.Test(httptest.NewRequest("GET", "/admin/live", nil), -1)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEqual(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+	login := httptest.NewRequest("POST", "/admin/login", strings.NewReader("email=admin@formlander.local&password=formlander"))
+	login.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	login.Header.Set("Sec-Fetch-Site", "same-origin")
+	resp, err = ts.App.Test(login, -1)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The admin was last active an hour ago, with the page left open.
+	idleSince := time.Now().Add(-time.Hour).UTC()
+	require.NoError(t, db.Model(&accounts.Session{}).Where("1 = 1").Update("last_seen_at", idleSince).Error)
+	lastSeen := func() time.Time {
+		t.Helper()
+		var session accounts.Session
+		require.NoError(t, db.First(&session).Error)
+		return session.LastSeenAt
+	}
+
+	// Streams need a real connection; App.Test waits for the whole body.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go ts.App.Listener(ln)
+	// The stream only notices the client left on its next write.
+	t.Cleanup(func() { _ = ts.App.ShutdownWithTimeout(100 * time.Millisecond) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+ln.Addr().String()+"/admin/live", nil)
+	require.NoError(t, err)
+	for _, c := range resp.Cookies() {
+		req.AddCookie(c)
+	}
+	stream, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	require.Equal(t, 200, stream.StatusCode)
+	assert.Equal(t, "text/event-stream", stream.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(stream.Body)
+	readUntil := func(prefix string) string {
+		t.Helper()
+		for lines.Scan() {
+			if strings.HasPrefix(lines.Text(), prefix) {
+				return lines.Text()
+			}
+		}
+		t.Fatalf("stream ended before %q: %v", prefix, lines.Err())
+		return ""
+	}
+	readUntil(": connected")
+
+	status, body := formPost(t, ts, "/forms/contact/submit?token=live-token", "name=Ada", nil)
+	require.Less(t, status, 400, body)
+
+	assert.Equal(t, "event: submission.created", readUntil("event:"))
+	var event struct {
+		SubmissionID uint `json:"submission_id"`
+		FormID       uint `json:"form_id"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(readUntil("data: "), "data: ")), &event))
+	assert.Equal(t, f.ID, event.FormID)
+	assert.NotZero(t, event.SubmissionID)
+
+	t.Run("live updates don't keep an idle session alive", func(t *testing.T) {
+		assert.WithinDuration(t, idleSince, lastSeen(), time.Second, "the open stream")
+		for _, path := range []string{"/admin/live/dashboard", "/admin/live/submissions"} {
+			req := httptest.NewRequest("GET", path, nil)
+			for _, c := range resp.Cookies() {
+				req.AddCookie(c)
+			}
+			refresh, err := ts.App.Test(req, -1)
+			require.NoError(t, err)
+			refresh.Body.Close()
+			require.Equal(t, 200, refresh.StatusCode, path)
+			assert.WithinDuration(t, idleSince, lastSeen(), time.Second, path)
+		}
+
+		req := httptest.NewRequest("GET", "/admin", nil)
+		for _, c := range resp.Cookies() {
+			req.AddCookie(c)
+		}
+		page, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		page.Body.Close()
+		assert.WithinDuration(t, time.Now(), lastSeen(), time.Minute, "opening a page is activity")
+	})
+}
+
+func TestUserInvitationsAndAccess(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	profile := &integrations.MailerProfile{Name: "Team", Provider: "smtp", DefaultFromEmail: "team@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+
+	status, body := adminPost(t, ts, "/admin/settings/users/invitations", fmt.Sprintf("email=new@example.com&role=viewer&mailer_profile_id=%d", profile.ID))
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "Invitation to new@example.com queued")
+
+	var invitation accounts.Invitation
+	require.NoError(t, db.Where("email = ?", "new@example.com").First(&invitation).Error)
+	path := "/invite/" + accounts.InvitationToken("test-secret", &invitation)
+	var invited audit.Entry
+	require.NoError(t, db.Where("action = ?", audit.ActionInvitationCreated).Last(&invited).Error)
+	assert.Equal(t, invitation.ID, invited.TargetID)
+	assert.Equal(t, "admin@formlander.local", invited.ActorEmail)
+
+	resp, err := ts.App.Test(httptest.NewRequest("GET", path, nil), -1)
+	require.NoError(t, err)
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(page), "new@example.com")
+
+	accept := func(password, confirm string) *http.Response {
+		req := httptest.NewRequest("POST", path, strings.NewReader("password="+password+"&confirm_password="+confirm))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+	resp = accept("password123", "password124")
+	page, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(page), "Passwords do not match")
+
+	resp = accept("password123", "password123")
+	resp.Body.Close()
+	require.Equal(t, 302, resp.StatusCode)
+	assert.Equal(t, "/admin", resp.Header.Get("Location"))
+	newUserCookies := resp.Cookies()
+
+	resp = accept("password123", "password123")
+	resp.Body.Close()
+	assert.Equal(t, 404, resp.StatusCode, "invite links work once")
+
+	asNewUser := func(path string) *http.Response {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range newUserCookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	assert.Equal(t, 200, asNewUser("/admin").StatusCode)
+
+	newUser, err := accounts.FindByEmail(db, "new@example.com")
+	require.NoError(t, err)
+	status, body = adminGet(t, ts, "/admin/settings/users")
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "new@example.com")
+	assert.Contains(t, body, fmt.Sprintf("/admin/settings/users/%d", newUser.ID))
+
+	status, _ = adminPost(t, ts, fmt.Sprintf("/admin/settings/users/%d/deactivate", newUser.ID), "")
+	assert.Equal(t, 302, status)
+	resp = asNewUser("/admin")
+	assert.Equal(t, 302, resp.StatusCode, "sessions of deactivated users are rejected")
+	assert.Equal(t, "/admin/login", resp.Header.Get("Location"))
+
+	admin, err := accounts.FindByEmail(db, "admin@formlander.local")
+	require.NoError(t, err)
+	status, body = adminPost(t, ts, fmt.Sprintf("/admin/settings/users/%d/deactivate", admin.ID), "")
+	assert.Equal(t, 200, status)
+	assert.Contains(t, body, "Manage your own account from Settings")
+
+	status, _ = adminPost(t, ts, fmt.Sprintf("/admin/settings/users/%d/password", newUser.ID), "new_password=rotated-pass&confirm_password=rotated-pass")
+	assert.Equal(t, 200, status)
+	status, _ = adminPost(t, ts, fmt.Sprintf("/admin/settings/users/%d/delete", newUser.ID), "")
+	assert.Equal(t, 302, status)
+	_, err = accounts.FindByID(db, newUser.ID)
+	assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+
+	granted := &forms.Form{Name: "Granted Form", Slug: "granted", Token: "granted-token"}
+	hidden := &forms.Form{Name: "Hidden Form", Slug: "hidden", Token: "hidden-token"}
+	require.NoError(t, db.Create(granted).Error)
+	require.NoError(t, db.Create(hidden).Error)
+	grantedSub := &forms.Submission{FormID: granted.ID, DataJSON: `{"email":"visible@example.com"}`}
+	hiddenSub := &forms.Submission{FormID: hidden.ID, DataJSON: `{"email":"secret@example.com"}`}
+	require.NoError(t, db.Create(grantedSub).Error)
+	require.NoError(t, db.Create(hiddenSub).Error)
+
+	for _, role := range []string{accounts.RoleViewer, accounts.RoleEditor} {
+		seedAdmin(t, ts, role+"@example.com", "password123")
+		user, err := accounts.FindByEmail(db, role+"@example.com")
+		require.NoError(t, err)
+		status, _ := adminPost(t, ts, fmt.Sprintf("/admin/settings/users/%d/role", user.ID), "role="+role)
+		require.Equal(t, 200, status)
+		status, _ = adminPost(t, ts, fmt.Sprintf("/admin/settings/users/%d/forms", user.ID), fmt.Sprintf("form_id=%d", granted.ID))
+		require.Equal(t, 200, status)
+	}
+	viewer, err := accounts.FindByEmail(db, "viewer@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, accounts.RoleViewer, viewer.Role)
+
+	as := func(role, method, path, body string) (int, string) {
+		t.Helper()
+		return userRequest(t, ts, role+"@example.com", "password123", method, path, body)
+	}
+
+	t.Run("viewers only see granted forms", func(t *testing.T) {
+		status, body := as(accounts.RoleViewer, "GET", "/admin/submissions", "")
+		require.Equal(t, 200, status)
+		assert.Contains(t, body, "visible@example.com")
+		assert.NotContains(t, body, "secret@example.com")
+		assert.NotContains(t, body, "Hidden Form")
+
+		status, body = as(accounts.RoleViewer, "GET", "/admin", "")
+		require.Equal(t, 200, status)
+		assert.NotContains(t, body, "Hidden Form")
+
+		status, body = as(accounts.RoleViewer, "GET", "/admin/submissions/export?status=all", "")
+		require.Equal(t, 200, status)
+		assert.Contains(t, body, "visible@example.com")
+		assert.NotContains(t, body, "secret@example.com")
+
+		status, _ = as(accounts.RoleViewer, "GET", fmt.Sprintf("/admin/submissions/%d", grantedSub.ID), "")
+		assert.Equal(t, 200, status)
+		status, _ = as(accounts.RoleViewer, "GET", fmt.Sprintf("/admin/submissions/%d", hiddenSub.ID), "")
+		assert.Equal(t, 404, status)
+		status, _ = as(accounts.RoleViewer, "GET", fmt.Sprintf("/admin/forms/%d", hidden.ID), "")
+		assert.Equal(t, 404, status)
+	})
+
+	t.Run("viewers can't change anything", func(t *testing.T) {
+		status, _ := as(accounts.RoleViewer, "POST", fmt.Sprintf("/admin/submissions/%d/notes", grantedSub.ID), "body=hello")
+		assert.Equal(t, 403, status)
+		status, _ = as(accounts.RoleViewer, "GET", fmt.Sprintf("/admin/forms/%d/edit", granted.ID), "")
+		assert.Equal(t, 403, status)
+		status, _ = as(accounts.RoleViewer, "GET", "/admin/settings/mailers", "")
+		assert.Equal(t, 403, status)
+		status, _ = as(accounts.RoleViewer, "GET", "/admin/settings/users", "")
+		assert.Equal(t, 403, status)
+	})
+
+	t.Run("editors work granted submissions only", func(t *testing.T) {
+		status, _ := as(accounts.RoleEditor, "POST", fmt.Sprintf("/admin/submissions/%d/notes", grantedSub.ID), "body=hello")
+		assert.Equal(t, 302, status)
+		status, _ = as(accounts.RoleEditor, "POST", fmt.Sprintf("/admin/submissions/%d/notes", hiddenSub.ID), "body=hello")
+		assert.Equal(t, 404, status)
+		status, _ = as(accounts.RoleEditor, "GET", "/admin/forms/new", "")
+		assert.Equal(t, 403, status)
+	})
+
+	t.Run("admins can't manage owners", func(t *testing.T) {
+		seedAdmin(t, ts, "second-admin@example.com", "password123")
+		second, err := accounts.FindByEmail(db, "second-admin@example.com")
+		require.NoError(t, err)
+		require.NoError(t, db.Model(second).Update("role", accounts.RoleAdmin).Error)
+		owner, err := accounts.FindByEmail(db, "admin@formlander.local")
+		require.NoError(t, err)
+
+		status, body := userRequest(t, ts, "second-admin@example.com", "password123", "POST", fmt.Sprintf("/admin/settings/users/%d/deactivate", owner.ID), "")
+		assert.Equal(t, 200, status)
+		assert.Contains(t, body, "Only an owner can manage owners")
+		status, _ = userRequest(t, ts, "second-admin@example.com", "password123", "GET", "/admin/submissions", "")
+		assert.Equal(t, 200, status)
+	})
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+
+	status, body := adminPost(t, ts, "/admin/settings/2fa/setup", "")
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "<svg")
+	admin, err := accounts.FindByEmail(db, "admin@formlander.local")
+	require.NoError(t, err)
+	require.NotEmpty(t, admin.TOTPSecret)
+	assert.Contains(t, body, admin.TOTPSecret)
+	assert.False(t, admin.TOTPEnabled(), "setup alone doesn't change sign-in")
+
+	status, body = adminPost(t, ts, "/admin/settings/2fa/enable", "code=000000")
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "That code is not valid")
+
+	now := time.Now()
+	code, err := accounts.TOTPCode(admin.TOTPSecret, now)
+	require.NoError(t, err)
+	status, body = adminPost(t, ts, "/admin/settings/2fa/enable", "code="+code)
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "Save these recovery codes")
+
+	post := func(path, body string, cookies []*http.Cookie) *http.Response {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+	get := func(path string, cookies []*http.Cookie) *http.Response {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := post("/admin/login", "email=admin@formlander.local&password=formlander", nil)
+	resp.Body.Close()
+	require.Equal(t, 302, resp.StatusCode)
+	assert.Equal(t, "/admin/login/verify", resp.Header.Get("Location"))
+	challenge := resp.Cookies()
+	for _, c := range challenge {
+		assert.NotEqual(t, "formlander_session", c.Name, "the password alone must not start a session")
+	}
+
+	t.Run("the challenge is not a session", func(t *testing.T) {
+		resp := get("/admin", challenge)
+		assert.Equal(t, 302, resp.StatusCode)
+		assert.Equal(t, "/admin/login", resp.Header.Get("Location"))
+		assert.Equal(t, 200, get("/admin/login/verify", challenge).StatusCode)
+	})
+
+	t.Run("the verify step needs a challenge", func(t *testing.T) {
+		resp := get("/admin/login/verify", nil)
+		assert.Equal(t, 302, resp.StatusCode)
+
+		resp = post("/admin/login/verify", "code="+code, nil)
+		page, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Contains(t, string(page), "Your sign-in expired")
+	})
+
+	t.Run("wrong and replayed codes are rejected", func(t *testing.T) {
+		for _, attempt := range []string{"000000", code} {
+			resp := post("/admin/login/verify", "code="+attempt, challenge)
+			page, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Contains(t, string(page), "That code is not valid")
+		}
+	})
+
+	t.Run("a fresh code starts the session", func(t *testing.T) {
+		next, err := accounts.TOTPCode(admin.TOTPSecret, now.Add(30*time.Second))
+		require.NoError(t, err)
+		resp := post("/admin/login/verify", "code="+next, challenge)
+		resp.Body.Close()
+		require.Equal(t, 302, resp.StatusCode)
+		assert.Equal(t, "/admin", resp.Header.Get("Location"))
+		assert.Equal(t, 200, get("/admin", resp.Cookies()).StatusCode)
+	})
+
+	t.Run("recovery codes sign in once", func(t *testing.T) {
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		codes, err := accounts.RegenerateRecoveryCodes(logger, db, admin.ID, "formlander")
+		require.NoError(t, err)
+
+		resp := post("/admin/login/verify", "code="+codes[0], challenge)
+		resp.Body.Close()
+		assert.Equal(t, 302, resp.StatusCode)
+		resp = post("/admin/login/verify", "code="+codes[0], challenge)
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+}
+
+// passkeyCBOR encodes the few CBOR shapes a test authenticator sends:
+// integers, byte and text strings, and maps given as key/value pairs.
+func passkeyCBOR(v any) []byte {
+	head := func(major byte, n int) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 256:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, -1-v)
+		}
+		return head(0, v)
+	case []byte:
+		return append(head(2, len(v)), v...)
+	case string:
+		return append(head(3, len(v)), v...)
+	case [][2]any:
+		out := head(5, len(v))
+		for _, pair := range v {
+			out = append(out, passkeyCBOR(pair[0])...)
+			out = append(out, passkeyCBOR(pair[1])...)
+		}
+		return out
+	}
+	panic("unsupported CBOR value")
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+
+	send := func(path, contentType, body string, cookies ...*http.Cookie) (*http.Response, []byte) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, data
+	}
+	cookie := func(resp *http.Response, name string) *http.Cookie {
+		for _, c := range resp.Cookies() {
+			if c.Name == name && c.Value != "" {
+				return c
+			}
+		}
+		t.Fatalf("response has no %s cookie", name)
+		return nil
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	resp, _ := send("/admin/login", "application/x-www-form-urlencoded", "email=admin@formlander.local&password=formlander")
+	require.Equal(t, 302, resp.StatusCode)
+	session, sid := cookie(resp, "formlander_session"), cookie(resp, "formlander_sid")
+
+	// A P-256 authenticator, as most platform passkeys are.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	point, err := key.PublicKey.Bytes()
+	require.NoError(t, err)
+	credentialID := []byte("test-credential")
+	var signCount uint32
+
+	resp, body := send("/admin/settings/passkeys/options", "application/json", "", session, sid)
+	require.Equal(t, 200, resp.StatusCode)
+	var creation struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RP        struct {
+				ID string `json:"id"`
+			} `json:"rp"`
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	require.NoError(t, json.Unmarshal(body, &creation))
+	rpID := creation.PublicKey.RP.ID
+	origin := "http://" + rpID
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	clientData := func(ceremony, challenge string) []byte {
+		data, _ := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": origin})
+		return data
+	}
+	authData := func() []byte {
+		data := append(append([]byte(nil), rpIDHash[:]...), 0x05) // user present and verified
+		return binary.BigEndian.AppendUint32(data, signCount)
+	}
+
+	attested := append(authData(), make([]byte, 16)...)
+	attested[32] |= 0x40
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(credentialID)))
+	attested = append(attested, credentialID...)
+	attested = append(attested, passkeyCBOR([][2]any{{1, 2}, {3, -7}, {-1, 1}, {-2, point[1:33]}, {-3, point[33:]}})...)
+	attestation := passkeyCBOR([][2]any{{"fmt", "none"}, {"attStmt", [][2]any{}}, {"authData", attested}})
+
+	registration, _ := json.Marshal(map[string]any{
+		"name": "Laptop",
+		"credential": map[string]any{
+			"rawId": b64(credentialID),
+			"type":  "public-key",
+			"response": map[string]string{
+				"clientDataJSON":    b64(clientData("webauthn.create", creation.PublicKey.Challenge)),
+				"attestationObject": b64(attestation),
+			},
+		},
+	})
+
+	t.Run("registration needs the challenge cookie", func(t *testing.T) {
+		resp, _ := send("/admin/settings/passkeys", "application/json", string(registration), session, sid)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	resp, body = send("/admin/settings/passkeys", "application/json", string(registration), session, sid, cookie(resp, "formlander_passkey"))
+	require.Equal(t, 200, resp.StatusCode, string(body))
+	status, page := adminGet(t, ts, "/admin/settings")
+	require.Equal(t, 200, status)
+	assert.Contains(t, page, `value="Laptop"`)
+
+	assertion := func() (string, *http.Cookie) {
+		resp, body := send("/admin/login/passkey/options", "application/json", "")
+		require.Equal(t, 200, resp.StatusCode)
+		var request struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+			} `json:"publicKey"`
+		}
+		require.NoError(t, json.Unmarshal(body, &request))
+
+		signCount++
+		data := authData()
+		cdata := clientData("webauthn.get", request.PublicKey.Challenge)
+		clientHash := sha256.Sum256(cdata)
+		digest := sha256.Sum256(append(append([]byte(nil), data...), clientHash[:]...))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+
+		payload, _ := json.Marshal(map[string]any{
+			"rawId": b64(credentialID),
+			"type":  "public-key",
+			"response": map[string]string{
+				"clientDataJSON":    b64(cdata),
+				"authenticatorData": b64(data),
+				"signature":         b64(sig),
+				"userHandle":        creation.PublicKey.User.ID,
+			},
+		})
+		return string(payload), cookie(resp, "formlander_passkey")
+	}
+
+	payload, challenge := assertion()
+	t.Run("a passkey signs in without password or code", func(t *testing.T) {
+		resp, body := send("/admin/login/passkey", "application/json", payload, challenge)
+		require.Equal(t, 200, resp.StatusCode, string(body))
+		assert.JSONEq(t, `{"ok":true,"redirect":"/admin"}`, string(body))
+
+		req := httptest.NewRequest("GET", "/admin", nil)
+		req.AddCookie(cookie(resp, "formlander_session"))
+		req.AddCookie(cookie(resp, "formlander_sid"))
+		page, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		page.Body.Close()
+		assert.Equal(t, 200, page.StatusCode)
+	})
+
+	t.Run("a replayed assertion is refused", func(t *testing.T) {
+		// The challenge was used up by the sign-in above, before the
+		// signature counter is even looked at.
+		resp, body := send("/admin/login/passkey", "application/json", payload, challenge)
+		assert.Equal(t, 400, resp.StatusCode)
+		assert.Contains(t, string(body), "Passkey sign-in expired")
+	})
+
+	t.Run("a challenge is used up by a failed sign-in too", func(t *testing.T) {
+		_, challenge := assertion()
+		resp, _ := send("/admin/login/passkey", "application/json", `{"rawId":"AAAA"}`, challenge)
+		require.Equal(t, 401, resp.StatusCode)
+		payload, _ := assertion()
+		resp, _ = send("/admin/login/passkey", "application/json", payload, challenge)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("revoked passkeys no longer sign in", func(t *testing.T) {
+		passkey, err := accounts.FindPasskey(ts.DB.GetConnection(), credentialID)
+		require.NoError(t, err)
+		status, _ := adminPost(t, ts, fmt.Sprintf("/admin/settings/passkeys/%d/delete", passkey.ID), "")
+		require.Equal(t, 200, status)
+
+		payload, challenge := assertion()
+		resp, _ := send("/admin/login/passkey", "application/json", payload, challenge)
+		assert.Equal(t, 401, resp.StatusCode)
+	})
+}
+
+func TestSSOLogin(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	idp := oidctest.NewProvider(t, "formlander", "client-secret")
+	ts := mountTestServer(t, func(cfg *config.Config) {
+		cfg.OIDC = config.OIDCConfig{
+			Issuer:         idp.Issuer(),
+			ClientID:       "formlander",
+			ClientSecret:   "client-secret",
+			AllowedDomains: "example.com",
+			DefaultRole:    accounts.RoleEditor,
+			ButtonLabel:    "Sign in with Acme",
+		}
+	})
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+
+	get := func(path string, cookies ...*http.Cookie) (*http.Response, string) {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+	// start begins a sign-in and returns the provider's redirect back to us
+	// with the request cookie.
+	start := func() (*url.URL, *http.Cookie) {
+		resp, _ := get("/admin/login/sso")
+		require.Equal(t, 302, resp.StatusCode)
+		require.True(t, strings.HasPrefix(resp.Header.Get("Location"), idp.Issuer()+"/authorize?"))
+		for _, c := range resp.Cookies() {
+			if c.Name == "formlander_sso" {
+				return idp.Authorize(t, resp.Header.Get("Location")), c
+			}
+		}
+		t.Fatal("no sign-in request cookie")
+		return nil, nil
+	}
+
+	_, page := get("/admin/login")
+	assert.Contains(t, page, "Sign in with Acme")
+
+	t.Run("a new user in an allowed domain is provisioned and signed in", func(t *testing.T) {
+		idp.SignIn(oidctest.Identity{Subject: "sub-grace", Email: "grace@example.com", EmailVerified: true})
+		callback, cookie := start()
+		assert.Equal(t, "/admin/login/sso/callback", callback.Path)
+
+		resp, _ := get(callback.RequestURI(), cookie)
+		require.Equal(t, 302, resp.StatusCode)
+		assert.Equal(t, "/admin", resp.Header.Get("Location"))
+		var session, sid *http.Cookie
+		for _, c := range resp.Cookies() {
+			switch c.Name {
+			case "formlander_session":
+				session = c
+			case "formlander_sid":
+				sid = c
+			}
+		}
+		require.NotNil(t, session)
+		require.NotNil(t, sid)
+		dashboard, _ := get("/admin", session, sid)
+		assert.Equal(t, 200, dashboard.StatusCode)
+
+		user, err := accounts.FindByEmail(ts.DB.GetConnection(), "grace@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, accounts.RoleEditor, user.Role)
+	})
+
+	t.Run("the callback needs the browser that started it", func(t *testing.T) {
+		callback, cookie := start()
+		_, page := get(callback.RequestURI())
+		assert.Contains(t, page, "Your sign-in expired")
+
+		query := callback.Query()
+		query.Set("state", "forged")
+		callback.RawQuery = query.Encode()
+		_, page = get(callback.RequestURI(), cookie)
+		assert.Contains(t, page, "Your sign-in expired")
+	})
+
+	t.Run("other domains are turned away", func(t *testing.T) {
+		idp.SignIn(oidctest.Identity{Subject: "sub-mallory", Email: "mallory@example.org", EmailVerified: true})
+		callback, cookie := start()
+		_, page := get(callback.RequestURI(), cookie)
+		assert.Contains(t, page, "can&#39;t sign in here")
+		_, err := accounts.FindByEmail(ts.DB.GetConnection(), "mallory@example.org")
+		assert.ErrorIs(t, err, accounts.ErrUserNotFound)
+	})
+
+	t.Run("SSO is off without configuration", func(t *testing.T) {
+		plain := mountTestServer(t)
+		req := httptest.NewRequest("GET", "/admin/login/sso", nil)
+		resp, err := plain.App.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+}
+
+func TestAllFormsDigestSettings(t *testing.T) {
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+
+	profile := &integrations.MailerProfile{Name: "Relay", Provider: "smtp", DefaultFromEmail: "forms@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+
+	status, body := adminPost(t, ts, "/admin/settings/mailers/digest", "digest_mode=daily&recipient=team@example.com")
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "Mailer profile and recipient required")
+
+	status, _ = adminPost(t, ts, "/admin/settings/mailers/digest",
+		fmt.Sprintf("digest_mode=weekly&mailer_profile_id=%d&recipient=team@example.com&digest_hour=8&digest_weekday=5&digest_timezone=Europe/Madrid", profile.ID))
+	require.Equal(t, 302, status)
+
+	digest, err := forms.GetAllFormsDigest(db)
+	require.NoError(t, err)
+	assert.True(t, digest.Enabled())
+	assert.Equal(t, "team@example.com", digest.Recipient)
+	assert.Equal(t, 8, digest.DigestHour)
+	assert.Equal(t, 5, digest.DigestWeekday)
+	require.NotNil(t, digest.LastDigestAt, "turning the digest on starts its first window")
+
+	_, body = adminGet(t, ts, "/admin/settings/mailers")
+	assert.Contains(t, body, `value="team@example.com"`)
+	assert.Contains(t, body, `<option value="weekly" selected>Weekly digest</option>`)
+
+	var entry audit.Entry
+	require.NoError(t, db.Where("action = ?", audit.ActionAllFormsDigestChanged).Last(&entry).Error)
+	assert.Contains(t, entry.Details, "weekly")
+}
+
+func TestPasswordResetByEmail(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t, func(cfg *config.Config) {
+		cfg.BaseURL = "https://forms.example.com"
+	})
+	db := ts.DB.GetConnection()
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+	sameOrigin := map[string]string{"Sec-Fetch-Site": "same-origin"}
+
+	get := func(path string) (int, string) {
+		resp, err := ts.App.Test(httptest.NewRequest("GET", path, nil), -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	_, body := get("/admin/login")
+	assert.NotContains(t, body, "Forgot password?", "no reset link without a system mailer")
+	_, body = get("/admin/password/forgot")
+	assert.Contains(t, body, "change-admin-password")
+
+	profile := &integrations.MailerProfile{Name: "System", Provider: "smtp", DefaultFromEmail: "noreply@example.com"}
+	require.NoError(t, db.Create(profile).Error)
+	status, _ := adminPost(t, ts, "/admin/settings/mailers/system", fmt.Sprintf("mailer_profile_id=%d", profile.ID))
+	require.Equal(t, 302, status)
+	_, body = adminGet(t, ts, "/admin/settings/mailers")
+	assert.Contains(t, body, fmt.Sprintf(`<option value="%d" selected>System</option>`, profile.ID))
+
+	_, body = get("/admin/login")
+	assert.Contains(t, body, "Forgot password?")
+
+	status, known := formPost(t, ts, "/admin/password/forgot", "email=Admin@Formlander.local", sameOrigin)
+	require.Equal(t, 200, status)
+	_, unknown := formPost(t, ts, "/admin/password/forgot", "email=nobody@example.com", sameOrigin)
+	assert.Contains(t, known, "If an account exists for that email")
+	assert.Equal(t, known, unknown, "the response doesn't tell whether the account exists")
+
+	// The link is emailed by the job runner, not during the request.
+	var requested accounts.PasswordReset
+	require.NoError(t, db.First(&requested).Error)
+	assert.Len(t, requested.TokenHash, 64)
+	assert.Nil(t, requested.SentAt)
+
+	t.Run("requests per email are limited", func(t *testing.T) {
+		// The request above was the first of three allowed per hour.
+		for i := 0; i < 2; i++ {
+			status, _ := formPost(t, ts, "/admin/password/forgot", "email=admin@formlander.local", sameOrigin)
+			require.Equal(t, 200, status)
+		}
+		var latest accounts.PasswordReset
+		require.NoError(t, db.First(&latest).Error)
+
+		status, body := formPost(t, ts, "/admin/password/forgot", "email=admin@formlander.local", sameOrigin)
+		require.Equal(t, 200, status)
+		assert.Contains(t, body, "If an account exists for that email")
+		var after accounts.PasswordReset
+		require.NoError(t, db.First(&after).Error)
+		assert.Equal(t, latest.ID, after.ID, "limited requests don't issue a new link")
+	})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, token, err := accounts.RequestPasswordReset(logger, db, "test-secret", "admin@formlander.local", "", time.Now())
+	require.NoError(t, err)
+	path := "/admin/password/reset/" + token
+
+	status, body = get(path)
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "admin@formlander.local")
+
+	_, body = formPost(t, ts, path, "password=new-password&confirm_password=other-password", sameOrigin)
+	assert.Contains(t, body, "Passwords do not match")
+
+	status, body = formPost(t, ts, path, "password=new-password&confirm_password=new-password", sameOrigin)
+	require.Equal(t, 200, status)
+	assert.Contains(t, body, "Your password has been reset")
+
+	status, _ = formPost(t, ts, path, "password=new-password&confirm_password=new-password", sameOrigin)
+	assert.Equal(t, 404, status, "reset links work once")
+
+	status, _ = formPost(t, ts, "/admin/login", "email=admin@formlander.local&password=new-password", nil)
+	assert.Equal(t, 302, status)
+	_, body = formPost(t, ts, "/admin/login", "email=admin@formlander.local&password=formlander", nil)
+	assert.Contains(t, body, "Invalid credentials")
+}
+
+func TestSessionManagement(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+
+	signIn := func(userAgent string) []*http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/admin/login", strings.NewReader("email=admin@formlander.local&password=formlander"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, 302, resp.StatusCode)
+		return resp.Cookies()
+	}
+	send := func(method, path, body string, cookies []*http.Cookie) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Sec-Fetch-Site", "same-origin")
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(b)
+	}
+	signedIn := func(cookies []*http.Cookie) bool {
+		t.Helper()
+		resp, _ := send("GET", "/admin", "", cookies)
+		return resp.StatusCode == 200
+	}
+	sessionIDs := func() []uint {
+		var ids []uint
+		require.NoError(t, ts.DB.GetConnection().Model(&accounts.Session{}).Where("revoked_at IS NULL").Order("id").Pluck("id", &ids).Error)
+		return ids
+	}
+
+	laptop := signIn("Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Gecko/20100101 Firefox/128.0")
+	phone := signIn("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile/15E148 Safari/604.1")
+	tablet := signIn("Mozilla/5.0 (Linux; Android 14) Chrome/126.0 Safari/537.36")
+
+	_, body := send("GET", "/admin/settings", "", laptop)
+	assert.Contains(t, body, "Firefox on macOS")
+	assert.Contains(t, body, "Safari on iOS")
+	assert.Contains(t, body, "Chrome on Android")
+	assert.Contains(t, body, "This session")
+
+	t.Run("the signed cookie alone doesn't sign in", func(t *testing.T) {
+		var sessionOnly []*http.Cookie
+		for _, c := range laptop {
+			if c.Name == "formlander_session" {
+				sessionOnly = append(sessionOnly, c)
+			}
+		}
+		assert.False(t, signedIn(sessionOnly))
+	})
+
+	t.Run("another session is signed out", func(t *testing.T) {
+		ids := sessionIDs()
+		require.Len(t, ids, 3)
+		resp, body := send("POST", fmt.Sprintf("/admin/settings/sessions/%d/revoke", ids[1]), "", laptop)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, body, "Session signed out")
+		assert.False(t, signedIn(phone))
+		assert.True(t, signedIn(laptop))
+	})
+
+	t.Run("password changes end the other sessions", func(t *testing.T) {
+		resp, body := send("POST", "/admin/settings/password", "current_password=formlander&new_password=new-password&confirm_password=new-password", laptop)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, body, "Password updated successfully")
+		assert.False(t, signedIn(tablet))
+		assert.False(t, signedIn(laptop), "the old session ended too")
+		laptop = resp.Cookies()
+		assert.True(t, signedIn(laptop), "the browser that made the change stays signed in")
+	})
+
+	t.Run("all other sessions are signed out", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/login", strings.NewReader("email=admin@formlander.local&password=new-password"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := ts.App.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close()
+		other := resp.Cookies()
+		require.True(t, signedIn(other))
+
+		resp, body := send("POST", "/admin/settings/sessions/revoke-others", "", laptop)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, body, "Signed out of all other sessions")
+		assert.False(t, signedIn(other))
+		assert.True(t, signedIn(laptop))
+	})
+
+	t.Run("logging out ends the session", func(t *testing.T) {
+		resp, _ := send("POST", "/admin/logout", "", laptop)
+		require.Equal(t, 302, resp.StatusCode)
+		assert.False(t, signedIn(laptop), "the cookies no longer work, even if kept")
+		assert.Empty(t, sessionIDs())
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := mountTestServer(t)
+	db := ts.DB.GetConnection()
+	seedAdmin(t, ts, "admin@formlander.local", "formlander")
+
+	status, _ := formPost(t, ts, "/admin/login", "email=admin@formlander.local&password=wrong", nil)
+	require.Equal(t, 200, status)
+	status, _ = adminPost(t, ts, "/admin/settings/mailers", "name=Team&provider=smtp&smtp_host=smtp.example.com&smtp_password=hunter22")
+	require.Equal(t, 302, status)
+	form := &forms.Form{Name: "Contact", Slug: "contact", Token: "contact-token"}
+	require.NoError(t, db.Create(form).Error)
+	sub := &forms.Submission{FormID: form.ID, DataJSON: `{"email":"visitor@example.com"}`}
+	require.NoError(t, db.Create(sub).Error)
+	status, _ = adminGet(t, ts, fmt.Sprintf("/admin/submissions/%d", sub.ID))
+	require.Equal(t, 200, status)
+
+	t.Run("actions are recorded with who did them", func(t *testing.T) {
+		var entries []audit.Entry
+		require.NoError(t, db.Order("id").Find(&entries).Error)
+		actions := map[string]audit.Entry{}
+		for _, e := range entries {
+			actions[e.Action] = e
+		}
+
+		failed := actions[audit.ActionLoginFailed]
+		assert.Nil(t, failed.ActorID)
+		assert.Contains(t, failed.Details, "admin@formlander.local")
+		assert.Equal(t, "admin@formlander.local", actions[audit.ActionLogin].ActorEmail)
+		assert.Equal(t, "admin@formlander.local", actions[audit.ActionMailerCreated].ActorEmail)
+		assert.Equal(t, sub.ID, actions[audit.ActionSubmissionViewed].TargetID)
+	})
+
+	t.Run("live refreshes of an open submission are not views", func(t *testing.T) {
+		login := httptest.NewRequest("POST", "/admin/login", strings.NewReader("email=admin@formlander.local&password=formlander"))
+		login.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := ts.App.Test(login, -1)
+		require.NoError(t, err)
+		resp.Body.Close()
+		cookies := resp.Cookies()
+		get := func(path string, header map[string]string) string {
+			t.Helper()
+			req := httptest.NewRequest("GET", path, nil)
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			for _, c := range cookies {
+				req.AddCookie(c)
+			}
+			resp, err := ts.App.Test(req, -1)
+			require.NoError(t, err)
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.Equal(t, 200, resp.StatusCode, path)
+			return string(body)
+		}
+		views := func() int64 {
+			var n int64
+			db.Model(&audit.Entry{}).Where("action = ?", audit.ActionSubmissionViewed).Count(&n)
+			return n
+		}
+
+		before := views()
+		body := get(fmt.Sprintf("/admin/live/submissions/%d/deliveries", sub.ID), nil)
+		assert.Contains(t, body, `id="submission-deliveries"`)
+		assert.NotContains(t, body, "visitor@example.com", "refreshes carry only the delivery status")
+		assert.Equal(t, before, views())
+
+		// The page itself is always a view, whatever the client says.
+		body = get(fmt.Sprintf("/admin/submissions/%d", sub.ID), map[string]string{"X-Formlander-Live": "1"})
+		assert.Contains(t, body, fmt.Sprintf(`data-live-src="/admin/live/submissions/%d/deliveries"`, sub.ID))
+		assert.Equal(t, before+1, views())
+	})
+
+	t.Run("admins browse the log with secrets masked", func(t *testing.T) {
+		status, body := adminGet(t, ts, "/admin/settings/audit?action=mailer_profile.")
+		require.Equal(t, 200, status)
+		assert.Contains(t, body, "mailer_profile.created")
+		assert.Contains(t, body, "smtp_password: ********")
+		assert.NotContains(t, body, "hunter22")
+		assert.NotContains(t, body, "auth.login_failed</span>")
+	})
+
+	t.Run("the log exports as JSON", func(t *testing.T) {
+		status, body := adminGet(t, ts, "/admin/settings/audit/export?action=auth.login_failed")
+		require.Equal(t, 200, status)
+		var exported []map[string]any
+		require.NoError(t, json.Unmarshal([]byte(body), &exported))
+		require.Len(t, exported, 1)
+		assert.Equal(t, "auth.login_failed", exported[0]["action"])
+		assert.Equal(t, "invalid_credentials", exported[0]["details"].(map[string]any)["reason"])
+	})
+
+	t.Run("only admins see the log", func(t *testing.T) {
+		seedAdmin(t, ts, "viewer@example.com", "password123")
+		viewer, err := accounts.FindByEmail(db, "viewer@example.com")
+		require.NoError(t, err)
+		status, _ := adminPost(t, ts, fmt.Sprintf("/admin/settings/users/%d/role", viewer.ID), "role="+accounts.RoleViewer)
+		require.Equal(t, 200, status)
+
+		status, _ = userRequest(t, ts, "viewer@example.com", "password123", "GET", "/admin/settings/audit", "")
+		assert.Equal(t, 403, status)
+		status, _ = userRequest(t, ts, "viewer@example.com", "password123", "GET", "/admin/settings/audit/export", "")
+		assert.Equal(t, 403, status)
+	})
+}
@@ -9,12 +9,37 @@
  * Forms are auto-detected by:
  *   - action URL matching /forms/{slug}/submit pattern
  *   - data-formlander attribute on the form element
+ *
+ * Every submission carries an Idempotency-Key, so retries never create
+ * duplicates. Submissions that can't be sent (offline, server unreachable)
+ * are saved in IndexedDB (localStorage without file uploads) and sent on the
+ * next page load or when the browser comes back online.
+ *
+ * Each form shown on a page is counted once as a view, for conversion rates.
+ * The view beacon sends no cookies or visitor data and is skipped when the
+ * browser sends Do-Not-Track or Global Privacy Control.
+ *
+ * Embed mode renders a form from its definition, no HTML required:
+ *   <script src="https://your-formlander.com/assets/formlander.js" data-form="PUBLIC_ID" async></script>
+ *
+ * The form is inserted after the script tag (or into data-target="#selector")
+ * inside a shadow root, so page styles don't leak in. Optional attributes:
+ *   - data-accent="#0f766e"   override the form's accent color
+ *   - data-theme="dark"       dark color scheme
  */
 (function () {
   'use strict';
 
+  var currentScript = document.currentScript;
+  if (window.Formlander && window.Formlander.embed) {
+    // Loaded again by another embed snippet: only render that one.
+    if (currentScript) window.Formlander.embed(currentScript);
+    return;
+  }
+
   var MAX_RETRIES = 3;
   var RETRY_DELAYS = [1000, 2000, 4000];
+  var TURNSTILE_SRC = 'https://challenges.cloudflare.com/turnstile/v0/api.js?render=explicit';
 
   function isFormlanderForm(form) {
     if (form.hasAttribute('data-formlander')) return true;
@@ -28,11 +53,12 @@
     });
   }
 
-  function submitWithRetry(url, formData, attempt) {
+  function submitWithRetry(url, formData, key, attempt) {
     attempt = attempt || 0;
 
     return fetch(url, {
       method: 'POST',
+      headers: { Accept: 'application/json', 'Idempotency-Key': key },
       body: formData
     })
       .then(function (response) {
@@ -40,21 +66,229 @@
           var retryAfter = response.headers.get('Retry-After');
           var delay = retryAfter ? parseInt(retryAfter, 10) * 1000 : RETRY_DELAYS[attempt];
           return sleep(delay).then(function () {
-            return submitWithRetry(url, formData, attempt + 1);
+            return submitWithRetry(url, formData, key, attempt + 1);
           });
         }
         return response;
       })
       .catch(function (err) {
-        if (attempt < MAX_RETRIES) {
+        if (attempt < MAX_RETRIES && navigator.onLine !== false) {
           return sleep(RETRY_DELAYS[attempt]).then(function () {
-            return submitWithRetry(url, formData, attempt + 1);
+            return submitWithRetry(url, formData, key, attempt + 1);
           });
         }
         throw err;
       });
   }
 
+  // ---------------------------------------------------------------------
+  // Idempotency keys and the offline queue
+  // ---------------------------------------------------------------------
+
+  var QUEUE_DB = 'formlander';
+  var QUEUE_STORE = 'outbox';
+  var QUEUE_STORAGE_KEY = 'formlander:outbox';
+  var QUEUE_MAX_AGE = 7 * 24 * 60 * 60 * 1000; // the server deduplicates keys for the same window
+  var QUEUED_MESSAGE = "You're offline. Your submission was saved and will be sent automatically.";
+
+  function newIdempotencyKey() {
+    var cryptoObj = window.crypto || window.msCrypto;
+    if (cryptoObj && cryptoObj.randomUUID) return cryptoObj.randomUUID();
+    var bytes = new Uint8Array(16);
+    if (cryptoObj && cryptoObj.getRandomValues) {
+      cryptoObj.getRandomValues(bytes);
+    } else {
+      for (var i = 0; i < bytes.length; i++) bytes[i] = Math.floor(Math.random() * 256);
+    }
+    var hex = '';
+    for (var j = 0; j < bytes.length; j++) hex += (bytes[j] + 0x100).toString(16).slice(1);
+    return hex;
+  }
+
+  function formDataEntries(formData) {
+    var entries = [];
+    formData.forEach(function (value, name) {
+      entries.push([name, value]);
+    });
+    return entries;
+  }
+
+  function entriesToFormData(entries) {
+    var formData = new FormData();
+    for (var i = 0; i < entries.length; i++) {
+      var value = entries[i][1];
+      if (value && typeof value === 'object' && 'name' in value) {
+        formData.append(entries[i][0], value, value.name);
+      } else {
+        formData.append(entries[i][0], value);
+      }
+    }
+    return formData;
+  }
+
+  var queueDB = null;
+
+  function openQueueDB() {
+    if (queueDB) return queueDB;
+    queueDB = new Promise(function (resolve) {
+      if (!window.indexedDB) return resolve(null);
+      try {
+        var request = window.indexedDB.open(QUEUE_DB, 1);
+        request.onupgradeneeded = function () {
+          request.result.createObjectStore(QUEUE_STORE, { keyPath: 'key' });
+        };
+        request.onsuccess = function () { resolve(request.result); };
+        request.onerror = function () { resolve(null); };
+      } catch (err) {
+        resolve(null);
+      }
+    });
+    return queueDB;
+  }
+
+  function idbRequest(db, mode, action) {
+    return new Promise(function (resolve, reject) {
+      var tx = db.transaction(QUEUE_STORE, mode);
+      var request = action(tx.objectStore(QUEUE_STORE));
+      tx.oncomplete = function () { resolve(request.result); };
+      tx.onerror = function () { reject(tx.error); };
+      tx.onabort = function () { reject(tx.error); };
+    });
+  }
+
+  function readStorageQueue() {
+    try {
+      return JSON.parse(window.localStorage.getItem(QUEUE_STORAGE_KEY) || '[]');
+    } catch (err) {
+      return [];
+    }
+  }
+
+  function writeStorageQueue(entries) {
+    try {
+      window.localStorage.setItem(QUEUE_STORAGE_KEY, JSON.stringify(entries));
+      return true;
+    } catch (err) {
+      return false;
+    }
+  }
+
+  function storageQueueAdd(entry) {
+    // localStorage only holds strings: posts with real file uploads can't be
+    // kept, and empty file inputs are dropped.
+    var fields = [];
+    for (var i = 0; i < entry.fields.length; i++) {
+      var value = entry.fields[i][1];
+      if (typeof value !== 'string') {
+        if (value && value.size > 0) return false;
+        continue;
+      }
+      fields.push(entry.fields[i]);
+    }
+    var entries = readStorageQueue();
+    entries.push({ key: entry.key, url: entry.url, fields: fields, createdAt: entry.createdAt });
+    return writeStorageQueue(entries);
+  }
+
+  // queueSubmission saves an unsent post. Resolves to false when nothing
+  // could store it.
+  function queueSubmission(url, formData, key) {
+    var entry = { key: key, url: url, fields: formDataEntries(formData), createdAt: Date.now() };
+    return openQueueDB().then(function (db) {
+      if (!db) return storageQueueAdd(entry);
+      return idbRequest(db, 'readwrite', function (store) {
+        return store.put(entry);
+      }).then(function () {
+        return true;
+      }, function () {
+        return storageQueueAdd(entry);
+      });
+    });
+  }
+
+  function queuedSubmissions() {
+    return openQueueDB().then(function (db) {
+      var stored = readStorageQueue();
+      if (!db) return stored;
+      return idbRequest(db, 'readonly', function (store) {
+        return store.getAll();
+      }).then(function (entries) {
+        return (entries || []).concat(stored);
+      }, function () {
+        return stored;
+      });
+    });
+  }
+
+  function dequeueSubmission(key) {
+    writeStorageQueue(readStorageQueue().filter(function (entry) {
+      return entry.key !== key;
+    }));
+    return openQueueDB().then(function (db) {
+      if (!db) return null;
+      return idbRequest(db, 'readwrite', function (store) {
+        return store['delete'](key);
+      })['catch'](function () {});
+    });
+  }
+
+  var flushing = false;
+
+  // flushQueue resends saved submissions one at a time. Their idempotency
+  // key is reused, so a post that did reach the server before the tab went
+  // away isn't stored twice. Server errors keep the entry for a later try;
+  // any other answer removes it.
+  function flushQueue() {
+    if (flushing || navigator.onLine === false) return Promise.resolve();
+    flushing = true;
+
+    return queuedSubmissions()
+      .then(function (entries) {
+        return entries.reduce(function (chain, entry) {
+          return chain.then(function () {
+            if (Date.now() - entry.createdAt > QUEUE_MAX_AGE) {
+              return dequeueSubmission(entry.key);
+            }
+            return fetch(entry.url, {
+              method: 'POST',
+              headers: { Accept: 'application/json', 'Idempotency-Key': entry.key },
+              body: entriesToFormData(entry.fields)
+            }).then(function (response) {
+              if (response.status < 500 && response.status !== 408 && response.status !== 429) {
+                return dequeueSubmission(entry.key);
+              }
+            }, function () {
+              // Still unreachable; keep it for the next attempt.
+            });
+          });
+        }, Promise.resolve());
+      })
+      ['catch'](function () {})
+      .then(function () {
+        flushing = false;
+      });
+  }
+
+  // ---------------------------------------------------------------------
+  // View tracking
+  // ---------------------------------------------------------------------
+
+  var trackedViews = {};
+
+  function doNotTrack() {
+    return navigator.doNotTrack === '1' || window.doNotTrack === '1' || navigator.globalPrivacyControl === true;
+  }
+
+  // trackView counts one view of the form posting to submitURL, once per
+  // page load.
+  function trackView(submitURL) {
+    if (!submitURL || !window.fetch || doNotTrack()) return;
+    var viewURL = submitURL.replace(/(\/forms\/[^/?#]+)\/submit(?=[?#]|$)/, '$1/view');
+    if (viewURL === submitURL || trackedViews[viewURL]) return;
+    trackedViews[viewURL] = true;
+    fetch(viewURL, { method: 'POST', credentials: 'omit', keepalive: true })['catch'](function () {});
+  }
+
   function setFormDisabled(form, disabled) {
     var elements = form.elements;
     for (var i = 0; i < elements.length; i++) {
@@ -89,6 +323,7 @@
   }
 
   function hookForm(form) {
+    trackView(form.action);
     form.addEventListener('submit', function (e) {
       e.preventDefault();
 
@@ -102,8 +337,13 @@
 
       var successUrl = formData.get('_success_url');
       var errorUrl = formData.get('_error_url');
+      var key = newIdempotencyKey();
+
+      var sending = navigator.onLine === false
+        ? Promise.reject(new Error('offline'))
+        : submitWithRetry(form.action, formData, key);
 
-      submitWithRetry(form.action, formData)
+      sending
         .then(function (response) {
           return response.json().catch(function () {
             return {};
@@ -131,9 +371,17 @@
           }
         })
         .catch(function () {
-          // Final fallback: submit normally (let browser handle it)
-          setFormDisabled(form, false);
-          form.submit();
+          // Couldn't reach the server: keep the submission for later, or
+          // as a last resort submit normally (let browser handle it)
+          return queueSubmission(form.action, formData, key).then(function (saved) {
+            if (saved) {
+              form.reset();
+              showMessage(form, 'success', QUEUED_MESSAGE);
+              return;
+            }
+            setFormDisabled(form, false);
+            form.submit();
+          });
         })
         .finally(function () {
           setFormDisabled(form, false);
@@ -142,6 +390,426 @@
     });
   }
 
+  // ---------------------------------------------------------------------
+  // Embed mode
+  // ---------------------------------------------------------------------
+
+  var EMBED_CSS = [
+    ':host { display: block; --fl-text: #1f2937; --fl-muted: #6b7280; --fl-border: #d1d5db;',
+    '  --fl-surface: #ffffff; --fl-error: #b91c1c; --fl-success: #15803d;',
+    '  font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; color: var(--fl-text); }',
+    ':host(.fl-dark) { --fl-text: #f3f4f6; --fl-muted: #9ca3af; --fl-border: #4b5563; --fl-surface: #1f2937;',
+    '  --fl-error: #fca5a5; --fl-success: #86efac; }',
+    '.fl-page { background: var(--fl-surface); padding: 1.5rem; border-radius: 12px; border: 1px solid var(--fl-border); }',
+    '.fl-logo { max-height: 48px; margin-bottom: 1rem; }',
+    '.fl-title { margin: 0 0 0.5rem; font-size: 1.25rem; }',
+    '.fl-description { margin: 0 0 1.25rem; color: var(--fl-muted); white-space: pre-line; }',
+    '.fl-field { margin-bottom: 1rem; }',
+    '.fl-field > label, .fl-field legend { display: block; margin-bottom: 0.375rem; font-size: 0.875rem; font-weight: 600; }',
+    '.fl-field fieldset { border: 0; margin: 0; padding: 0; }',
+    '.fl-choice { display: flex; align-items: center; gap: 0.5rem; font-size: 0.9375rem; margin: 0.25rem 0; }',
+    '.fl-form input:not([type="checkbox"]):not([type="radio"]), .fl-form select, .fl-form textarea {',
+    '  box-sizing: border-box; width: 100%; padding: 0.625rem 0.75rem; font: inherit; color: inherit;',
+    '  background: var(--fl-surface); border: 1px solid var(--fl-border); border-radius: 8px; }',
+    '.fl-form input:focus, .fl-form select:focus, .fl-form textarea:focus {',
+    '  outline: 2px solid var(--fl-accent); outline-offset: 1px; border-color: var(--fl-accent); }',
+    '.fl-form [aria-invalid="true"] { border-color: var(--fl-error); }',
+    '.fl-error { margin: 0.25rem 0 0; font-size: 0.8125rem; color: var(--fl-error); }',
+    '.fl-progress { width: 100%; height: 6px; margin-top: 0.5rem; accent-color: var(--fl-accent); }',
+    '.fl-submit { padding: 0.625rem 1.25rem; font: inherit; font-weight: 600; color: #fff; background: var(--fl-accent);',
+    '  border: 0; border-radius: 8px; cursor: pointer; }',
+    '.fl-submit[disabled] { opacity: 0.6; cursor: default; }',
+    '.fl-message { margin-top: 1rem; font-size: 0.9375rem; }',
+    '.fl-message.fl-is-error { color: var(--fl-error); }',
+    '.fl-success .fl-title { color: var(--fl-success); }',
+    '.fl-hp { position: absolute; left: -10000px; width: 1px; height: 1px; overflow: hidden; }'
+  ].join('\n');
+
+  function el(tag, attrs, text) {
+    var node = document.createElement(tag);
+    if (attrs) {
+      for (var key in attrs) {
+        if (Object.prototype.hasOwnProperty.call(attrs, key) && attrs[key] !== undefined && attrs[key] !== null && attrs[key] !== false) {
+          node.setAttribute(key, attrs[key] === true ? '' : String(attrs[key]));
+        }
+      }
+    }
+    if (text) node.textContent = text;
+    return node;
+  }
+
+  function fieldId(def, field, suffix) {
+    return 'fl-' + def.public_id + '-' + field.name.replace(/[^a-zA-Z0-9_-]/g, '_') + (suffix === undefined ? '' : '-' + suffix);
+  }
+
+  function controlAttrs(field, id) {
+    return {
+      id: id,
+      name: field.name,
+      placeholder: field.placeholder,
+      required: field.required,
+      multiple: field.multiple,
+      minlength: field.minlength,
+      maxlength: field.maxlength,
+      min: field.min,
+      max: field.max,
+      step: field.step,
+      pattern: field.pattern,
+      accept: field.accept
+    };
+  }
+
+  function renderChoices(def, field, wrapper) {
+    var fieldset = el('fieldset');
+    fieldset.appendChild(el('legend', null, field.label));
+    // A checkbox group is valid with any box ticked, so "required" is checked
+    // by hand; radios and single checkboxes can rely on the browser.
+    var nativeRequired = field.type === 'radio' || field.options.length === 1;
+    for (var i = 0; i < field.options.length; i++) {
+      var option = field.options[i];
+      var id = fieldId(def, field, i);
+      var label = el('label', { 'class': 'fl-choice', 'for': id });
+      label.appendChild(el('input', {
+        id: id,
+        type: field.type,
+        name: field.name,
+        value: option.value,
+        checked: option.selected,
+        required: field.required && nativeRequired
+      }));
+      label.appendChild(document.createTextNode(option.label));
+      fieldset.appendChild(label);
+    }
+    wrapper.appendChild(fieldset);
+  }
+
+  function renderField(def, field) {
+    if (field.type === 'hidden') {
+      return el('input', { type: 'hidden', name: field.name, value: field.value });
+    }
+
+    var wrapper = el('div', { 'class': 'fl-field', 'data-field': field.name, 'data-type': field.type });
+    if ((field.type === 'radio' || field.type === 'checkbox') && field.options && field.options.length) {
+      if (field.required) wrapper.setAttribute('data-required', '');
+      renderChoices(def, field, wrapper);
+    } else {
+      var id = fieldId(def, field);
+      wrapper.appendChild(el('label', { 'for': id }, field.label));
+      var control;
+      if (field.type === 'textarea') {
+        var attrs = controlAttrs(field, id);
+        attrs.rows = field.rows || 4;
+        control = el('textarea', attrs);
+        if (field.value) control.value = field.value;
+      } else if (field.type === 'select') {
+        control = el('select', controlAttrs(field, id));
+        var options = field.options || [];
+        for (var i = 0; i < options.length; i++) {
+          control.appendChild(el('option', { value: options[i].value, selected: options[i].selected }, options[i].label));
+        }
+      } else {
+        var inputAttrs = controlAttrs(field, id);
+        inputAttrs.type = field.type;
+        if (field.type !== 'file') inputAttrs.value = field.value;
+        control = el('input', inputAttrs);
+      }
+      wrapper.appendChild(control);
+      if (field.type === 'file') {
+        var progress = el('progress', { 'class': 'fl-progress', max: 100, value: 0 });
+        progress.hidden = true;
+        wrapper.appendChild(progress);
+      }
+    }
+
+    var error = el('p', { 'class': 'fl-error', role: 'alert' });
+    error.hidden = true;
+    wrapper.appendChild(error);
+    return wrapper;
+  }
+
+  function setFieldError(wrapper, message) {
+    var error = wrapper.querySelector('.fl-error');
+    var controls = wrapper.querySelectorAll('input, select, textarea');
+    for (var i = 0; i < controls.length; i++) {
+      if (message) {
+        controls[i].setAttribute('aria-invalid', 'true');
+      } else {
+        controls[i].removeAttribute('aria-invalid');
+      }
+    }
+    if (error) {
+      error.textContent = message || '';
+      error.hidden = !message;
+    }
+  }
+
+  // validateEmbedForm shows the browser's validation message under each
+  // invalid field and returns the first invalid control, if any.
+  function validateEmbedForm(form) {
+    var firstInvalid = null;
+    var wrappers = form.querySelectorAll('.fl-field');
+    for (var i = 0; i < wrappers.length; i++) {
+      var wrapper = wrappers[i];
+      var message = '';
+      var controls = wrapper.querySelectorAll('input, select, textarea');
+      for (var j = 0; j < controls.length && !message; j++) {
+        if (!controls[j].checkValidity()) message = controls[j].validationMessage;
+      }
+      if (!message && wrapper.hasAttribute('data-required') && !wrapper.querySelector('input:checked')) {
+        message = 'Please select at least one option.';
+      }
+      setFieldError(wrapper, message);
+      if (message && !firstInvalid) firstInvalid = controls[0];
+    }
+    return firstInvalid;
+  }
+
+  var turnstileQueue = null;
+
+  function withTurnstile(callback) {
+    if (window.turnstile) {
+      callback(window.turnstile);
+      return;
+    }
+    if (turnstileQueue) {
+      turnstileQueue.push(callback);
+      return;
+    }
+    turnstileQueue = [callback];
+    var script = el('script', { src: TURNSTILE_SRC, async: true });
+    script.onload = function () {
+      var queue = turnstileQueue;
+      turnstileQueue = null;
+      for (var i = 0; i < queue.length; i++) queue[i](window.turnstile);
+    };
+    document.head.appendChild(script);
+  }
+
+  // mountCaptcha renders Turnstile in the light DOM (slotted into the shadow
+  // form), since the widget can't run inside a shadow root. The token is
+  // copied into a hidden input of the shadow form.
+  function mountCaptcha(host, form, captcha) {
+    var tokenInput = el('input', { type: 'hidden', name: 'cf-turnstile-response' });
+    var slot = el('div', { 'class': 'fl-field' });
+    slot.appendChild(el('slot', { name: 'captcha' }));
+    form.appendChild(tokenInput);
+    form.appendChild(slot);
+
+    var container = el('div', { slot: 'captcha' });
+    host.appendChild(container);
+
+    var widget = { id: null, api: null };
+    withTurnstile(function (api) {
+      widget.api = api;
+      widget.id = api.render(container, {
+        sitekey: captcha.site_key,
+        action: captcha.action || undefined,
+        theme: captcha.theme || undefined,
+        language: captcha.language || undefined,
+        size: captcha.size || undefined,
+        callback: function (token) { tokenInput.value = token; },
+        'expired-callback': function () { tokenInput.value = ''; }
+      });
+    });
+    return {
+      reset: function () {
+        tokenInput.value = '';
+        if (widget.api && widget.id !== null) widget.api.reset(widget.id);
+      }
+    };
+  }
+
+  // sendEmbedForm posts with XHR so file uploads can report progress.
+  // Busy (503) responses and network errors are retried like hooked forms.
+  function sendEmbedForm(url, formData, key, onProgress, attempt) {
+    attempt = attempt || 0;
+    return new Promise(function (resolve, reject) {
+      var xhr = new XMLHttpRequest();
+      xhr.open('POST', url);
+      xhr.setRequestHeader('Accept', 'application/json');
+      xhr.setRequestHeader('Idempotency-Key', key);
+      if (xhr.upload && onProgress) {
+        xhr.upload.onprogress = function (e) {
+          if (e.lengthComputable) onProgress(Math.round((e.loaded / e.total) * 100));
+        };
+      }
+      xhr.onload = function () {
+        var data = {};
+        try { data = JSON.parse(xhr.responseText); } catch (err) { data = {}; }
+        resolve({ status: xhr.status, retryAfter: xhr.getResponseHeader('Retry-After'), data: data });
+      };
+      xhr.onerror = function () { reject(new Error('network error')); };
+      xhr.send(formData);
+    }).then(function (result) {
+      if (result.status === 503 && attempt < MAX_RETRIES) {
+        var delay = result.retryAfter ? parseInt(result.retryAfter, 10) * 1000 : RETRY_DELAYS[attempt];
+        return sleep(delay).then(function () {
+          return sendEmbedForm(url, formData, key, onProgress, attempt + 1);
+        });
+      }
+      return result;
+    }, function (err) {
+      if (attempt < MAX_RETRIES && navigator.onLine !== false) {
+        return sleep(RETRY_DELAYS[attempt]).then(function () {
+          return sendEmbedForm(url, formData, key, onProgress, attempt + 1);
+        });
+      }
+      throw err;
+    });
+  }
+
+  function hasSelectedFiles(form) {
+    var inputs = form.querySelectorAll('input[type="file"]');
+    for (var i = 0; i < inputs.length; i++) {
+      if (inputs[i].files && inputs[i].files.length) return true;
+    }
+    return false;
+  }
+
+  function renderEmbed(host, root, def, base) {
+    var style = el('style');
+    style.textContent = EMBED_CSS;
+    root.appendChild(style);
+    if (def.custom_css) {
+      var custom = el('style');
+      custom.textContent = def.custom_css;
+      root.appendChild(custom);
+    }
+    host.style.setProperty('--fl-accent', host.getAttribute('data-accent') || def.accent_color || '#2563eb');
+
+    var page = el('div', { 'class': 'fl-page', part: 'page' });
+    if (def.logo_url) page.appendChild(el('img', { 'class': 'fl-logo', src: def.logo_url, alt: '' }));
+    if (def.title) page.appendChild(el('h2', { 'class': 'fl-title', part: 'title' }, def.title));
+    if (def.description) page.appendChild(el('p', { 'class': 'fl-description' }, def.description));
+
+    var form = el('form', { 'class': 'fl-form', part: 'form', novalidate: true });
+    var fields = def.fields || [];
+    for (var i = 0; i < fields.length; i++) {
+      form.appendChild(renderField(def, fields[i]));
+    }
+
+    var honeypot = el('div', { 'class': 'fl-hp', 'aria-hidden': 'true' });
+    honeypot.appendChild(el('input', { type: 'text', name: def.honeypot_field, tabindex: '-1', autocomplete: 'off' }));
+    form.appendChild(honeypot);
+
+    var captcha = def.captcha ? mountCaptcha(host, form, def.captcha) : null;
+
+    var submit = el('button', { type: 'submit', 'class': 'fl-submit', part: 'submit' }, def.submit_label || 'Send');
+    form.appendChild(submit);
+    var message = el('p', { 'class': 'fl-message', role: 'status' });
+    message.hidden = true;
+    form.appendChild(message);
+    page.appendChild(form);
+    root.appendChild(page);
+
+    form.addEventListener('input', function (e) {
+      var wrapper = e.target.closest ? e.target.closest('.fl-field') : null;
+      if (wrapper) setFieldError(wrapper, '');
+    });
+
+    form.addEventListener('submit', function (e) {
+      e.preventDefault();
+      message.hidden = true;
+
+      var invalid = validateEmbedForm(form);
+      if (invalid) {
+        invalid.focus();
+        return;
+      }
+
+      var formData = new FormData(form);
+      var progress = hasSelectedFiles(form) ? form.querySelectorAll('.fl-progress') : [];
+      var onProgress = progress.length ? function (pct) {
+        for (var p = 0; p < progress.length; p++) {
+          progress[p].hidden = false;
+          progress[p].value = pct;
+        }
+      } : null;
+
+      submit.disabled = true;
+      submit.textContent = 'Sending...';
+
+      var submitURL = new URL(def.submit_url, base).href;
+      var key = newIdempotencyKey();
+
+      sendEmbedForm(submitURL, formData, key, onProgress)
+        .then(function (result) {
+          if (result.status >= 200 && result.status < 300 && result.data.ok) {
+            var done = el('div', { 'class': 'fl-success', role: 'status' });
+            done.appendChild(el('h3', { 'class': 'fl-title' }, def.success_title));
+            done.appendChild(el('p', { 'class': 'fl-description' }, def.success_message));
+            page.replaceChild(done, form);
+            host.dispatchEvent(new CustomEvent('formlander:success', {
+              bubbles: true,
+              composed: true,
+              detail: { form: def.public_id, submissionId: result.data.submission_id }
+            }));
+            return;
+          }
+          throw new Error(result.data.error || def.error_message);
+        })
+        .catch(function (err) {
+          for (var p = 0; p < progress.length; p++) progress[p].hidden = true;
+          if (err && err.message === 'network error') {
+            return queueSubmission(submitURL, formData, key).then(function (saved) {
+              if (saved) {
+                form.reset();
+                message.textContent = QUEUED_MESSAGE;
+                message.className = 'fl-message';
+              } else {
+                message.textContent = def.error_message;
+                message.className = 'fl-message fl-is-error';
+              }
+              message.hidden = false;
+            });
+          }
+          message.textContent = (err && err.message) || def.error_message;
+          message.className = 'fl-message fl-is-error';
+          message.hidden = false;
+          if (captcha) captcha.reset();
+        })
+        .finally(function () {
+          submit.disabled = false;
+          submit.textContent = def.submit_label || 'Send';
+        });
+    });
+  }
+
+  // embedFromScript renders the form named by a script tag's data-form
+  // attribute, fetching its definition from the script's origin.
+  function embedFromScript(script) {
+    if (!script || !script.getAttribute('data-form') || script.hasAttribute('data-formlander-embedded')) return;
+    script.setAttribute('data-formlander-embedded', '');
+
+    var publicId = script.getAttribute('data-form');
+    var base = new URL(script.src || window.location.href, window.location.href);
+
+    var host = el('div', { 'class': 'formlander-embed', 'data-accent': script.getAttribute('data-accent') });
+    if (script.getAttribute('data-theme') === 'dark') host.classList.add('fl-dark');
+    var target = script.getAttribute('data-target') ? document.querySelector(script.getAttribute('data-target')) : null;
+    if (target) {
+      target.appendChild(host);
+    } else {
+      script.parentNode.insertBefore(host, script.nextSibling);
+    }
+    var root = host.attachShadow ? host.attachShadow({ mode: 'open' }) : host;
+
+    fetch(new URL('/embed/' + encodeURIComponent(publicId), base).href, {
+      headers: { Accept: 'application/json' }
+    })
+      .then(function (response) {
+        return response.json().then(function (data) {
+          if (!response.ok || !data.ok) throw new Error(data.error || 'form unavailable');
+          renderEmbed(host, root, data.form, base);
+          trackView(new URL(data.form.submit_url, base).href);
+        });
+      })
+      .catch(function (err) {
+        root.textContent = 'This form could not be loaded.';
+        if (window.console) console.warn('Formlander embed:', err.message);
+      });
+  }
+
   function init() {
     var forms = document.querySelectorAll('form');
     for (var i = 0; i < forms.length; i++) {
@@ -149,8 +817,18 @@
         hookForm(forms[i]);
       }
     }
+
+    var scripts = document.querySelectorAll('script[data-form]');
+    for (var j = 0; j < scripts.length; j++) {
+      embedFromScript(scripts[j]);
+    }
+
+    flushQueue();
+    window.addEventListener('online', flushQueue);
   }
 
+  window.Formlander = { embed: embedFromScript, flush: flushQueue };
+
   if (document.readyState === 'loading') {
     document.addEventListener('DOMContentLoaded', init);
   } else {
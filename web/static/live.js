@@ -0,0 +1,95 @@
+/**
+ * Live admin pages.
+ *
+ * Opens one Server-Sent Events stream to /admin/live and refreshes the parts
+ * of the page that opt in with data-live, listing the event types they care
+ * about. data-live-submission limits an element to one submission's events.
+ *
+ *   <div id="deliveries" data-live="delivery.updated" data-live-submission="42">
+ *
+ * On a matching event the element's data-live-src, or else the current page,
+ * is fetched again and the element is replaced by the element with the same
+ * id in the response. Refresh sources are live routes, which don't count as
+ * the user's activity or as views of what they show. Bursts of events are coalesced into one fetch per
+ * source. Elements need an id.
+ */
+(function () {
+  'use strict';
+
+  if (window.FormlanderLive || !window.EventSource || !window.fetch || !window.DOMParser) return;
+
+  var EVENT_TYPES = ['submission.created', 'delivery.updated'];
+  var REFRESH_DELAY = 400;
+
+  var pending = {};
+  var timer = null;
+
+  function listens(el, type, data) {
+    var types = (el.getAttribute('data-live') || '').split(/\s+/);
+    if (types.indexOf(type) === -1) return false;
+    var submission = el.getAttribute('data-live-submission');
+    return !submission || submission === String(data.submission_id);
+  }
+
+  function refresh() {
+    timer = null;
+    var ids = Object.keys(pending);
+    pending = {};
+
+    var sources = {};
+    ids.forEach(function (id) {
+      var el = document.getElementById(id);
+      if (!el) return;
+      var src = el.getAttribute('data-live-src') || window.location.href;
+      (sources[src] = sources[src] || []).push(id);
+    });
+    Object.keys(sources).forEach(function (src) {
+      load(src, sources[src]);
+    });
+  }
+
+  function load(src, ids) {
+    var page = window.location.href;
+    fetch(src, { credentials: 'same-origin', headers: { Accept: 'text/html' } })
+      .then(function (response) {
+        if (!response.ok || response.redirected) throw new Error('refresh failed');
+        return response.text();
+      })
+      .then(function (html) {
+        // The user navigated away while the page was loading.
+        if (window.location.href !== page) return;
+        var doc = new DOMParser().parseFromString(html, 'text/html');
+        ids.forEach(function (id) {
+          var current = document.getElementById(id);
+          var fresh = doc.getElementById(id);
+          if (!current || !fresh || current.contains(document.activeElement)) return;
+          current.replaceWith(fresh);
+          if (window.htmx) window.htmx.process(fresh);
+        });
+      })
+      ['catch'](function () {});
+  }
+
+  function handle(type, event) {
+    var data;
+    try {
+      data = JSON.parse(event.data);
+    } catch (err) {
+      return;
+    }
+    var elements = document.querySelectorAll('[data-live][id]');
+    for (var i = 0; i < elements.length; i++) {
+      if (listens(elements[i], type, data)) pending[elements[i].id] = true;
+    }
+    if (!timer && Object.keys(pending).length) timer = setTimeout(refresh, REFRESH_DELAY);
+  }
+
+  var source = new EventSource('/admin/live');
+  EVENT_TYPES.forEach(function (type) {
+    source.addEventListener(type, function (event) {
+      handle(type, event);
+    });
+  });
+
+  window.FormlanderLive = { source: source };
+})();
@@ -0,0 +1,125 @@
+/**
+ * Passkey ceremonies.
+ *
+ * Forms marked data-passkey-register add a passkey to the signed-in user;
+ * the form's name input labels it. Buttons marked data-passkey-login sign in
+ * with one. Both fetch options from the server, hand them to the browser's
+ * WebAuthn API and post the result back, with buffers as base64url strings.
+ * Errors go into the element named by data-passkey-error.
+ *
+ *   <form data-passkey-register data-passkey-error="passkey-error">
+ *   <button type="button" data-passkey-login data-passkey-error="login-error">
+ */
+(function () {
+  'use strict';
+
+  if (!window.PublicKeyCredential || !window.fetch) return;
+
+  function toBase64URL(buffer) {
+    var bytes = new Uint8Array(buffer);
+    var binary = '';
+    for (var i = 0; i < bytes.length; i++) binary += String.fromCharCode(bytes[i]);
+    return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+  }
+
+  function fromBase64URL(value) {
+    var base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    while (base64.length % 4) base64 += '=';
+    var binary = atob(base64);
+    var bytes = new Uint8Array(binary.length);
+    for (var i = 0; i < binary.length; i++) bytes[i] = binary.charCodeAt(i);
+    return bytes.buffer;
+  }
+
+  function postJSON(url, body) {
+    return fetch(url, {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: { 'Content-Type': 'application/json', Accept: 'application/json' },
+      body: body === undefined ? '' : JSON.stringify(body)
+    }).then(function (res) {
+      return res.json().catch(function () { return {}; }).then(function (data) {
+        if (!res.ok || data.ok === false) throw new Error(data.error || 'Something went wrong. Please try again.');
+        return data;
+      });
+    });
+  }
+
+  function showError(el, err) {
+    var target = document.getElementById(el.getAttribute('data-passkey-error'));
+    if (!target) return;
+    // The browser rejects with NotAllowedError when the user cancels.
+    target.textContent = err && err.name === 'NotAllowedError'
+      ? 'Passkey request was cancelled or timed out.'
+      : (err && err.message) || 'Passkey request failed.';
+    target.hidden = false;
+  }
+
+  function register(form) {
+    var name = form.querySelector('input[name="name"]');
+    return postJSON('/admin/settings/passkeys/options').then(function (data) {
+      var options = data.publicKey;
+      options.challenge = fromBase64URL(options.challenge);
+      options.user.id = fromBase64URL(options.user.id);
+      options.excludeCredentials = options.excludeCredentials.map(function (c) {
+        return { type: c.type, id: fromBase64URL(c.id) };
+      });
+      return navigator.credentials.create({ publicKey: options });
+    }).then(function (credential) {
+      return postJSON('/admin/settings/passkeys', {
+        name: name ? name.value : '',
+        credential: {
+          rawId: toBase64URL(credential.rawId),
+          type: credential.type,
+          response: {
+            clientDataJSON: toBase64URL(credential.response.clientDataJSON),
+            attestationObject: toBase64URL(credential.response.attestationObject)
+          }
+        }
+      });
+    }).then(function () {
+      window.location.reload();
+    });
+  }
+
+  function login() {
+    return postJSON('/admin/login/passkey/options').then(function (data) {
+      var options = data.publicKey;
+      options.challenge = fromBase64URL(options.challenge);
+      return navigator.credentials.get({ publicKey: options });
+    }).then(function (credential) {
+      var response = credential.response;
+      return postJSON('/admin/login/passkey', {
+        rawId: toBase64URL(credential.rawId),
+        type: credential.type,
+        response: {
+          clientDataJSON: toBase64URL(response.clientDataJSON),
+          authenticatorData: toBase64URL(response.authenticatorData),
+          signature: toBase64URL(response.signature),
+          userHandle: response.userHandle ? toBase64URL(response.userHandle) : ''
+        }
+      });
+    }).then(function (data) {
+      window.location.href = data.redirect || '/admin';
+    });
+  }
+
+  document.querySelectorAll('[data-passkey-register]').forEach(function (form) {
+    form.hidden = false;
+    form.addEventListener('submit', function (event) {
+      event.preventDefault();
+      register(form).catch(function (err) { showError(form, err); });
+    });
+  });
+
+  document.querySelectorAll('[data-passkey-login]').forEach(function (button) {
+    button.hidden = false;
+    button.addEventListener('click', function () {
+      button.disabled = true;
+      login().catch(function (err) {
+        button.disabled = false;
+        showError(button, err);
+      });
+    });
+  });
+})();